@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable in headers, logs and secret scanners
+const APIKeyPrefix = "ga_"
+
+// APIKeyHeader carries an API key for callers that cannot send it as a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// apiKeyTouchInterval is how stale an API key's last-used time may get before it is written again,
+// so a busy key does not cost a write per request
+const apiKeyTouchInterval = time.Minute
+
+var (
+	// ErrInvalidAPIKey is returned for an API key that does not exist, is revoked or has expired
+	ErrInvalidAPIKey = errors.New("invalid, revoked or expired API key")
+	// ErrAPIKeyAddress is returned when an API key is used from outside its allowed IP ranges
+	ErrAPIKeyAddress = errors.New("API key is not allowed from this address")
+)
+
+// apiKeyEncoding writes secrets without the underscore that separates a key's prefix from it
+var apiKeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ParseAllowedIP checks an allowed IP entry, which is an address or a CIDR range
+func ParseAllowedIP(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// GenerateAPIKey returns a new key, such as ga_k3v9q2xm_..., with the prefix it is looked up by and
+// the hash that is stored in place of it.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 5+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix = APIKeyPrefix + strings.ToLower(apiKeyEncoding.EncodeToString(b[:5]))
+	key = prefix + "_" + strings.ToLower(apiKeyEncoding.EncodeToString(b[5:]))
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hex SHA-256 of a key, which is what is stored
+func HashAPIKey(key string) string {
+	return hashToken(key)
+}
+
+type apiKeyKey struct{}
+
+// WithAPIKey returns a copy of ctx carrying the API key that authenticated the request
+func WithAPIKey(ctx context.Context, key *models.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// APIKeyFromContext returns the API key that authenticated the request, if any
+func APIKeyFromContext(ctx context.Context) (*models.APIKey, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(*models.APIKey)
+	return key, ok
+}
+
+// requestAPIKey reads an API key from the X-API-Key header or an "Authorization: Bearer" header
+func requestAPIKey(r *http.Request) (string, bool) {
+	if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); key != "" {
+		return key, true
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || !strings.HasPrefix(token, APIKeyPrefix) {
+		return "", false
+	}
+	return token, true
+}
+
+// AuthenticateAPIKey returns the key if it is active and allowed from addr, the address of the
+// client, and records that it was used
+func (a *Authenticator) AuthenticateAPIKey(ctx context.Context, token, addr string) (*models.APIKey, error) {
+	if a.APIKeys == nil || !strings.HasPrefix(token, APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	rest, _, ok := strings.Cut(strings.TrimPrefix(token, APIKeyPrefix), "_")
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := a.APIKeys.GetAPIKeyByPrefix(ctx, APIKeyPrefix+rest)
+	if errors.Is(err, models.ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(token)), []byte(key.Hash)) != 1 || !key.Active(time.Now()) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !allowedFrom(key.AllowedIPs, addr) {
+		return nil, ErrAPIKeyAddress
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyTouchInterval || key.LastUsedIP != addr {
+		// Failing to record the use is no reason to turn the caller away
+		if err := a.APIKeys.TouchAPIKey(ctx, key.ID, addr); err != nil {
+			logging.FromContext(ctx).Warn("recording API key use", "api_key_id", key.ID.String(), "error", err)
+		}
+	}
+	return key, nil
+}
+
+// allowedFrom reports whether addr falls in one of the allowed ranges; no ranges allows any address
+func allowedFrom(allowed []string, addr string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, entry := range allowed {
+		if prefix, err := ParseAllowedIP(entry); err == nil && prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAddr is the address of the client, as the ClientAddr hook reports it or else the peer
+func (a *Authenticator) clientAddr(r *http.Request) string {
+	if a.ClientAddr != nil {
+		return a.ClientAddr(r)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// authorizeAPIKey checks that the key grants the scope the matched route needs
+func authorizeAPIKey(key *models.APIKey, r *http.Request) error {
+	scope := RequiredScope(r.Method, routeTemplate(r))
+	if scope == "" {
+		return errors.New("this route cannot be called with an API key")
+	}
+	if !slices.Contains(key.Scopes, scope) {
+		return fmt.Errorf("API key lacks the %s scope", scope)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+// Package auth logs admins in with their email and password, or admins and employees through the
+// OpenID Connect provider, and authenticates requests with the token of the session the login
+// returned, sent as "Authorization: Bearer <token>" or, from browsers, in the session cookie.
+// Employee sessions only reach the self-service routes under /me. Admins who enrolled an
+// authenticator app must also give a TOTP or recovery code before the session is issued.
+// Machine-to-machine callers can instead present a client certificate that is mapped to a service
+// identity, or an API key, each limited to scopes. Failed logins are counted per account and lock
+// it out for exponentially longer after too many in a row.
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email or a wrong password, without saying which
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrPasswordLoginDisabled is returned by Login when admins have to log in through the provider
+	ErrPasswordLoginDisabled = errors.New("password login is disabled; log in with single sign-on")
+)
+
+// LockedError is returned while an account is locked out after failed logins
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed logins; try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Authenticator checks credentials and sessions
+type Authenticator struct {
+	Admins   models.AdminRepository
+	Sessions models.SessionRepository
+	Lockouts ratelimit.LockoutStore
+	Lockout  ratelimit.LockoutPolicy
+	// SessionTimeouts bounds how long sessions last, in total and without activity
+	SessionTimeouts SessionTimeouts
+	// TwoFactor holds admins' authenticators and logins waiting for them; nil disables two-factor
+	// authentication
+	TwoFactor models.TwoFactorRepository
+	// TOTPIssuer names this service in authenticator apps
+	TOTPIssuer string
+	// SecureCookies restricts the session cookies to HTTPS
+	SecureCookies bool
+	// CertificateIdentities maps client certificate names to service identities for mutual TLS
+	CertificateIdentities map[string]CertificateIdentity
+	// APIKeys holds the keys scripts and services authenticate with; nil disables API keys
+	APIKeys models.APIKeyRepository
+	// ClientAddr returns the address of the client, checked against an API key's allowed IPs;
+	// nil uses the address of the peer
+	ClientAddr func(r *http.Request) string
+	// Employees and Identities map single sign-on logins to admins and employees; nil Identities
+	// disables single sign-on
+	Employees  models.EmployeeRepository
+	Identities models.IdentityRepository
+	// ProvisionEmployees creates an employee for a single sign-on login that matches no one
+	ProvisionEmployees bool
+	// DisablePasswordLogin turns Login off, so admins only log in through the provider
+	DisablePasswordLogin bool
+}
+
+// NewAuthenticator returns an Authenticator locking accounts out according to policy
+func NewAuthenticator(admins models.AdminRepository, sessions models.SessionRepository, lockouts ratelimit.LockoutStore, policy ratelimit.LockoutPolicy) *Authenticator {
+	return &Authenticator{Admins: admins, Sessions: sessions, Lockouts: lockouts, Lockout: policy,
+		SessionTimeouts: DefaultSessionTimeouts, TOTPIssuer: DefaultTOTPIssuer}
+}
+
+// DefaultTOTPIssuer is how the service is labelled in authenticator apps unless configured otherwise
+const DefaultTOTPIssuer = "Go-Asset"
+
+// lockoutKey names the failure counter of the account an email belongs to. Unknown emails are
+// counted too, so a lockout does not reveal whether an account exists.
+func lockoutKey(email string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// Login checks an admin's email and password and starts a session, returning it with the token
+// that authenticates it. While the account is locked out it returns a *LockedError without
+// checking the password. For an admin with two-factor authentication it returns a
+// *SecondFactorRequired instead, and VerifySecondFactor starts the session.
+func (a *Authenticator) Login(ctx context.Context, email, password string) (*models.Session, string, error) {
+	if a.DisablePasswordLogin {
+		return nil, "", ErrPasswordLoginDisabled
+	}
+
+	key := lockoutKey(email)
+	if a.Lockout.Enabled() {
+		locked, err := a.Lockouts.Locked(ctx, key)
+		if err != nil {
+			return nil, "", err
+		}
+		if locked > 0 {
+			return nil, "", &LockedError{RetryAfter: locked}
+		}
+	}
+
+	admin, err := a.Admins.GetAdminByEmail(ctx, strings.TrimSpace(email))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, "", err
+	}
+
+	hash := unknownAdminHash
+	if admin != nil {
+		hash = admin.Password
+	}
+	if !CheckPassword(hash, password) || admin == nil {
+		return nil, "", a.fail(ctx, key, ErrInvalidCredentials)
+	}
+
+	// The failure count is only cleared once the second factor is given too, so a known password
+	// does not buy unlimited guesses at the code
+	required, err := a.requiresSecondFactor(ctx, admin.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if required {
+		return nil, "", a.challenge(ctx, admin.ID)
+	}
+
+	if a.Lockout.Enabled() {
+		if err := a.Lockouts.Succeed(ctx, key); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return a.StartSession(ctx, admin.ID)
+}
+
+// fail records a failed login and returns the error to report for it: invalid, or a *LockedError
+// once the account is locked
+func (a *Authenticator) fail(ctx context.Context, key string, invalid error) error {
+	if !a.Lockout.Enabled() {
+		return invalid
+	}
+
+	locked, err := a.Lockouts.Fail(ctx, key, a.Lockout)
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		logging.FromContext(ctx).Warn("account locked after failed logins", "account", key, "locked_for", locked.String())
+		return &LockedError{RetryAfter: locked}
+	}
+	return invalid
+}
+
+// Authenticate returns the session a token belongs to with its admin, or its employee for an
+// employee session, if the session has not ended and its owner has not been archived. Activity
+// extends the session by the idle timeout, up to its absolute expiry.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*models.Admin, *models.Employee, *models.Session, error) {
+	session, err := a.Sessions.GetSessionByTokenHash(ctx, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now()
+	if !session.Archive_at.After(now) {
+		return nil, nil, nil, ErrInvalidSession
+	}
+
+	var admin *models.Admin
+	var employee *models.Employee
+	var archived *time.Time
+	if session.EmployeeID != nil {
+		if a.Employees == nil {
+			return nil, nil, nil, ErrInvalidSession
+		}
+		employee, err = a.Employees.GetEmployeeByID(ctx, *session.EmployeeID)
+		if err == nil {
+			archived = employee.ArchivedAt
+		}
+	} else {
+		admin, err = a.Admins.GetAdminByID(ctx, session.AdminID)
+		if err == nil {
+			archived = admin.ArchivedAt
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if archived != nil {
+		return nil, nil, nil, ErrInvalidSession
+	}
+
+	if a.SessionTimeouts.renew(session, now) {
+		// Failing to extend the session is no reason to turn this request away
+		if err := a.Sessions.UpdateSession(ctx, session); err != nil {
+			logging.FromContext(ctx).Warn("renewing session", "session_id", session.ID.String(), "error", err)
+		}
+	}
+	return admin, employee, session, nil
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// EnsureAdmin creates an admin with the email and password unless an active admin already has the
+// email, so the first admin of a new database can log in. Without a password the admin can only
+// log in through single sign-on. It reports whether one was created.
+func EnsureAdmin(ctx context.Context, admins models.AdminRepository, email, password string) (bool, error) {
+	_, err := admins.GetAdminByEmail(ctx, email)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	var hash string
+	if password != "" {
+		if hash, err = HashPassword(password); err != nil {
+			return false, err
+		}
+	}
+	admin := &models.Admin{Name: "Administrator", Email: email, Password: hash, CreatedAt: time.Now()}
+	if err := admins.CreateAdmin(ctx, admin); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,32 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestEnsureAdmin(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+
+		created, err := auth.EnsureAdmin(ctx, repos.Admins, "root@example.com", "hunter2")
+		if err != nil || !created {
+			t.Fatalf("EnsureAdmin = %v, %v; want the admin created", created, err)
+		}
+		admin, err := repos.Admins.GetAdminByEmail(ctx, "root@example.com")
+		if err != nil {
+			t.Fatalf("GetAdminByEmail: %v", err)
+		}
+		if admin.Password == "hunter2" || !auth.CheckPassword(admin.Password, "hunter2") {
+			t.Errorf("stored password %q is not a hash of the bootstrap password", admin.Password)
+		}
+
+		created, err = auth.EnsureAdmin(ctx, repos.Admins, "ROOT@example.com", "other")
+		if err != nil || created {
+			t.Errorf("second EnsureAdmin = %v, %v; want the existing admin kept", created, err)
+		}
+	})
+}
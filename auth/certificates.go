@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+type serviceKey struct{}
+
+// WithService returns a copy of ctx carrying the authenticated service identity
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceKey{}, service)
+}
+
+// ServiceFromContext returns the service identity that authenticated the request, if any
+func ServiceFromContext(ctx context.Context) (string, bool) {
+	service, ok := ctx.Value(serviceKey{}).(string)
+	return service, ok
+}
+
+// CertificateIdentity is the service a client certificate authenticates as, with the scopes it is
+// granted. Scopes work as they do for API keys, so routes closed to API keys are closed to
+// certificates too.
+type CertificateIdentity struct {
+	Service string
+	Scopes  []string
+}
+
+// ParseCertificateIdentities reads the mapping from client certificates to service identities,
+// written as "name=identity scope..." entries separated by commas, such as
+// "mdm.internal.example.com=mdm assets:read assets:write,spiffe://example.com/hr-sync=hr employees:write".
+// A name matches the certificate's subject common name or one of its DNS or URI subject
+// alternative names.
+func ParseCertificateIdentities(s string) (map[string]CertificateIdentity, error) {
+	identities := make(map[string]CertificateIdentity)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, grant, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(grant)
+		if !ok || name == "" || len(fields) == 0 {
+			return nil, fmt.Errorf("%q is not a name=identity entry", entry)
+		}
+		for _, scope := range fields[1:] {
+			if !ValidScope(scope) {
+				return nil, fmt.Errorf("%q grants %s, which is not a valid scope", entry, scope)
+			}
+		}
+		identities[name] = CertificateIdentity{Service: fields[0], Scopes: fields[1:]}
+	}
+	return identities, nil
+}
+
+// certificateIdentity returns the service identity of the client certificate the TLS handshake
+// verified, if it is mapped to one
+func (a *Authenticator) certificateIdentity(r *http.Request) (CertificateIdentity, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(a.CertificateIdentities) == 0 {
+		return CertificateIdentity{}, false
+	}
+
+	leaf := r.TLS.VerifiedChains[0][0]
+	names := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+	for _, uri := range leaf.URIs {
+		names = append(names, uri.String())
+	}
+	for _, name := range names {
+		if identity, ok := a.CertificateIdentities[name]; ok && name != "" {
+			return identity, true
+		}
+	}
+	return CertificateIdentity{}, false
+}
+
+// authorizeService checks that a certificate identity grants the scope the matched route needs.
+// GET /me is always allowed, so a service can see who it authenticated as.
+func authorizeService(identity CertificateIdentity, r *http.Request) error {
+	if r.Method == http.MethodGet && routeTemplate(r) == selfServicePrefix {
+		return nil
+	}
+	scope := RequiredScope(r.Method, routeTemplate(r))
+	if scope == "" {
+		return errors.New("this route cannot be called with a client certificate")
+	}
+	if !slices.Contains(identity.Scopes, scope) {
+		return fmt.Errorf("service %s lacks the %s scope", identity.Service, scope)
+	}
+	return nil
+}
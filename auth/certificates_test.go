@@ -0,0 +1,95 @@
+package auth_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+)
+
+func TestParseCertificateIdentities(t *testing.T) {
+	got, err := auth.ParseCertificateIdentities(" mdm.example.com = mdm assets:read  assets:write ,spiffe://example.com/hr=hr,")
+	if err != nil {
+		t.Fatalf("ParseCertificateIdentities: %v", err)
+	}
+	want := map[string]auth.CertificateIdentity{
+		"mdm.example.com":         {Service: "mdm", Scopes: []string{"assets:read", "assets:write"}},
+		"spiffe://example.com/hr": {Service: "hr", Scopes: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseCertificateIdentities = %v, want %v", got, want)
+	}
+
+	for _, bad := range []string{"mdm", "=mdm", "mdm.example.com=", "mdm.example.com=mdm assets:delete", "mdm.example.com=mdm admins:read"} {
+		if _, err := auth.ParseCertificateIdentities(bad); err == nil {
+			t.Errorf("ParseCertificateIdentities(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestCertificateIdentity(t *testing.T) {
+	authenticator := &auth.Authenticator{CertificateIdentities: map[string]auth.CertificateIdentity{
+		"mdm.example.com":         {Service: "mdm", Scopes: []string{"assets:read", "assets:write"}},
+		"spiffe://example.com/hr": {Service: "hr", Scopes: []string{"employees:read"}},
+	}}
+	router := mux.NewRouter()
+	router.Use(authenticator.Middleware())
+	for _, route := range []string{"/assets", "/employees", "/admins", "/me"} {
+		router.HandleFunc(route, func(w http.ResponseWriter, r *http.Request) {
+			service, _ := auth.ServiceFromContext(r.Context())
+			io.WriteString(w, service)
+		})
+	}
+
+	mdm := &x509.Certificate{Subject: pkix.Name{CommonName: "mdm.example.com"}}
+	hrURI, _ := url.Parse("spiffe://example.com/hr")
+	for _, tc := range []struct {
+		name        string
+		method      string
+		path        string
+		cert        *x509.Certificate
+		wantStatus  int
+		wantService string
+	}{
+		{"common name", "GET", "/assets", mdm, http.StatusOK, "mdm"},
+		{"DNS name", "GET", "/assets", &x509.Certificate{DNSNames: []string{"other.example.com", "mdm.example.com"}}, http.StatusOK, "mdm"},
+		{"URI", "GET", "/employees", &x509.Certificate{URIs: []*url.URL{hrURI}}, http.StatusOK, "hr"},
+		{"granted write", "POST", "/assets", mdm, http.StatusOK, "mdm"},
+		{"missing scope", "GET", "/employees", mdm, http.StatusForbidden, ""},
+		{"read-only scope", "POST", "/employees", &x509.Certificate{URIs: []*url.URL{hrURI}}, http.StatusForbidden, ""},
+		{"route closed to API keys", "GET", "/admins", mdm, http.StatusForbidden, ""},
+		{"who am I", "GET", "/me", mdm, http.StatusOK, "mdm"},
+		{"unmapped", "GET", "/assets", &x509.Certificate{Subject: pkix.Name{CommonName: "laptop.example.com"}}, http.StatusUnauthorized, ""},
+		{"no certificate", "GET", "/assets", nil, http.StatusUnauthorized, ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.cert != nil {
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{tc.cert}}}
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.wantStatus {
+			t.Errorf("%s: %s %s status %d, want %d", tc.name, tc.method, tc.path, rec.Code, tc.wantStatus)
+		} else if tc.wantStatus == http.StatusOK && rec.Body.String() != tc.wantService {
+			t.Errorf("%s: authenticated as %q, want %q", tc.name, rec.Body.String(), tc.wantService)
+		}
+	}
+
+	// A certificate the handshake did not verify proves nothing
+	req := httptest.NewRequest("GET", "/assets", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{mdm}}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unverified certificate: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
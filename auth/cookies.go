@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// Browsers authenticate with cookies instead of the Authorization header. The session cookie is
+// HttpOnly; the CSRF cookie is readable by the front-end, which echoes it in CSRFHeader on every
+// request that changes something. Another site can make the browser send the cookies but cannot
+// read them, so it cannot set the header.
+const (
+	SessionCookie = "session"
+	CSRFCookie    = "csrf_token"
+	CSRFHeader    = "X-CSRF-Token"
+)
+
+// SessionTokenHeader returns the new token of a session rotated by the request, which replaces
+// the one the client sent
+const SessionTokenHeader = "X-Session-Token"
+
+// SetRotatedSession hands the client the new token of a rotated session, in SessionTokenHeader and,
+// for browsers, in fresh cookies
+func (a *Authenticator) SetRotatedSession(w http.ResponseWriter, session *models.Session, sessionToken string) error {
+	w.Header().Set(SessionTokenHeader, sessionToken)
+	return a.SetSessionCookies(w, session, sessionToken)
+}
+
+// SetSessionCookies sets the session and CSRF cookies for a new or rotated session. They last
+// until the session's absolute expiry; the server ends idle sessions before that.
+func (a *Authenticator) SetSessionCookies(w http.ResponseWriter, session *models.Session, sessionToken string) error {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    sessionToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   a.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(token),
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   a.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// cookieSession reads the session token from the session cookie
+func cookieSession(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
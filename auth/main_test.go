@@ -0,0 +1,11 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/db/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// ErrInvalidSession is returned for a session that does not exist, has expired or belongs to an
+// archived admin
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+type contextKey struct{}
+
+// WithAdmin returns a copy of ctx carrying the authenticated admin
+func WithAdmin(ctx context.Context, admin *models.Admin) context.Context {
+	return context.WithValue(ctx, contextKey{}, admin)
+}
+
+// AdminFromContext returns the admin that authenticated the request, if any
+func AdminFromContext(ctx context.Context) (*models.Admin, bool) {
+	admin, ok := ctx.Value(contextKey{}).(*models.Admin)
+	return admin, ok
+}
+
+type employeeKey struct{}
+
+// WithEmployee returns a copy of ctx carrying the employee whose session authenticated the request
+func WithEmployee(ctx context.Context, employee *models.Employee) context.Context {
+	return context.WithValue(ctx, employeeKey{}, employee)
+}
+
+// EmployeeFromContext returns the employee whose session authenticated the request, if any
+func EmployeeFromContext(ctx context.Context) (*models.Employee, bool) {
+	employee, ok := ctx.Value(employeeKey{}).(*models.Employee)
+	return employee, ok
+}
+
+// selfServicePrefix is where the routes employee sessions may use live
+const selfServicePrefix = "/me"
+
+// selfService reports whether a route template is one of the self-service routes
+func selfService(template string) bool {
+	return template == selfServicePrefix || strings.HasPrefix(template, selfServicePrefix+"/")
+}
+
+// bearerSession reads the session token from an "Authorization: Bearer" header
+func bearerSession(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// Middleware requires a client certificate mapped to a service identity or an API key granting the
+// route's scope, or a valid session from the Authorization header or else the session cookie, on
+// every request except to the public routes, written as "METHOD /path/template" such as
+// "POST /sessions". The admin, service or API key is put in the request context and on the
+// request's logger. Employee sessions are refused outside the self-service routes under /me.
+// Register it with router.Use so the matched route is known.
+func (a *Authenticator) Middleware(public ...string) func(http.Handler) http.Handler {
+	open := make(map[string]bool, len(public))
+	for _, route := range public {
+		open[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if open[r.Method+" "+routeTemplate(r)] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if identity, ok := a.certificateIdentity(r); ok {
+				if err := authorizeService(identity, r); err != nil {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+
+				ctx := WithService(r.Context(), identity.Service)
+				ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("service", identity.Service))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			if token, ok := requestAPIKey(r); ok {
+				key, err := a.AuthenticateAPIKey(r.Context(), token, a.clientAddr(r))
+				switch {
+				case errors.Is(err, ErrInvalidAPIKey):
+					unauthorized(w, "Invalid, revoked or expired API key")
+					return
+				case errors.Is(err, ErrAPIKeyAddress):
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				case err != nil:
+					http.Error(w, fmt.Sprintf("Error authenticating: %v", err), http.StatusInternalServerError)
+					return
+				}
+				if err := authorizeAPIKey(key, r); err != nil {
+					http.Error(w, err.Error(), http.StatusForbidden)
+					return
+				}
+
+				ctx := WithAPIKey(r.Context(), key)
+				ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("api_key_id", key.ID.String()))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			token, ok := bearerSession(r)
+			if !ok && r.Header.Get("Authorization") == "" {
+				token, ok = cookieSession(r)
+			}
+			if !ok {
+				unauthorized(w, "Authentication required")
+				return
+			}
+
+			admin, employee, session, err := a.Authenticate(r.Context(), token)
+			if errors.Is(err, ErrInvalidSession) {
+				unauthorized(w, "Invalid or expired session")
+				return
+			}
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error authenticating: %v", err), http.StatusInternalServerError)
+				return
+			}
+
+			if employee != nil {
+				if !selfService(routeTemplate(r)) {
+					http.Error(w, "Employee sessions can only use the /me routes", http.StatusForbidden)
+					return
+				}
+				ctx := WithSession(WithEmployee(r.Context(), employee), session)
+				ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("employee_id", employee.ID.String()))
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			ctx := WithSession(WithAdmin(r.Context(), admin), session)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("admin_id", admin.ID.String()))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="go-asset"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrPasswordTooLong is returned for passwords bcrypt would silently truncate
+var ErrPasswordTooLong = errors.New("password must be at most 72 bytes")
+
+// unknownAdminHash is compared against when no admin has the email, so a login for an unknown
+// address takes as long as one with a wrong password
+var unknownAdminHash, _ = HashPassword("unknown admin")
+
+// HashPassword returns the bcrypt hash stored in admin.password
+func HashPassword(password string) (string, error) {
+	if len(password) > 72 {
+		return "", ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches a hash from HashPassword
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// scopeResources maps the first segment of a route to the resource its scopes are named after.
+// Routes outside it, such as admins, sessions and API keys themselves, cannot be called with an
+// API key or client certificate at all.
+var scopeResources = map[string]string{
+	"assets":          "assets",
+	"employees":       "employees",
+	"employeeassets":  "employeeassets",
+	"employeesassets": "employeeassets",
+	"assetrequests":   "assetrequests",
+	"audits":          "audits",
+	"kits":            "kits",
+	"labels":          "labels",
+	"reservations":    "reservations",
+	"import":          "import",
+}
+
+// ValidScope reports whether scope is a permission an API key or service can be granted: a
+// resource followed by :read for GET and HEAD requests or :write for everything else, such as
+// assets:read
+func ValidScope(scope string) bool {
+	resource, action, ok := strings.Cut(scope, ":")
+	if !ok || (action != "read" && action != "write") {
+		return false
+	}
+	for _, r := range scopeResources {
+		if r == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredScope is the scope an API key or service needs for a request to the route template, or
+// "" if the route is closed to them
+func RequiredScope(method, template string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(template, "/"), "/")
+	resource, ok := scopeResources[segment]
+	if !ok {
+		return ""
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
+
+// routeTemplate is the mux route the request matched, such as /assets/{id}
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return ""
+}
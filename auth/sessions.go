@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// SessionTimeouts bounds how long a session lasts. Absolute is the most a session can last from
+// login; Idle ends it early once it has gone unused that long, and zero disables it.
+type SessionTimeouts struct {
+	Absolute time.Duration
+	Idle     time.Duration
+}
+
+// DefaultSessionTimeouts ends sessions after a day, or after an hour without use
+var DefaultSessionTimeouts = SessionTimeouts{Absolute: 24 * time.Hour, Idle: time.Hour}
+
+// end returns when a session last seen at the given time should end
+func (t SessionTimeouts) end(session *models.Session, seen time.Time) time.Time {
+	if t.Idle <= 0 {
+		return session.ExpiresAt
+	}
+	if idle := seen.Add(t.Idle); idle.Before(session.ExpiresAt) {
+		return idle
+	}
+	return session.ExpiresAt
+}
+
+// renew slides a session's end forward for activity at now and reports whether it changed. Only
+// activity more than a minute apart, or a tenth of the idle timeout if that is shorter, is
+// recorded, so a busy session does not cost a write per request.
+func (t SessionTimeouts) renew(session *models.Session, now time.Time) bool {
+	every := time.Minute
+	if t.Idle > 0 && t.Idle/10 < every {
+		every = t.Idle / 10
+	}
+	if now.Sub(session.LastSeenAt) < every {
+		return false
+	}
+	session.LastSeenAt = now
+	session.Archive_at = t.end(session, now)
+	return true
+}
+
+// GenerateSessionToken returns a new random session token and the hash that is stored in place of it
+func GenerateSessionToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hex SHA-256 of a random token. The tokens carry 256 bits of entropy, so
+// unlike passwords they need no slow hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type sessionKey struct{}
+
+// WithSession returns a copy of ctx carrying the session that authenticated the request
+func WithSession(ctx context.Context, session *models.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session that authenticated the request, if any
+func SessionFromContext(ctx context.Context) (*models.Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*models.Session)
+	return session, ok
+}
+
+// StartSession starts a session for an admin and returns it with its token
+func (a *Authenticator) StartSession(ctx context.Context, adminID uuid.UUID) (*models.Session, string, error) {
+	return a.startSession(ctx, &models.Session{AdminID: adminID})
+}
+
+// StartEmployeeSession starts a session for an employee, which only reaches the self-service
+// routes, and returns it with its token
+func (a *Authenticator) StartEmployeeSession(ctx context.Context, employeeID uuid.UUID) (*models.Session, string, error) {
+	return a.startSession(ctx, &models.Session{EmployeeID: &employeeID})
+}
+
+func (a *Authenticator) startSession(ctx context.Context, session *models.Session) (*models.Session, string, error) {
+	token, hash, err := GenerateSessionToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session.TokenHash, session.ExpiresAt = hash, now.Add(a.SessionTimeouts.Absolute)
+	session.Archive_at = a.SessionTimeouts.end(session, now)
+	if err := a.Sessions.CreateSession(ctx, session); err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// RenewSession extends a session by the idle timeout from now, up to its absolute expiry. A
+// session that has already ended stays ended.
+func (a *Authenticator) RenewSession(ctx context.Context, session *models.Session) error {
+	now := time.Now()
+	session.LastSeenAt = now
+	session.Archive_at = a.SessionTimeouts.end(session, now)
+	return a.Sessions.UpdateSession(ctx, session)
+}
+
+// RotateSession gives a session a new token, so one captured before a privilege change, such as
+// a new password, stops working. The new token is returned.
+func (a *Authenticator) RotateSession(ctx context.Context, session *models.Session) (string, error) {
+	token, hash, err := GenerateSessionToken()
+	if err != nil {
+		return "", err
+	}
+	if err := a.Sessions.RotateSessionToken(ctx, session.ID, hash); err != nil {
+		return "", err
+	}
+	session.TokenHash = hash
+	return token, nil
+}
+
+// ResetSessions ends an admin's sessions after a privilege change such as a new password. If the
+// request making the change runs under one of the admin's own sessions, that session is kept but
+// rotated, and it is returned with its new token for the client to switch to.
+func (a *Authenticator) ResetSessions(ctx context.Context, adminID uuid.UUID) (*models.Session, string, error) {
+	current, ok := SessionFromContext(ctx)
+	if !ok || current.AdminID != adminID {
+		current = nil
+	}
+
+	except := uuid.Nil
+	if current != nil {
+		except = current.ID
+	}
+	if _, err := a.Sessions.ArchiveAdminSessions(ctx, adminID, except); err != nil {
+		return nil, "", err
+	}
+	if current == nil {
+		return nil, "", nil
+	}
+
+	token, err := a.RotateSession(ctx, current)
+	if err != nil {
+		return nil, "", err
+	}
+	return current, token, nil
+}
+
+// PurgeSessions deletes sessions that ended more than retention ago, and expired logins that were
+// waiting for a second factor, every interval until ctx is cancelled
+func (a *Authenticator) PurgeSessions(ctx context.Context, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := a.Sessions.PurgeSessions(ctx, time.Now().Add(-retention))
+			if err != nil {
+				logging.FromContext(ctx).Error("purging expired sessions", "error", err)
+			} else if purged > 0 {
+				logging.FromContext(ctx).Info("purged expired sessions", "sessions", purged)
+			}
+			if a.TwoFactor == nil {
+				continue
+			}
+			if _, err := a.TwoFactor.PurgeLoginChallenges(ctx, time.Now()); err != nil {
+				logging.FromContext(ctx).Error("purging expired login challenges", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+)
+
+func TestSessionTimeouts(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := &models.Admin{Name: "Grace Hopper", Email: "grace@example.com", Password: "secret", CreatedAt: time.Now()}
+		if err := repos.Admins.CreateAdmin(ctx, admin); err != nil {
+			t.Fatalf("CreateAdmin: %v", err)
+		}
+		a := auth.NewAuthenticator(repos.Admins, repos.Sessions, ratelimit.NewMemory(), ratelimit.LockoutPolicy{})
+		a.SessionTimeouts = auth.SessionTimeouts{Absolute: 2 * time.Hour, Idle: 30 * time.Minute}
+
+		session, token, err := a.StartSession(ctx, admin.ID)
+		if err != nil {
+			t.Fatalf("StartSession: %v", err)
+		}
+		start := time.Now()
+		if !within(session.ExpiresAt, start.Add(2*time.Hour)) || !within(session.Archive_at, start.Add(30*time.Minute)) {
+			t.Errorf("new session expires at %v and ends at %v, want in 2h and 30m", session.ExpiresAt, session.Archive_at)
+		}
+		if session.TokenHash == "" || session.TokenHash == token {
+			t.Errorf("session token hash = %q, want the hash of the token", session.TokenHash)
+		}
+
+		// Activity slides the idle timeout forward
+		session.LastSeenAt = start.Add(-10 * time.Minute)
+		session.Archive_at = start.Add(20 * time.Minute)
+		if err := repos.Sessions.UpdateSession(ctx, session); err != nil {
+			t.Fatalf("UpdateSession: %v", err)
+		}
+		if _, _, _, err := a.Authenticate(ctx, token); err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		got, err := repos.Sessions.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID: %v", err)
+		}
+		if !within(got.Archive_at, start.Add(30*time.Minute)) || !within(got.LastSeenAt, start) {
+			t.Errorf("after activity session ends at %v, last seen %v; want 30m from now and now", got.Archive_at, got.LastSeenAt)
+		}
+
+		// But never past the absolute timeout
+		a.SessionTimeouts.Idle = 4 * time.Hour
+		if err := a.RenewSession(ctx, got); err != nil {
+			t.Fatalf("RenewSession: %v", err)
+		}
+		if !got.Archive_at.Equal(got.ExpiresAt) {
+			t.Errorf("renewed session ends at %v, want its expiry %v", got.Archive_at, got.ExpiresAt)
+		}
+
+		// Once idle past the timeout it is refused
+		got.Archive_at = time.Now().Add(-time.Second)
+		if err := repos.Sessions.UpdateSession(ctx, got); err != nil {
+			t.Fatalf("UpdateSession: %v", err)
+		}
+		if _, _, _, err := a.Authenticate(ctx, token); !errors.Is(err, auth.ErrInvalidSession) {
+			t.Errorf("Authenticate(idle) error = %v, want ErrInvalidSession", err)
+		}
+	})
+}
+
+func TestResetSessions(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := &models.Admin{Name: "Grace Hopper", Email: "grace@example.com", Password: "secret", CreatedAt: time.Now()}
+		if err := repos.Admins.CreateAdmin(ctx, admin); err != nil {
+			t.Fatalf("CreateAdmin: %v", err)
+		}
+		a := auth.NewAuthenticator(repos.Admins, repos.Sessions, ratelimit.NewMemory(), ratelimit.LockoutPolicy{})
+
+		current, currentToken, err := a.StartSession(ctx, admin.ID)
+		if err != nil {
+			t.Fatalf("StartSession: %v", err)
+		}
+		_, otherToken, err := a.StartSession(ctx, admin.ID)
+		if err != nil {
+			t.Fatalf("StartSession: %v", err)
+		}
+
+		rotated, token, err := a.ResetSessions(auth.WithSession(ctx, current), admin.ID)
+		if err != nil {
+			t.Fatalf("ResetSessions: %v", err)
+		}
+		if rotated == nil || rotated.ID != current.ID || token == "" || token == currentToken {
+			t.Fatalf("ResetSessions = %v, %q; want the current session with a new token", rotated, token)
+		}
+		for name, old := range map[string]string{"other session": otherToken, "old token": currentToken} {
+			if _, _, _, err := a.Authenticate(ctx, old); !errors.Is(err, auth.ErrInvalidSession) {
+				t.Errorf("Authenticate(%s) error = %v, want ErrInvalidSession", name, err)
+			}
+		}
+		if _, _, session, err := a.Authenticate(ctx, token); err != nil || session.ID != current.ID {
+			t.Errorf("Authenticate(new token) = %v, %v; want the current session", session, err)
+		}
+
+		// Without a session of the admin's own, every session ends
+		if rotated, _, err := a.ResetSessions(ctx, admin.ID); err != nil || rotated != nil {
+			t.Fatalf("ResetSessions = %v, %v; want no session kept", rotated, err)
+		}
+		if _, _, _, err := a.Authenticate(ctx, token); !errors.Is(err, auth.ErrInvalidSession) {
+			t.Errorf("Authenticate after reset error = %v, want ErrInvalidSession", err)
+		}
+	})
+}
+
+// within reports whether got is within a few seconds of want
+func within(got, want time.Time) bool {
+	d := got.Sub(want)
+	return d > -5*time.Second && d < 5*time.Second
+}
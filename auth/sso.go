@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/oidc"
+)
+
+var (
+	// ErrUnknownIdentity is returned for a single sign-on login that matches no active admin or
+	// employee, when employees are not provisioned
+	ErrUnknownIdentity = errors.New("no active admin or employee matches this login")
+	// ErrUnverifiedEmail is returned for a first single sign-on login whose email address the
+	// provider has not verified, since it cannot be trusted to match an account
+	ErrUnverifiedEmail = errors.New("the identity provider has not verified this email address")
+)
+
+// SingleSignOn starts a session for a login verified by the OpenID Connect provider. The first
+// time an identity logs in it is linked to the active admin, or else employee, with its verified
+// email address, or to a new employee when ProvisionEmployees is set; later logins follow the link.
+// Admins with two-factor authentication get a *SecondFactorRequired as with Login.
+func (a *Authenticator) SingleSignOn(ctx context.Context, issuer string, claims *oidc.Claims) (*models.Session, string, error) {
+	if a.Identities == nil {
+		return nil, "", ErrUnknownIdentity
+	}
+
+	identity, err := a.Identities.GetIdentity(ctx, issuer, claims.Subject)
+	if errors.Is(err, sql.ErrNoRows) {
+		identity, err = a.linkIdentity(ctx, issuer, claims)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if identity.EmployeeID != nil {
+		employee, err := a.Employees.GetEmployeeByID(ctx, *identity.EmployeeID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && employee.ArchivedAt != nil) {
+			return nil, "", ErrUnknownIdentity
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		return a.StartEmployeeSession(ctx, employee.ID)
+	}
+
+	admin, err := a.Admins.GetAdminByID(ctx, *identity.AdminID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && admin.ArchivedAt != nil) {
+		return nil, "", ErrUnknownIdentity
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	required, err := a.requiresSecondFactor(ctx, admin.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if required {
+		return nil, "", a.challenge(ctx, admin.ID)
+	}
+	return a.StartSession(ctx, admin.ID)
+}
+
+// linkIdentity links an identity seen for the first time to the admin or employee with its email
+// address, provisioning an employee if allowed
+func (a *Authenticator) linkIdentity(ctx context.Context, issuer string, claims *oidc.Claims) (*models.Identity, error) {
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return nil, ErrUnverifiedEmail
+	}
+	identity := &models.Identity{Issuer: issuer, Subject: claims.Subject, Email: email}
+
+	admin, err := a.Admins.GetAdminByEmail(ctx, email)
+	switch {
+	case err == nil:
+		identity.AdminID = &admin.ID
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
+	default:
+		employee, err := a.Employees.GetEmployeeByEmail(ctx, email)
+		switch {
+		case err == nil:
+			identity.EmployeeID = &employee.ID
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		case !a.ProvisionEmployees:
+			return nil, ErrUnknownIdentity
+		default:
+			if employee, err = a.provisionEmployee(ctx, email, claims.Name); err != nil {
+				return nil, err
+			}
+			identity.EmployeeID = &employee.ID
+		}
+	}
+
+	err = a.Identities.CreateIdentity(ctx, identity)
+	if errors.Is(err, models.ErrIdentityExists) {
+		// Another login linked it first
+		return a.Identities.GetIdentity(ctx, issuer, claims.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("linked single sign-on identity", "issuer", issuer, "subject", claims.Subject,
+		"admin", identity.AdminID != nil)
+	return identity, nil
+}
+
+// provisionEmployee creates an employee for a login that matched no one
+func (a *Authenticator) provisionEmployee(ctx context.Context, email, name string) (*models.Employee, error) {
+	if name = strings.TrimSpace(name); name == "" {
+		name, _, _ = strings.Cut(email, "@")
+	}
+	employee := &models.Employee{Name: name, Email: email, CreatedAt: time.Now()}
+	if err := a.Employees.CreateEmployee(ctx, employee); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("provisioned employee from single sign-on", "employee_id", employee.ID.String())
+	return employee, nil
+}
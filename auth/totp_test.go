@@ -0,0 +1,53 @@
+package auth_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/auth"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// The SHA-1 vectors of RFC 6238, truncated to six digits; the secret is "12345678901234567890"
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for _, tt := range []struct {
+		at   int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{20000000000, "353130"},
+	} {
+		got, err := auth.TOTPCode(secret, time.Unix(tt.at, 0))
+		if err != nil || got != tt.want {
+			t.Errorf("TOTPCode at %d = %q, %v; want %q", tt.at, got, err, tt.want)
+		}
+	}
+}
+
+func TestTOTPURL(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("secret %q is %d characters, want 32", secret, len(secret))
+	}
+
+	u, err := url.Parse(auth.TOTPURL("Go Asset", "grace@example.com", secret))
+	if err != nil {
+		t.Fatalf("parsing URL: %v", err)
+	}
+	query := u.Query()
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Go Asset:grace@example.com" ||
+		query.Get("secret") != secret || query.Get("issuer") != "Go Asset" || query.Get("digits") != "6" {
+		t.Errorf("TOTPURL = %s, want an otpauth://totp URL labelled with the issuer and account", u)
+	}
+
+	png, err := auth.TOTPQRCode(u.String())
+	if err != nil || len(png) < 8 || string(png[1:4]) != "PNG" {
+		t.Errorf("TOTPQRCode = %d bytes, %v; want a PNG", len(png), err)
+	}
+}
@@ -0,0 +1,273 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// Limits on a login waiting for its second factor
+const (
+	loginChallengeLifetime = 5 * time.Minute
+	loginChallengeAttempts = 5
+)
+
+var (
+	// ErrInvalidChallenge is returned for a login challenge that does not exist, has expired or
+	// has already been used
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+	// ErrInvalidSecondFactor is returned for a wrong, reused or stale authenticator or recovery code
+	ErrInvalidSecondFactor = errors.New("invalid authentication code")
+	// ErrTOTPNotEnrolled is returned when confirming or using an authenticator the admin never set up
+	ErrTOTPNotEnrolled = errors.New("no authenticator enrolled")
+)
+
+// SecondFactorRequired is returned by Login when the password is right but the admin has
+// two-factor authentication on. The login is finished by VerifySecondFactor with the challenge.
+type SecondFactorRequired struct {
+	Challenge string
+	ExpiresAt time.Time
+}
+
+func (e *SecondFactorRequired) Error() string {
+	return "second factor required"
+}
+
+// TOTPEnrollment is what an admin needs to add the authenticator to an app. QRCode is a PNG of URL.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URL    string `json:"otpauth_url"`
+	QRCode []byte `json:"qr_code"`
+}
+
+// requiresSecondFactor reports whether an admin has a confirmed authenticator
+func (a *Authenticator) requiresSecondFactor(ctx context.Context, adminID uuid.UUID) (bool, error) {
+	if a.TwoFactor == nil {
+		return false, nil
+	}
+	totp, err := a.TwoFactor.GetTOTP(ctx, adminID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return totp.ConfirmedAt != nil, nil
+}
+
+// challenge holds a login that passed the password check until the second factor is given
+func (a *Authenticator) challenge(ctx context.Context, adminID uuid.UUID) error {
+	token, hash, err := GenerateSessionToken()
+	if err != nil {
+		return err
+	}
+	challenge := &models.LoginChallenge{AdminID: adminID, TokenHash: hash, ExpiresAt: time.Now().Add(loginChallengeLifetime)}
+	if err := a.TwoFactor.CreateLoginChallenge(ctx, challenge); err != nil {
+		return err
+	}
+	return &SecondFactorRequired{Challenge: token, ExpiresAt: challenge.ExpiresAt}
+}
+
+// VerifySecondFactor finishes a login held by Login for its second factor, given the challenge
+// and a code from the admin's authenticator or one of their recovery codes. Wrong codes count as
+// failed logins towards the account's lockout, and a challenge allows only a few of them.
+func (a *Authenticator) VerifySecondFactor(ctx context.Context, challengeToken, code string) (*models.Session, string, error) {
+	if a.TwoFactor == nil {
+		return nil, "", ErrInvalidChallenge
+	}
+	challenge, err := a.TwoFactor.GetLoginChallengeByTokenHash(ctx, hashToken(challengeToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if !challenge.ExpiresAt.After(time.Now()) {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	admin, err := a.Admins.GetAdminByID(ctx, challenge.AdminID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if admin.ArchivedAt != nil {
+		return nil, "", ErrInvalidChallenge
+	}
+
+	key := lockoutKey(admin.Email)
+	if a.Lockout.Enabled() {
+		locked, err := a.Lockouts.Locked(ctx, key)
+		if err != nil {
+			return nil, "", err
+		}
+		if locked > 0 {
+			return nil, "", &LockedError{RetryAfter: locked}
+		}
+	}
+
+	// Deleting the challenge claims it before the code is used up, so of the requests racing with
+	// the same challenge only one spends a code, and it finishes at most one login
+	err = a.TwoFactor.DeleteLoginChallenge(ctx, challenge.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	ok, err := a.checkSecondFactor(ctx, admin.ID, code)
+	if err != nil {
+		return nil, "", err
+	}
+	if !ok {
+		// A wrong code puts the challenge back for another try, until it runs out of attempts
+		challenge.Attempts++
+		if challenge.Attempts < loginChallengeAttempts {
+			if err := a.TwoFactor.RestoreLoginChallenge(ctx, challenge); err != nil {
+				return nil, "", err
+			}
+		}
+		return nil, "", a.fail(ctx, key, ErrInvalidSecondFactor)
+	}
+
+	if a.Lockout.Enabled() {
+		if err := a.Lockouts.Succeed(ctx, key); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return a.StartSession(ctx, admin.ID)
+}
+
+// checkSecondFactor reports whether code is an unused code from the admin's authenticator or an
+// unused recovery code, and uses it up
+func (a *Authenticator) checkSecondFactor(ctx context.Context, adminID uuid.UUID, code string) (bool, error) {
+	totp, err := a.TwoFactor.GetTOTP(ctx, adminID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := checkTOTP(totp.Secret, code, time.Now()); ok {
+		err := a.TwoFactor.UseTOTPStep(ctx, adminID, step)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	err = a.TwoFactor.UseRecoveryCode(ctx, adminID, hashRecoveryCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	logging.FromContext(ctx).Warn("recovery code used to log in", "admin_id", adminID.String())
+	return true, nil
+}
+
+// EnrollTOTP starts adding an authenticator for an admin, replacing one they never confirmed. It
+// takes effect once ConfirmTOTP is given a code from it.
+func (a *Authenticator) EnrollTOTP(ctx context.Context, admin *models.Admin) (*TOTPEnrollment, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.TwoFactor.StartTOTP(ctx, &models.TOTP{AdminID: admin.ID, Secret: secret}); err != nil {
+		return nil, err
+	}
+
+	enrollment := &TOTPEnrollment{Secret: secret, URL: TOTPURL(a.TOTPIssuer, admin.Email, secret)}
+	if enrollment.QRCode, err = TOTPQRCode(enrollment.URL); err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// ConfirmTOTP turns on an enrolled authenticator once the admin enters a code from it, and
+// returns their recovery codes. They are shown only this once.
+func (a *Authenticator) ConfirmTOTP(ctx context.Context, adminID uuid.UUID, code string) ([]string, error) {
+	totp, err := a.TwoFactor.GetTOTP(ctx, adminID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt != nil {
+		return nil, models.ErrTwoFactorEnabled
+	}
+	step, ok := checkTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidSecondFactor
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = a.TwoFactor.ConfirmTOTP(ctx, adminID, step, hashes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces an admin's recovery codes, given a code from their authenticator
+func (a *Authenticator) RegenerateRecoveryCodes(ctx context.Context, adminID uuid.UUID, code string) ([]string, error) {
+	totp, err := a.TwoFactor.GetTOTP(ctx, adminID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	step, ok := checkTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidSecondFactor
+	}
+	err = a.TwoFactor.UseTOTPStep(ctx, adminID, step)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidSecondFactor
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := a.TwoFactor.ReplaceRecoveryCodes(ctx, adminID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor removes an admin's authenticator and recovery codes, such as after they lost their
+// phone, and ends their sessions. They log in with their password alone until they enroll again.
+func (a *Authenticator) ResetTwoFactor(ctx context.Context, adminID uuid.UUID) error {
+	if err := a.TwoFactor.DeleteTOTP(ctx, adminID); err != nil {
+		return err
+	}
+	_, err := a.Sessions.ArchiveAdminSessions(ctx, adminID, uuid.Nil)
+	return err
+}
@@ -0,0 +1,351 @@
+// Package config reads the server settings from the environment in one place.
+package config
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/db"
+	"github.com/cameo1221/Go-Asset/labels"
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/middleware"
+	"github.com/cameo1221/Go-Asset/oidc"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+	"github.com/cameo1221/Go-Asset/tlsconfig"
+)
+
+// Config holds the settings the server is started with
+type Config struct {
+	// BaseURL is the public address of the API, used for links printed on labels
+	BaseURL string
+	// Printers are the Zebra printers that may receive raw ZPL, by name
+	Printers map[string]string
+	// CalendarFeedKey signs the tokens in calendar feed URLs
+	CalendarFeedKey []byte
+	// RequestTimeout bounds how long a handler may run, except for streamed lists and exports
+	RequestTimeout time.Duration
+	// StreamTimeout bounds a whole streamed list or export, and StreamWriteTimeout how long one
+	// may go without getting another batch of rows to the client
+	StreamTimeout      time.Duration
+	StreamWriteTimeout time.Duration
+	// QueryTimeout bounds each model call against the database
+	QueryTimeout time.Duration
+	// ReadHeaderTimeout and ReadTimeout bound how long a client may take to send a request,
+	// which stops slow clients holding connections open
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout bounds writing a response; keep it above RequestTimeout. Streamed responses
+	// replace it with StreamWriteTimeout
+	WriteTimeout time.Duration
+	// IdleTimeout closes keep-alive connections that sit unused
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long in-flight requests get to finish after SIGTERM or SIGINT
+	ShutdownTimeout time.Duration
+	// TracesExporter is where spans are sent: otlp, stdout or none
+	TracesExporter string
+	// LogLevel is the lowest level written to the JSON log
+	LogLevel slog.Level
+	// LogRedactFields are the body and query fields whose values never reach the log
+	LogRedactFields []string
+	// LogBodyLimit is how many bytes of each request body are logged; zero logs none
+	LogBodyLimit int
+	// RateLimit applies per client IP to every API request, AdminRateLimit per authenticated
+	// admin or service, and LoginRateLimit per client IP to logins
+	RateLimit      ratelimit.Limit
+	AdminRateLimit ratelimit.Limit
+	LoginRateLimit ratelimit.Limit
+	// Lockout locks an admin account out after repeated failed logins
+	Lockout ratelimit.LockoutPolicy
+	// BootstrapAdminEmail and BootstrapAdminPassword create the first admin when no active admin
+	// has that email yet
+	BootstrapAdminEmail    string
+	BootstrapAdminPassword string
+	// CORS lists the browser origins allowed to call the API and what they may do
+	CORS middleware.CORSOptions
+	// Security configures the hardening headers sent with every response
+	Security middleware.SecurityOptions
+	// SecureCookies restricts the session cookies to HTTPS
+	SecureCookies bool
+	// TOTPIssuer names the service in admins' authenticator apps
+	TOTPIssuer string
+	// SessionTimeouts are how long admin sessions last from login and without use
+	SessionTimeouts auth.SessionTimeouts
+	// SessionPurgeInterval is how often ended sessions older than SessionRetention are deleted;
+	// zero keeps them forever
+	SessionPurgeInterval time.Duration
+	SessionRetention     time.Duration
+	// TrustProxyHeaders takes the client IP from X-Forwarded-For; only set it behind a proxy
+	TrustProxyHeaders bool
+	// TLS serves HTTPS when a certificate is configured, optionally verifying client certificates
+	TLS tlsconfig.Options
+	// TLSReloadInterval is how often the certificate files are checked for renewal
+	TLSReloadInterval time.Duration
+	// CertificateIdentities maps client certificate names to the service identities they log in as
+	CertificateIdentities map[string]auth.CertificateIdentity
+	// Database is how the PostgreSQL connection is secured
+	Database db.TLSOptions
+	// OIDC is the OpenID Connect provider admins and employees log in with; unset disables it
+	OIDC oidc.Config
+	// OIDCProvisionEmployees creates an employee for a provider login that matches no one
+	OIDCProvisionEmployees bool
+	// OIDCPostLoginURL is where browsers land after logging in with the provider; empty answers
+	// with the session as JSON
+	OIDCPostLoginURL string
+	// PasswordLogin lets admins log in with a password; turning it off requires the provider
+	PasswordLogin bool
+}
+
+// Load reads the configuration from the environment, falling back to defaults for unset values
+func Load() (*Config, error) {
+	cfg := &Config{
+		BaseURL:        getenv("APP_BASE_URL", "http://localhost:8080"),
+		TracesExporter: getenv("OTEL_TRACES_EXPORTER", "none"),
+
+		BootstrapAdminEmail:    os.Getenv("BOOTSTRAP_ADMIN_EMAIL"),
+		BootstrapAdminPassword: os.Getenv("BOOTSTRAP_ADMIN_PASSWORD"),
+		TOTPIssuer:             getenv("TOTP_ISSUER", auth.DefaultTOTPIssuer),
+	}
+
+	var err error
+	if cfg.Printers, err = labels.ParsePrinters(os.Getenv("ZPL_PRINTERS")); err != nil {
+		return nil, fmt.Errorf("ZPL_PRINTERS: %w", err)
+	}
+	if cfg.CalendarFeedKey, err = secret("CALENDAR_FEED_KEY"); err != nil {
+		return nil, err
+	}
+	if cfg.RequestTimeout, err = duration("REQUEST_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.StreamTimeout, err = duration("STREAM_TIMEOUT", 30*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.StreamWriteTimeout, err = duration("STREAM_WRITE_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.QueryTimeout, err = duration("QUERY_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ReadHeaderTimeout, err = duration("READ_HEADER_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ReadTimeout, err = duration("READ_TIMEOUT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WriteTimeout, err = duration("WRITE_TIMEOUT", 75*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.IdleTimeout, err = duration("IDLE_TIMEOUT", 120*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.ShutdownTimeout, err = duration("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.LogLevel, err = logging.ParseLevel(getenv("LOG_LEVEL", "info")); err != nil {
+		return nil, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	cfg.LogRedactFields = strings.Split(getenv("LOG_REDACT_FIELDS", "password,token,challenge,secret"), ",")
+	if cfg.LogBodyLimit, err = size("LOG_BODY_LIMIT", 2048); err != nil {
+		return nil, err
+	}
+	if cfg.RateLimit, err = limit("RATE_LIMIT", "300/1m"); err != nil {
+		return nil, err
+	}
+	if cfg.AdminRateLimit, err = limit("ADMIN_RATE_LIMIT", "600/1m"); err != nil {
+		return nil, err
+	}
+	if cfg.LoginRateLimit, err = limit("LOGIN_RATE_LIMIT", "10/1m"); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.Threshold, err = size("LOCKOUT_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.Base, err = duration("LOCKOUT_BASE", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Lockout.Max, err = duration("LOCKOUT_MAX", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.TrustProxyHeaders, err = boolean("TRUST_PROXY_HEADERS", false); err != nil {
+		return nil, err
+	}
+	cfg.CORS = middleware.CORSOptions{
+		AllowedOrigins: list(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		AllowedMethods: list(getenv("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE")),
+		AllowedHeaders: list(getenv("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,Accept,X-Request-ID,X-CSRF-Token")),
+		ExposedHeaders: []string{"X-Request-ID", "X-Session-Token", "Content-Disposition", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+	}
+	if cfg.CORS.AllowCredentials, err = boolean("CORS_ALLOW_CREDENTIALS", false); err != nil {
+		return nil, err
+	}
+	if cfg.CORS.AllowCredentials && slices.Contains(cfg.CORS.AllowedOrigins, "*") {
+		return nil, fmt.Errorf("CORS_ALLOWED_ORIGINS: * cannot be combined with CORS_ALLOW_CREDENTIALS; list the origins")
+	}
+	if cfg.CORS.MaxAge, err = duration("CORS_MAX_AGE", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.Security.HSTSMaxAge, err = duration("HSTS_MAX_AGE", 0); err != nil {
+		return nil, err
+	}
+	cfg.Security.FrameAncestors = getenv("FRAME_ANCESTORS", "'none'")
+	if cfg.SecureCookies, err = boolean("SECURE_COOKIES", true); err != nil {
+		return nil, err
+	}
+	if cfg.SessionTimeouts.Absolute, err = duration("SESSION_ABSOLUTE_TIMEOUT", auth.DefaultSessionTimeouts.Absolute); err != nil {
+		return nil, err
+	}
+	if cfg.SessionTimeouts.Absolute == 0 {
+		return nil, fmt.Errorf("SESSION_ABSOLUTE_TIMEOUT: sessions must expire")
+	}
+	if cfg.SessionTimeouts.Idle, err = duration("SESSION_IDLE_TIMEOUT", auth.DefaultSessionTimeouts.Idle); err != nil {
+		return nil, err
+	}
+	if cfg.SessionPurgeInterval, err = duration("SESSION_PURGE_INTERVAL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.SessionRetention, err = duration("SESSION_RETENTION", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.TLS = tlsconfig.Options{
+		CertFile:     os.Getenv("TLS_CERT_FILE"),
+		KeyFile:      os.Getenv("TLS_KEY_FILE"),
+		ClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		ClientAuth:   getenv("TLS_CLIENT_AUTH", tlsconfig.ClientAuthNone),
+	}
+	if err := cfg.TLS.Validate(); err != nil {
+		return nil, fmt.Errorf("TLS: %w", err)
+	}
+	if cfg.TLSReloadInterval, err = duration("TLS_RELOAD_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.CertificateIdentities, err = auth.ParseCertificateIdentities(os.Getenv("MTLS_IDENTITIES")); err != nil {
+		return nil, fmt.Errorf("MTLS_IDENTITIES: %w", err)
+	}
+	if len(cfg.CertificateIdentities) > 0 && cfg.TLS.ClientCAFile == "" {
+		return nil, fmt.Errorf("MTLS_IDENTITIES needs TLS_CLIENT_CA_FILE to verify client certificates")
+	}
+	cfg.Database = db.TLSOptions{
+		SSLMode:     getenv("DB_SSLMODE", "disable"),
+		SSLRootCert: os.Getenv("DB_SSLROOTCERT"),
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		return nil, fmt.Errorf("DB_SSLMODE: %w", err)
+	}
+	cfg.OIDC = oidc.Config{
+		Issuer:       os.Getenv("OIDC_ISSUER"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  getenv("OIDC_REDIRECT_URL", strings.TrimSuffix(cfg.BaseURL, "/")+"/sessions/oidc/callback"),
+		Scopes:       list(getenv("OIDC_SCOPES", "openid,email,profile")),
+	}
+	if err := cfg.OIDC.Validate(); err != nil {
+		return nil, fmt.Errorf("OIDC: %w", err)
+	}
+	if cfg.OIDCProvisionEmployees, err = boolean("OIDC_PROVISION_EMPLOYEES", false); err != nil {
+		return nil, err
+	}
+	cfg.OIDCPostLoginURL = os.Getenv("OIDC_POST_LOGIN_URL")
+	if cfg.PasswordLogin, err = boolean("PASSWORD_LOGIN", true); err != nil {
+		return nil, err
+	}
+	if !cfg.PasswordLogin && !cfg.OIDC.Enabled() {
+		return nil, fmt.Errorf("PASSWORD_LOGIN: turning password login off needs OIDC_ISSUER, or no one could log in")
+	}
+	switch {
+	case !cfg.PasswordLogin && cfg.BootstrapAdminPassword != "":
+		return nil, fmt.Errorf("BOOTSTRAP_ADMIN_PASSWORD cannot be set with password login off; the admin logs in with single sign-on")
+	case cfg.PasswordLogin && (cfg.BootstrapAdminEmail == "") != (cfg.BootstrapAdminPassword == ""):
+		return nil, fmt.Errorf("BOOTSTRAP_ADMIN_EMAIL and BOOTSTRAP_ADMIN_PASSWORD must be set together")
+	}
+
+	return cfg, nil
+}
+
+func getenv(name, def string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return def
+}
+
+// duration parses a Go duration such as "30s"; "0" turns the limit off
+func duration(name string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: %q is not a duration like 30s", name, value)
+	}
+	return d, nil
+}
+
+// secret reads a signing key of at least 16 bytes. Unset, a random key is used, so whatever it
+// signs is only good until the next restart.
+func secret(name string) ([]byte, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return key, nil
+	}
+	if len(value) < 16 {
+		return nil, fmt.Errorf("%s: must be at least 16 bytes", name)
+	}
+	return []byte(value), nil
+}
+
+// limit parses a rate limit such as "10/1m"; "off" turns it off
+func limit(name, def string) (ratelimit.Limit, error) {
+	l, err := ratelimit.ParseLimit(getenv(name, def))
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return l, nil
+}
+
+// list splits a comma-separated value, dropping empty entries
+func list(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// boolean parses true or false
+func boolean(name string, def bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s: %q is not true or false", name, value)
+	}
+	return b, nil
+}
+
+// size parses a non-negative count, such as a number of bytes
+func size(name string, def int) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: %q is not a non-negative number", name, value)
+	}
+	return n, nil
+}
@@ -4,23 +4,81 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 
-	_ "github.com/lib/pq"
+	"github.com/cameo1221/Go-Asset/tracing"
+	"github.com/lib/pq"
 )
 
+// tracedDriverName is the lib/pq driver wrapped to trace every statement
+const tracedDriverName = "postgres+otel"
+
+var registerTracedDriver sync.Once
+
 // Database represents the PostgreSQL database connection
 type Database struct {
 	Conn *sql.DB
 }
 
-// Connect connects to the PostgreSQL database
-func Connect() (*Database, error) {
+// TLSOptions secures the connection to PostgreSQL
+type TLSOptions struct {
+	// SSLMode is disable, require, verify-ca or verify-full, as understood by lib/pq
+	SSLMode string
+	// SSLRootCert is the PEM file of the CA that signed the server's certificate
+	SSLRootCert string
+}
+
+// Validate checks that the mode is one lib/pq supports and that a root CA comes with TLS
+func (o TLSOptions) Validate() error {
+	switch o.SSLMode {
+	case "disable":
+		if o.SSLRootCert != "" {
+			return fmt.Errorf("a root certificate needs sslmode require, verify-ca or verify-full")
+		}
+	case "require", "verify-ca", "verify-full":
+	default:
+		return fmt.Errorf("unknown sslmode %q; use disable, require, verify-ca or verify-full", o.SSLMode)
+	}
+	return nil
+}
+
+// connString builds the lib/pq connection string, quoting each value
+func connString(host, port, user, password, dbname string, opts TLSOptions) string {
+	params := [][2]string{
+		{"host", host},
+		{"port", port},
+		{"user", user},
+		{"password", password},
+		{"dbname", dbname},
+		{"sslmode", opts.SSLMode},
+	}
+	if opts.SSLRootCert != "" {
+		params = append(params, [2]string{"sslrootcert", opts.SSLRootCert})
+	}
+
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	pairs := make([]string, len(params))
+	for i, param := range params {
+		pairs[i] = fmt.Sprintf("%s='%s'", param[0], quote.Replace(param[1]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Connect connects to the PostgreSQL database, over TLS unless opts disables it
+func Connect(opts TLSOptions) (*Database, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Construct the connection string
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		"localhost", "5432", "local", "docker", "go_asset_db")
+	connStr := connString("localhost", "5432", "local", "docker", "go_asset_db", opts)
 
-	// Open a connection to the database
-	db, err := sql.Open("postgres", connStr)
+	// Open a connection to the database; statements are traced through the wrapped driver
+	registerTracedDriver.Do(func() {
+		sql.Register(tracedDriverName, tracing.WrapDriver(pq.Driver{}))
+	})
+	db, err := sql.Open(tracedDriverName, connStr)
 	if err != nil {
 		return nil, err
 	}
@@ -34,3 +92,8 @@ func Connect() (*Database, error) {
 
 	return &Database{Conn: db}, nil
 }
+
+// Close waits for in-flight queries to finish and closes every pooled connection
+func (d *Database) Close() error {
+	return d.Conn.Close()
+}
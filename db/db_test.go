@@ -0,0 +1,28 @@
+package db
+
+import "testing"
+
+func TestConnString(t *testing.T) {
+	got := connString("db.internal", "5432", "asset", `it's\secret`, "go_asset_db", TLSOptions{SSLMode: "verify-full", SSLRootCert: "/etc/ssl/db ca.pem"})
+	want := `host='db.internal' port='5432' user='asset' password='it\'s\\secret' dbname='go_asset_db' sslmode='verify-full' sslrootcert='/etc/ssl/db ca.pem'`
+	if got != want {
+		t.Errorf("connString =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTLSOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		opts  TLSOptions
+		valid bool
+	}{
+		{TLSOptions{SSLMode: "disable"}, true},
+		{TLSOptions{SSLMode: "verify-ca", SSLRootCert: "ca.pem"}, true},
+		{TLSOptions{SSLMode: "disable", SSLRootCert: "ca.pem"}, false},
+		{TLSOptions{SSLMode: "prefer"}, false},
+		{TLSOptions{}, false},
+	} {
+		if err := tc.opts.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%+v: Validate = %v, want valid %v", tc.opts, err, tc.valid)
+		}
+	}
+}
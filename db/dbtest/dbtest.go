@@ -0,0 +1,211 @@
+// Package dbtest runs integration tests against a throwaway PostgreSQL server. The server is
+// started from the initdb and postgres binaries on PATH (or in $PG_BIN), listens only on a Unix
+// socket in a temporary directory and is removed when the tests finish. The schema is migrated
+// once into a template database; every test gets a fresh copy of it.
+package dbtest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/db"
+)
+
+// templateName is the migrated database each test database is cloned from
+const templateName = "go_asset_template"
+
+// startTimeout bounds how long the server may take to accept connections
+const startTimeout = 30 * time.Second
+
+// Server is a running throwaway PostgreSQL instance
+type Server struct {
+	dir   string
+	cmd   *exec.Cmd
+	admin *sql.DB
+	next  atomic.Int64
+}
+
+// binary finds a PostgreSQL program in $PG_BIN or on PATH
+func binary(name string) (string, error) {
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return exec.LookPath(name)
+}
+
+// Start initialises a data directory, starts the server and migrates the template database
+func Start() (*Server, error) {
+	initdb, err := binary("initdb")
+	if err != nil {
+		return nil, err
+	}
+	postgres, err := binary("postgres")
+	if err != nil {
+		return nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, errors.New("postgres refuses to run as root")
+	}
+
+	dir, err := os.MkdirTemp("", "go-asset-pg-")
+	if err != nil {
+		return nil, err
+	}
+	data := filepath.Join(dir, "data")
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust", "-E", "UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("initdb: %w\n%s", err, out)
+	}
+
+	logFile, err := os.Create(filepath.Join(dir, "postgres.log"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	defer logFile.Close()
+
+	// No TCP listener and no durability: the data is thrown away with the directory
+	cmd := exec.Command(postgres, "-D", data, "-k", dir,
+		"-c", "listen_addresses=",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s := &Server{dir: dir, cmd: cmd}
+	if err := s.init(); err != nil {
+		s.Stop()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Server) init() error {
+	admin, err := sql.Open("postgres", s.DSN("postgres"))
+	if err != nil {
+		return err
+	}
+	s.admin = admin
+
+	deadline := time.Now().Add(startTimeout)
+	for {
+		err := admin.Ping()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			logs, _ := os.ReadFile(filepath.Join(s.dir, "postgres.log"))
+			return fmt.Errorf("postgres did not start: %w\n%s", err, logs)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := admin.Exec(`CREATE DATABASE ` + templateName); err != nil {
+		return err
+	}
+
+	conn, err := sql.Open("postgres", s.DSN(templateName))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return (&db.Database{Conn: conn}).Migrate()
+}
+
+// DSN returns the connection string for a database on the server
+func (s *Server) DSN(name string) string {
+	return fmt.Sprintf("host=%s user=postgres dbname=%s sslmode=disable", s.dir, name)
+}
+
+// NewDatabase creates a database cloned from the migrated template. The returned function closes
+// the connection pool and drops the database.
+func (s *Server) NewDatabase() (*sql.DB, func() error, error) {
+	name := fmt.Sprintf("go_asset_test_%d", s.next.Add(1))
+	if _, err := s.admin.Exec(`CREATE DATABASE ` + name + ` TEMPLATE ` + templateName); err != nil {
+		return nil, nil, err
+	}
+
+	conn, err := sql.Open("postgres", s.DSN(name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	drop := func() error {
+		conn.Close()
+		_, err := s.admin.Exec(`DROP DATABASE IF EXISTS ` + name)
+		return err
+	}
+	return conn, drop, nil
+}
+
+// Stop shuts the server down and removes its data directory
+func (s *Server) Stop() error {
+	if s.admin != nil {
+		s.admin.Close()
+	}
+	// SIGINT asks postgres for a fast shutdown
+	if err := s.cmd.Process.Signal(os.Interrupt); err == nil {
+		s.cmd.Wait()
+	}
+	return os.RemoveAll(s.dir)
+}
+
+var (
+	server   *Server
+	startErr error
+)
+
+// Main starts a server for the test binary, runs the tests and stops it. Call it from TestMain.
+// When PostgreSQL is not installed the tests still run and Open skips.
+func Main(m *testing.M) {
+	server, startErr = Start()
+	code := m.Run()
+	if server != nil {
+		server.Stop()
+	}
+	os.Exit(code)
+}
+
+// Open returns a fresh migrated database that is dropped when the test ends. Without a server
+// the test is skipped, unless DBTEST_REQUIRED is set, in which case it fails.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	if server == nil {
+		if os.Getenv("DBTEST_REQUIRED") != "" {
+			t.Fatalf("PostgreSQL is required: %v", startErr)
+		}
+		t.Skipf("skipping PostgreSQL test: %v", startErr)
+	}
+
+	conn, drop, err := server.NewDatabase()
+	if err != nil {
+		t.Fatalf("creating test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := drop(); err != nil {
+			t.Errorf("dropping test database: %v", err)
+		}
+	})
+
+	return conn
+}
@@ -0,0 +1,42 @@
+-- Baseline schema the models were written against.
+
+CREATE TABLE IF NOT EXISTS admin (
+	id         UUID PRIMARY KEY,
+	name       TEXT NOT NULL,
+	email      TEXT NOT NULL,
+	password   TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	archive_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS admin_session (
+	id         UUID PRIMARY KEY,
+	admin_id   UUID NOT NULL REFERENCES admin (id),
+	archive_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS asset (
+	id         UUID PRIMARY KEY,
+	model      TEXT NOT NULL,
+	company    TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	archive_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS employee (
+	id         UUID PRIMARY KEY,
+	name       TEXT NOT NULL,
+	email      TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	archive_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS employee_asset_mapping (
+	id          UUID PRIMARY KEY,
+	asset_id    UUID NOT NULL REFERENCES asset (id),
+	employee_id UUID NOT NULL REFERENCES employee (id),
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	archive_at  TIMESTAMPTZ
+);
@@ -0,0 +1,26 @@
+-- Assets carry a lifecycle status so offboarding can record what came back.
+ALTER TABLE asset ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'available';
+
+UPDATE asset SET status = 'assigned'
+WHERE id IN (SELECT asset_id FROM employee_asset_mapping WHERE archive_at IS NULL);
+
+CREATE TABLE employee_offboarding (
+	id           UUID PRIMARY KEY,
+	employee_id  UUID NOT NULL REFERENCES employee (id),
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	completed_at TIMESTAMPTZ
+);
+
+-- At most one offboarding in progress per employee.
+CREATE UNIQUE INDEX employee_offboarding_open_idx
+	ON employee_offboarding (employee_id) WHERE completed_at IS NULL;
+
+CREATE TABLE offboarding_item (
+	id                UUID PRIMARY KEY,
+	offboarding_id    UUID NOT NULL REFERENCES employee_offboarding (id),
+	employee_asset_id UUID NOT NULL REFERENCES employee_asset_mapping (id),
+	asset_id          UUID NOT NULL REFERENCES asset (id),
+	status            TEXT NOT NULL DEFAULT 'pending',
+	note              TEXT NOT NULL DEFAULT '',
+	resolved_at       TIMESTAMPTZ
+);
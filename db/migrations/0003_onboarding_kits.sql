@@ -0,0 +1,21 @@
+-- Kits are matched against asset categories and employee role or department.
+ALTER TABLE asset ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT '';
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS department TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX asset_category_status_idx ON asset (category, status) WHERE archive_at IS NULL;
+
+CREATE TABLE kit (
+	id         UUID PRIMARY KEY,
+	name       TEXT NOT NULL UNIQUE,
+	role       TEXT NOT NULL DEFAULT '',
+	department TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	archive_at TIMESTAMPTZ
+);
+
+CREATE TABLE kit_item (
+	kit_id   UUID NOT NULL REFERENCES kit (id),
+	category TEXT NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (kit_id, category)
+);
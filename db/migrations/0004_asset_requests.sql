@@ -0,0 +1,19 @@
+-- Requests are approved by the employee's manager first, then by an admin acting as asset manager.
+ALTER TABLE employee ADD COLUMN IF NOT EXISTS manager_id UUID REFERENCES employee (id);
+
+CREATE TABLE asset_request (
+	id                 UUID PRIMARY KEY,
+	employee_id        UUID NOT NULL REFERENCES employee (id),
+	category           TEXT NOT NULL,
+	justification      TEXT NOT NULL DEFAULT '',
+	status             TEXT NOT NULL,
+	manager_id         UUID REFERENCES employee (id),
+	manager_decided_at TIMESTAMPTZ,
+	approver_id        UUID REFERENCES admin (id),
+	decided_at         TIMESTAMPTZ,
+	rejection_reason   TEXT NOT NULL DEFAULT '',
+	employee_asset_id  UUID REFERENCES employee_asset_mapping (id),
+	created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX asset_request_status_idx ON asset_request (status);
@@ -0,0 +1,16 @@
+-- btree_gist lets the exclusion constraint combine UUID equality with range overlap.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE asset_reservation (
+	id          UUID PRIMARY KEY,
+	asset_id    UUID NOT NULL REFERENCES asset (id),
+	employee_id UUID NOT NULL REFERENCES employee (id),
+	during      TSTZRANGE NOT NULL CHECK (NOT isempty(during)),
+	note        TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	archive_at  TIMESTAMPTZ,
+	CONSTRAINT asset_reservation_no_overlap
+		EXCLUDE USING gist (asset_id WITH =, during WITH &&) WHERE (archive_at IS NULL)
+);
+
+CREATE INDEX asset_reservation_employee_idx ON asset_reservation USING gist (employee_id, during);
@@ -0,0 +1,29 @@
+-- Physical identifiers and where an asset is supposed to be, for stocktakes and labels.
+ALTER TABLE asset ADD COLUMN IF NOT EXISTS tag TEXT NOT NULL DEFAULT '';
+ALTER TABLE asset ADD COLUMN IF NOT EXISTS serial TEXT NOT NULL DEFAULT '';
+ALTER TABLE asset ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX asset_tag_idx ON asset (tag) WHERE tag <> '';
+CREATE UNIQUE INDEX asset_serial_idx ON asset (serial) WHERE serial <> '';
+
+CREATE TABLE inventory_audit (
+	id         UUID PRIMARY KEY,
+	name       TEXT NOT NULL,
+	location   TEXT NOT NULL DEFAULT '',
+	category   TEXT NOT NULL DEFAULT '',
+	status     TEXT NOT NULL DEFAULT 'open',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	closed_at  TIMESTAMPTZ
+);
+
+CREATE TABLE audit_scan (
+	id         UUID PRIMARY KEY,
+	audit_id   UUID NOT NULL REFERENCES inventory_audit (id),
+	code       TEXT NOT NULL,
+	location   TEXT NOT NULL DEFAULT '',
+	asset_id   UUID REFERENCES asset (id),
+	result     TEXT NOT NULL,
+	scanned_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_scan_audit_idx ON audit_scan (audit_id);
@@ -0,0 +1,16 @@
+-- API keys let scripts and other services call the API without an interactive login. Only a hash
+-- of each key is stored; the prefix is kept in clear so a key can be found and recognised.
+CREATE TABLE api_key (
+	id           UUID PRIMARY KEY,
+	name         TEXT NOT NULL,
+	prefix       TEXT NOT NULL UNIQUE,
+	hash         TEXT NOT NULL,
+	scopes       TEXT[] NOT NULL DEFAULT '{}',
+	allowed_ips  TEXT[] NOT NULL DEFAULT '{}',
+	created_by   UUID REFERENCES admin (id),
+	expires_at   TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	last_used_ip TEXT NOT NULL DEFAULT '',
+	revoked_at   TIMESTAMPTZ,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,15 @@
+-- Sessions are authenticated by a random token stored only as a hash, instead of by their id.
+-- Existing sessions have no token and can no longer be used, so their admins log in again.
+-- archive_at is when the session ends, which moves forward while it is in use up to expires_at.
+ALTER TABLE admin_session ADD COLUMN IF NOT EXISTS token_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE admin_session ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE admin_session ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMPTZ;
+
+UPDATE admin_session SET expires_at = archive_at, last_seen_at = created_at WHERE expires_at IS NULL;
+
+ALTER TABLE admin_session ALTER COLUMN expires_at SET NOT NULL;
+ALTER TABLE admin_session ALTER COLUMN last_seen_at SET NOT NULL;
+
+CREATE UNIQUE INDEX admin_session_token_idx ON admin_session (token_hash) WHERE token_hash <> '';
+CREATE INDEX admin_session_admin_idx ON admin_session (admin_id);
+CREATE INDEX admin_session_archive_idx ON admin_session (archive_at);
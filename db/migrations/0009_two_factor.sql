@@ -0,0 +1,33 @@
+-- Admins can add a TOTP authenticator as a second factor. The secret has to be kept to check codes;
+-- last_step is the newest time step used, so a code cannot be replayed. confirmed_at stays NULL
+-- until the admin proves the authenticator works, and only then is the second factor required.
+CREATE TABLE admin_totp (
+	admin_id     UUID PRIMARY KEY REFERENCES admin (id),
+	secret       TEXT NOT NULL,
+	confirmed_at TIMESTAMPTZ,
+	last_step    BIGINT NOT NULL DEFAULT 0,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- One-time recovery codes for an admin who lost their authenticator, stored only as hashes
+CREATE TABLE admin_recovery_code (
+	id         UUID PRIMARY KEY,
+	admin_id   UUID NOT NULL REFERENCES admin (id),
+	code_hash  TEXT NOT NULL,
+	used_at    TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (admin_id, code_hash)
+);
+
+-- A login that passed the password check and waits for the second factor. The challenge token is
+-- stored as a hash and is good for a few minutes and a few attempts.
+CREATE TABLE admin_login_challenge (
+	id         UUID PRIMARY KEY,
+	admin_id   UUID NOT NULL REFERENCES admin (id),
+	token_hash TEXT NOT NULL UNIQUE,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX admin_login_challenge_expires_idx ON admin_login_challenge (expires_at);
@@ -0,0 +1,21 @@
+-- Logins through the OpenID Connect provider. An identity is the provider's issuer and subject,
+-- linked to the admin or employee it logs in as the first time it is seen, so later logins do not
+-- depend on the email address staying the same.
+CREATE TABLE oidc_identity (
+	issuer      TEXT NOT NULL,
+	subject     TEXT NOT NULL,
+	admin_id    UUID REFERENCES admin (id),
+	employee_id UUID REFERENCES employee (id),
+	email       TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (issuer, subject),
+	CHECK ((admin_id IS NULL) <> (employee_id IS NULL))
+);
+
+-- Employees get sessions too, for the self-service routes. A session belongs to exactly one admin
+-- or employee.
+ALTER TABLE admin_session ALTER COLUMN admin_id DROP NOT NULL;
+ALTER TABLE admin_session ADD COLUMN IF NOT EXISTS employee_id UUID REFERENCES employee (id);
+ALTER TABLE admin_session ADD CONSTRAINT admin_session_owner_check CHECK ((admin_id IS NULL) <> (employee_id IS NULL));
+
+CREATE INDEX admin_session_employee_idx ON admin_session (employee_id);
@@ -0,0 +1,3 @@
+-- An employee's current assignments are looked up on their own, for /me/assets, without reading
+-- every assignment ever made.
+CREATE INDEX employee_asset_mapping_active_employee_idx ON employee_asset_mapping (employee_id) WHERE archive_at IS NULL;
@@ -0,0 +1,4 @@
+-- An admin can take a request past a manager who cannot decide it, such as one who has left or has
+-- no way to log in. Who did so and why stays on the request.
+ALTER TABLE asset_request ADD COLUMN IF NOT EXISTS escalated_by UUID REFERENCES admin (id);
+ALTER TABLE asset_request ADD COLUMN IF NOT EXISTS escalation_reason TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,5 @@
+-- Asset statuses are a fixed lifecycle, and an asset is with at most one person at a time.
+ALTER TABLE asset ADD CONSTRAINT asset_status_check
+	CHECK (status IN ('available', 'assigned', 'lost', 'written_off'));
+
+CREATE UNIQUE INDEX employee_asset_mapping_active_asset_idx ON employee_asset_mapping (asset_id) WHERE archive_at IS NULL;
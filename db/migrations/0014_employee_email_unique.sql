@@ -0,0 +1,3 @@
+-- An address belongs to one current employee, whatever its case. Leavers keep theirs, so someone
+-- rejoining or a new hire reusing a shared address gets a new row instead of the archived one.
+CREATE UNIQUE INDEX employee_email_active_idx ON employee (lower(email)) WHERE archive_at IS NULL;
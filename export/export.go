@@ -0,0 +1,209 @@
+// Package export streams tabular data as CSV, XLSX or JSON Lines, one row at a time, so large
+// listings never have to be held in memory.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Column is one exportable field of T
+type Column[T any] struct {
+	Name  string
+	Value func(T) interface{}
+}
+
+// Select returns the named columns in the requested order, or every column when names is empty
+func Select[T any](columns []Column[T], names []string) ([]Column[T], error) {
+	if len(names) == 0 {
+		return columns, nil
+	}
+
+	byName := make(map[string]Column[T], len(columns))
+	for _, column := range columns {
+		byName[column.Name] = column
+	}
+
+	selected := make([]Column[T], 0, len(names))
+	for _, name := range names {
+		column, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q; use any of %s", name, strings.Join(Names(columns), ", "))
+		}
+		selected = append(selected, column)
+	}
+	return selected, nil
+}
+
+// Names lists the column names
+func Names[T any](columns []Column[T]) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// Values extracts the row for item
+func Values[T any](columns []Column[T], item T) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = column.Value(item)
+	}
+	return values
+}
+
+// RowWriter writes rows after a header. Close must be called to finish the document.
+type RowWriter interface {
+	WriteRow(values []interface{}) error
+	// Flush pushes buffered rows to the underlying writer
+	Flush() error
+	Close() error
+}
+
+// Format is a supported export format
+type Format struct {
+	ContentType string
+	Extension   string
+	// New starts a document with the given column names
+	New func(w io.Writer, columns []string) (RowWriter, error)
+}
+
+// Formats are the supported export formats, keyed by the name used in ?format=
+var Formats = map[string]Format{
+	"csv":    {ContentType: "text/csv; charset=utf-8", Extension: "csv", New: newCSVWriter},
+	"xlsx":   {ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Extension: "xlsx", New: newXLSXWriter},
+	"ndjson": {ContentType: "application/x-ndjson", Extension: "ndjson", New: newNDJSONWriter},
+}
+
+// FormatNames lists the supported formats in a stable order
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MediaType is the format's content type without parameters, as it appears in an Accept header
+func (f Format) MediaType() string {
+	mediaType, _, _ := strings.Cut(f.ContentType, ";")
+	return mediaType
+}
+
+// formatCell renders a value for the text based formats
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *uuid.UUID:
+		if v == nil {
+			return ""
+		}
+		return v.String()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// textCell renders value like formatCell, but defuses text a spreadsheet would otherwise run as a
+// formula, such as an asset model typed in as =HYPERLINK(...), by prefixing it with a quote
+func textCell(value interface{}) string {
+	cell := formatCell(value)
+	switch value.(type) {
+	case int, int64, float64, bool:
+		return cell
+	}
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+type csvWriter struct {
+	w   *csv.Writer
+	row []string
+}
+
+func newCSVWriter(w io.Writer, columns []string) (RowWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), row: make([]string, len(columns))}
+	if err := cw.w.Write(columns); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		cw.row[i] = textCell(value)
+	}
+	return cw.w.Write(cw.row)
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	return cw.Flush()
+}
+
+type ndjsonWriter struct {
+	w       io.Writer
+	columns []string
+}
+
+func newNDJSONWriter(w io.Writer, columns []string) (RowWriter, error) {
+	return &ndjsonWriter{w: w, columns: columns}, nil
+}
+
+// WriteRow writes one JSON object per line, keeping the selected column order
+func (nw *ndjsonWriter) WriteRow(values []interface{}) error {
+	var line []byte
+	line = append(line, '{')
+	for i, value := range values {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		key, _ := json.Marshal(nw.columns[i])
+		line = append(line, key...)
+		line = append(line, ':')
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		line = append(line, encoded...)
+	}
+	line = append(line, '}', '\n')
+
+	_, err := nw.w.Write(line)
+	return err
+}
+
+func (nw *ndjsonWriter) Flush() error {
+	return nil
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/export"
+)
+
+type item struct {
+	Model     string
+	Count     int
+	Active    bool
+	CreatedAt time.Time
+	ArchiveAt *time.Time
+}
+
+var (
+	columns = []export.Column[item]{
+		{"model", func(i item) interface{} { return i.Model }},
+		{"count", func(i item) interface{} { return i.Count }},
+		{"active", func(i item) interface{} { return i.Active }},
+		{"created_at", func(i item) interface{} { return i.CreatedAt }},
+		{"archive_at", func(i item) interface{} { return i.ArchiveAt }},
+	}
+	createdAt = time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	items     = []item{
+		{Model: "ThinkPad T14", Count: -2, Active: true, CreatedAt: createdAt},
+		{Model: `=HYPERLINK("http://example.com","x")`, CreatedAt: createdAt},
+		{Model: "+44 20 7946 0000", CreatedAt: createdAt},
+		{Model: "-1+1", CreatedAt: createdAt},
+		{Model: "@SUM(A1)", CreatedAt: createdAt},
+	}
+	// want is the text each format should carry for items, formulas defused and numbers left alone
+	want = [][]string{
+		{"model", "count", "active", "created_at", "archive_at"},
+		{"ThinkPad T14", "-2", "true", "2026-03-01T09:30:00Z", ""},
+		{`'=HYPERLINK("http://example.com","x")`, "0", "false", "2026-03-01T09:30:00Z", ""},
+		{"'+44 20 7946 0000", "0", "false", "2026-03-01T09:30:00Z", ""},
+		{"'-1+1", "0", "false", "2026-03-01T09:30:00Z", ""},
+		{"'@SUM(A1)", "0", "false", "2026-03-01T09:30:00Z", ""},
+	}
+)
+
+// write exports items in the named format
+func write(t *testing.T, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := export.Formats[format].New(&buf, export.Names(columns))
+	if err != nil {
+		t.Fatalf("starting %s: %v", format, err)
+	}
+	for _, i := range items {
+		if err := w.WriteRow(export.Values(columns, i)); err != nil {
+			t.Fatalf("writing a %s row: %v", format, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(write(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatalf("reading the CSV back: %v", err)
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %q, want %q", records, want)
+	}
+}
+
+// sheet is the part of a worksheet the test reads back
+type sheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSX(t *testing.T) {
+	body := write(t, "xlsx")
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("opening the workbook: %v", err)
+	}
+
+	parts := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		parts[f.Name] = f
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		if parts[name] == nil {
+			t.Errorf("workbook has no %s", name)
+		}
+	}
+	if parts["xl/worksheets/sheet1.xml"] == nil {
+		return
+	}
+
+	f, err := parts["xl/worksheets/sheet1.xml"].Open()
+	if err != nil {
+		t.Fatalf("opening the sheet: %v", err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("reading the sheet: %v", err)
+	}
+	var got sheet
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parsing the sheet: %v", err)
+	}
+
+	var rows [][]string
+	for _, row := range got.Rows {
+		var cells []string
+		for i, cell := range row.Cells {
+			switch {
+			case cell.Type == "inlineStr":
+				cells = append(cells, cell.Inline)
+			case cell.Type == "b":
+				cells = append(cells, map[string]string{"0": "false", "1": "true"}[cell.Value])
+			case cell.Type == "" && len(rows) > 0 && i == 1:
+				cells = append(cells, cell.Value)
+			default:
+				t.Errorf("row %d cell %d has type %q, want text, or a number in the count column", len(rows), i, cell.Type)
+			}
+		}
+		rows = append(rows, cells)
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("sheet = %q, want %q", rows, want)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	selected, err := export.Select(columns, []string{"created_at", " model"})
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if names := export.Names(selected); !reflect.DeepEqual(names, []string{"created_at", "model"}) {
+		t.Errorf("Select = %q, want created_at and model in that order", names)
+	}
+	if _, err := export.Select(columns, []string{"serial"}); err == nil {
+		t.Error("Select of an unknown column succeeded")
+	}
+}
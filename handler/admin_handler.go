@@ -1,13 +1,13 @@
 package handler
 
 import (
+	"github.com/cameo1221/Go-Asset/auth"
 	"github.com/cameo1221/Go-Asset/middleware"
 	
 	"encoding/json"
 	"fmt"
 	"io"
 	
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -18,38 +18,52 @@ import (
 
 
 type AdminHandler struct {
-	AdminModel *models.AdminModel
+	AdminModel models.AdminRepository
+	Auth       *auth.Authenticator
 }
 
-func NewAdminHandler(adminModel *models.AdminModel) *AdminHandler {
-	return &AdminHandler{AdminModel: adminModel}
+func NewAdminHandler(adminModel models.AdminRepository, authenticator *auth.Authenticator) *AdminHandler {
+	return &AdminHandler{AdminModel: adminModel, Auth: authenticator}
+}
+
+// adminInput is the body of POST and PUT /admins. The password is accepted here but never
+// returned, and only its bcrypt hash is stored. With password login disabled admins have no
+// password and log in through single sign-on.
+type adminInput struct {
+	models.Admin
+	Password string `json:"password"`
 }
 
 func (ah *AdminHandler) createAdmin(w http.ResponseWriter, r *http.Request) {
-	var admin models.Admin
+	var input adminInput
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		return 
 	}
 
 	if len(body) > 0 {
-		err = json.Unmarshal(body, &admin)
+		err = json.Unmarshal(body, &input)
 		if err != nil {
+			http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
 			return 
 		}
 	}
-	if err != nil {
-		if err == io.EOF {
-			http.Error(w, "Request body is empty", http.StatusBadRequest)
+	if !ah.checkPasswordInput(w, input.Password, true) {
+		return
+	}
+
+	admin := input.Admin
+	if input.Password != "" {
+		admin.Password, err = auth.HashPassword(input.Password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
-		return
 	}
 
-	err = ah.AdminModel.CreateAdmin(&admin)
+	err = ah.AdminModel.CreateAdmin(r.Context(), &admin)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating admin: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error creating admin: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -58,25 +72,22 @@ func (ah *AdminHandler) createAdmin(w http.ResponseWriter, r *http.Request) {
 	
 }
 
+// checkPasswordInput answers 400 for a missing password that is required, or for any password
+// while password login is disabled
+func (ah *AdminHandler) checkPasswordInput(w http.ResponseWriter, password string, required bool) bool {
+	switch {
+	case ah.Auth.DisablePasswordLogin && password != "":
+		http.Error(w, "password login is disabled; admins log in with single sign-on", http.StatusBadRequest)
+		return false
+	case !ah.Auth.DisablePasswordLogin && required && password == "":
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 func (ah *AdminHandler) getAllAdmins(w http.ResponseWriter, r *http.Request) {
-    admins, err := ah.AdminModel.GetAllAdmins()
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error getting admins: %v", err), http.StatusInternalServerError)
-        return
-    }
-
-    adminsJSON, err := json.Marshal(admins)
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error marshaling admins to JSON: %v", err), http.StatusInternalServerError)
-        return
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _, err = w.Write(adminsJSON)
-    if err != nil {
-        log.Printf("Error writing response: %v\n", err)
-    }
+    writeJSONArray(w, r, "admins", ah.AdminModel.ForEachAdmin)
 }
 func (ah *AdminHandler) getAdmin(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -88,9 +99,9 @@ func (ah *AdminHandler) getAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	admin, err := ah.AdminModel.GetAdminByID(id)
+	admin, err := ah.AdminModel.GetAdminByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving admin: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error retrieving admin: %v", err), errorStatus(r, err))
 		return
 	}
 	json.NewEncoder(w).Encode(admin)
@@ -107,22 +118,58 @@ func (ah *AdminHandler) updateAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updatedAdmin models.Admin
+	var input adminInput
 
-	err = json.NewDecoder(r.Body).Decode(&updatedAdmin)
+	err = json.NewDecoder(r.Body).Decode(&input)
 	if err != nil {
 		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
 		return
 	}
 
+	if !ah.checkPasswordInput(w, input.Password, false) {
+		return
+	}
+
+	updatedAdmin := input.Admin
 	updatedAdmin.ID = id
 
-	err = ah.AdminModel.UpdateAdmin(&updatedAdmin)
+	// Without a new password the stored hash is kept
+	if input.Password != "" {
+		updatedAdmin.Password, err = auth.HashPassword(input.Password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		existing, err := ah.AdminModel.GetAdminByID(r.Context(), id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error updating admin: %v", err), errorStatus(r, err))
+			return
+		}
+		updatedAdmin.Password = existing.Password
+	}
+
+	err = ah.AdminModel.UpdateAdmin(r.Context(), &updatedAdmin)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating admin: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error updating admin: %v", err), errorStatus(r, err))
 		return
 	}
 
+	// A new password ends the admin's other sessions; the caller's own session gets a new token
+	if input.Password != "" {
+		session, token, err := ah.Auth.ResetSessions(r.Context(), id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error ending sessions: %v", err), errorStatus(r, err))
+			return
+		}
+		if session != nil {
+			if err := ah.Auth.SetRotatedSession(w, session, token); err != nil {
+				http.Error(w, fmt.Sprintf("Error rotating session: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "Admin updated successfully")
 }
@@ -138,9 +185,15 @@ func (ah *AdminHandler) deleteAdmin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ah.AdminModel.ArchiveAdmin(adminID)
+	err = ah.AdminModel.ArchiveAdmin(r.Context(), adminID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting admin: %v", err), errorStatus(r, err))
+		return
+	}
+
+	_, err = ah.Auth.Sessions.ArchiveAdminSessions(r.Context(), adminID, uuid.Nil)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting admin: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error ending admin's sessions: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -152,7 +205,6 @@ func (ah *AdminHandler) deleteAdmin(w http.ResponseWriter, r *http.Request) {
 
 func RegisterAdminRoutes(router *mux.Router, ah *AdminHandler) {
     router.Use(middleware.JSONContentTypeMiddleware)
-    router.Use(middleware.LoggingMiddleware)
 
 	router.HandleFunc("/admins", ah.createAdmin).Methods("POST")
 	router.HandleFunc("/admins", ah.getAllAdmins).Methods("Get")
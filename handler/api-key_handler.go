@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// APIKeyHandler lets admins manage the API keys scripts and services call the API with
+type APIKeyHandler struct {
+	APIKeyModel models.APIKeyRepository
+}
+
+// NewAPIKeyHandler creates a new instance of APIKeyHandler
+func NewAPIKeyHandler(apiKeyModel models.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{APIKeyModel: apiKeyModel}
+}
+
+// apiKeyInput is the body of POST /apikeys
+type apiKeyInput struct {
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// issuedAPIKey is a key as it is returned on creation and rotation, the only time the secret is shown
+type issuedAPIKey struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// writeAPIKeyError maps API key errors onto HTTP statuses
+func writeAPIKeyError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, models.ErrAPIKeyNotFound):
+		http.Error(w, "API key not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrAPIKeyInactive):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Error %s API key: %v", action, err), errorStatus(r, err))
+	}
+}
+
+// newAPIKey generates a key for the admin making the request
+func newAPIKey(r *http.Request) (*models.APIKey, string, error) {
+	secret, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &models.APIKey{Prefix: prefix, Hash: hash}
+	if admin, ok := auth.AdminFromContext(r.Context()); ok {
+		key.CreatedBy = &admin.ID
+	}
+	return key, secret, nil
+}
+
+func (kh *APIKeyHandler) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var input apiKeyInput
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if input.Name == "" || len(input.Scopes) == 0 {
+		http.Error(w, "name and scopes are required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range input.Scopes {
+		if !auth.ValidScope(scope) {
+			http.Error(w, fmt.Sprintf("Unknown scope %q", scope), http.StatusBadRequest)
+			return
+		}
+	}
+	allowedIPs := make([]string, len(input.AllowedIPs))
+	for i, entry := range input.AllowedIPs {
+		prefix, err := auth.ParseAllowedIP(entry)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid allowed IP %q: %v", entry, err), http.StatusBadRequest)
+			return
+		}
+		allowedIPs[i] = prefix.String()
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		http.Error(w, "expires_at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	key, secret, err := newAPIKey(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error creating API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+	key.Name, key.Scopes, key.AllowedIPs, key.ExpiresAt = input.Name, input.Scopes, allowedIPs, input.ExpiresAt
+
+	err = kh.APIKeyModel.CreateAPIKey(r.Context(), key)
+	if err != nil {
+		writeAPIKeyError(w, r, "creating", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(issuedAPIKey{APIKey: key, Key: secret})
+}
+
+func (kh *APIKeyHandler) getAllAPIKeys(w http.ResponseWriter, r *http.Request) {
+	writeJSONArray(w, r, "API keys", kh.APIKeyModel.ForEachAPIKey)
+}
+
+func (kh *APIKeyHandler) getAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	key, err := kh.APIKeyModel.GetAPIKeyByID(r.Context(), id)
+	if err != nil {
+		writeAPIKeyError(w, r, "retrieving", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(key)
+}
+
+// rotateAPIKey issues a replacement for a key. The old key keeps working for the optional
+// grace_period, such as "1h", so callers can switch over; without one it stops at once.
+func (kh *APIKeyHandler) rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	var input struct {
+		GracePeriod string `json:"grace_period"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&input)
+	if err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	var grace time.Duration
+	if input.GracePeriod != "" {
+		grace, err = time.ParseDuration(input.GracePeriod)
+		if err != nil || grace < 0 {
+			http.Error(w, fmt.Sprintf("Invalid grace_period %q", input.GracePeriod), http.StatusBadRequest)
+			return
+		}
+	}
+
+	key, secret, err := newAPIKey(r)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error rotating API key: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	err = kh.APIKeyModel.RotateAPIKey(r.Context(), id, key, time.Now().Add(grace))
+	if err != nil {
+		writeAPIKeyError(w, r, "rotating", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(issuedAPIKey{APIKey: key, Key: secret})
+}
+
+func (kh *APIKeyHandler) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	err = kh.APIKeyModel.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		writeAPIKeyError(w, r, "revoking", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "API key revoked successfully")
+}
+
+// RegisterAPIKeyRoutes registers the API key routes on the provided router. API keys themselves
+// cannot reach these routes, so a leaked key cannot mint more.
+func RegisterAPIKeyRoutes(router *mux.Router, kh *APIKeyHandler) {
+	router.HandleFunc("/apikeys", kh.createAPIKey).Methods("POST")
+	router.HandleFunc("/apikeys", kh.getAllAPIKeys).Methods("GET")
+	router.HandleFunc("/apikeys/{id}", kh.getAPIKey).Methods("GET")
+	router.HandleFunc("/apikeys/{id}/rotate", kh.rotateAPIKey).Methods("POST")
+	router.HandleFunc("/apikeys/{id}", kh.revokeAPIKey).Methods("DELETE")
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// AssetRequestHandler handles employee equipment requests and their approval
+type AssetRequestHandler struct {
+	AssetRequestModel models.AssetRequestRepository
+}
+
+// NewAssetRequestHandler creates a new instance of AssetRequestHandler
+func NewAssetRequestHandler(assetRequestModel models.AssetRequestRepository) *AssetRequestHandler {
+	return &AssetRequestHandler{AssetRequestModel: assetRequestModel}
+}
+
+// writeAssetRequestError maps workflow errors onto HTTP statuses
+func writeAssetRequestError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, models.ErrAssetRequestNotFound):
+		http.Error(w, "Asset request not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrEmployeeNotFound):
+		http.Error(w, "Employee not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrNotApprover):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, models.ErrAssetRequestClosed), errors.Is(err, models.ErrNoAssetAvailable),
+		errors.Is(err, models.ErrNotWaitingForManager):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Error %s asset request: %v", action, err), errorStatus(r, err))
+	}
+}
+
+func (arh *AssetRequestHandler) createAssetRequest(w http.ResponseWriter, r *http.Request) {
+	var request models.AssetRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if request.EmployeeID == uuid.Nil || request.Category == "" {
+		http.Error(w, "employee_id and category are required", http.StatusBadRequest)
+		return
+	}
+
+	err = arh.AssetRequestModel.CreateAssetRequest(r.Context(), &request)
+	if err != nil {
+		writeAssetRequestError(w, r, "creating", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+func (arh *AssetRequestHandler) getAllAssetRequests(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	writeJSONArray(w, r, "asset requests", func(ctx context.Context, fn func(*models.AssetRequest) error) error {
+		return arh.AssetRequestModel.ForEachAssetRequest(ctx, status, fn)
+	})
+}
+
+func (arh *AssetRequestHandler) getAssetRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset request ID", http.StatusBadRequest)
+		return
+	}
+
+	request, err := arh.AssetRequestModel.GetAssetRequestByID(r.Context(), id)
+	if err != nil {
+		writeAssetRequestError(w, r, "retrieving", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request)
+}
+
+// approveAssetRequest is an admin's decision on a request its manager has approved, optionally
+// naming the asset to assign
+func (arh *AssetRequestHandler) approveAssetRequest(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.AdminFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only admins can approve requests for assets", http.StatusForbidden)
+		return
+	}
+
+	var decision struct {
+		AssetID *uuid.UUID `json:"asset_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&decision)
+	if err != nil && err != io.EOF {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	arh.approve(w, r, admin.ID, decision.AssetID)
+}
+
+func (arh *AssetRequestHandler) rejectAssetRequest(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.AdminFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only admins can reject requests for assets", http.StatusForbidden)
+		return
+	}
+
+	arh.reject(w, r, admin.ID)
+}
+
+// escalateAssetRequest takes a request past a manager who cannot decide it, such as one who has
+// left or cannot log in, so the admins can decide it instead
+func (arh *AssetRequestHandler) escalateAssetRequest(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.AdminFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only admins can escalate requests for assets", http.StatusForbidden)
+		return
+	}
+
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset request ID", http.StatusBadRequest)
+		return
+	}
+
+	var escalation struct {
+		Reason string `json:"reason"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&escalation)
+	if err != nil {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+	if escalation.Reason == "" {
+		http.Error(w, "An escalation reason is required", http.StatusBadRequest)
+		return
+	}
+
+	request, err := arh.AssetRequestModel.EscalateAssetRequest(r.Context(), id, admin.ID, escalation.Reason)
+	if err != nil {
+		writeAssetRequestError(w, r, "escalating", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request)
+}
+
+// createMyAssetRequest files a request for the calling employee
+func (arh *AssetRequestHandler) createMyAssetRequest(w http.ResponseWriter, r *http.Request) {
+	employee, ok := auth.EmployeeFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only employees can file their own requests; admins use POST /assetrequests", http.StatusBadRequest)
+		return
+	}
+
+	var request models.AssetRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if request.Category == "" {
+		http.Error(w, "category is required", http.StatusBadRequest)
+		return
+	}
+	request.EmployeeID = employee.ID
+
+	err = arh.AssetRequestModel.CreateAssetRequest(r.Context(), &request)
+	if err != nil {
+		writeAssetRequestError(w, r, "creating", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(request)
+}
+
+// approveAsManager is the calling employee's approval of a request from someone they manage
+func (arh *AssetRequestHandler) approveAsManager(w http.ResponseWriter, r *http.Request) {
+	employee, ok := auth.EmployeeFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only managers can approve requests here; admins use /assetrequests/{id}/approve", http.StatusForbidden)
+		return
+	}
+
+	arh.approve(w, r, employee.ID, nil)
+}
+
+// rejectAsManager is the calling employee's rejection of a request from someone they manage
+func (arh *AssetRequestHandler) rejectAsManager(w http.ResponseWriter, r *http.Request) {
+	employee, ok := auth.EmployeeFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only managers can reject requests here; admins use /assetrequests/{id}/reject", http.StatusForbidden)
+		return
+	}
+
+	arh.reject(w, r, employee.ID)
+}
+
+// approve records approverID's approval of the request in the URL; the model checks they are the
+// approver for the request's current stage
+func (arh *AssetRequestHandler) approve(w http.ResponseWriter, r *http.Request, approverID uuid.UUID, assetID *uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset request ID", http.StatusBadRequest)
+		return
+	}
+
+	request, err := arh.AssetRequestModel.ApproveAssetRequest(r.Context(), id, approverID, assetID)
+	if err != nil {
+		writeAssetRequestError(w, r, "approving", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request)
+}
+
+// reject records approverID's rejection of the request in the URL, with the reason from the body
+func (arh *AssetRequestHandler) reject(w http.ResponseWriter, r *http.Request, approverID uuid.UUID) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset request ID", http.StatusBadRequest)
+		return
+	}
+
+	var decision struct {
+		Reason string `json:"reason"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&decision)
+	if err != nil {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	if decision.Reason == "" {
+		http.Error(w, "A rejection reason is required", http.StatusBadRequest)
+		return
+	}
+
+	request, err := arh.AssetRequestModel.RejectAssetRequest(r.Context(), id, approverID, decision.Reason)
+	if err != nil {
+		writeAssetRequestError(w, r, "rejecting", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(request)
+}
+
+// RegisterAssetRequestRoutes registers the asset request routes on the provided router
+func RegisterAssetRequestRoutes(router *mux.Router, arh *AssetRequestHandler) {
+	router.HandleFunc("/assetrequests", arh.createAssetRequest).Methods("POST")
+	router.HandleFunc("/assetrequests", arh.getAllAssetRequests).Methods("GET")
+	router.HandleFunc("/assetrequests/{id}", arh.getAssetRequest).Methods("GET")
+	router.HandleFunc("/assetrequests/{id}/approve", arh.approveAssetRequest).Methods("POST")
+	router.HandleFunc("/assetrequests/{id}/reject", arh.rejectAssetRequest).Methods("POST")
+	router.HandleFunc("/assetrequests/{id}/escalate", arh.escalateAssetRequest).Methods("POST")
+
+	// Employees file their own requests and managers decide the first stage with an employee session
+	router.HandleFunc("/me/asset-requests", arh.createMyAssetRequest).Methods("POST")
+	router.HandleFunc("/me/asset-requests/{id}/approve", arh.approveAsManager).Methods("POST")
+	router.HandleFunc("/me/asset-requests/{id}/reject", arh.rejectAsManager).Methods("POST")
+}
@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAssetRequestApprovers(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		authenticator := newAuthenticator(repos)
+		router := newRouter(repos, nil)
+
+		employeeToken := func(employee *models.Employee) string {
+			t.Helper()
+			_, token, err := authenticator.StartEmployeeSession(ctx, employee.ID)
+			if err != nil {
+				t.Fatalf("StartEmployeeSession: %v", err)
+			}
+			return token
+		}
+		ada, barbara := employeeToken(f.employee), employeeToken(f.manager)
+
+		do := func(path, body, token string) (int, *models.AssetRequest) {
+			t.Helper()
+			req := httptest.NewRequest("POST", path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			var request models.AssetRequest
+			if rec.Code < 300 {
+				if err := json.NewDecoder(rec.Body).Decode(&request); err != nil {
+					t.Fatalf("decoding %s: %v", path, err)
+				}
+			}
+			return rec.Code, &request
+		}
+
+		// Ada files for herself; an employee_id in the body is ignored
+		code, request := do("/me/asset-requests", `{"employee_id":"`+f.spare.ID.String()+`","category":"laptop"}`, ada)
+		if code != http.StatusCreated || request.EmployeeID != f.employee.ID || request.Status != models.AssetRequestPendingManager {
+			t.Fatalf("filing a request = %d %+v, want Ada's request waiting for her manager", code, request)
+		}
+		path := "/me/asset-requests/" + request.ID.String()
+
+		if code, _ := do(path+"/approve", "", ada); code != http.StatusForbidden {
+			t.Errorf("approving her own request = %d, want 403", code)
+		}
+		if code, _ := do("/assetrequests/"+request.ID.String()+"/approve", `{"approver_id":"`+f.manager.ID.String()+`"}`, f.token); code != http.StatusForbidden {
+			t.Errorf("admin naming the manager as approver = %d, want 403", code)
+		}
+		if code, _ := do("/assetrequests/"+request.ID.String()+"/approve", "", barbara); code != http.StatusForbidden {
+			t.Errorf("manager on the admin route = %d, want 403", code)
+		}
+
+		code, request = do(path+"/approve", "", barbara)
+		if code != http.StatusOK || request.Status != models.AssetRequestPendingAssetManager {
+			t.Fatalf("manager approval = %d %+v, want it passed to asset managers", code, request)
+		}
+		if code, _ := do(path+"/reject", `{"reason":"changed my mind"}`, barbara); code != http.StatusForbidden {
+			t.Errorf("manager deciding the admin stage = %d, want 403", code)
+		}
+
+		// Ada's offboarding is open in the fixtures, so nothing more can be handed to her
+		if code, _ := do("/assetrequests/"+request.ID.String()+"/approve", "", f.token); code != http.StatusConflict {
+			t.Errorf("admin approval for someone leaving = %d, want 409", code)
+		}
+		code, request = do("/assetrequests/"+request.ID.String()+"/reject", `{"reason":"leaving"}`, f.token)
+		if code != http.StatusOK || request.Status != models.AssetRequestRejected || request.ApproverID == nil || *request.ApproverID != f.admin.ID {
+			t.Errorf("admin rejection = %d %+v, want it rejected by %s", code, request, f.admin.ID)
+		}
+
+		if code, _ := do("/me/asset-requests/"+uuid.NewString()+"/approve", "", barbara); code != http.StatusNotFound {
+			t.Errorf("approving a missing request = %d, want 404", code)
+		}
+	})
+}
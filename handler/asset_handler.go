@@ -3,11 +3,11 @@ package handler
 import (
 	
 	"github.com/cameo1221/Go-Asset/middleware"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -17,10 +17,10 @@ import (
 )
 
 type AssetHandler struct {
-	AssetModel *models.AssetModel
+	AssetModel models.AssetRepository
 }
 
-func NewAssetHandler(assetModel *models.AssetModel) *AssetHandler {
+func NewAssetHandler(assetModel models.AssetRepository) *AssetHandler {
 	return &AssetHandler{AssetModel: assetModel}
 }
 
@@ -46,9 +46,9 @@ func (ah *AssetHandler) createAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ah.AssetModel.CreateAsset(&asset)
+	err = ah.AssetModel.CreateAsset(r.Context(), &asset)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating asset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error creating asset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -57,25 +57,35 @@ func (ah *AssetHandler) createAsset(w http.ResponseWriter, r *http.Request) {
 	
 }
 
+// assetFilterFromQuery reads the status, category, location and company list filters
+func assetFilterFromQuery(r *http.Request) models.AssetFilter {
+	query := r.URL.Query()
+	return models.AssetFilter{
+		Status:   query.Get("status"),
+		Category: query.Get("category"),
+		Location: query.Get("location"),
+		Company:  query.Get("company"),
+	}
+}
+
 func (ah *AssetHandler) getAllAssets(w http.ResponseWriter, r *http.Request) {
-    assets, err := ah.AssetModel.GetAllAssets()
+    filter := assetFilterFromQuery(r)
+
+    format, err := exportFormat(r)
     if err != nil {
-        http.Error(w, fmt.Sprintf("Error getting assets: %v", err), http.StatusInternalServerError)
+        exportFormatError(w, err)
         return
     }
-
-    assetsJSON, err := json.Marshal(assets)
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error marshaling assets to JSON: %v", err), http.StatusInternalServerError)
+    if format != "" {
+        writeExport(w, r, format, "assets", assetExportColumns, func(ctx context.Context, fn func(*models.Asset) error) error {
+            return ah.AssetModel.ForEachAsset(ctx, filter, fn)
+        })
         return
     }
 
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _, err = w.Write(assetsJSON)
-    if err != nil {
-        log.Printf("Error writing response: %v\n", err)
-    }
+    writeJSONArray(w, r, "assets", func(ctx context.Context, fn func(*models.Asset) error) error {
+        return ah.AssetModel.ForEachAsset(ctx, filter, fn)
+    })
 }
 func (ah *AssetHandler) getAsset(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -87,9 +97,9 @@ func (ah *AssetHandler) getAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	asset, err := ah.AssetModel.GetAssetByID(id)
+	asset, err := ah.AssetModel.GetAssetByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving asset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error retrieving asset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -115,9 +125,9 @@ func (ah *AssetHandler) updateAsset(w http.ResponseWriter, r *http.Request) {
 
 	updatedAsset.Id = id
 
-	err = ah.AssetModel.UpdateAsset(&updatedAsset)
+	err = ah.AssetModel.UpdateAsset(r.Context(), &updatedAsset)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating asset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error updating asset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -136,9 +146,9 @@ func (ah *AssetHandler) deleteAsset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = ah.AssetModel.ArchiveAsset(assetID)
+	err = ah.AssetModel.ArchiveAsset(r.Context(), assetID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting asset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error deleting asset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -152,7 +162,6 @@ func (ah *AssetHandler) deleteAsset(w http.ResponseWriter, r *http.Request) {
 
 func RegisterAssetRoutes(router *mux.Router, ah *AssetHandler) {
 	router.Use(middleware.JSONContentTypeMiddleware)
-    router.Use(middleware.LoggingMiddleware)
 	router.HandleFunc("/assets", ah.createAsset).Methods("POST")
 	router.HandleFunc("/assets", ah.getAllAssets).Methods("Get")
 	router.HandleFunc("/assets/{id}", ah.getAsset).Methods("GET")
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// AuditHandler handles physical inventory audits (stocktakes)
+type AuditHandler struct {
+	AuditModel models.AuditRepository
+}
+
+// NewAuditHandler creates a new instance of AuditHandler
+func NewAuditHandler(auditModel models.AuditRepository) *AuditHandler {
+	return &AuditHandler{AuditModel: auditModel}
+}
+
+// writeAuditError maps audit errors onto HTTP statuses
+func writeAuditError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, models.ErrAuditNotFound):
+		http.Error(w, "Audit not found", http.StatusNotFound)
+	case errors.Is(err, models.ErrAuditClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Error %s: %v", action, err), errorStatus(r, err))
+	}
+}
+
+func (ah *AuditHandler) createAudit(w http.ResponseWriter, r *http.Request) {
+	var audit models.Audit
+	err := json.NewDecoder(r.Body).Decode(&audit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if audit.Name == "" {
+		http.Error(w, "Audit name is required", http.StatusBadRequest)
+		return
+	}
+
+	err = ah.AuditModel.CreateAudit(r.Context(), &audit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error creating audit: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(audit)
+}
+
+func (ah *AuditHandler) getAllAudits(w http.ResponseWriter, r *http.Request) {
+	writeJSONArray(w, r, "audits", ah.AuditModel.ForEachAudit)
+}
+
+func (ah *AuditHandler) getAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid audit ID", http.StatusBadRequest)
+		return
+	}
+
+	audit, err := ah.AuditModel.GetAuditByID(r.Context(), id)
+	if err != nil {
+		writeAuditError(w, r, "retrieving audit", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(audit)
+}
+
+func (ah *AuditHandler) createScan(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid audit ID", http.StatusBadRequest)
+		return
+	}
+
+	var scan models.AuditScan
+	err = json.NewDecoder(r.Body).Decode(&scan)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if scan.Code == "" {
+		http.Error(w, "Scanned code is required", http.StatusBadRequest)
+		return
+	}
+
+	scan.AuditID = id
+
+	err = ah.AuditModel.RecordScan(r.Context(), &scan)
+	if err != nil {
+		writeAuditError(w, r, "recording scan", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scan)
+}
+
+func (ah *AuditHandler) getAuditReport(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid audit ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := ah.AuditModel.GetAuditReport(r.Context(), id)
+	if err != nil {
+		writeAuditError(w, r, "building audit report", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// closeAudit closes an audit: POST /audits/{id}/close[?mark_missing_lost=true]
+func (ah *AuditHandler) closeAudit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid audit ID", http.StatusBadRequest)
+		return
+	}
+
+	markMissingLost := false
+	if v := r.URL.Query().Get("mark_missing_lost"); v != "" {
+		markMissingLost, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid mark_missing_lost flag", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := ah.AuditModel.CloseAudit(r.Context(), id, markMissingLost)
+	if err != nil {
+		writeAuditError(w, r, "closing audit", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// RegisterAuditRoutes registers the inventory audit routes on the provided router
+func RegisterAuditRoutes(router *mux.Router, ah *AuditHandler) {
+	router.HandleFunc("/audits", ah.createAudit).Methods("POST")
+	router.HandleFunc("/audits", ah.getAllAudits).Methods("GET")
+	router.HandleFunc("/audits/{id}", ah.getAudit).Methods("GET")
+	router.HandleFunc("/audits/{id}/scans", ah.createScan).Methods("POST")
+	router.HandleFunc("/audits/{id}/report", ah.getAuditReport).Methods("GET")
+	router.HandleFunc("/audits/{id}/close", ah.closeAudit).Methods("POST")
+}
@@ -0,0 +1,611 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/handler"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAuthentication(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		get := func(authorization string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/assets", nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := get("")
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("no credentials = %d with WWW-Authenticate %q, want 401 with a challenge", rec.Code, rec.Header().Get("WWW-Authenticate"))
+		}
+		// The session id is not a credential; only its token is
+		for _, authorization := range []string{"Bearer nope", "Basic Z3JhY2U6c2VjcmV0", "Bearer " + f.admin.ID.String(), "Bearer " + f.session.ID.String()} {
+			if rec := get(authorization); rec.Code != http.StatusUnauthorized {
+				t.Errorf("Authorization %q = %d, want 401", authorization, rec.Code)
+			}
+		}
+		if rec := get("bearer " + f.token); rec.Code != http.StatusOK {
+			t.Errorf("valid session = %d, want 200: %s", rec.Code, rec.Body)
+		}
+
+		// Log in and use the new session
+		req := httptest.NewRequest("POST", "/sessions", strings.NewReader(`{"email":"grace@example.com","password":"secret"}`))
+		rec = httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("login = %d, want 201: %s", rec.Code, rec.Body)
+		}
+		var login struct {
+			models.Session
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&login); err != nil {
+			t.Fatalf("decoding session: %v", err)
+		}
+		if login.Token == "" || strings.Contains(rec.Body.String(), "token_hash") {
+			t.Errorf("login returned token %q, want a token and no hash", login.Token)
+		}
+		if rec := get("Bearer " + login.Token); rec.Code != http.StatusOK {
+			t.Errorf("new session = %d, want 200", rec.Code)
+		}
+
+		// Ended sessions are refused
+		if err := repos.Sessions.ArchiveSession(ctx, login.ID); err != nil {
+			t.Fatalf("ArchiveSession: %v", err)
+		}
+		if rec := get("Bearer " + login.Token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("ended session = %d, want 401", rec.Code)
+		}
+
+		// So are sessions of archived admins
+		if err := repos.Admins.ArchiveAdmin(ctx, f.admin.ID); err != nil {
+			t.Fatalf("ArchiveAdmin: %v", err)
+		}
+		if rec := get("Bearer " + f.token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("archived admin's session = %d, want 401", rec.Code)
+		}
+	})
+}
+
+func TestSessionManagement(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		do := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		// Renewing ignores any expiry asked for
+		rec := do("PUT", "/sessions/"+f.session.ID.String(), `{"archive_at":"2099-01-01T00:00:00Z"}`, f.token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("renew session = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		var renewed models.Session
+		if err := json.NewDecoder(rec.Body).Decode(&renewed); err != nil {
+			t.Fatalf("decoding session: %v", err)
+		}
+		if renewed.Archive_at.After(time.Now().Add(auth.DefaultSessionTimeouts.Idle + time.Minute)) {
+			t.Errorf("renewed session ends at %v, want within the idle timeout", renewed.Archive_at)
+		}
+
+		// Only the owner can renew a session, and only while it lasts
+		other := &models.Admin{Name: "Ada", Email: "ada@example.com", Password: passwordHash, CreatedAt: time.Now()}
+		if err := repos.Admins.CreateAdmin(ctx, other); err != nil {
+			t.Fatalf("CreateAdmin: %v", err)
+		}
+		otherSession := &models.Session{AdminID: other.ID}
+		if err := repos.Sessions.CreateSession(ctx, otherSession); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if rec := do("PUT", "/sessions/"+otherSession.ID.String(), "", f.token); rec.Code != http.StatusForbidden {
+			t.Errorf("renew another admin's session = %d, want 403", rec.Code)
+		}
+		ended := &models.Session{AdminID: f.admin.ID}
+		if err := repos.Sessions.CreateSession(ctx, ended); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		if err := repos.Sessions.ArchiveSession(ctx, ended.ID); err != nil {
+			t.Fatalf("ArchiveSession: %v", err)
+		}
+		if rec := do("PUT", "/sessions/"+ended.ID.String(), "", f.token); rec.Code != http.StatusConflict {
+			t.Errorf("renew ended session = %d, want 409", rec.Code)
+		}
+
+		// A new password ends the admin's other sessions and rotates this one
+		_, elsewhere, err := newAuthenticator(repos).StartSession(ctx, f.admin.ID)
+		if err != nil {
+			t.Fatalf("StartSession: %v", err)
+		}
+		rec = do("PUT", "/admins/"+f.admin.ID.String(), `{"name":"Grace","email":"grace@example.com","password":"n3w"}`, f.token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("change password = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		rotated := rec.Header().Get(auth.SessionTokenHeader)
+		if rotated == "" || rotated == f.token {
+			t.Fatalf("change password returned token %q, want a new one", rotated)
+		}
+		for name, token := range map[string]string{"old token": f.token, "other session": elsewhere} {
+			if rec := do("GET", "/assets", "", token); rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s after password change = %d, want 401", name, rec.Code)
+			}
+		}
+		if rec := do("GET", "/assets", "", rotated); rec.Code != http.StatusOK {
+			t.Errorf("rotated token = %d, want 200", rec.Code)
+		}
+
+		// Logging out everywhere ends the calling session too
+		rec = do("DELETE", "/sessions", "", rotated)
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"ended":1`) {
+			t.Fatalf("log out everywhere = %d %s, want 200 ending 1 session", rec.Code, rec.Body)
+		}
+		if rec := do("GET", "/assets", "", rotated); rec.Code != http.StatusUnauthorized {
+			t.Errorf("token after logging out everywhere = %d, want 401", rec.Code)
+		}
+	})
+}
+
+func TestTwoFactor(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		do := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+		login := func() string {
+			t.Helper()
+			rec := do("POST", "/sessions", `{"email":"grace@example.com","password":"secret"}`, "")
+			var pending struct {
+				SecondFactor string `json:"second_factor"`
+				Challenge    string `json:"challenge"`
+			}
+			if rec.Code != http.StatusAccepted || json.NewDecoder(rec.Body).Decode(&pending) != nil || pending.Challenge == "" {
+				t.Fatalf("login = %d %s, want 202 with a challenge", rec.Code, rec.Body)
+			}
+			if len(rec.Result().Cookies()) > 0 {
+				t.Errorf("login waiting for the second factor set cookies %v", rec.Result().Cookies())
+			}
+			return pending.Challenge
+		}
+		verify := func(challenge, code string) *httptest.ResponseRecorder {
+			return do("POST", "/sessions/2fa", `{"challenge":"`+challenge+`","code":"`+code+`"}`, "")
+		}
+
+		// Enroll and confirm an authenticator
+		rec := do("POST", "/2fa", "", f.token)
+		var enrollment auth.TOTPEnrollment
+		if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&enrollment) != nil {
+			t.Fatalf("enroll = %d %s, want 201", rec.Code, rec.Body)
+		}
+		if !strings.HasPrefix(enrollment.URL, "otpauth://totp/") || len(enrollment.QRCode) == 0 {
+			t.Errorf("enrollment = %+v, want an otpauth URL and a QR code", enrollment)
+		}
+		if rec := do("POST", "/2fa/confirm", `{"code":"000000"}`, f.token); rec.Code != http.StatusBadRequest {
+			t.Errorf("confirm with a wrong code = %d, want 400", rec.Code)
+		}
+		code, err := auth.TOTPCode(enrollment.Secret, time.Now())
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		rec = do("POST", "/2fa/confirm", `{"code":"`+code+`"}`, f.token)
+		var recovery struct {
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&recovery) != nil || len(recovery.RecoveryCodes) != 10 {
+			t.Fatalf("confirm = %d %s, want 200 with 10 recovery codes", rec.Code, rec.Body)
+		}
+
+		// Turning it on rotates the session
+		token := rec.Header().Get(auth.SessionTokenHeader)
+		if rec := do("GET", "/2fa", "", f.token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("session token from before 2FA = %d, want 401", rec.Code)
+		}
+		if rec := do("GET", "/2fa", "", token); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"enabled":true`) {
+			t.Errorf("2FA status = %d %s, want enabled", rec.Code, rec.Body)
+		}
+		if rec := do("POST", "/2fa", "", token); rec.Code != http.StatusConflict {
+			t.Errorf("enrolling again = %d, want 409", rec.Code)
+		}
+
+		// Logging in now takes a code; the one used to confirm cannot be replayed
+		challenge := login()
+		if rec := verify(challenge, code); rec.Code != http.StatusUnauthorized {
+			t.Errorf("replayed code = %d, want 401", rec.Code)
+		}
+		next, err := auth.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if rec := verify(challenge, next); rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"token"`) {
+			t.Fatalf("verify with the next code = %d %s, want 201 with a session", rec.Code, rec.Body)
+		}
+		if rec := verify(challenge, next); rec.Code != http.StatusUnauthorized {
+			t.Errorf("reusing the challenge = %d, want 401", rec.Code)
+		}
+
+		// A recovery code works once
+		if rec := verify(login(), strings.ToUpper(recovery.RecoveryCodes[0])); rec.Code != http.StatusCreated {
+			t.Errorf("verify with a recovery code = %d %s, want 201", rec.Code, rec.Body)
+		}
+		if rec := verify(login(), recovery.RecoveryCodes[0]); rec.Code != http.StatusUnauthorized {
+			t.Errorf("reusing a recovery code = %d, want 401", rec.Code)
+		}
+
+		// A challenge that already finished a login is claimed before any code is checked, so
+		// replaying it does not spend the code it is given
+		spent := login()
+		if rec := verify(spent, recovery.RecoveryCodes[1]); rec.Code != http.StatusCreated {
+			t.Fatalf("verify with a recovery code = %d %s, want 201", rec.Code, rec.Body)
+		}
+		if rec := verify(spent, recovery.RecoveryCodes[2]); rec.Code != http.StatusUnauthorized {
+			t.Errorf("replaying a finished challenge = %d, want 401", rec.Code)
+		}
+		if rec := do("GET", "/2fa", "", token); !strings.Contains(rec.Body.String(), `"recovery_codes_remaining":8`) {
+			t.Errorf("2FA status = %s, want 8 recovery codes left", rec.Body)
+		}
+		if rec := verify(login(), recovery.RecoveryCodes[2]); rec.Code != http.StatusCreated {
+			t.Errorf("recovery code offered to a finished challenge = %d %s, want it still usable", rec.Code, rec.Body)
+		}
+
+		// Another admin resets it, which ends the sessions it protected
+		other := &models.Admin{Name: "Ada", Email: "ada@example.com", Password: passwordHash, CreatedAt: time.Now()}
+		if err := repos.Admins.CreateAdmin(ctx, other); err != nil {
+			t.Fatalf("CreateAdmin: %v", err)
+		}
+		_, otherToken, err := newAuthenticator(repos).StartSession(ctx, other.ID)
+		if err != nil {
+			t.Fatalf("StartSession: %v", err)
+		}
+		if rec := do("DELETE", "/admins/"+f.admin.ID.String()+"/2fa", "", otherToken); rec.Code != http.StatusOK {
+			t.Fatalf("reset 2FA = %d %s, want 200", rec.Code, rec.Body)
+		}
+		if rec := do("GET", "/2fa", "", token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("session after 2FA reset = %d, want 401", rec.Code)
+		}
+		if rec := do("POST", "/sessions", `{"email":"grace@example.com","password":"secret"}`, ""); rec.Code != http.StatusCreated {
+			t.Errorf("login after 2FA reset = %d, want 201", rec.Code)
+		}
+	})
+}
+
+func TestSecondFactorLockout(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+		authenticator := newAuthenticator(repos)
+
+		enrollment, err := authenticator.EnrollTOTP(ctx, f.admin)
+		if err != nil {
+			t.Fatalf("EnrollTOTP: %v", err)
+		}
+		code, err := auth.TOTPCode(enrollment.Secret, time.Now().Add(-30*time.Second))
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if _, err := authenticator.ConfirmTOTP(ctx, f.admin.ID, code); err != nil {
+			t.Fatalf("ConfirmTOTP: %v", err)
+		}
+
+		_, _, err = authenticator.Login(ctx, "grace@example.com", password)
+		var required *auth.SecondFactorRequired
+		if !errors.As(err, &required) {
+			t.Fatalf("Login error = %v, want SecondFactorRequired", err)
+		}
+
+		// Wrong codes count towards the lockout newRouter applies after three failures
+		verify := func(code string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/sessions/2fa", strings.NewReader(`{"challenge":"`+required.Challenge+`","code":"`+code+`"}`))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+		for i := 1; i <= 2; i++ {
+			if rec := verify("000000"); rec.Code != http.StatusUnauthorized {
+				t.Fatalf("wrong code %d = %d, want 401", i, rec.Code)
+			}
+		}
+		if rec := verify("000000"); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("third wrong code = %d, want 429", rec.Code)
+		}
+		code, err = auth.TOTPCode(enrollment.Secret, time.Now())
+		if err != nil {
+			t.Fatalf("TOTPCode: %v", err)
+		}
+		if rec := verify(code); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("right code while locked = %d, want 429", rec.Code)
+		}
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		seed(t, repos)
+		router := newRouter(repos, nil)
+
+		login := func(password string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("POST", "/sessions", strings.NewReader(`{"email":"grace@example.com","password":"`+password+`"}`))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		// newRouter locks an account after three failures in a row
+		for i := 1; i <= 2; i++ {
+			if rec := login("guess"); rec.Code != http.StatusUnauthorized {
+				t.Fatalf("failure %d = %d, want 401", i, rec.Code)
+			}
+		}
+		rec := login("guess")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+			t.Fatalf("third failure = %d with Retry-After %q, want 429 after 60s", rec.Code, rec.Header().Get("Retry-After"))
+		}
+
+		// The right password does not get through the lock
+		if rec := login(password); rec.Code != http.StatusTooManyRequests {
+			t.Errorf("login while locked = %d, want 429", rec.Code)
+		}
+	})
+}
+
+func TestCookieSessionCSRF(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		seed(t, repos)
+		router := newRouter(repos, nil)
+
+		req := httptest.NewRequest("POST", "/sessions", strings.NewReader(`{"email":"grace@example.com","password":"secret"}`))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("login = %d, want 201: %s", rec.Code, rec.Body)
+		}
+
+		cookies := map[string]*http.Cookie{}
+		for _, cookie := range rec.Result().Cookies() {
+			cookies[cookie.Name] = cookie
+		}
+		session, csrf := cookies[auth.SessionCookie], cookies[auth.CSRFCookie]
+		if session == nil || csrf == nil {
+			t.Fatalf("login set cookies %v, want the session and CSRF cookies", cookies)
+		}
+		if !session.HttpOnly || csrf.HttpOnly || session.SameSite != http.SameSiteLaxMode {
+			t.Errorf("session cookie %+v must be HttpOnly and SameSite=Lax; CSRF cookie %+v must be readable", session, csrf)
+		}
+
+		send := func(method, path, body, token string) int {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.AddCookie(session)
+			req.AddCookie(csrf)
+			if token != "" {
+				req.Header.Set(auth.CSRFHeader, token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		asset := `{"Model":"XPS","Company":"Dell","Serial":"SN-9"}`
+		if code := send("GET", "/assets", "", ""); code != http.StatusOK {
+			t.Errorf("GET with the session cookie = %d, want 200", code)
+		}
+		if code := send("POST", "/assets", asset, ""); code != http.StatusForbidden {
+			t.Errorf("POST without a CSRF token = %d, want 403", code)
+		}
+		if code := send("POST", "/assets", asset, "forged"); code != http.StatusForbidden {
+			t.Errorf("POST with the wrong CSRF token = %d, want 403", code)
+		}
+		if code := send("POST", "/assets", asset, csrf.Value); code != http.StatusCreated {
+			t.Errorf("POST with the CSRF token = %d, want 201", code)
+		}
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		do := func(method, path, body string, header http.Header, remoteAddr string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			for name, values := range header {
+				req.Header[name] = values
+			}
+			if remoteAddr != "" {
+				req.RemoteAddr = remoteAddr
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+		asAdmin := http.Header{"Authorization": {"Bearer " + f.token}}
+		withKey := func(key string) http.Header { return http.Header{"X-Api-Key": {key}} }
+
+		// An admin creates a read-only key for one network; the secret is shown once
+		rec := do("POST", "/apikeys", `{"name":"mdm","scopes":["assets:read"],"allowed_ips":["192.0.2.0/24"]}`, asAdmin, "")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create API key = %d, want 201: %s", rec.Code, rec.Body)
+		}
+		var created struct {
+			models.APIKey
+			Key string `json:"key"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+			t.Fatalf("decoding API key: %v", err)
+		}
+		if !strings.HasPrefix(created.Key, created.Prefix+"_") || !strings.HasPrefix(created.Prefix, auth.APIKeyPrefix) {
+			t.Fatalf("key %q does not start with its prefix %q", created.Key, created.Prefix)
+		}
+		stored, err := repos.APIKeys.GetAPIKeyByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetAPIKeyByID: %v", err)
+		}
+		if stored.Hash == created.Key || stored.Hash != auth.HashAPIKey(created.Key) {
+			t.Errorf("stored hash %q is not the hash of the key", stored.Hash)
+		}
+		if strings.Contains(do("GET", "/apikeys/"+created.ID.String(), "", asAdmin, "").Body.String(), created.Key) {
+			t.Error("GET /apikeys/{id} returned the secret")
+		}
+
+		inside, outside := "192.0.2.10:4000", "198.51.100.7:4000"
+		for _, tc := range []struct {
+			name       string
+			method     string
+			path       string
+			header     http.Header
+			remoteAddr string
+			want       int
+		}{
+			{"header", "GET", "/assets", withKey(created.Key), inside, http.StatusOK},
+			{"bearer", "GET", "/assets", http.Header{"Authorization": {"Bearer " + created.Key}}, inside, http.StatusOK},
+			{"outside the allowed range", "GET", "/assets", withKey(created.Key), outside, http.StatusForbidden},
+			{"missing scope", "POST", "/assets", withKey(created.Key), inside, http.StatusForbidden},
+			{"route closed to keys", "GET", "/apikeys", withKey(created.Key), inside, http.StatusForbidden},
+			{"wrong secret", "GET", "/assets", withKey(created.Prefix + "_wrong"), inside, http.StatusUnauthorized},
+			{"unknown key", "GET", "/assets", withKey(auth.APIKeyPrefix + "nope_nope"), inside, http.StatusUnauthorized},
+		} {
+			if rec := do(tc.method, tc.path, `{}`, tc.header, tc.remoteAddr); rec.Code != tc.want {
+				t.Errorf("%s: %s %s = %d, want %d: %s", tc.name, tc.method, tc.path, rec.Code, tc.want, rec.Body)
+			}
+		}
+
+		used, err := repos.APIKeys.GetAPIKeyByID(ctx, created.ID)
+		if err != nil {
+			t.Fatalf("GetAPIKeyByID: %v", err)
+		}
+		if used.LastUsedAt == nil || used.LastUsedIP != "192.0.2.10" {
+			t.Errorf("last used at %v from %q, want recorded from 192.0.2.10", used.LastUsedAt, used.LastUsedIP)
+		}
+
+		// Rotating with a grace period keeps the old key working until it ends
+		rec = do("POST", "/apikeys/"+created.ID.String()+"/rotate", `{"grace_period":"1h"}`, asAdmin, "")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("rotate API key = %d, want 201: %s", rec.Code, rec.Body)
+		}
+		var rotated struct {
+			models.APIKey
+			Key string `json:"key"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&rotated); err != nil {
+			t.Fatalf("decoding API key: %v", err)
+		}
+		if rotated.Name != "mdm" || len(rotated.Scopes) != 1 || len(rotated.AllowedIPs) != 1 {
+			t.Errorf("rotated key %+v did not keep the name, scopes and ranges", rotated.APIKey)
+		}
+		for _, key := range []string{created.Key, rotated.Key} {
+			if rec := do("GET", "/assets", "", withKey(key), inside); rec.Code != http.StatusOK {
+				t.Errorf("key during grace period = %d, want 200", rec.Code)
+			}
+		}
+
+		// Revoking stops the old key at once; a key can only be revoked once
+		if rec := do("DELETE", "/apikeys/"+created.ID.String(), "", asAdmin, ""); rec.Code != http.StatusOK {
+			t.Fatalf("revoke API key = %d, want 200: %s", rec.Code, rec.Body)
+		}
+		if rec := do("GET", "/assets", "", withKey(created.Key), inside); rec.Code != http.StatusUnauthorized {
+			t.Errorf("revoked key = %d, want 401", rec.Code)
+		}
+		if rec := do("DELETE", "/apikeys/"+created.ID.String(), "", asAdmin, ""); rec.Code != http.StatusConflict {
+			t.Errorf("revoking twice = %d, want 409", rec.Code)
+		}
+
+		// Rotating without a grace period retires the old key immediately
+		rec = do("POST", "/apikeys/"+rotated.ID.String()+"/rotate", "", asAdmin, "")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("rotate API key = %d, want 201: %s", rec.Code, rec.Body)
+		}
+		if rec := do("GET", "/assets", "", withKey(rotated.Key), inside); rec.Code != http.StatusUnauthorized {
+			t.Errorf("key rotated without grace = %d, want 401", rec.Code)
+		}
+	})
+}
+
+func TestCalendarFeedTokens(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		get := func(path, authorization string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", path, nil)
+			if authorization != "" {
+				req.Header.Set("Authorization", authorization)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		feed := "/assets/" + f.stock.Id.String() + "/calendar-feed"
+		if rec := get(feed, ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("feed URL without a session = %d, want 401", rec.Code)
+		}
+		rec := get(feed, "Bearer "+f.token)
+		var body struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); rec.Code != http.StatusOK || err != nil {
+			t.Fatalf("feed URL = %d (%v), want 200", rec.Code, err)
+		}
+		path, ok := strings.CutPrefix(body.URL, "https://assets.example.com")
+		if !ok {
+			t.Fatalf("feed URL %q is not on the base URL", body.URL)
+		}
+
+		// A calendar app subscribes with the URL alone
+		rec = get(path, "")
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/calendar") {
+			t.Errorf("subscribing = %d %q, want 200 text/calendar", rec.Code, rec.Header().Get("Content-Type"))
+		}
+
+		// The token opens only its own feed
+		other := "/assets/" + f.assigned.Id.String() + "/reservations.ics?" + strings.SplitN(path, "?", 2)[1]
+		if rec := get(other, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("another asset's feed with this token = %d, want 403", rec.Code)
+		}
+		if rec := get(path+"x", ""); rec.Code != http.StatusForbidden {
+			t.Errorf("tampered token = %d, want 403", rec.Code)
+		}
+	})
+}
+
+func TestOneTimeCodeRoutes(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		routes := registeredRoutes(newRouter(repos, nil))
+		for _, route := range handler.OneTimeCodeRoutes {
+			if !routes[route] {
+				t.Errorf("one-time code route %q is not registered", route)
+			}
+		}
+	})
+}
@@ -3,11 +3,11 @@ package handler
 import (
 	"github.com/cameo1221/Go-Asset/middleware"
 	
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -17,10 +17,10 @@ import (
 
 
 type EmployeeassetHandler struct {
-	EmployeeassetModel *models.EmployeeAssetModel
+	EmployeeassetModel models.EmployeeAssetRepository
 }
 
-func NewEmployeeassetHandler(EmployeeassetModel *models.EmployeeAssetModel) *EmployeeassetHandler {
+func NewEmployeeassetHandler(EmployeeassetModel models.EmployeeAssetRepository) *EmployeeassetHandler {
 	return &EmployeeassetHandler{EmployeeassetModel: EmployeeassetModel}
 }
 
@@ -46,9 +46,9 @@ func (ah *EmployeeassetHandler) createEmployeeasset(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = ah.EmployeeassetModel.CreateEmployeeAsset(&employeeasset)
+	err = ah.EmployeeassetModel.CreateEmployeeAsset(r.Context(), &employeeasset)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating employeeasset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error creating employeeasset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -58,24 +58,19 @@ func (ah *EmployeeassetHandler) createEmployeeasset(w http.ResponseWriter, r *ht
 }
 
 func (ah *EmployeeassetHandler) getAllEmployeeassets(w http.ResponseWriter, r *http.Request) {
-    employeeassets, err := ah.EmployeeassetModel.GetAllEmployeeAssets()
+    format, err := exportFormat(r)
     if err != nil {
-        http.Error(w, fmt.Sprintf("Error getting Employeeassets: %v", err), http.StatusInternalServerError)
+        exportFormatError(w, err)
         return
     }
-
-    employeeassetsJSON, err := json.Marshal(employeeassets)
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error marshaling employeeassets to JSON: %v", err), http.StatusInternalServerError)
+    if format != "" {
+        writeExport(w, r, format, "employeeassets", employeeAssetExportColumns, func(ctx context.Context, fn func(*models.EmployeeAsset) error) error {
+            return ah.EmployeeassetModel.ForEachEmployeeAsset(ctx, fn)
+        })
         return
     }
 
-    w.Header().Set("ContentType", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _, err = w.Write(employeeassetsJSON)
-    if err != nil {
-        log.Printf("Error writing response: %v\n", err)
-    }
+    writeJSONArray(w, r, "employeeassets", ah.EmployeeassetModel.ForEachEmployeeAsset)
 }
 func (ah *EmployeeassetHandler) getEmployeeasset(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -87,9 +82,9 @@ func (ah *EmployeeassetHandler) getEmployeeasset(w http.ResponseWriter, r *http.
 		return
 	}
 
-	employeeasset, err := ah.EmployeeassetModel.GetEmployeeAssetByID(id)
+	employeeasset, err := ah.EmployeeassetModel.GetEmployeeAssetByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving Employeeasset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error retrieving Employeeasset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -114,9 +109,9 @@ func (ah *EmployeeassetHandler) updateEmployeeasset(w http.ResponseWriter, r *ht
 	}
 
 	updatedEmployeeasset.ID = id
-	err = ah.EmployeeassetModel.UpdateEmployeeAsset(&updatedEmployeeasset)
+	err = ah.EmployeeassetModel.UpdateEmployeeAsset(r.Context(), &updatedEmployeeasset)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating Employeeasset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error updating Employeeasset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -135,9 +130,9 @@ func (ah *EmployeeassetHandler) deleteEmployeeasset(w http.ResponseWriter, r *ht
 		return
 	}
 
-	err = ah.EmployeeassetModel.ArchiveEmployeeAsset(employeeassetID)
+	err = ah.EmployeeassetModel.ArchiveEmployeeAsset(r.Context(), employeeassetID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting employeeasset: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error deleting employeeasset: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -148,7 +143,6 @@ func (ah *EmployeeassetHandler) deleteEmployeeasset(w http.ResponseWriter, r *ht
 
 func RegisterEmployeeassetRoutes(router *mux.Router, ah *EmployeeassetHandler) {
     router.Use(middleware.JSONContentTypeMiddleware)
-    router.Use(middleware.LoggingMiddleware)
 
 	router.HandleFunc("/employeeassets", ah.createEmployeeasset).Methods("POST")
 	router.HandleFunc("/employeeassets", ah.getAllEmployeeassets).Methods("Get")
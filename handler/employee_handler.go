@@ -3,11 +3,12 @@ package handler
 import (
 	"github.com/cameo1221/Go-Asset/middleware"
 	
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	
-	"log"
 	"net/http"
 
 	"github.com/google/uuid"
@@ -17,11 +18,11 @@ import (
 
 // EmployeeHandler represents the handler for managing assets
 type EmployeeHandler struct {
-	EmployeeModel *models.EmployeeModel
+	EmployeeModel models.EmployeeRepository
 }
 
 // NewEmployeeHandler creates a new instance of EmployeeHandler
-func NewEmployeeHandler(employeeModel *models.EmployeeModel) *EmployeeHandler {
+func NewEmployeeHandler(employeeModel models.EmployeeRepository) *EmployeeHandler {
 	return &EmployeeHandler{EmployeeModel: employeeModel}
 }
 
@@ -50,9 +51,9 @@ func (ah *EmployeeHandler) createEmployee(w http.ResponseWriter, r *http.Request
 	}
 
 	// Call the model method to create the Employee in the database
-	err = ah.EmployeeModel.CreateEmployee(&employee)
+	err = ah.EmployeeModel.CreateEmployee(r.Context(), &employee)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating employee: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error creating employee: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -63,27 +64,20 @@ func (ah *EmployeeHandler) createEmployee(w http.ResponseWriter, r *http.Request
 }
 
 func (ah *EmployeeHandler) getAllEmployees(w http.ResponseWriter, r *http.Request) {
-    // Call the model method to get all Employees from the database
-    employees, err := ah.EmployeeModel.GetAllEmployees()
+    format, err := exportFormat(r)
     if err != nil {
-        http.Error(w, fmt.Sprintf("Error getting employees: %v", err), http.StatusInternalServerError)
+        exportFormatError(w, err)
         return
     }
-
-    // Convert employees slice to JSON
-    employeesJSON, err := json.Marshal(employees)
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error marshaling employees to JSON: %v", err), http.StatusInternalServerError)
+    if format != "" {
+        writeExport(w, r, format, "employees", employeeExportColumns, func(ctx context.Context, fn func(*models.Employee) error) error {
+            return ah.EmployeeModel.ForEachEmployee(ctx, fn)
+        })
         return
     }
 
-    // Set content type header and write response
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _, err = w.Write(employeesJSON)
-    if err != nil {
-        log.Printf("Error writing response: %v\n", err)
-    }
+    // Stream employees straight from the database cursor as a JSON array
+    writeJSONArray(w, r, "employees", ah.EmployeeModel.ForEachEmployee)
 }
 // getemployee is a helper function for handling employee retrieval logic
 func (ah *EmployeeHandler) getEmployee(w http.ResponseWriter, r *http.Request) {
@@ -99,9 +93,9 @@ func (ah *EmployeeHandler) getEmployee(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the model method to retrieve the employee from the database
-	employee, err := ah.EmployeeModel.GetEmployeeByID(id)
+	employee, err := ah.EmployeeModel.GetEmployeeByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving employee: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error retrieving employee: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -133,10 +127,16 @@ func (ah *EmployeeHandler) updateEmployee(w http.ResponseWriter, r *http.Request
 	// Set the ID of the updated Employee
 	updatedEmployee.ID = id
 
+	// Archiving goes through DELETE or offboarding, which make sure no assets are left assigned
+	if updatedEmployee.ArchivedAt != nil {
+		http.Error(w, "archive_at cannot be set here; archive with DELETE /employees/{id} or offboarding", http.StatusBadRequest)
+		return
+	}
+
 	// Call the model method to update the Employee in the database
-	err = ah.EmployeeModel.UpdateEmployee(&updatedEmployee)
+	err = ah.EmployeeModel.UpdateEmployee(r.Context(), &updatedEmployee)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating employee: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error updating employee: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -159,9 +159,13 @@ func (ah *EmployeeHandler) deleteEmployee(w http.ResponseWriter, r *http.Request
 	}
 
 	// Call the model method to delete the employee from the database
-	err = ah.EmployeeModel.ArchiveEmployee(employeeID)
+	err = ah.EmployeeModel.ArchiveEmployee(r.Context(), employeeID)
+	if errors.Is(err, models.ErrEmployeeHasAssets) {
+		http.Error(w, "Employee still has assets assigned; complete offboarding at /employees/{id}/offboarding", http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting employee: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error deleting employee: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -175,7 +179,6 @@ func (ah *EmployeeHandler) deleteEmployee(w http.ResponseWriter, r *http.Request
 // RegisterRoutes registers all Employee related routes on the provided router
 func RegisterEmployeeRoutes(router *mux.Router, ah *EmployeeHandler) {
     router.Use(middleware.JSONContentTypeMiddleware)
-    router.Use(middleware.LoggingMiddleware)
 
 	router.HandleFunc("/employees", ah.createEmployee).Methods("POST")
 	router.HandleFunc("/employees", ah.getAllEmployees).Methods("Get")
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// StatusClientClosedRequest is the non-standard status nginx uses for a client that went away
+// before the response was ready
+const StatusClientClosedRequest = 499
+
+// errorStatus picks the status for a failed model call. Cancelled queries are not server faults:
+// a client that disconnected gets 499, and a request or query that ran out of time gets 503.
+// Assignments and asset statuses the models refuse are the caller's to fix.
+func errorStatus(r *http.Request, err error) int {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(r.Context().Err(), context.Canceled):
+		return StatusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(r.Context().Err(), context.DeadlineExceeded),
+		models.IsQueryCanceled(err):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, models.ErrInvalidAssetStatus):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrEmployeeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrEmployeeOffboarding), errors.Is(err, models.ErrAssetUnavailable),
+		errors.Is(err, models.ErrAssetStatusConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/export"
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+var assetExportColumns = []export.Column[*models.Asset]{
+	{Name: "id", Value: func(a *models.Asset) interface{} { return a.Id }},
+	{Name: "model", Value: func(a *models.Asset) interface{} { return a.Model }},
+	{Name: "company", Value: func(a *models.Asset) interface{} { return a.Company }},
+	{Name: "tag", Value: func(a *models.Asset) interface{} { return a.Tag }},
+	{Name: "serial", Value: func(a *models.Asset) interface{} { return a.Serial }},
+	{Name: "category", Value: func(a *models.Asset) interface{} { return a.Category }},
+	{Name: "location", Value: func(a *models.Asset) interface{} { return a.Location }},
+	{Name: "status", Value: func(a *models.Asset) interface{} { return a.Status }},
+	{Name: "created_at", Value: func(a *models.Asset) interface{} { return a.CreatedAt }},
+	{Name: "archive_at", Value: func(a *models.Asset) interface{} { return a.ArchivedAt }},
+}
+
+var employeeExportColumns = []export.Column[*models.Employee]{
+	{Name: "id", Value: func(e *models.Employee) interface{} { return e.ID }},
+	{Name: "name", Value: func(e *models.Employee) interface{} { return e.Name }},
+	{Name: "email", Value: func(e *models.Employee) interface{} { return e.Email }},
+	{Name: "role", Value: func(e *models.Employee) interface{} { return e.Role }},
+	{Name: "department", Value: func(e *models.Employee) interface{} { return e.Department }},
+	{Name: "manager_id", Value: func(e *models.Employee) interface{} { return e.ManagerID }},
+	{Name: "created_at", Value: func(e *models.Employee) interface{} { return e.CreatedAt }},
+	{Name: "archive_at", Value: func(e *models.Employee) interface{} { return e.ArchivedAt }},
+}
+
+var employeeAssetExportColumns = []export.Column[*models.EmployeeAsset]{
+	{Name: "id", Value: func(ea *models.EmployeeAsset) interface{} { return ea.ID }},
+	{Name: "asset_id", Value: func(ea *models.EmployeeAsset) interface{} { return ea.AssetID }},
+	{Name: "employee_id", Value: func(ea *models.EmployeeAsset) interface{} { return ea.EmployeeID }},
+	{Name: "created_at", Value: func(ea *models.EmployeeAsset) interface{} { return ea.CreatedAt }},
+	{Name: "archive_at", Value: func(ea *models.EmployeeAsset) interface{} { return ea.ArchivedAt }},
+}
+
+// errNotAcceptable is returned by exportFormat when the Accept header rules out JSON and every
+// export format
+var errNotAcceptable = errors.New("acceptable types are application/json, " + strings.Join(exportMediaTypes(), ", "))
+
+// exportFormat picks an export format from ?format= or, failing that, the Accept header.
+// It returns "" when the client wants the regular JSON response.
+func exportFormat(r *http.Request) (string, error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if format == "json" {
+			return "", nil
+		}
+		if _, ok := export.Formats[format]; !ok {
+			return "", fmt.Errorf("format must be json or one of %s", strings.Join(export.FormatNames(), ", "))
+		}
+		return format, nil
+	}
+
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		return "", nil
+	}
+	ranges := parseAccept(header)
+
+	// JSON comes first so it wins ties, such as */* or application/*
+	candidates := append([]string{""}, export.FormatNames()...)
+	best, bestMatch := "", acceptRange{}
+	found := false
+	for _, format := range candidates {
+		mediaType := "application/json"
+		if format != "" {
+			mediaType = export.Formats[format].MediaType()
+		}
+		match, ok := matchAccept(ranges, mediaType)
+		if !ok || match.q == 0 {
+			continue
+		}
+		if !found || match.q > bestMatch.q ||
+			(match.q == bestMatch.q && (match.specificity > bestMatch.specificity ||
+				(match.specificity == bestMatch.specificity && match.position < bestMatch.position))) {
+			best, bestMatch, found = format, match, true
+		}
+	}
+	if !found {
+		return "", errNotAcceptable
+	}
+	return best, nil
+}
+
+// acceptRange is one media range of an Accept header
+type acceptRange struct {
+	mediaType   string
+	q           float64
+	specificity int // 0 for */*, 1 for type/*, 2 for type/subtype
+	position    int
+}
+
+// parseAccept reads the media ranges of an Accept header, skipping ones that do not parse
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for i, accept := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		specificity := 2
+		switch {
+		case mediaType == "*/*":
+			specificity = 0
+		case strings.HasSuffix(mediaType, "/*"):
+			specificity = 1
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q, specificity: specificity, position: i})
+	}
+	return ranges
+}
+
+// matchAccept finds the most specific range that covers mediaType, which is the one whose q-value
+// applies to it
+func matchAccept(ranges []acceptRange, mediaType string) (acceptRange, bool) {
+	var best acceptRange
+	found := false
+	for _, ar := range ranges {
+		var matches bool
+		switch ar.specificity {
+		case 0:
+			matches = true
+		case 1:
+			matches = strings.HasPrefix(mediaType, strings.TrimSuffix(ar.mediaType, "*"))
+		default:
+			matches = strings.EqualFold(ar.mediaType, mediaType)
+		}
+		if matches && (!found || ar.specificity > best.specificity) {
+			best, found = ar, true
+		}
+	}
+	return best, found
+}
+
+// exportMediaTypes lists the media types the export formats answer to
+func exportMediaTypes() []string {
+	var types []string
+	for _, name := range export.FormatNames() {
+		types = append(types, export.Formats[name].MediaType())
+	}
+	return types
+}
+
+// exportFormatError reports a bad ?format= as 400 and an Accept header nothing satisfies as 406
+func exportFormatError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, errNotAcceptable) {
+		status = http.StatusNotAcceptable
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// writeExport streams the rows produced by each in the given format, restricted to the columns
+// named in ?columns=. Nothing is written until the query has returned its first row, so a failing
+// query still gets a proper error status.
+func writeExport[T any](w http.ResponseWriter, r *http.Request, format, name string, columns []export.Column[T], each func(context.Context, func(T) error) error) {
+	var names []string
+	if v := r.URL.Query().Get("columns"); v != "" {
+		names = strings.Split(v, ",")
+	}
+	columns, err := export.Select(columns, names)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f := export.Formats[format]
+	rc := http.NewResponseController(w)
+	var rows export.RowWriter
+	started := false
+	count := 0
+	start := func() error {
+		started = true
+		extendWriteDeadline(rc)
+		w.Header().Set("Content-Type", f.ContentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format("20060102"), f.Extension))
+		w.WriteHeader(http.StatusOK)
+		rows, err = f.New(w, export.Names(columns))
+		return err
+	}
+
+	err = each(r.Context(), func(item T) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := rows.WriteRow(export.Values(columns, item)); err != nil {
+			return err
+		}
+
+		count++
+		if count%streamFlushRows == 0 {
+			if err := rows.Flush(); err != nil {
+				return err
+			}
+			rc.Flush()
+			extendWriteDeadline(rc)
+		}
+		return nil
+	})
+	if err != nil && !started {
+		http.Error(w, fmt.Sprintf("Error exporting %s: %v", name, err), errorStatus(r, err))
+		return
+	}
+	if err != nil {
+		abortStream(r, name, err)
+	}
+
+	if !started {
+		if err := start(); err != nil {
+			logging.FromContext(r.Context()).Error("exporting", "export", name, "error", err)
+			return
+		}
+	}
+	if err := rows.Close(); err != nil {
+		logging.FromContext(r.Context()).Error("exporting", "export", name, "error", err)
+	}
+}
@@ -0,0 +1,65 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestExportNegotiation(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		tests := []struct {
+			path       string
+			accept     string
+			wantStatus int
+			wantType   string
+		}{
+			{"/assets", "", http.StatusOK, "application/json"},
+			{"/assets", "*/*", http.StatusOK, "application/json"},
+			{"/assets", "application/*", http.StatusOK, "application/json"},
+			{"/assets", "text/csv", http.StatusOK, "text/csv"},
+			{"/assets", "TEXT/CSV", http.StatusOK, "text/csv"},
+			// The highest q-value wins, wherever it sits in the header
+			{"/assets", "application/json;q=0.5, text/csv;q=0.9", http.StatusOK, "text/csv"},
+			{"/assets", "text/csv;q=0.2, application/x-ndjson", http.StatusOK, "application/x-ndjson"},
+			{"/assets", "text/html, application/json;q=0.1", http.StatusOK, "application/json"},
+			// A browser's header falls back to JSON through */*
+			{"/assets", "text/html,application/xhtml+xml,*/*;q=0.8", http.StatusOK, "application/json"},
+			// The most specific range sets a type's q-value, so q=0 rules it out
+			{"/assets", "*/*, application/json;q=0", http.StatusOK, "text/csv"},
+			{"/assets", "text/*, text/csv;q=0", http.StatusNotAcceptable, ""},
+			{"/assets", "text/html", http.StatusNotAcceptable, ""},
+			{"/assets", "application/json;q=0", http.StatusNotAcceptable, ""},
+			{"/assets?format=json", "text/html", http.StatusOK, "application/json"},
+			{"/assets?format=pdf", "", http.StatusBadRequest, ""},
+			{"/employees", "text/html;q=0.9, application/x-ndjson", http.StatusOK, "application/x-ndjson"},
+			{"/employeeassets", "image/png", http.StatusNotAcceptable, ""},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+f.token)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET %s with Accept %q = %d, want %d: %s", tt.path, tt.accept, rec.Code, tt.wantStatus, rec.Body.String())
+				continue
+			}
+			if got := rec.Header().Get("Content-Type"); tt.wantType != "" && !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("GET %s with Accept %q has Content-Type %q, want %s", tt.path, tt.accept, got, tt.wantType)
+			}
+			if tt.wantStatus == http.StatusNotAcceptable && !strings.Contains(rec.Body.String(), "text/csv") {
+				t.Errorf("406 body %q does not list the acceptable types", rec.Body.String())
+			}
+		}
+	})
+}
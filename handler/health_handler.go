@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/db"
+	"github.com/cameo1221/Go-Asset/logging"
+)
+
+// readinessTimeout bounds the database checks behind /readyz so a stuck pool fails the probe
+const readinessTimeout = 2 * time.Second
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// ReadBuildInfo reads the module version, VCS commit and Go version embedded by the toolchain.
+// buildTime is normally set with -ldflags "-X main.buildTime=..."; without it the commit time is used.
+func ReadBuildInfo(buildTime string) BuildInfo {
+	info := BuildInfo{BuildTime: buildTime}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.GoVersion = bi.GoVersion
+	if bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Commit = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		case "vcs.time":
+			if info.BuildTime == "" {
+				info.BuildTime = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// HealthHandler answers the orchestrator's liveness, readiness and version probes
+type HealthHandler struct {
+	Database *db.Database
+	Build    BuildInfo
+}
+
+// NewHealthHandler creates a new instance of HealthHandler
+func NewHealthHandler(database *db.Database, build BuildInfo) *HealthHandler {
+	return &HealthHandler{Database: database, Build: build}
+}
+
+// poolStats is the part of sql.DBStats worth watching from outside
+type poolStats struct {
+	MaxOpen      int   `json:"max_open_connections"`
+	Open         int   `json:"open_connections"`
+	InUse        int   `json:"in_use"`
+	Idle         int   `json:"idle"`
+	WaitCount    int64 `json:"wait_count"`
+	WaitDuration int64 `json:"wait_duration_ms"`
+}
+
+type readiness struct {
+	Status            string    `json:"status"`
+	Database          string    `json:"database"`
+	PendingMigrations []string  `json:"pending_migrations,omitempty"`
+	Pool              poolStats `json:"pool"`
+}
+
+// healthz reports that the process is up; it never touches the database
+func (hh *HealthHandler) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readyz reports whether the database answers and its schema is current: 200 when ready, 503 otherwise
+func (hh *HealthHandler) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	stats := hh.Database.Conn.Stats()
+	report := readiness{
+		Status:   "ready",
+		Database: "ok",
+		Pool: poolStats{
+			MaxOpen:      stats.MaxOpenConnections,
+			Open:         stats.OpenConnections,
+			InUse:        stats.InUse,
+			Idle:         stats.Idle,
+			WaitCount:    stats.WaitCount,
+			WaitDuration: stats.WaitDuration.Milliseconds(),
+		},
+	}
+
+	// The probe is unauthenticated, so the reason stays in the logs rather than the response
+	if err := hh.Database.Conn.PingContext(ctx); err != nil {
+		logging.FromContext(r.Context()).Error("readiness: pinging database", "error", err)
+		report.Status = "unavailable"
+		report.Database = "unavailable"
+	} else if pending, err := hh.Database.PendingMigrations(ctx); err != nil {
+		logging.FromContext(r.Context()).Error("readiness: checking migrations", "error", err)
+		report.Status = "unavailable"
+		report.Database = "unavailable"
+	} else if len(pending) > 0 {
+		report.Status = "migrations pending"
+		report.PendingMigrations = pending
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// version reports what build is running
+func (hh *HealthHandler) version(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hh.Build)
+}
+
+// RegisterHealthRoutes registers the probe routes on the provided router. They must stay reachable
+// without credentials, and the logging middleware skips them so probes do not flood the log.
+func RegisterHealthRoutes(router *mux.Router, hh *HealthHandler) {
+	router.HandleFunc("/healthz", hh.healthz).Methods("GET")
+	router.HandleFunc("/readyz", hh.readyz).Methods("GET")
+	router.HandleFunc("/version", hh.version).Methods("GET")
+}
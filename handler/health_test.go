@@ -0,0 +1,72 @@
+package handler_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/db"
+	"github.com/cameo1221/Go-Asset/db/dbtest"
+	"github.com/cameo1221/Go-Asset/handler"
+)
+
+func probe(t *testing.T, conn *sql.DB, path string) (int, map[string]interface{}) {
+	t.Helper()
+
+	router := mux.NewRouter()
+	handler.RegisterHealthRoutes(router, handler.NewHealthHandler(&db.Database{Conn: conn}, handler.ReadBuildInfo("2030-01-01T00:00:00Z")))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("GET %s: decoding body: %v", path, err)
+	}
+	return rec.Code, body
+}
+
+// unreachable is a pool pointing at a socket directory with no server in it
+func unreachable(t *testing.T) *sql.DB {
+	conn, err := sql.Open("postgres", "host="+filepath.Join(t.TempDir(), "nowhere")+" user=postgres sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestHealthz(t *testing.T) {
+	code, body := probe(t, unreachable(t), "/healthz")
+	if code != http.StatusOK || body["status"] != "ok" {
+		t.Errorf("GET /healthz = %d %v, want 200 ok even without a database", code, body)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	code, body := probe(t, unreachable(t), "/version")
+	if code != http.StatusOK || body["build_time"] != "2030-01-01T00:00:00Z" || body["go_version"] == "" {
+		t.Errorf("GET /version = %d %v, want the build time and Go version", code, body)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	t.Run("database down", func(t *testing.T) {
+		code, body := probe(t, unreachable(t), "/readyz")
+		if code != http.StatusServiceUnavailable || body["status"] != "unavailable" || body["database"] != "unavailable" {
+			t.Errorf("GET /readyz = %d %v, want 503 unavailable without the driver's error", code, body)
+		}
+	})
+
+	t.Run("migrated", func(t *testing.T) {
+		code, body := probe(t, dbtest.Open(t), "/readyz")
+		if code != http.StatusOK || body["status"] != "ready" {
+			t.Errorf("GET /readyz = %d %v, want 200 ready", code, body)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// icalEscape escapes TEXT values per RFC 5545 section 3.3.11
+func icalEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// writeICalLine writes a content line, folding it at 75 octets as RFC 5545 requires
+func writeICalLine(w io.Writer, line string) {
+	// Continuation lines start with a space, which counts towards their 75 octets
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		// Never split a multi-byte UTF-8 sequence across a fold
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		fmt.Fprintf(w, "%s\r\n ", line[:cut])
+		line = line[cut:]
+		limit = 74
+	}
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+// writeICalendar renders reservations as an iCalendar feed
+func writeICalendar(w io.Writer, name string, reservations []*models.Reservation) {
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+
+	writeICalLine(w, "BEGIN:VCALENDAR")
+	writeICalLine(w, "VERSION:2.0")
+	writeICalLine(w, "PRODID:-//Go-Asset//Reservations//EN")
+	writeICalLine(w, "CALSCALE:GREGORIAN")
+	writeICalLine(w, "X-WR-CALNAME:"+icalEscape(name))
+
+	for _, reservation := range reservations {
+		summary := "Asset reservation"
+		if reservation.Note != "" {
+			summary = reservation.Note
+		}
+
+		writeICalLine(w, "BEGIN:VEVENT")
+		writeICalLine(w, "UID:"+reservation.ID.String()+"@go-asset")
+		writeICalLine(w, "DTSTAMP:"+stamp)
+		writeICalLine(w, "DTSTART:"+reservation.StartsAt.UTC().Format(icalTimeFormat))
+		writeICalLine(w, "DTEND:"+reservation.EndsAt.UTC().Format(icalTimeFormat))
+		writeICalLine(w, "SUMMARY:"+icalEscape(summary))
+		writeICalLine(w, "DESCRIPTION:"+icalEscape(fmt.Sprintf("Asset %s reserved by employee %s", reservation.AssetID, reservation.EmployeeID)))
+		writeICalLine(w, "END:VEVENT")
+	}
+
+	writeICalLine(w, "END:VCALENDAR")
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// unfold splits a feed into physical lines, checking each is CRLF terminated, and joins folded
+// continuations back onto their content line
+func unfold(t *testing.T, feed string) (physical, logical []string) {
+	t.Helper()
+
+	if !strings.HasSuffix(feed, "\r\n") {
+		t.Fatalf("feed %q does not end with CRLF", feed)
+	}
+	physical = strings.Split(strings.TrimSuffix(feed, "\r\n"), "\r\n")
+	for _, line := range physical {
+		if strings.Contains(line, "\n") {
+			t.Errorf("line %q has a bare LF", line)
+		}
+		if strings.HasPrefix(line, " ") && len(logical) > 0 {
+			logical[len(logical)-1] += line[1:]
+			continue
+		}
+		logical = append(logical, line)
+	}
+	return physical, logical
+}
+
+func TestWriteICalLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		folds int
+	}{
+		{"short", "SUMMARY:Laptop", 0},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67), 0},
+		{"76 octets", "SUMMARY:" + strings.Repeat("a", 68), 1},
+		{"long ASCII", "DESCRIPTION:" + strings.Repeat("abcdefghij", 20), 2},
+		{"two-byte characters", "SUMMARY:" + strings.Repeat("é", 100), 2},
+		{"three-byte characters", "SUMMARY:" + strings.Repeat("€", 60), 2},
+		{"four-byte characters straddling the fold", "SUMMARY:" + strings.Repeat("a", 66) + strings.Repeat("💻", 10), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeICalLine(&buf, tt.line)
+
+			physical, logical := unfold(t, buf.String())
+			if len(physical)-1 != tt.folds {
+				t.Errorf("folded %d times into %q, want %d folds", len(physical)-1, physical, tt.folds)
+			}
+			for i, line := range physical {
+				if len(line) > 75 {
+					t.Errorf("line %d is %d octets, want at most 75", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line %d %q splits a UTF-8 sequence", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("continuation line %d %q does not start with a space", i, line)
+				}
+			}
+			if len(logical) != 1 || logical[0] != tt.line {
+				t.Errorf("unfolded to %q, want %q", logical, tt.line)
+			}
+		})
+	}
+}
+
+func TestICalEscape(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Laptop", "Laptop"},
+		{"desk, second floor", `desk\, second floor`},
+		{"dock; charger", `dock\; charger`},
+		{`C:\Users\ada`, `C:\\Users\\ada`},
+		{"first line\nsecond line", `first line\nsecond line`},
+		{"first line\r\nsecond line", `first line\nsecond line`},
+		{`a\,b;c`, `a\\\,b\;c`},
+	}
+	for _, tt := range tests {
+		if got := icalEscape(tt.in); got != tt.want {
+			t.Errorf("icalEscape(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestWriteICalendar(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone data: %v", err)
+	}
+	reservation := &models.Reservation{
+		ID:         uuid.New(),
+		AssetID:    uuid.New(),
+		EmployeeID: uuid.New(),
+		StartsAt:   time.Date(2026, 3, 2, 9, 0, 0, 0, newYork),
+		EndsAt:     time.Date(2026, 3, 2, 17, 30, 0, 0, newYork),
+		Note:       "Demo, day one; bring the dock\nand charger",
+	}
+
+	var buf bytes.Buffer
+	writeICalendar(&buf, "Laptops, London", []*models.Reservation{reservation})
+	_, lines := unfold(t, buf.String())
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR",
+		`X-WR-CALNAME:Laptops\, London`,
+		"BEGIN:VEVENT",
+		"UID:" + reservation.ID.String() + "@go-asset",
+		"DTSTART:20260302T140000Z",
+		"DTEND:20260302T223000Z",
+		`SUMMARY:Demo\, day one\; bring the dock\nand charger`,
+		"END:VEVENT",
+		"END:VCALENDAR",
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("feed has no line %q in %q", want, lines)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "DTSTAMP:") && !strings.HasSuffix(line, "Z") {
+			t.Errorf("%q is not in UTC", line)
+		}
+	}
+}
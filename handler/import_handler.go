@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// maxImportSize caps the CSV body accepted by an import
+const maxImportSize = 32 << 20
+
+// ImportHandler handles bulk CSV imports
+type ImportHandler struct {
+	ImportModel models.ImportRepository
+}
+
+// NewImportHandler creates a new instance of ImportHandler
+func NewImportHandler(importModel models.ImportRepository) *ImportHandler {
+	return &ImportHandler{ImportModel: importModel}
+}
+
+// parseColumnMapping reads repeated map=field:Header parameters. Fields that are not mapped are
+// read from a column with the same name as the field.
+func parseColumnMapping(values []string, fields []string) (map[string]string, error) {
+	known := make(map[string]bool, len(fields))
+	mapping := make(map[string]string, len(fields))
+	for _, field := range fields {
+		known[field] = true
+		mapping[field] = field
+	}
+
+	for _, value := range values {
+		field, header, ok := strings.Cut(value, ":")
+		field = strings.TrimSpace(field)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("mapping %q is not field:Header", value)
+		}
+		if !known[field] {
+			return nil, fmt.Errorf("unknown field %q; use one of %s", field, strings.Join(fields, ", "))
+		}
+		mapping[field] = strings.TrimSpace(header)
+	}
+
+	return mapping, nil
+}
+
+// readImportCSV maps each CSV record onto import fields using the header row
+func readImportCSV(body io.Reader, fields, required []string, mapping map[string]string) ([]models.ImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make(map[string]int, len(fields))
+	for _, field := range fields {
+		if i, ok := index[strings.ToLower(mapping[field])]; ok {
+			columns[field] = i
+		}
+	}
+	for _, field := range required {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("no column %q for required field %s", mapping[field], field)
+		}
+	}
+
+	var rows []models.ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := models.ImportRow{Line: line, Values: make(map[string]string, len(columns))}
+		for field, i := range columns {
+			row.Values[field] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// importCSV handles POST /import/{kind}?dry_run=true&map=serial:Serial%20Number
+func (ih *ImportHandler) importCSV(w http.ResponseWriter, r *http.Request) {
+	kind := mux.Vars(r)["kind"]
+	fields, required, err := models.ImportFields(kind)
+	if errors.Is(err, models.ErrUnknownImportKind) {
+		http.Error(w, "Import kind must be assets, employees or employeeassets", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	dryRun := false
+	if v := query.Get("dry_run"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	mapping, err := parseColumnMapping(query["map"], fields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := readImportCSV(http.MaxBytesReader(w, r.Body, maxImportSize), fields, required, mapping)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error reading CSV: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	report, err := ih.ImportModel.Import(r.Context(), kind, rows, dryRun)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error importing %s: %v", kind, err), errorStatus(r, err))
+		return
+	}
+
+	// A real import with invalid rows commits nothing; the report says which rows to fix
+	status := http.StatusOK
+	if !dryRun && len(report.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// RegisterImportRoutes registers the bulk import routes on the provided router
+func RegisterImportRoutes(router *mux.Router, ih *ImportHandler) {
+	router.HandleFunc("/import/{kind}", ih.importCSV).Methods("POST")
+}
@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// KitHandler handles onboarding kit templates and provisioning them for new hires
+type KitHandler struct {
+	KitModel models.KitRepository
+}
+
+// NewKitHandler creates a new instance of KitHandler
+func NewKitHandler(kitModel models.KitRepository) *KitHandler {
+	return &KitHandler{KitModel: kitModel}
+}
+
+// validateKit checks the fields a kit needs before it can be stored
+func validateKit(kit *models.Kit) error {
+	if kit.Name == "" {
+		return errors.New("kit name is required")
+	}
+	if len(kit.Items) == 0 {
+		return errors.New("kit must list at least one item")
+	}
+	for _, item := range kit.Items {
+		if item.Category == "" || item.Quantity < 1 {
+			return fmt.Errorf("kit item %q needs a category and a positive quantity", item.Category)
+		}
+	}
+	return nil
+}
+
+func (kh *KitHandler) createKit(w http.ResponseWriter, r *http.Request) {
+	var kit models.Kit
+	err := json.NewDecoder(r.Body).Decode(&kit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateKit(&kit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = kh.KitModel.CreateKit(r.Context(), &kit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error creating kit: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "Kit created successfully")
+}
+
+func (kh *KitHandler) getAllKits(w http.ResponseWriter, r *http.Request) {
+	kits, err := kh.KitModel.GetAllKits(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting kits: %v", err), errorStatus(r, err))
+		return
+	}
+
+	kitsJSON, err := json.Marshal(kits)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error marshaling kits to JSON: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(kitsJSON)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("writing response", "error", err)
+	}
+}
+
+func (kh *KitHandler) getKit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid kit ID", http.StatusBadRequest)
+		return
+	}
+
+	kit, err := kh.KitModel.GetKitByID(r.Context(), id)
+	if errors.Is(err, models.ErrKitNotFound) {
+		http.Error(w, "Kit not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving kit: %v", err), errorStatus(r, err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(kit)
+}
+
+func (kh *KitHandler) updateKit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid kit ID", http.StatusBadRequest)
+		return
+	}
+
+	var updatedKit models.Kit
+	err = json.NewDecoder(r.Body).Decode(&updatedKit)
+	if err != nil {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateKit(&updatedKit); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	updatedKit.ID = id
+
+	err = kh.KitModel.UpdateKit(r.Context(), &updatedKit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error updating kit: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Kit updated successfully")
+}
+
+func (kh *KitHandler) deleteKit(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid kit ID", http.StatusBadRequest)
+		return
+	}
+
+	err = kh.KitModel.ArchiveKit(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting kit: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Kit deleted successfully")
+}
+
+// onboardEmployee provisions a kit for an employee: POST /employees/{id}/onboard?kit=engineering[&strict=true]
+func (kh *KitHandler) onboardEmployee(w http.ResponseWriter, r *http.Request) {
+	employeeID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	strict := false
+	if v := query.Get("strict"); v != "" {
+		strict, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "Invalid strict flag", http.StatusBadRequest)
+			return
+		}
+	}
+
+	onboarding, err := kh.KitModel.OnboardEmployee(r.Context(), employeeID, query.Get("kit"), strict)
+	switch {
+	case errors.Is(err, models.ErrEmployeeNotFound):
+		http.Error(w, "Employee not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrKitNotFound):
+		http.Error(w, "No kit found for this employee", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrKitShortfall):
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(onboarding)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Error onboarding employee: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(onboarding)
+}
+
+// RegisterKitRoutes registers the kit template and onboarding routes on the provided router
+func RegisterKitRoutes(router *mux.Router, kh *KitHandler) {
+	router.HandleFunc("/kits", kh.createKit).Methods("POST")
+	router.HandleFunc("/kits", kh.getAllKits).Methods("GET")
+	router.HandleFunc("/kits/{id}", kh.getKit).Methods("GET")
+	router.HandleFunc("/kits/{id}", kh.updateKit).Methods("PUT")
+	router.HandleFunc("/kits/{id}", kh.deleteKit).Methods("DELETE")
+	router.HandleFunc("/employees/{id}/onboard", kh.onboardEmployee).Methods("POST")
+}
@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boombuler/barcode"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/labels"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+const (
+	defaultLabelSize = 300
+	maxLabelSize     = 2000
+	printerTimeout   = 10 * time.Second
+)
+
+// LabelHandler renders printable labels for assets
+type LabelHandler struct {
+	AssetModel models.AssetRepository
+	// BaseURL is the public address of the API, used for the deep link in QR codes
+	BaseURL string
+	// Printers maps printer names to the host:port of Zebra printers accepting raw ZPL.
+	// Labels are only ever sent to these addresses.
+	Printers map[string]string
+}
+
+// NewLabelHandler creates a new instance of LabelHandler
+func NewLabelHandler(assetModel models.AssetRepository, baseURL string, printers map[string]string) *LabelHandler {
+	return &LabelHandler{AssetModel: assetModel, BaseURL: strings.TrimRight(baseURL, "/"), Printers: printers}
+}
+
+// assetLink is the deep link encoded in an asset's QR code
+func (lh *LabelHandler) assetLink(asset *models.Asset) string {
+	return fmt.Sprintf("%s/assets/%s", lh.BaseURL, asset.Id)
+}
+
+// assetLabel collects what gets printed for an asset
+func (lh *LabelHandler) assetLabel(asset *models.Asset) labels.Label {
+	subtitle := asset.Company
+	if asset.Category != "" {
+		subtitle = strings.TrimSpace(subtitle + " " + asset.Category)
+	}
+	return labels.Label{
+		Link:     lh.assetLink(asset),
+		Tag:      asset.Tag,
+		Title:    asset.Model,
+		Subtitle: subtitle,
+	}
+}
+
+// getAssetLabel renders a single code: GET /assets/{id}/label?code=qr|barcode&format=png|svg&width=&height=
+func (lh *LabelHandler) getAssetLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset ID", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	kind := query.Get("code")
+	if kind == "" {
+		kind = "qr"
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "png"
+	}
+
+	width, err := labelDimension(query.Get("width"), defaultLabelSize)
+	if err != nil {
+		http.Error(w, "Invalid width", http.StatusBadRequest)
+		return
+	}
+	defaultHeight := width
+	if kind == "barcode" {
+		defaultHeight = width / 3
+	}
+	height, err := labelDimension(query.Get("height"), defaultHeight)
+	if err != nil {
+		http.Error(w, "Invalid height", http.StatusBadRequest)
+		return
+	}
+
+	asset, err := lh.AssetModel.GetAssetByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving asset: %v", err), errorStatus(r, err))
+		return
+	}
+
+	var code barcode.Barcode
+	switch kind {
+	case "qr":
+		code, err = labels.QRCode(lh.assetLink(asset))
+	case "barcode":
+		code, err = labels.Code128(asset.Tag)
+	default:
+		http.Error(w, "code must be qr or barcode", http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, labels.ErrNoTag) {
+		http.Error(w, "Asset has no tag to encode", http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error encoding label: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Render into a buffer first so an encoding error can still become a proper HTTP error
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		w.Header().Set("Content-Type", "image/png")
+		err = labels.WritePNG(&buf, code, width, height)
+	case "svg":
+		w.Header().Set("Content-Type", "image/svg+xml")
+		err = labels.WriteSVG(&buf, code, width, height)
+	default:
+		http.Error(w, "format must be png or svg", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error rendering label: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Write(buf.Bytes())
+}
+
+// labelDimension parses a pixel size, falling back to def
+func labelDimension(value string, def int) (int, error) {
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxLabelSize {
+		return 0, fmt.Errorf("dimension must be between 1 and %d", maxLabelSize)
+	}
+	return n, nil
+}
+
+// getLabelSheet renders a PDF sheet for the filtered asset list: GET /labels?layout=avery-5160&category=laptop
+func (lh *LabelHandler) getLabelSheet(w http.ResponseWriter, r *http.Request) {
+	layoutName := r.URL.Query().Get("layout")
+	if layoutName == "" {
+		layoutName = labels.DefaultLayout
+	}
+	layout, ok := labels.Layouts[layoutName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown layout; use one of %s", strings.Join(labels.LayoutNames(), ", ")), http.StatusBadRequest)
+		return
+	}
+
+	assets, err := lh.AssetModel.GetAssets(r.Context(), assetFilterFromQuery(r))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error getting assets: %v", err), errorStatus(r, err))
+		return
+	}
+
+	sheet := make([]labels.Label, 0, len(assets))
+	for _, asset := range assets {
+		sheet = append(sheet, lh.assetLabel(asset))
+	}
+
+	var buf bytes.Buffer
+	if err := labels.WriteSheet(&buf, layout, sheet); err != nil {
+		http.Error(w, fmt.Sprintf("Error rendering label sheet: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="labels-%s.pdf"`, layoutName))
+	w.Write(buf.Bytes())
+}
+
+// zplOptions selects how a thermal label is rendered
+type zplOptions struct {
+	Template string `json:"template"`
+	Size     string `json:"size"`
+	DPI      int    `json:"dpi"`
+	Copies   int    `json:"copies"`
+}
+
+// renderZPL loads the asset and renders its ZPL label, writing any error to w
+func (lh *LabelHandler) renderZPL(w http.ResponseWriter, r *http.Request, options zplOptions) ([]byte, bool) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	if options.Template == "" {
+		options.Template = labels.DefaultZPLTemplate
+	}
+	if options.Size == "" {
+		options.Size = labels.DefaultLabelSize
+	}
+	if options.DPI == 0 {
+		options.DPI = labels.DefaultDPI
+	}
+	if _, ok := labels.ZPLTemplates[options.Template]; !ok {
+		http.Error(w, fmt.Sprintf("Unknown template; use one of %s", strings.Join(labels.ZPLTemplateNames(), ", ")), http.StatusBadRequest)
+		return nil, false
+	}
+	size, err := labels.ParseLabelSize(options.Size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	asset, err := lh.AssetModel.GetAssetByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Asset not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving asset: %v", err), errorStatus(r, err))
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	err = labels.RenderZPL(&buf, options.Template, size, options.DPI, options.Copies, labels.ZPLFields{
+		Tag:     asset.Tag,
+		Model:   asset.Model,
+		Company: asset.Company,
+		Serial:  asset.Serial,
+		Link:    lh.assetLink(asset),
+	})
+	if errors.Is(err, labels.ErrNoTag) {
+		http.Error(w, "Asset has no tag to encode", http.StatusUnprocessableEntity)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error rendering label: %v", err), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return buf.Bytes(), true
+}
+
+// getAssetZPL streams the ZPL label: GET /assets/{id}/label.zpl?template=standard&size=2x1&dpi=203&copies=1
+func (lh *LabelHandler) getAssetZPL(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	options := zplOptions{Template: query.Get("template"), Size: query.Get("size")}
+
+	for name, target := range map[string]*int{"dpi": &options.DPI, "copies": &options.Copies} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid %s", name), http.StatusBadRequest)
+				return
+			}
+			*target = n
+		}
+	}
+
+	zpl, ok := lh.renderZPL(w, r, options)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-zpl")
+	w.Write(zpl)
+}
+
+// printAssetLabel sends the ZPL label to a configured printer: POST /assets/{id}/label/print
+func (lh *LabelHandler) printAssetLabel(w http.ResponseWriter, r *http.Request) {
+	var job struct {
+		zplOptions
+		Printer string `json:"printer"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&job)
+	if err != nil {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	address, ok := lh.Printers[job.Printer]
+	if !ok {
+		http.Error(w, "Unknown printer", http.StatusBadRequest)
+		return
+	}
+
+	zpl, ok := lh.renderZPL(w, r, job.zplOptions)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), printerTimeout)
+	defer cancel()
+
+	if err := labels.SendToPrinter(ctx, address, zpl); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Label sent to printer successfully")
+}
+
+// RegisterLabelRoutes registers the asset label routes on the provided router
+func RegisterLabelRoutes(router *mux.Router, lh *LabelHandler) {
+	router.HandleFunc("/assets/{id}/label", lh.getAssetLabel).Methods("GET")
+	router.HandleFunc("/assets/{id}/label.zpl", lh.getAssetZPL).Methods("GET")
+	router.HandleFunc("/assets/{id}/label/print", lh.printAssetLabel).Methods("POST")
+	router.HandleFunc("/labels", lh.getLabelSheet).Methods("GET")
+}
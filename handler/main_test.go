@@ -0,0 +1,15 @@
+package handler_test
+
+import (
+	"io"
+	"log"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/db/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	// The request logging middleware would otherwise print every request of every test
+	log.SetOutput(io.Discard)
+	dbtest.Main(m)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// MeHandler serves the self-service routes under /me, the only ones employee sessions can use
+type MeHandler struct {
+	Sessions models.SessionRepository
+	Assets   models.AssetRepository
+}
+
+// NewMeHandler creates a new instance of MeHandler
+func NewMeHandler(sessions models.SessionRepository, assets models.AssetRepository) *MeHandler {
+	return &MeHandler{Sessions: sessions, Assets: assets}
+}
+
+// caller is who made the request; exactly one of admin, employee and service is set
+type caller struct {
+	Type     string           `json:"type"`
+	Admin    *models.Admin    `json:"admin,omitempty"`
+	Employee *models.Employee `json:"employee,omitempty"`
+	Service  string           `json:"service,omitempty"`
+	Session  *models.Session  `json:"session,omitempty"`
+}
+
+// getMe returns the admin, employee or service making the request, with the session it used
+func (mh *MeHandler) getMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var me caller
+	if admin, ok := auth.AdminFromContext(ctx); ok {
+		me.Type, me.Admin = "admin", admin
+	} else if employee, ok := auth.EmployeeFromContext(ctx); ok {
+		me.Type, me.Employee = "employee", employee
+	} else if service, ok := auth.ServiceFromContext(ctx); ok {
+		me.Type, me.Service = "service", service
+	} else {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	me.Session, _ = auth.SessionFromContext(ctx)
+
+	json.NewEncoder(w).Encode(me)
+}
+
+// getMyAssets lists the assets currently assigned to the calling employee
+func (mh *MeHandler) getMyAssets(w http.ResponseWriter, r *http.Request) {
+	employee, ok := auth.EmployeeFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Only employees have assets assigned", http.StatusBadRequest)
+		return
+	}
+
+	writeJSONArray(w, r, "assets", func(ctx context.Context, fn func(*models.Asset) error) error {
+		return mh.Assets.ForEachAssignedAsset(ctx, employee.ID, fn)
+	})
+}
+
+// deleteMySession logs out, ending the session the request was made with
+func (mh *MeHandler) deleteMySession(w http.ResponseWriter, r *http.Request) {
+	session, ok := auth.SessionFromContext(r.Context())
+	if !ok {
+		http.Error(w, "The request was not made with a session", http.StatusBadRequest)
+		return
+	}
+
+	if err := mh.Sessions.ArchiveSession(r.Context(), session.ID); err != nil {
+		http.Error(w, fmt.Sprintf("Error ending session: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "session ended successfully")
+}
+
+func RegisterMeRoutes(router *mux.Router, mh *MeHandler) {
+	router.HandleFunc("/me", mh.getMe).Methods("GET")
+	router.HandleFunc("/me/assets", mh.getMyAssets).Methods("GET")
+	router.HandleFunc("/me/session", mh.deleteMySession).Methods("DELETE")
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// OffboardingHandler handles reclaiming assets from departing employees
+type OffboardingHandler struct {
+	OffboardingModel models.OffboardingRepository
+}
+
+// NewOffboardingHandler creates a new instance of OffboardingHandler
+func NewOffboardingHandler(offboardingModel models.OffboardingRepository) *OffboardingHandler {
+	return &OffboardingHandler{OffboardingModel: offboardingModel}
+}
+
+func (oh *OffboardingHandler) startOffboarding(w http.ResponseWriter, r *http.Request) {
+	employeeID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+		return
+	}
+
+	offboarding, err := oh.OffboardingModel.StartOffboarding(r.Context(), employeeID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting offboarding: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(offboarding)
+}
+
+func (oh *OffboardingHandler) getOffboarding(w http.ResponseWriter, r *http.Request) {
+	employeeID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+		return
+	}
+
+	offboarding, err := oh.OffboardingModel.GetOffboardingByEmployeeID(r.Context(), employeeID)
+	if errors.Is(err, models.ErrOffboardingNotFound) {
+		http.Error(w, "Offboarding not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving offboarding: %v", err), errorStatus(r, err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(offboarding)
+}
+
+func (oh *OffboardingHandler) resolveOffboardingItem(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	employeeID, err := uuid.Parse(vars["id"])
+	if err != nil {
+		http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+		return
+	}
+
+	itemID, err := uuid.Parse(vars["itemId"])
+	if err != nil {
+		http.Error(w, "Invalid offboarding item ID", http.StatusBadRequest)
+		return
+	}
+
+	var resolution struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&resolution)
+	if err != nil {
+		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		return
+	}
+
+	err = oh.OffboardingModel.ResolveOffboardingItem(r.Context(), employeeID, itemID, resolution.Status, resolution.Note)
+	switch {
+	case errors.Is(err, models.ErrInvalidOffboardingStatus):
+		http.Error(w, "Status must be one of returned, lost or written_off", http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrOffboardingNotFound):
+		http.Error(w, "Offboarding item not found", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrOffboardingItemResolved):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Error updating offboarding item: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Offboarding item updated successfully")
+}
+
+func (oh *OffboardingHandler) completeOffboarding(w http.ResponseWriter, r *http.Request) {
+	employeeID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid employee ID", http.StatusBadRequest)
+		return
+	}
+
+	err = oh.OffboardingModel.CompleteOffboarding(r.Context(), employeeID)
+	switch {
+	case errors.Is(err, models.ErrOffboardingNotFound):
+		http.Error(w, "No offboarding in progress for this employee", http.StatusNotFound)
+		return
+	case errors.Is(err, models.ErrOffboardingIncomplete), errors.Is(err, models.ErrEmployeeHasAssets):
+		http.Error(w, fmt.Sprintf("Cannot complete offboarding: %v", err), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Error completing offboarding: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Employee offboarded successfully")
+}
+
+// RegisterOffboardingRoutes registers the employee offboarding routes on the provided router
+func RegisterOffboardingRoutes(router *mux.Router, oh *OffboardingHandler) {
+	router.HandleFunc("/employees/{id}/offboarding", oh.startOffboarding).Methods("POST")
+	router.HandleFunc("/employees/{id}/offboarding", oh.getOffboarding).Methods("GET")
+	router.HandleFunc("/employees/{id}/offboarding/items/{itemId}", oh.resolveOffboardingItem).Methods("PUT")
+	router.HandleFunc("/employees/{id}/offboarding/complete", oh.completeOffboarding).Methods("POST")
+}
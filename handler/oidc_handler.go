@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/oidc"
+)
+
+// OIDCHandler logs admins and employees in through the OpenID Connect provider
+type OIDCHandler struct {
+	Auth     *auth.Authenticator
+	Provider *oidc.Provider
+	// PostLoginURL is where browsers are sent once logged in; empty answers with the session as JSON
+	PostLoginURL string
+}
+
+// NewOIDCHandler creates a new instance of OIDCHandler
+func NewOIDCHandler(authenticator *auth.Authenticator, provider *oidc.Provider, postLoginURL string) *OIDCHandler {
+	return &OIDCHandler{Auth: authenticator, Provider: provider, PostLoginURL: postLoginURL}
+}
+
+// OIDCLoginRoute sends the browser to the provider, which sends it back to OIDCCallbackRoute. Both
+// are reachable without a session.
+const (
+	OIDCLoginRoute    = "GET /sessions/oidc"
+	OIDCCallbackRoute = "GET /sessions/oidc/callback"
+)
+
+// Between the two routes the login's state, nonce and PKCE verifier are kept in a short-lived
+// cookie, which only the callback can read
+const (
+	oidcLoginCookie   = "oidc_login"
+	oidcLoginPath     = "/sessions/oidc"
+	oidcLoginLifetime = 10 * time.Minute
+)
+
+func (oh *OIDCHandler) setLoginCookie(w http.ResponseWriter, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    value,
+		Path:     oidcLoginPath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   oh.Auth.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// loginRequestFromCookie reads the login started in this browser from its cookie
+func loginRequestFromCookie(r *http.Request) (*oidc.AuthRequest, bool) {
+	cookie, err := r.Cookie(oidcLoginCookie)
+	if err != nil {
+		return nil, false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return nil, false
+	}
+	return &oidc.AuthRequest{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, true
+}
+
+// login starts a login at the provider
+func (oh *OIDCHandler) login(w http.ResponseWriter, r *http.Request) {
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting login: %v", err), http.StatusInternalServerError)
+		return
+	}
+	authURL, err := oh.Provider.AuthCodeURL(r.Context(), req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting login: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	oh.setLoginCookie(w, req.State+"."+req.Nonce+"."+req.Verifier, int(oidcLoginLifetime.Seconds()))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback finishes a login the provider sent the browser back from, exchanging the code for an
+// ID token and starting a session for the admin or employee it maps to. Admins with two-factor
+// authentication get 202 and a challenge for POST /sessions/2fa, as with a password login.
+func (oh *OIDCHandler) callback(w http.ResponseWriter, r *http.Request) {
+	req, ok := loginRequestFromCookie(r)
+	// The login cookie is good for one callback
+	oh.setLoginCookie(w, "", -1)
+
+	query := r.URL.Query()
+	if code := query.Get("error"); code != "" {
+		http.Error(w, (&oidc.Error{Code: code, Description: query.Get("error_description")}).Error(), http.StatusUnauthorized)
+		return
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(req.State)) != 1 {
+		http.Error(w, "Login state does not match; start the login again", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code") == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := oh.Provider.Exchange(r.Context(), query.Get("code"), req)
+	var providerErr *oidc.Error
+	switch {
+	case errors.As(err, &providerErr), errors.Is(err, oidc.ErrInvalidToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Error logging in with the identity provider: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	session, token, err := oh.Auth.SingleSignOn(r.Context(), oh.Provider.Issuer(), claims)
+	if err != nil {
+		writeLoginError(w, r, err)
+		return
+	}
+	if err := oh.Auth.SetSessionCookies(w, session, token); err != nil {
+		http.Error(w, fmt.Sprintf("Error creating session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if oh.PostLoginURL != "" {
+		http.Redirect(w, r, oh.PostLoginURL, http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(issuedSession{Session: session, Token: token})
+}
+
+// RegisterOIDCRoutes adds the single sign-on routes. Register them before the session routes, whose
+// /sessions/{id} would otherwise match the login route.
+func RegisterOIDCRoutes(router *mux.Router, oh *OIDCHandler) {
+	router.HandleFunc("/sessions/oidc", oh.login).Methods("GET")
+	router.HandleFunc("/sessions/oidc/callback", oh.callback).Methods("GET")
+}
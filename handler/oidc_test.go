@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+	"github.com/cameo1221/Go-Asset/oidc"
+	"github.com/cameo1221/Go-Asset/oidc/oidctest"
+)
+
+func TestSingleSignOn(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		f := seed(t, repos)
+		stub := oidctest.NewServer(t, "go-asset", "s3cret")
+		authenticator := newAuthenticator(repos)
+		router := newRouterWith(repos, authenticator,
+			oidc.NewProvider(stub.Config("https://assets.example.com/sessions/oidc/callback"), nil), nil)
+
+		do := func(method, path, token string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			for _, cookie := range cookies {
+				req.AddCookie(cookie)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+		// login goes through the provider as user and returns the callback's answer
+		login := func(user oidctest.User) *httptest.ResponseRecorder {
+			t.Helper()
+			stub.SetUser(user)
+			rec := do("GET", "/sessions/oidc", "")
+			if rec.Code != http.StatusFound {
+				t.Fatalf("starting login = %d %s, want 302", rec.Code, rec.Body)
+			}
+			cookies := rec.Result().Cookies()
+			callback := oidctest.Authorize(t, rec.Header().Get("Location"))
+			return do("GET", callback.RequestURI(), "", cookies...)
+		}
+		session := func(rec *httptest.ResponseRecorder) string {
+			t.Helper()
+			var issued struct {
+				models.Session
+				Token string `json:"token"`
+			}
+			if rec.Code != http.StatusCreated || json.NewDecoder(rec.Body).Decode(&issued) != nil || issued.Token == "" {
+				t.Fatalf("callback = %d %s, want 201 with a session", rec.Code, rec.Body)
+			}
+			return issued.Token
+		}
+
+		// An admin is matched by verified email, and later logins follow the linked subject
+		token := session(login(oidctest.User{Subject: "grace", Email: "GRACE@example.com", EmailVerified: true}))
+		if rec := do("GET", "/admins", token); rec.Code != http.StatusOK {
+			t.Errorf("admin single sign-on session = %d, want 200", rec.Code)
+		}
+		token = session(login(oidctest.User{Subject: "grace", Email: "grace.hopper@example.com"}))
+		if rec := do("GET", "/admins", token); rec.Code != http.StatusOK {
+			t.Errorf("linked admin = %d, want 200", rec.Code)
+		}
+
+		// An employee only gets the self-service routes
+		token = session(login(oidctest.User{Subject: "ada", Email: "ada@example.com", EmailVerified: true}))
+		rec := do("GET", "/me", token)
+		var me struct {
+			Type     string           `json:"type"`
+			Employee *models.Employee `json:"employee"`
+		}
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&me) != nil || me.Type != "employee" || me.Employee.ID != f.employee.ID {
+			t.Errorf("GET /me as employee = %d %s, want Ada", rec.Code, rec.Body)
+		}
+		rec = do("GET", "/me/assets", token)
+		var assets []models.Asset
+		if rec.Code != http.StatusOK || json.NewDecoder(rec.Body).Decode(&assets) != nil || len(assets) != 1 || assets[0].Id != f.assigned.Id || assets[0].Tag != f.assigned.Tag {
+			t.Errorf("GET /me/assets = %d %s, want Ada's laptop", rec.Code, rec.Body)
+		}
+		if rec := do("GET", "/assets", token); rec.Code != http.StatusForbidden {
+			t.Errorf("employee session on /assets = %d, want 403", rec.Code)
+		}
+		if rec := do("DELETE", "/me/session", token); rec.Code != http.StatusOK {
+			t.Errorf("logging out = %d, want 200", rec.Code)
+		}
+		if rec := do("GET", "/me", token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("ended employee session = %d, want 401", rec.Code)
+		}
+
+		// Unknown and unverified emails are refused until employees are provisioned
+		if rec := login(oidctest.User{Subject: "linus", Email: "linus@example.com", EmailVerified: true}); rec.Code != http.StatusForbidden {
+			t.Errorf("unknown identity = %d, want 403", rec.Code)
+		}
+		if rec := login(oidctest.User{Subject: "mallory", Email: "barbara@example.com"}); rec.Code != http.StatusForbidden {
+			t.Errorf("unverified email = %d, want 403", rec.Code)
+		}
+		authenticator.ProvisionEmployees = true
+		session(login(oidctest.User{Subject: "linus", Email: "linus@example.com", EmailVerified: true, Name: "Linus"}))
+		if employee, err := repos.Employees.GetEmployeeByEmail(ctx, "linus@example.com"); err != nil || employee.Name != "Linus" {
+			t.Errorf("provisioned employee = %+v, %v; want Linus", employee, err)
+		}
+
+		// Admins with an authenticator still give the second factor
+		if err := repos.TwoFactor.StartTOTP(ctx, &models.TOTP{AdminID: f.admin.ID, Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}); err != nil {
+			t.Fatalf("StartTOTP: %v", err)
+		}
+		if err := repos.TwoFactor.ConfirmTOTP(ctx, f.admin.ID, 0, nil); err != nil {
+			t.Fatalf("ConfirmTOTP: %v", err)
+		}
+		if rec := login(oidctest.User{Subject: "grace"}); rec.Code != http.StatusAccepted || !strings.Contains(rec.Body.String(), "challenge") {
+			t.Errorf("admin with two-factor authentication = %d %s, want 202 with a challenge", rec.Code, rec.Body)
+		}
+
+		// The callback has to come back to the browser that started the login
+		stub.SetUser(oidctest.User{Subject: "ada"})
+		start := do("GET", "/sessions/oidc", "")
+		callback := oidctest.Authorize(t, start.Header().Get("Location"))
+		if rec := do("GET", callback.RequestURI(), ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("callback without the login cookie = %d, want 400", rec.Code)
+		}
+		query := callback.Query()
+		query.Set("state", "forged")
+		if rec := do("GET", "/sessions/oidc/callback?"+query.Encode(), "", start.Result().Cookies()...); rec.Code != http.StatusBadRequest {
+			t.Errorf("callback with another state = %d, want 400", rec.Code)
+		}
+		if rec := do("GET", "/sessions/oidc/callback?error=access_denied&state=x", ""); rec.Code != http.StatusUnauthorized {
+			t.Errorf("callback with a provider error = %d, want 401", rec.Code)
+		}
+	})
+}
+
+func TestPasswordLoginDisabled(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		f := seed(t, repos)
+		authenticator := newAuthenticator(repos)
+		authenticator.DisablePasswordLogin = true
+		router := newRouterWith(repos, authenticator, unreachableProvider, nil)
+
+		do := func(method, path, body, token string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		if rec := do("POST", "/sessions", `{"email":"grace@example.com","password":"secret"}`, ""); rec.Code != http.StatusForbidden {
+			t.Errorf("password login = %d, want 403", rec.Code)
+		}
+		if rec := do("POST", "/admins", `{"name":"Ken","email":"ken@example.com","password":"hunter2"}`, f.token); rec.Code != http.StatusBadRequest {
+			t.Errorf("creating an admin with a password = %d, want 400", rec.Code)
+		}
+		if rec := do("POST", "/admins", `{"name":"Ken","email":"ken@example.com"}`, f.token); rec.Code != http.StatusCreated {
+			t.Errorf("creating an admin without a password = %d %s, want 201", rec.Code, rec.Body)
+		}
+		admin, err := repos.Admins.GetAdminByEmail(context.Background(), "ken@example.com")
+		if err != nil || admin.Password != "" {
+			t.Errorf("created admin = %+v, %v; want one with no password", admin, err)
+		}
+
+		// Even with password login back on, an admin without a password cannot use it
+		authenticator.DisablePasswordLogin = false
+		if _, _, err := authenticator.Login(context.Background(), "ken@example.com", ""); !errors.Is(err, auth.ErrInvalidCredentials) {
+			t.Errorf("Login without a password = %v, want ErrInvalidCredentials", err)
+		}
+	})
+}
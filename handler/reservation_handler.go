@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// Windows used when a request does not specify one
+const (
+	defaultAvailabilityWindow = 7 * 24 * time.Hour
+	calendarFeedLookback      = 30 * 24 * time.Hour
+	calendarFeedLookahead     = 365 * 24 * time.Hour
+)
+
+// CalendarFeedRoutes serve the .ics feeds. Calendar apps cannot log in, so these routes are
+// public and each feed's URL carries a token that only opens that feed.
+var CalendarFeedRoutes = []string{
+	"GET /assets/{id}/reservations.ics",
+	"GET /employees/{id}/reservations.ics",
+}
+
+// ReservationHandler handles booking shared assets for time windows
+type ReservationHandler struct {
+	ReservationModel models.ReservationRepository
+	// BaseURL is the public address feed URLs are built on
+	BaseURL string
+	// FeedKey signs the calendar feed tokens; changing it revokes every feed URL handed out
+	FeedKey []byte
+}
+
+// NewReservationHandler creates a new instance of ReservationHandler
+func NewReservationHandler(reservationModel models.ReservationRepository, baseURL string, feedKey []byte) *ReservationHandler {
+	return &ReservationHandler{ReservationModel: reservationModel, BaseURL: strings.TrimRight(baseURL, "/"), FeedKey: feedKey}
+}
+
+// CalendarFeedToken is the token that opens the calendar feed of the asset or employee, kind
+// being "asset" or "employee"
+func CalendarFeedToken(key []byte, kind string, id uuid.UUID) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(kind + ":" + id.String()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseWindow reads RFC 3339 from/to query parameters, falling back to the given defaults
+func parseWindow(r *http.Request, defaultFrom, defaultTo time.Time) (time.Time, time.Time, error) {
+	from, to := defaultFrom, defaultTo
+	query := r.URL.Query()
+
+	if v := query.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from: %w", err)
+		}
+		from = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to: %w", err)
+		}
+		to = t
+	}
+	if !to.After(from) {
+		return from, to, errors.New("to must be after from")
+	}
+
+	return from, to, nil
+}
+
+func (rh *ReservationHandler) createReservation(w http.ResponseWriter, r *http.Request) {
+	var reservation models.Reservation
+	err := json.NewDecoder(r.Body).Decode(&reservation)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	err = rh.ReservationModel.CreateReservation(r.Context(), &reservation)
+	switch {
+	case errors.Is(err, models.ErrInvalidReservation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrReservationConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, fmt.Sprintf("Error creating reservation: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+func (rh *ReservationHandler) getReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	reservation, err := rh.ReservationModel.GetReservationByID(r.Context(), id)
+	if errors.Is(err, models.ErrReservationNotFound) {
+		http.Error(w, "Reservation not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving reservation: %v", err), errorStatus(r, err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(reservation)
+}
+
+func (rh *ReservationHandler) deleteReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	err = rh.ReservationModel.ArchiveReservation(r.Context(), id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error deleting reservation: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Reservation deleted successfully")
+}
+
+func (rh *ReservationHandler) getAssetAvailability(w http.ResponseWriter, r *http.Request) {
+	assetID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid asset ID", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	from, to, err := parseWindow(r, now, now.Add(defaultAvailabilityWindow))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	availability, err := rh.ReservationModel.GetAvailability(r.Context(), assetID, from, to)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving availability: %v", err), errorStatus(r, err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(availability)
+}
+
+func (rh *ReservationHandler) getAssetCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	rh.serveCalendarFeed(w, r, "asset", "/assets/%s/reservations.ics")
+}
+
+func (rh *ReservationHandler) getEmployeeCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	rh.serveCalendarFeed(w, r, "employee", "/employees/%s/reservations.ics")
+}
+
+// serveCalendarFeed returns the URL, token included, a calendar app subscribes to for the asset
+// or employee named in the route
+func (rh *ReservationHandler) serveCalendarFeed(w http.ResponseWriter, r *http.Request, kind, path string) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s ID", kind), http.StatusBadRequest)
+		return
+	}
+
+	url := rh.BaseURL + fmt.Sprintf(path, id) + "?token=" + CalendarFeedToken(rh.FeedKey, kind, id)
+	json.NewEncoder(w).Encode(map[string]string{"url": url})
+}
+
+func (rh *ReservationHandler) getAssetCalendar(w http.ResponseWriter, r *http.Request) {
+	rh.serveCalendar(w, r, "asset", rh.ReservationModel.GetAssetReservations)
+}
+
+func (rh *ReservationHandler) getEmployeeCalendar(w http.ResponseWriter, r *http.Request) {
+	rh.serveCalendar(w, r, "employee", rh.ReservationModel.GetEmployeeReservations)
+}
+
+// serveCalendar writes the .ics feed for the asset or employee named in the route, if the URL
+// carries that feed's token
+func (rh *ReservationHandler) serveCalendar(w http.ResponseWriter, r *http.Request, kind string,
+	list func(context.Context, uuid.UUID, time.Time, time.Time) ([]*models.Reservation, error)) {
+	id, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid %s ID", kind), http.StatusBadRequest)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if !hmac.Equal([]byte(token), []byte(CalendarFeedToken(rh.FeedKey, kind, id))) {
+		http.Error(w, "Invalid calendar feed token", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now()
+	from, to, err := parseWindow(r, now.Add(-calendarFeedLookback), now.Add(calendarFeedLookahead))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reservations, err := list(r.Context(), id, from, to)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error retrieving reservations: %v", err), errorStatus(r, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-%s.ics"`, kind, id))
+	writeICalendar(w, fmt.Sprintf("Reservations for %s %s", kind, id), reservations)
+}
+
+// RegisterReservationRoutes registers the reservation and calendar routes on the provided router
+func RegisterReservationRoutes(router *mux.Router, rh *ReservationHandler) {
+	router.HandleFunc("/reservations", rh.createReservation).Methods("POST")
+	router.HandleFunc("/reservations/{id}", rh.getReservation).Methods("GET")
+	router.HandleFunc("/reservations/{id}", rh.deleteReservation).Methods("DELETE")
+	router.HandleFunc("/assets/{id}/availability", rh.getAssetAvailability).Methods("GET")
+	router.HandleFunc("/assets/{id}/calendar-feed", rh.getAssetCalendarFeed).Methods("GET")
+	router.HandleFunc("/employees/{id}/calendar-feed", rh.getEmployeeCalendarFeed).Methods("GET")
+	router.HandleFunc("/assets/{id}/reservations.ics", rh.getAssetCalendar).Methods("GET")
+	router.HandleFunc("/employees/{id}/reservations.ics", rh.getEmployeeCalendar).Methods("GET")
+}
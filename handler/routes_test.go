@@ -0,0 +1,430 @@
+package handler_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/handler"
+	"github.com/cameo1221/Go-Asset/middleware"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/memory"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+	"github.com/cameo1221/Go-Asset/oidc"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+)
+
+// password is the fixture admin's password; hashing it once keeps the tests fast
+const password = "secret"
+
+var passwordHash = func() string {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}()
+
+// newAuthenticator authenticates against repos, locking accounts after three failed logins
+func newAuthenticator(repos modeltest.Repositories) *auth.Authenticator {
+	authenticator := auth.NewAuthenticator(repos.Admins, repos.Sessions, ratelimit.NewMemory(),
+		ratelimit.LockoutPolicy{Threshold: 3, Base: time.Minute, Max: time.Hour})
+	authenticator.APIKeys = repos.APIKeys
+	authenticator.TwoFactor = repos.TwoFactor
+	authenticator.Employees = repos.Employees
+	authenticator.Identities = repos.Identities
+	return authenticator
+}
+
+// feedKey signs the calendar feed tokens in tests
+var feedKey = []byte("calendar-feed-test-key")
+
+// unreachableProvider is an identity provider no test can reach, for routers that do not log in
+// through it
+var unreachableProvider = oidc.NewProvider(oidc.Config{
+	Issuer:      "http://127.0.0.1:1",
+	ClientID:    "go-asset",
+	RedirectURL: "https://assets.example.com/sessions/oidc/callback",
+}, nil)
+
+// newRouter wires every handler behind authentication the way main does
+func newRouter(repos modeltest.Repositories, printers map[string]string) *mux.Router {
+	return newRouterWith(repos, newAuthenticator(repos), unreachableProvider, printers)
+}
+
+// newRouterWith wires every handler with the given authenticator and identity provider
+func newRouterWith(repos modeltest.Repositories, authenticator *auth.Authenticator, provider *oidc.Provider, printers map[string]string) *mux.Router {
+	public := []string{handler.LoginRoute, handler.SecondFactorRoute, handler.OIDCLoginRoute, handler.OIDCCallbackRoute}
+	public = append(public, handler.CalendarFeedRoutes...)
+
+	router := mux.NewRouter()
+	api := router.NewRoute().Subrouter()
+	api.Use(middleware.CSRF(public...))
+	api.Use(authenticator.Middleware(public...))
+
+	handler.RegisterAssetRoutes(api, handler.NewAssetHandler(repos.Assets))
+	handler.RegisterAdminRoutes(api, handler.NewAdminHandler(repos.Admins, authenticator))
+	handler.RegisterEmployeeRoutes(api, handler.NewEmployeeHandler(repos.Employees))
+	handler.RegisterEmployeeassetRoutes(api, handler.NewEmployeeassetHandler(repos.EmployeeAssets))
+	handler.RegisterOIDCRoutes(api, handler.NewOIDCHandler(authenticator, provider, ""))
+	handler.RegisterSessionRoutes(api, handler.NewSessionHandler(repos.Sessions, authenticator))
+	handler.RegisterTwoFactorRoutes(api, handler.NewTwoFactorHandler(authenticator))
+	handler.RegisterAPIKeyRoutes(api, handler.NewAPIKeyHandler(repos.APIKeys))
+	handler.RegisterOffboardingRoutes(api, handler.NewOffboardingHandler(repos.Offboardings))
+	handler.RegisterKitRoutes(api, handler.NewKitHandler(repos.Kits))
+	handler.RegisterAssetRequestRoutes(api, handler.NewAssetRequestHandler(repos.AssetRequests))
+	handler.RegisterReservationRoutes(api, handler.NewReservationHandler(repos.Reservations, "https://assets.example.com", feedKey))
+	handler.RegisterAuditRoutes(api, handler.NewAuditHandler(repos.Audits))
+	handler.RegisterLabelRoutes(api, handler.NewLabelHandler(repos.Assets, "https://assets.example.com", printers))
+	handler.RegisterImportRoutes(api, handler.NewImportHandler(repos.Imports))
+	handler.RegisterMeRoutes(api, handler.NewMeHandler(repos.Sessions, repos.Assets))
+	return router
+}
+
+// fixtures is the data every route test starts from
+type fixtures struct {
+	admin       *models.Admin
+	manager     *models.Employee
+	employee    *models.Employee
+	spare       *models.Employee
+	assigned    *models.Asset
+	stock       *models.Asset
+	mapping     *models.EmployeeAsset
+	session     *models.Session
+	token       string
+	apiKey      *models.APIKey
+	kit         *models.Kit
+	request     *models.AssetRequest
+	queued      *models.AssetRequest
+	reservation *models.Reservation
+	audit       *models.Audit
+	item        *models.OffboardingItem
+}
+
+func seed(t *testing.T, repos modeltest.Repositories) *fixtures {
+	t.Helper()
+
+	ctx := context.Background()
+	f := &fixtures{}
+	check := func(what string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("seeding %s: %v", what, err)
+		}
+	}
+
+	f.admin = &models.Admin{Name: "Grace", Email: "grace@example.com", Password: passwordHash}
+	check("admin", repos.Admins.CreateAdmin(ctx, f.admin))
+
+	f.manager = &models.Employee{Name: "Barbara", Email: "barbara@example.com", Role: "manager", CreatedAt: time.Now()}
+	check("manager", repos.Employees.CreateEmployee(ctx, f.manager))
+	f.employee = &models.Employee{Name: "Ada", Email: "ada@example.com", Role: "engineer", ManagerID: &f.manager.ID, CreatedAt: time.Now()}
+	check("employee", repos.Employees.CreateEmployee(ctx, f.employee))
+	f.spare = &models.Employee{Name: "Alan", Email: "alan@example.com", Role: "engineer", CreatedAt: time.Now()}
+	check("spare employee", repos.Employees.CreateEmployee(ctx, f.spare))
+
+	f.assigned = &models.Asset{Model: "T14", Company: "Lenovo", Tag: "TAG-1", Serial: "SN-1", Category: "laptop", Location: "London"}
+	check("assigned asset", repos.Assets.CreateAsset(ctx, f.assigned))
+	f.stock = &models.Asset{Model: "T14", Company: "Lenovo", Tag: "TAG-2", Serial: "SN-2", Category: "laptop", Location: "London"}
+	check("stock asset", repos.Assets.CreateAsset(ctx, f.stock))
+
+	f.mapping = &models.EmployeeAsset{AssetID: f.assigned.Id, EmployeeID: f.employee.ID, CreatedAt: time.Now()}
+	check("assignment", repos.EmployeeAssets.CreateEmployeeAsset(ctx, f.mapping))
+
+	token, tokenHash, err := auth.GenerateSessionToken()
+	check("session", err)
+	f.session = &models.Session{AdminID: f.admin.ID, TokenHash: tokenHash}
+	check("session", repos.Sessions.CreateSession(ctx, f.session))
+	f.token = token
+
+	_, prefix, hash, err := auth.GenerateAPIKey()
+	check("API key", err)
+	f.apiKey = &models.APIKey{Name: "mdm", Prefix: prefix, Hash: hash, Scopes: []string{"assets:read"}}
+	check("API key", repos.APIKeys.CreateAPIKey(ctx, f.apiKey))
+
+	f.kit = &models.Kit{Name: "engineer", Role: "engineer", Items: []models.KitItem{{Category: "laptop", Quantity: 1}}}
+	check("kit", repos.Kits.CreateKit(ctx, f.kit))
+
+	f.request = &models.AssetRequest{EmployeeID: f.employee.ID, Category: "laptop", Justification: "second screen"}
+	check("asset request", repos.AssetRequests.CreateAssetRequest(ctx, f.request))
+	// Alan has no manager, so his request waits for an admin
+	f.queued = &models.AssetRequest{EmployeeID: f.spare.ID, Category: "laptop"}
+	check("queued asset request", repos.AssetRequests.CreateAssetRequest(ctx, f.queued))
+
+	starts := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+	f.reservation = &models.Reservation{AssetID: f.stock.Id, EmployeeID: f.spare.ID, StartsAt: starts, EndsAt: starts.Add(2 * time.Hour)}
+	check("reservation", repos.Reservations.CreateReservation(ctx, f.reservation))
+
+	f.audit = &models.Audit{Name: "Q1", Location: "London"}
+	check("audit", repos.Audits.CreateAudit(ctx, f.audit))
+
+	offboarding, err := repos.Offboardings.StartOffboarding(ctx, f.employee.ID)
+	check("offboarding", err)
+	f.item = offboarding.Items[0]
+
+	return f
+}
+
+// printer accepts raw ZPL on a local port, standing in for a Zebra printer
+func printer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("starting fake printer: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			io.Copy(io.Discard, conn)
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   func(f *fixtures) string
+		body   func(f *fixtures) string
+		want   int
+	}{
+		// Assets
+		{"create asset", "POST", static("/assets"), static(`{"Model":"XPS","Company":"Dell","Serial":"SN-9"}`), http.StatusCreated},
+		{"list assets", "GET", static("/assets"), nil, http.StatusOK},
+		{"list assets as csv", "GET", static("/assets?format=csv"), nil, http.StatusOK},
+		{"get asset", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() }, nil, http.StatusOK},
+		{"get asset bad id", "GET", static("/assets/nope"), nil, http.StatusBadRequest},
+		{"update asset", "PUT", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() }, static(`{"Model":"T14s","Company":"Lenovo","Serial":"SN-2"}`), http.StatusOK},
+		{"delete asset", "DELETE", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() }, nil, http.StatusOK},
+
+		// Admins
+		{"create admin", "POST", static("/admins"), static(`{"name":"Ken","email":"ken@example.com","password":"hunter2"}`), http.StatusCreated},
+		{"create admin without password", "POST", static("/admins"), static(`{"name":"Ken","email":"ken@example.com"}`), http.StatusBadRequest},
+		{"list admins", "GET", static("/admins"), nil, http.StatusOK},
+		{"get admin", "GET", func(f *fixtures) string { return "/admins/" + f.admin.ID.String() }, nil, http.StatusOK},
+		{"update admin", "PUT", func(f *fixtures) string { return "/admins/" + f.admin.ID.String() }, static(`{"name":"Grace H","email":"grace@example.com"}`), http.StatusOK},
+		{"delete admin", "DELETE", func(f *fixtures) string { return "/admins/" + f.admin.ID.String() }, nil, http.StatusOK},
+
+		// Employees
+		{"create employee", "POST", static("/employees"), static(`{"name":"Linus","email":"linus@example.com","role":"engineer"}`), http.StatusCreated},
+		{"list employees", "GET", static("/employees"), nil, http.StatusOK},
+		{"get employee", "GET", func(f *fixtures) string { return "/employees/" + f.employee.ID.String() }, nil, http.StatusOK},
+		{"update employee", "PUT", func(f *fixtures) string { return "/employees/" + f.spare.ID.String() }, static(`{"name":"Alan T","email":"alan@example.com","role":"engineer"}`), http.StatusOK},
+		{"archive employee through update", "PUT", func(f *fixtures) string { return "/employees/" + f.employee.ID.String() }, static(`{"name":"Ada","archive_at":"2030-01-01T00:00:00Z"}`), http.StatusBadRequest},
+		{"delete employee", "DELETE", func(f *fixtures) string { return "/employees/" + f.spare.ID.String() }, nil, http.StatusOK},
+		{"delete employee with assets", "DELETE", func(f *fixtures) string { return "/employees/" + f.employee.ID.String() }, nil, http.StatusConflict},
+
+		// Assignments
+		{"create employee asset", "POST", static("/employeeassets"), func(f *fixtures) string {
+			return `{"asset_id":"` + f.stock.Id.String() + `","employee_id":"` + f.spare.ID.String() + `"}`
+		}, http.StatusCreated},
+		{"list employee assets", "GET", static("/employeeassets"), nil, http.StatusOK},
+		{"get employee asset", "GET", func(f *fixtures) string { return "/employeeassets/" + f.mapping.ID.String() }, nil, http.StatusOK},
+		{"update employee asset", "PUT", func(f *fixtures) string { return "/employeeassets/" + f.mapping.ID.String() }, func(f *fixtures) string {
+			return `{"asset_id":"` + f.assigned.Id.String() + `","employee_id":"` + f.spare.ID.String() + `"}`
+		}, http.StatusOK},
+		{"delete employee asset", "DELETE", func(f *fixtures) string { return "/employeesassets/" + f.mapping.ID.String() }, nil, http.StatusOK},
+
+		// Sessions
+		{"create session", "POST", static("/sessions"), static(`{"email":"GRACE@example.com","password":"secret"}`), http.StatusCreated},
+		{"create session wrong password", "POST", static("/sessions"), static(`{"email":"grace@example.com","password":"guess"}`), http.StatusUnauthorized},
+		{"create session unknown admin", "POST", static("/sessions"), static(`{"email":"nobody@example.com","password":"secret"}`), http.StatusUnauthorized},
+		{"list sessions", "GET", static("/sessions"), nil, http.StatusOK},
+		{"get session", "GET", func(f *fixtures) string { return "/sessions/" + f.session.ID.String() }, nil, http.StatusOK},
+		{"update session", "PUT", func(f *fixtures) string { return "/sessions/" + f.session.ID.String() }, static(`{"archive_at":"2030-01-01T00:00:00Z"}`), http.StatusOK},
+		{"delete session", "DELETE", func(f *fixtures) string { return "/sessions/" + f.session.ID.String() }, nil, http.StatusOK},
+		{"log out everywhere", "DELETE", static("/sessions"), nil, http.StatusOK},
+		{"second factor unknown challenge", "POST", static("/sessions/2fa"), static(`{"challenge":"nope","code":"123456"}`), http.StatusUnauthorized},
+		{"second factor missing code", "POST", static("/sessions/2fa"), static(`{"challenge":"nope"}`), http.StatusBadRequest},
+		{"single sign-on provider unreachable", "GET", static("/sessions/oidc"), nil, http.StatusBadGateway},
+		{"single sign-on callback without login", "GET", static("/sessions/oidc/callback?state=x&code=y"), nil, http.StatusBadRequest},
+
+		// Self-service
+		{"get me", "GET", static("/me"), nil, http.StatusOK},
+		{"get my assets as admin", "GET", static("/me/assets"), nil, http.StatusBadRequest},
+		{"end my session", "DELETE", static("/me/session"), nil, http.StatusOK},
+
+		// Two-factor authentication
+		{"get 2fa", "GET", static("/2fa"), nil, http.StatusOK},
+		{"enroll 2fa", "POST", static("/2fa"), nil, http.StatusCreated},
+		{"confirm 2fa not enrolled", "POST", static("/2fa/confirm"), static(`{"code":"123456"}`), http.StatusConflict},
+		{"confirm 2fa missing code", "POST", static("/2fa/confirm"), static(`{}`), http.StatusBadRequest},
+		{"regenerate recovery codes not enrolled", "POST", static("/2fa/recovery-codes"), static(`{"code":"123456"}`), http.StatusConflict},
+		{"reset own 2fa", "DELETE", func(f *fixtures) string { return "/admins/" + f.admin.ID.String() + "/2fa" }, nil, http.StatusForbidden},
+		{"reset 2fa unknown admin", "DELETE", static("/admins/" + uuid.NewString() + "/2fa"), nil, http.StatusNotFound},
+		{"end admin sessions", "DELETE", func(f *fixtures) string { return "/admins/" + f.admin.ID.String() + "/sessions" }, nil, http.StatusOK},
+		{"end admin sessions bad id", "DELETE", static("/admins/nope/sessions"), nil, http.StatusBadRequest},
+
+		// API keys
+		{"create API key", "POST", static("/apikeys"), static(`{"name":"hr","scopes":["employees:read","employees:write"],"allowed_ips":["10.0.0.0/8"]}`), http.StatusCreated},
+		{"create API key unknown scope", "POST", static("/apikeys"), static(`{"name":"hr","scopes":["admins:write"]}`), http.StatusBadRequest},
+		{"create API key bad range", "POST", static("/apikeys"), static(`{"name":"hr","scopes":["employees:read"],"allowed_ips":["10.0.0.0/33"]}`), http.StatusBadRequest},
+		{"list API keys", "GET", static("/apikeys"), nil, http.StatusOK},
+		{"get API key", "GET", func(f *fixtures) string { return "/apikeys/" + f.apiKey.ID.String() }, nil, http.StatusOK},
+		{"get missing API key", "GET", func(*fixtures) string { return "/apikeys/" + uuid.NewString() }, nil, http.StatusNotFound},
+		{"rotate API key", "POST", func(f *fixtures) string { return "/apikeys/" + f.apiKey.ID.String() + "/rotate" }, static(`{"grace_period":"1h"}`), http.StatusCreated},
+		{"revoke API key", "DELETE", func(f *fixtures) string { return "/apikeys/" + f.apiKey.ID.String() }, nil, http.StatusOK},
+
+		// Offboarding
+		{"start offboarding", "POST", func(f *fixtures) string { return "/employees/" + f.employee.ID.String() + "/offboarding" }, nil, http.StatusCreated},
+		{"get offboarding", "GET", func(f *fixtures) string { return "/employees/" + f.employee.ID.String() + "/offboarding" }, nil, http.StatusOK},
+		{"get missing offboarding", "GET", func(f *fixtures) string { return "/employees/" + f.spare.ID.String() + "/offboarding" }, nil, http.StatusNotFound},
+		{"resolve offboarding item", "PUT", func(f *fixtures) string {
+			return "/employees/" + f.employee.ID.String() + "/offboarding/items/" + f.item.ID.String()
+		}, static(`{"status":"returned"}`), http.StatusOK},
+		{"resolve offboarding item bad status", "PUT", func(f *fixtures) string {
+			return "/employees/" + f.employee.ID.String() + "/offboarding/items/" + f.item.ID.String()
+		}, static(`{"status":"stolen"}`), http.StatusBadRequest},
+		{"complete offboarding early", "POST", func(f *fixtures) string { return "/employees/" + f.employee.ID.String() + "/offboarding/complete" }, nil, http.StatusConflict},
+
+		// Kits
+		{"create kit", "POST", static("/kits"), static(`{"name":"designer","role":"designer","items":[{"category":"tablet","quantity":1}]}`), http.StatusCreated},
+		{"list kits", "GET", static("/kits"), nil, http.StatusOK},
+		{"get kit", "GET", func(f *fixtures) string { return "/kits/" + f.kit.ID.String() }, nil, http.StatusOK},
+		{"update kit", "PUT", func(f *fixtures) string { return "/kits/" + f.kit.ID.String() }, static(`{"name":"engineer","role":"engineer","items":[{"category":"laptop","quantity":2}]}`), http.StatusOK},
+		{"delete kit", "DELETE", func(f *fixtures) string { return "/kits/" + f.kit.ID.String() }, nil, http.StatusOK},
+		{"onboard employee", "POST", func(f *fixtures) string { return "/employees/" + f.spare.ID.String() + "/onboard" }, nil, http.StatusCreated},
+		{"onboard employee unknown kit", "POST", func(f *fixtures) string { return "/employees/" + f.spare.ID.String() + "/onboard?kit=designer" }, nil, http.StatusNotFound},
+
+		// Asset requests
+		{"create asset request", "POST", static("/assetrequests"), func(f *fixtures) string {
+			return `{"employee_id":"` + f.spare.ID.String() + `","category":"laptop"}`
+		}, http.StatusCreated},
+		{"list asset requests", "GET", static("/assetrequests?status=pending_manager"), nil, http.StatusOK},
+		{"get asset request", "GET", func(f *fixtures) string { return "/assetrequests/" + f.request.ID.String() }, nil, http.StatusOK},
+		{"approve asset request", "POST", func(f *fixtures) string { return "/assetrequests/" + f.queued.ID.String() + "/approve" }, func(f *fixtures) string {
+			return `{"asset_id":"` + f.stock.Id.String() + `"}`
+		}, http.StatusOK},
+		{"approve asset request before its manager", "POST", func(f *fixtures) string { return "/assetrequests/" + f.request.ID.String() + "/approve" }, func(f *fixtures) string {
+			return `{"approver_id":"` + f.manager.ID.String() + `"}`
+		}, http.StatusForbidden},
+		{"reject asset request", "POST", func(f *fixtures) string { return "/assetrequests/" + f.queued.ID.String() + "/reject" }, static(`{"reason":"not needed"}`), http.StatusOK},
+		{"reject asset request before its manager", "POST", func(f *fixtures) string { return "/assetrequests/" + f.request.ID.String() + "/reject" }, static(`{"reason":"not needed"}`), http.StatusForbidden},
+		{"escalate asset request", "POST", func(f *fixtures) string { return "/assetrequests/" + f.request.ID.String() + "/escalate" }, static(`{"reason":"manager has left"}`), http.StatusOK},
+		{"escalate asset request without a reason", "POST", func(f *fixtures) string { return "/assetrequests/" + f.request.ID.String() + "/escalate" }, static(`{}`), http.StatusBadRequest},
+		{"escalate asset request past its manager", "POST", func(f *fixtures) string { return "/assetrequests/" + f.queued.ID.String() + "/escalate" }, static(`{"reason":"manager has left"}`), http.StatusConflict},
+		{"file my asset request as admin", "POST", static("/me/asset-requests"), static(`{"category":"laptop"}`), http.StatusBadRequest},
+		{"approve as manager as admin", "POST", func(f *fixtures) string { return "/me/asset-requests/" + f.request.ID.String() + "/approve" }, nil, http.StatusForbidden},
+		{"reject as manager as admin", "POST", func(f *fixtures) string { return "/me/asset-requests/" + f.request.ID.String() + "/reject" }, static(`{"reason":"no"}`), http.StatusForbidden},
+
+		// Reservations
+		{"create reservation", "POST", static("/reservations"), func(f *fixtures) string {
+			return `{"asset_id":"` + f.stock.Id.String() + `","employee_id":"` + f.spare.ID.String() + `","starts_at":"2031-01-01T09:00:00Z","ends_at":"2031-01-01T10:00:00Z"}`
+		}, http.StatusCreated},
+		{"create overlapping reservation", "POST", static("/reservations"), func(f *fixtures) string {
+			return `{"asset_id":"` + f.stock.Id.String() + `","employee_id":"` + f.spare.ID.String() + `","starts_at":"` +
+				f.reservation.StartsAt.Format(time.RFC3339) + `","ends_at":"` + f.reservation.EndsAt.Format(time.RFC3339) + `"}`
+		}, http.StatusConflict},
+		{"get reservation", "GET", func(f *fixtures) string { return "/reservations/" + f.reservation.ID.String() }, nil, http.StatusOK},
+		{"get missing reservation", "GET", func(*fixtures) string { return "/reservations/" + uuid.NewString() }, nil, http.StatusNotFound},
+		{"delete reservation", "DELETE", func(f *fixtures) string { return "/reservations/" + f.reservation.ID.String() }, nil, http.StatusOK},
+		{"asset availability", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/availability" }, nil, http.StatusOK},
+		{"asset calendar feed", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/calendar-feed" }, nil, http.StatusOK},
+		{"employee calendar feed", "GET", func(f *fixtures) string { return "/employees/" + f.spare.ID.String() + "/calendar-feed" }, nil, http.StatusOK},
+		{"asset calendar", "GET", func(f *fixtures) string {
+			return "/assets/" + f.stock.Id.String() + "/reservations.ics?token=" + handler.CalendarFeedToken(feedKey, "asset", f.stock.Id)
+		}, nil, http.StatusOK},
+		{"asset calendar without token", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/reservations.ics" }, nil, http.StatusForbidden},
+		{"asset calendar with another feed's token", "GET", func(f *fixtures) string {
+			return "/assets/" + f.stock.Id.String() + "/reservations.ics?token=" + handler.CalendarFeedToken(feedKey, "employee", f.stock.Id)
+		}, nil, http.StatusForbidden},
+		{"employee calendar", "GET", func(f *fixtures) string {
+			return "/employees/" + f.spare.ID.String() + "/reservations.ics?token=" + handler.CalendarFeedToken(feedKey, "employee", f.spare.ID)
+		}, nil, http.StatusOK},
+
+		// Audits
+		{"create audit", "POST", static("/audits"), static(`{"name":"Q2","location":"Paris"}`), http.StatusCreated},
+		{"list audits", "GET", static("/audits"), nil, http.StatusOK},
+		{"get audit", "GET", func(f *fixtures) string { return "/audits/" + f.audit.ID.String() }, nil, http.StatusOK},
+		{"record scan", "POST", func(f *fixtures) string { return "/audits/" + f.audit.ID.String() + "/scans" }, static(`{"code":"TAG-1"}`), http.StatusCreated},
+		{"audit report", "GET", func(f *fixtures) string { return "/audits/" + f.audit.ID.String() + "/report" }, nil, http.StatusOK},
+		{"close audit", "POST", func(f *fixtures) string { return "/audits/" + f.audit.ID.String() + "/close?mark_missing_lost=true" }, nil, http.StatusOK},
+
+		// Labels
+		{"asset label", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/label" }, nil, http.StatusOK},
+		{"asset barcode svg", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/label?code=barcode&format=svg" }, nil, http.StatusOK},
+		{"asset zpl", "GET", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/label.zpl" }, nil, http.StatusOK},
+		{"missing asset zpl", "GET", func(*fixtures) string { return "/assets/" + uuid.NewString() + "/label.zpl" }, nil, http.StatusNotFound},
+		{"print label", "POST", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/label/print" }, static(`{"printer":"desk"}`), http.StatusOK},
+		{"print label unknown printer", "POST", func(f *fixtures) string { return "/assets/" + f.stock.Id.String() + "/label/print" }, static(`{"printer":"lobby"}`), http.StatusBadRequest},
+		{"label sheet", "GET", static("/labels?category=laptop"), nil, http.StatusOK},
+
+		// Imports
+		{"import assets", "POST", static("/import/assets"), static("model,company,serial\nXPS,Dell,SN-9\n"), http.StatusOK},
+		{"import invalid assets", "POST", static("/import/assets"), static("model,company,serial,status\nXPS,Dell,SN-9,broken\n"), http.StatusUnprocessableEntity},
+		{"import unknown kind", "POST", static("/import/kits"), static("name\nx\n"), http.StatusNotFound},
+	}
+
+	// Every registered route must have at least one case above
+	t.Run("coverage", func(t *testing.T) {
+		repos := modeltest.Memory(memory.New())
+		f := seed(t, repos)
+		router := newRouter(repos, nil)
+
+		covered := make(map[*mux.Route]bool)
+		for _, tt := range tests {
+			var match mux.RouteMatch
+			if router.Match(httptest.NewRequest(tt.method, tt.path(f), nil), &match) {
+				covered[match.Route] = true
+			}
+		}
+
+		router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			// The authenticated subrouter itself has no handler
+			if route.GetHandler() == nil {
+				return nil
+			}
+			if !covered[route] {
+				template, _ := route.GetPathTemplate()
+				methods, _ := route.GetMethods()
+				t.Errorf("no test for %v %s", methods, template)
+			}
+			return nil
+		})
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+				f := seed(t, repos)
+				router := newRouter(repos, map[string]string{"desk": printer(t)})
+
+				var body io.Reader
+				if tt.body != nil {
+					body = strings.NewReader(tt.body(f))
+				}
+				req := httptest.NewRequest(tt.method, tt.path(f), body)
+				req.Header.Set("Authorization", "Bearer "+f.token)
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != tt.want {
+					t.Errorf("%s %s = %d, want %d: %s", tt.method, req.URL, rec.Code, tt.want, rec.Body)
+				}
+			})
+		})
+	}
+}
+
+// static ignores the fixtures
+func static(s string) func(*fixtures) string {
+	return func(*fixtures) string { return s }
+}
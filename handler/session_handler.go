@@ -2,14 +2,18 @@
 package handler
 
 import (
+	"github.com/cameo1221/Go-Asset/auth"
 	"github.com/cameo1221/Go-Asset/middleware"
 	
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	
-	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux" 
@@ -18,26 +22,74 @@ import (
 )
 
 type SessionHandler struct {
-	SessionModel *models.SessionModel
+	SessionModel models.SessionRepository
+	Auth         *auth.Authenticator
+}
+
+func NewSessionHandler(sessionModel models.SessionRepository, authenticator *auth.Authenticator) *SessionHandler {
+	return &SessionHandler{SessionModel: sessionModel, Auth: authenticator}
+}
+
+// LoginRoute is the route that logs in, which is reachable without a session. SecondFactorRoute
+// finishes a login that needs a second factor and is reachable without a session too.
+const (
+	LoginRoute        = "POST /sessions"
+	SecondFactorRoute = "POST /sessions/2fa"
+)
+
+// loginRequest is the body of POST /sessions
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// secondFactorRequest is the body of POST /sessions/2fa; code is from the authenticator app or a
+// recovery code
+type secondFactorRequest struct {
+	Challenge string `json:"challenge"`
+	Code      string `json:"code"`
+}
+
+// issuedSession is a session as returned on login, the only time its token is shown
+type issuedSession struct {
+	*models.Session
+	Token string `json:"token"`
 }
 
-func NewSessionHandler(sessionModel *models.SessionModel) *SessionHandler {
-	return &SessionHandler{SessionModel: sessionModel}
+// pendingLogin is returned instead of a session when the admin still has to give a second factor
+type pendingLogin struct {
+	SecondFactor string    `json:"second_factor"`
+	Challenge    string    `json:"challenge"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
+// createSession logs an admin in. The returned token is the bearer token for later requests;
+// browsers can rely on the session and CSRF cookies instead. Admins with two-factor authentication
+// get 202 and a challenge to send with their code to POST /sessions/2fa.
 func (ah *SessionHandler) createSession(w http.ResponseWriter, r *http.Request) {
-	var session models.Session
-	body, err := io.ReadAll(r.Body)
+	var login loginRequest
+	err := json.NewDecoder(r.Body).Decode(&login)
 	if err != nil {
-		return 
-	}
-
-	if len(body) > 0 {
-		err = json.Unmarshal(body, &session)
-		if err != nil {
-			return 
+		if err == io.EOF {
+			http.Error(w, "Request body is empty", http.StatusBadRequest)
+			return
 		}
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if login.Email == "" || login.Password == "" {
+		http.Error(w, "email and password are required", http.StatusBadRequest)
+		return
 	}
+
+	session, token, err := ah.Auth.Login(r.Context(), login.Email, login.Password)
+	ah.issueSession(w, r, session, token, err)
+}
+
+// verifySecondFactor finishes a login with a code from the admin's authenticator or a recovery code
+func (ah *SessionHandler) verifySecondFactor(w http.ResponseWriter, r *http.Request) {
+	var login secondFactorRequest
+	err := json.NewDecoder(r.Body).Decode(&login)
 	if err != nil {
 		if err == io.EOF {
 			http.Error(w, "Request body is empty", http.StatusBadRequest)
@@ -46,37 +98,57 @@ func (ah *SessionHandler) createSession(w http.ResponseWriter, r *http.Request)
 		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
 		return
 	}
+	if login.Challenge == "" || login.Code == "" {
+		http.Error(w, "challenge and code are required", http.StatusBadRequest)
+		return
+	}
+
+	session, token, err := ah.Auth.VerifySecondFactor(r.Context(), login.Challenge, login.Code)
+	ah.issueSession(w, r, session, token, err)
+}
+
+// writeLoginError answers a login that did not start a session: 202 with the challenge when the
+// admin still has to give a second factor, or why the login failed
+func writeLoginError(w http.ResponseWriter, r *http.Request, err error) {
+	var required *auth.SecondFactorRequired
+	var locked *auth.LockedError
+	switch {
+	case errors.As(err, &required):
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(pendingLogin{SecondFactor: "totp", Challenge: required.Challenge, ExpiresAt: required.ExpiresAt})
+	case errors.As(err, &locked):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		http.Error(w, locked.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, auth.ErrInvalidCredentials), errors.Is(err, auth.ErrInvalidChallenge),
+		errors.Is(err, auth.ErrInvalidSecondFactor):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrPasswordLoginDisabled), errors.Is(err, auth.ErrUnknownIdentity),
+		errors.Is(err, auth.ErrUnverifiedEmail):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, fmt.Sprintf("Error creating session: %v", err), errorStatus(r, err))
+	}
+}
 
-	err = ah.SessionModel.CreateSession(&session)
+// issueSession answers a login with the new session, or with why there is none
+func (ah *SessionHandler) issueSession(w http.ResponseWriter, r *http.Request, session *models.Session, token string, err error) {
 	if err != nil {
+		writeLoginError(w, r, err)
+		return
+	}
+
+	// Browsers get the session in cookies as well
+	if err := ah.Auth.SetSessionCookies(w, session, token); err != nil {
 		http.Error(w, fmt.Sprintf("Error creating session: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	fmt.Fprintf(w, "session created successfully")
-	
+	json.NewEncoder(w).Encode(issuedSession{Session: session, Token: token})
 }
 
 func (ah *SessionHandler) getAllSessions(w http.ResponseWriter, r *http.Request) {
-    sessions, err := ah.SessionModel.GetAllSessions()
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error getting Sessions: %v", err), http.StatusInternalServerError)
-        return
-    }
-
-    sessionsJSON, err := json.Marshal(sessions)
-    if err != nil {
-        http.Error(w, fmt.Sprintf("Error marshaling sessions to JSON: %v", err), http.StatusInternalServerError)
-        return
-    }
-
-    w.Header().Set("Content-Type", "application/json")
-    w.WriteHeader(http.StatusOK)
-    _, err = w.Write(sessionsJSON)
-    if err != nil {
-        log.Printf("Error writing response: %v\n", err)
-    }
+    writeJSONArray(w, r, "sessions", ah.SessionModel.ForEachSession)
 }
 func (ah *SessionHandler) getSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -88,15 +160,17 @@ func (ah *SessionHandler) getSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, err := ah.SessionModel.GetSessionByID(id)
+	session, err := ah.SessionModel.GetSessionByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error retrieving session: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error retrieving session: %v", err), errorStatus(r, err))
 		return
 	}
 
 	json.NewEncoder(w).Encode(session)
 }
 
+// updateSession renews one of the caller's sessions by the idle timeout, up to its absolute expiry.
+// Any expiry in the body is ignored; sessions cannot be extended past the configured limits.
 func (ah *SessionHandler) updateSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
@@ -107,26 +181,33 @@ func (ah *SessionHandler) updateSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	var updatedSession models.Session
-	err = json.NewDecoder(r.Body).Decode(&updatedSession)
+	session, err := ah.SessionModel.GetSessionByID(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Unable to decode request body", http.StatusBadRequest)
+		http.Error(w, fmt.Sprintf("Error updating Session: %v", err), errorStatus(r, err))
+		return
+	}
+	if admin, ok := auth.AdminFromContext(r.Context()); ok && admin.ID != session.AdminID {
+		http.Error(w, "Only your own sessions can be renewed", http.StatusForbidden)
+		return
+	}
+	if !session.Archive_at.After(time.Now()) {
+		http.Error(w, "Session has ended", http.StatusConflict)
 		return
 	}
 
-	updatedSession.ID = id
-
-	err = ah.SessionModel.UpdateSession(&updatedSession)
+	err = ah.Auth.RenewSession(r.Context(), session)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error updating Session: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error updating Session: %v", err), errorStatus(r, err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, "Session updated successfully")
+	json.NewEncoder(w).Encode(session)
 }
 
-
 func (ah *SessionHandler) deleteSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
@@ -137,9 +218,9 @@ func (ah *SessionHandler) deleteSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = ah.SessionModel.ArchiveSession(sessionID)
+	err = ah.SessionModel.ArchiveSession(r.Context(), sessionID)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error deleting session: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error deleting session: %v", err), errorStatus(r, err))
 		return
 	}
 
@@ -149,13 +230,49 @@ func (ah *SessionHandler) deleteSession(w http.ResponseWriter, r *http.Request)
 }
 
 
+// logoutEverywhere ends every session of the calling admin, this one included
+func (ah *SessionHandler) logoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	admin, ok := auth.AdminFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Logging out everywhere needs an admin session", http.StatusBadRequest)
+		return
+	}
+
+	ended, err := ah.SessionModel.ArchiveAdminSessions(r.Context(), admin.ID, uuid.Nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error ending sessions: %v", err), errorStatus(r, err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int64{"ended": ended})
+}
+
+// endAdminSessions ends every session of an admin, such as one whose device was lost
+func (ah *SessionHandler) endAdminSessions(w http.ResponseWriter, r *http.Request) {
+	adminID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admin ID", http.StatusBadRequest)
+		return
+	}
+
+	ended, err := ah.SessionModel.ArchiveAdminSessions(r.Context(), adminID, uuid.Nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error ending sessions: %v", err), errorStatus(r, err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]int64{"ended": ended})
+}
+
 func RegisterSessionRoutes(router *mux.Router, ah *SessionHandler) {
     router.Use(middleware.JSONContentTypeMiddleware)
-    router.Use(middleware.LoggingMiddleware)
 
 	router.HandleFunc("/sessions", ah.createSession).Methods("POST")
+	router.HandleFunc("/sessions/2fa", ah.verifySecondFactor).Methods("POST")
 	router.HandleFunc("/sessions", ah.getAllSessions).Methods("Get")
 	router.HandleFunc("/sessions/{id}", ah.getSession).Methods("GET")
 	router.HandleFunc("/sessions/{id}", ah.updateSession).Methods("PUT")
 	router.HandleFunc("/sessions/{id}", ah.deleteSession).Methods("DELETE")
+	router.HandleFunc("/sessions", ah.logoutEverywhere).Methods("DELETE")
+	router.HandleFunc("/admins/{id}/sessions", ah.endAdminSessions).Methods("DELETE")
 }
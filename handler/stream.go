@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/logging"
+)
+
+// streamFlushRows is how many rows are written between flushes of a streamed response
+const streamFlushRows = 100
+
+// StreamWriteTimeout is how long a streamed response may go without progress: each flush must
+// reach the client within it of the one before. It replaces the server's WriteTimeout for these
+// responses, which would otherwise cut a long export off however steadily it was going. Zero
+// leaves streams unbounded.
+var StreamWriteTimeout = 30 * time.Second
+
+// StreamRoutes are the routes whose responses are streamed, written as "METHOD /path/template".
+// They are exempt from the request timeout and bounded by StreamWriteTimeout instead.
+var StreamRoutes = []string{
+	"GET /admins",
+	"GET /apikeys",
+	"GET /assetrequests",
+	"GET /assets",
+	"GET /audits",
+	"GET /employeeassets",
+	"GET /employees",
+	"GET /me/assets",
+	"GET /sessions",
+}
+
+// extendWriteDeadline gives a streamed response another StreamWriteTimeout to make progress.
+// Writers that cannot set deadlines, such as test recorders, are left as they are.
+func extendWriteDeadline(rc *http.ResponseController) {
+	deadline := time.Time{}
+	if StreamWriteTimeout > 0 {
+		deadline = time.Now().Add(StreamWriteTimeout)
+	}
+	rc.SetWriteDeadline(deadline)
+}
+
+// writeJSONArray streams the items produced by each as a JSON array, flushing as it goes so memory
+// stays constant however many rows there are. As with exports, the status is only committed once
+// the first row arrives, so a failing query still gets a proper error response.
+func writeJSONArray[T any](w http.ResponseWriter, r *http.Request, name string, each func(context.Context, func(T) error) error) {
+	rc := http.NewResponseController(w)
+	count := 0
+
+	err := each(r.Context(), func(item T) error {
+		itemJSON, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+
+		sep := ","
+		if count == 0 {
+			extendWriteDeadline(rc)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			sep = "["
+		}
+		if _, err := w.Write(append([]byte(sep), itemJSON...)); err != nil {
+			return err
+		}
+
+		count++
+		if count%streamFlushRows == 0 {
+			rc.Flush()
+			extendWriteDeadline(rc)
+		}
+		return nil
+	})
+	if err != nil && count == 0 {
+		http.Error(w, fmt.Sprintf("Error getting %s: %v", name, err), errorStatus(r, err))
+		return
+	}
+	if err != nil {
+		abortStream(r, name, err)
+	}
+
+	if count == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[]"))
+		return
+	}
+	if _, err := w.Write([]byte("]")); err != nil {
+		logging.FromContext(r.Context()).Error("writing response", "error", err)
+	}
+}
+
+// abortStream gives up on a response whose status has already been sent. Aborting the handler
+// resets the connection, so clients see a failed transfer instead of a body that was silently cut
+// short.
+func abortStream(r *http.Request, name string, err error) {
+	logging.FromContext(r.Context()).Error("aborting stream", "stream", name, "error", err)
+	panic(http.ErrAbortHandler)
+}
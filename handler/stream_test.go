@@ -0,0 +1,133 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/handler"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+// streamingAssets lists rows and then fails with err, as a query does when it is cancelled or its
+// connection drops part way through
+type streamingAssets struct {
+	models.AssetRepository
+	rows int
+	err  error
+}
+
+func (s *streamingAssets) ForEachAsset(ctx context.Context, _ models.AssetFilter, fn func(*models.Asset) error) error {
+	for i := 0; i < s.rows; i++ {
+		if err := fn(&models.Asset{Id: uuid.New(), Tag: "A-1", Status: "available"}); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+// serveAssets lists /assets from repo, reporting whether the handler aborted the response
+func serveAssets(ctx context.Context, repo models.AssetRepository, path string) (rec *httptest.ResponseRecorder, aborted bool) {
+	router := mux.NewRouter()
+	handler.RegisterAssetRoutes(router, handler.NewAssetHandler(repo))
+
+	rec = httptest.NewRecorder()
+	defer func() {
+		if p := recover(); p != nil {
+			if p != http.ErrAbortHandler {
+				panic(p)
+			}
+			aborted = true
+		}
+	}()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil).WithContext(ctx))
+	return rec, false
+}
+
+func TestStreamErrorStatus(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want int
+	}{
+		{"client went away", context.Background(), context.Canceled, handler.StatusClientClosedRequest},
+		{"request cancelled", cancelled, errors.New("driver: bad connection"), handler.StatusClientClosedRequest},
+		{"query timed out", context.Background(), context.DeadlineExceeded, http.StatusServiceUnavailable},
+		{"request timed out", expired, errors.New("driver: bad connection"), http.StatusServiceUnavailable},
+		{"query failed", context.Background(), errors.New("relation does not exist"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		for _, path := range []string{"/assets", "/assets?format=csv"} {
+			rec, aborted := serveAssets(tt.ctx, &streamingAssets{err: tt.err}, path)
+			if aborted {
+				t.Errorf("%s: GET %s aborted before any row was written", tt.name, path)
+				continue
+			}
+			if rec.Code != tt.want {
+				t.Errorf("%s: GET %s = %d, want %d", tt.name, path, rec.Code, tt.want)
+			}
+		}
+	}
+}
+
+func TestStreamAbort(t *testing.T) {
+	for _, path := range []string{"/assets", "/assets?format=csv", "/assets?format=xlsx"} {
+		// Enough rows that some have been flushed to the client before the query fails
+		rec, aborted := serveAssets(context.Background(), &streamingAssets{rows: 250, err: context.DeadlineExceeded}, path)
+		if !aborted {
+			t.Errorf("GET %s = %d after the query failed mid-stream, want the handler aborted", path, rec.Code)
+			continue
+		}
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET %s sent %d with %d bytes before aborting, want 200 and the rows so far", path, rec.Code, rec.Body.Len())
+		}
+		if path == "/assets" && strings.HasSuffix(rec.Body.String(), "]") {
+			t.Errorf("GET %s closed the JSON array of an aborted stream", path)
+		}
+	}
+
+	// Without an error the same stream completes
+	rec, aborted := serveAssets(context.Background(), &streamingAssets{rows: 250}, "/assets")
+	if aborted || rec.Code != http.StatusOK || !strings.HasSuffix(rec.Body.String(), "]") {
+		t.Errorf("complete stream = %d, aborted %v, want a closed JSON array", rec.Code, aborted)
+	}
+}
+
+// registeredRoutes lists the router's routes as "METHOD /path/template"
+func registeredRoutes(router *mux.Router) map[string]bool {
+	routes := make(map[string]bool)
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		template, _ := route.GetPathTemplate()
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			routes[method+" "+template] = true
+		}
+		return nil
+	})
+	return routes
+}
+
+func TestStreamRoutes(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		routes := registeredRoutes(newRouter(repos, nil))
+		for _, route := range handler.StreamRoutes {
+			if !routes[route] {
+				t.Errorf("stream route %q is not registered", route)
+			}
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// OneTimeCodeRoutes take a code that logs in or changes a second factor: one from an
+// authenticator app, a recovery code or an OIDC authorization code. Plenty of other requests have
+// a harmless field called code, such as an audit scan, so it is only redacted from these routes' logs.
+var OneTimeCodeRoutes = []string{
+	"POST /2fa/confirm",
+	"POST /2fa/recovery-codes",
+	SecondFactorRoute,
+	OIDCCallbackRoute,
+}
+
+// TwoFactorHandler lets admins set up an authenticator app for their own account, and reset
+// another admin's
+type TwoFactorHandler struct {
+	Auth *auth.Authenticator
+}
+
+// NewTwoFactorHandler creates a new instance of TwoFactorHandler
+func NewTwoFactorHandler(authenticator *auth.Authenticator) *TwoFactorHandler {
+	return &TwoFactorHandler{Auth: authenticator}
+}
+
+// codeInput is the body of the requests that take a code from the authenticator app
+type codeInput struct {
+	Code string `json:"code"`
+}
+
+// twoFactorStatus is the state of the calling admin's two-factor authentication
+type twoFactorStatus struct {
+	Enabled                bool       `json:"enabled"`
+	Pending                bool       `json:"pending"`
+	ConfirmedAt            *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// recoveryCodes are shown once, when they are generated
+type recoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// writeTwoFactorError maps two-factor errors onto HTTP statuses
+func writeTwoFactorError(w http.ResponseWriter, r *http.Request, action string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrInvalidSecondFactor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, auth.ErrTOTPNotEnrolled), errors.Is(err, models.ErrTwoFactorEnabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, fmt.Sprintf("Error %s two-factor authentication: %v", action, err), errorStatus(r, err))
+	}
+}
+
+// callingAdmin returns the admin making the request, answering 400 for services and API keys,
+// which have no second factor of their own
+func callingAdmin(w http.ResponseWriter, r *http.Request) (*models.Admin, bool) {
+	admin, ok := auth.AdminFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Two-factor authentication needs an admin session", http.StatusBadRequest)
+	}
+	return admin, ok
+}
+
+// decodeCode reads the code from the request body
+func decodeCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var input codeInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+		return "", false
+	}
+	if input.Code == "" {
+		http.Error(w, "code is required", http.StatusBadRequest)
+		return "", false
+	}
+	return input.Code, true
+}
+
+func (th *TwoFactorHandler) getTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, ok := callingAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var status twoFactorStatus
+	totp, err := th.Auth.TwoFactor.GetTOTP(r.Context(), admin.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		writeTwoFactorError(w, r, "retrieving", err)
+		return
+	}
+	if totp != nil {
+		status.Enabled = totp.ConfirmedAt != nil
+		status.Pending = totp.ConfirmedAt == nil
+		status.ConfirmedAt = totp.ConfirmedAt
+	}
+	if status.Enabled {
+		if status.RecoveryCodesRemaining, err = th.Auth.TwoFactor.CountRecoveryCodes(r.Context(), admin.ID); err != nil {
+			writeTwoFactorError(w, r, "retrieving", err)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(status)
+}
+
+// enrollTwoFactor starts adding an authenticator app, returning its secret, otpauth:// URL and a
+// PNG QR code of the URL
+func (th *TwoFactorHandler) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, ok := callingAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := th.Auth.EnrollTOTP(r.Context(), admin)
+	if err != nil {
+		writeTwoFactorError(w, r, "enrolling", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// confirmTwoFactor turns two-factor authentication on with a code from the new authenticator and
+// returns the recovery codes. The admin's other sessions end and this one gets a new token.
+func (th *TwoFactorHandler) confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	admin, ok := callingAdmin(w, r)
+	if !ok {
+		return
+	}
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := th.Auth.ConfirmTOTP(r.Context(), admin.ID, code)
+	if err != nil {
+		writeTwoFactorError(w, r, "confirming", err)
+		return
+	}
+
+	session, token, err := th.Auth.ResetSessions(r.Context(), admin.ID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error ending sessions: %v", err), errorStatus(r, err))
+		return
+	}
+	if session != nil {
+		if err := th.Auth.SetRotatedSession(w, session, token); err != nil {
+			http.Error(w, fmt.Sprintf("Error rotating session: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(recoveryCodes{RecoveryCodes: codes})
+}
+
+// regenerateRecoveryCodes replaces the calling admin's recovery codes, given an authenticator code
+func (th *TwoFactorHandler) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	admin, ok := callingAdmin(w, r)
+	if !ok {
+		return
+	}
+	code, ok := decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := th.Auth.RegenerateRecoveryCodes(r.Context(), admin.ID, code)
+	if err != nil {
+		writeTwoFactorError(w, r, "regenerating recovery codes for", err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(recoveryCodes{RecoveryCodes: codes})
+}
+
+// resetTwoFactor removes another admin's authenticator and recovery codes and ends their sessions.
+// Admins cannot reset their own, so a stolen session cannot turn the second factor off.
+func (th *TwoFactorHandler) resetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	adminID, err := uuid.Parse(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid admin ID", http.StatusBadRequest)
+		return
+	}
+	if admin, ok := auth.AdminFromContext(r.Context()); ok && admin.ID == adminID {
+		http.Error(w, "Another admin has to reset your two-factor authentication", http.StatusForbidden)
+		return
+	}
+
+	if _, err := th.Auth.Admins.GetAdminByID(r.Context(), adminID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Admin not found", http.StatusNotFound)
+			return
+		}
+		writeTwoFactorError(w, r, "resetting", err)
+		return
+	}
+
+	if err := th.Auth.ResetTwoFactor(r.Context(), adminID); err != nil {
+		writeTwoFactorError(w, r, "resetting", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func RegisterTwoFactorRoutes(router *mux.Router, th *TwoFactorHandler) {
+	router.HandleFunc("/2fa", th.getTwoFactor).Methods("GET")
+	router.HandleFunc("/2fa", th.enrollTwoFactor).Methods("POST")
+	router.HandleFunc("/2fa/confirm", th.confirmTwoFactor).Methods("POST")
+	router.HandleFunc("/2fa/recovery-codes", th.regenerateRecoveryCodes).Methods("POST")
+	router.HandleFunc("/admins/{id}/2fa", th.resetTwoFactor).Methods("DELETE")
+}
@@ -0,0 +1,134 @@
+// Package labels renders asset labels: QR codes linking to the asset, Code128 barcodes of the
+// asset tag, and print-ready PDF sheets.
+package labels
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+)
+
+// ErrNoTag is returned when a barcode is requested for an asset without a tag
+var ErrNoTag = errors.New("asset has no tag to encode")
+
+// Label is the content printed for one asset
+type Label struct {
+	// Link is encoded in the QR code, usually a deep link to the asset
+	Link string
+	// Tag is encoded as a Code128 barcode and printed underneath it
+	Tag string
+	// Title and Subtitle are printed as text next to the codes
+	Title    string
+	Subtitle string
+}
+
+// QRCode encodes content as a QR code with medium error correction
+func QRCode(content string) (barcode.Barcode, error) {
+	return qr.Encode(content, qr.M, qr.Auto)
+}
+
+// Code128 encodes an asset tag as a Code128 barcode
+func Code128(tag string) (barcode.Barcode, error) {
+	if tag == "" {
+		return nil, ErrNoTag
+	}
+	return code128.Encode(tag)
+}
+
+// quietZone returns the blank margin, in modules, scanners expect around a code
+func quietZone(code barcode.Barcode) (x, y int) {
+	if code.Metadata().Dimensions == 1 {
+		return 10, 0
+	}
+	return 4, 4
+}
+
+// WritePNG renders the code with its quiet zone into a width x height PNG
+func WritePNG(w io.Writer, code barcode.Barcode, width, height int) error {
+	img, err := Image(code, width, height)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+// Image renders the code with its quiet zone into a width x height image
+func Image(code barcode.Barcode, width, height int) (image.Image, error) {
+	bounds := code.Bounds()
+	padX, padY := quietZone(code)
+
+	innerW := width * bounds.Dx() / (bounds.Dx() + 2*padX)
+	innerH := height
+	if padY > 0 {
+		innerH = height * bounds.Dy() / (bounds.Dy() + 2*padY)
+	}
+
+	scaled, err := barcode.Scale(code, innerW, innerH)
+	if err != nil {
+		return nil, fmt.Errorf("label too small for code: %w", err)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	offset := image.Pt((width-innerW)/2, (height-innerH)/2)
+	draw.Draw(img, scaled.Bounds().Add(offset), scaled, scaled.Bounds().Min, draw.Src)
+
+	return img, nil
+}
+
+// isDark reports whether a module of an unscaled code is printed
+func isDark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// WriteSVG writes the code and its quiet zone as an SVG of width x height user units. The SVG keeps
+// the unscaled module grid as its viewBox, so it stays sharp at any print size.
+func WriteSVG(w io.Writer, code barcode.Barcode, width, height int) error {
+	bounds := code.Bounds()
+	modulesX, modulesY := bounds.Dx(), bounds.Dy()
+	padX, padY := quietZone(code)
+
+	// 1D codes are a single row of modules that has to stretch to the requested height
+	aspect := "xMidYMid meet"
+	if code.Metadata().Dimensions == 1 {
+		aspect = "none"
+	}
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%d %d %d %d" preserveAspectRatio="%s" shape-rendering="crispEdges">`+
+		`<rect x="%d" y="%d" width="%d" height="%d" fill="#fff"/><path fill="#000" d="`,
+		width, height, -padX, -padY, modulesX+2*padX, modulesY+2*padY, aspect,
+		-padX, -padY, modulesX+2*padX, modulesY+2*padY)
+	if err != nil {
+		return err
+	}
+
+	for y := 0; y < modulesY; y++ {
+		// Merge runs of dark modules into a single rectangle to keep the path small
+		for x := 0; x < modulesX; {
+			if !isDark(code.At(bounds.Min.X+x, bounds.Min.Y+y)) {
+				x++
+				continue
+			}
+			run := 1
+			for x+run < modulesX && isDark(code.At(bounds.Min.X+x+run, bounds.Min.Y+y)) {
+				run++
+			}
+			if _, err := fmt.Fprintf(w, "M%d %dh%dv1h-%dz", x, y, run, run); err != nil {
+				return err
+			}
+			x += run
+		}
+	}
+
+	_, err = io.WriteString(w, `"/></svg>`)
+	return err
+}
@@ -0,0 +1,166 @@
+package labels_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"testing"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+
+	"github.com/cameo1221/Go-Asset/labels"
+)
+
+// dark reports whether a pixel of a rendered label is printed
+func dark(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r+g+b < 3*0x8000
+}
+
+// darkBounds is the smallest rectangle holding every printed pixel, which is the symbol without its
+// quiet zone
+func darkBounds(img image.Image) image.Rectangle {
+	var box image.Rectangle
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if dark(img.At(x, y)) {
+				box = box.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return box
+}
+
+// decodePNG renders code into a width x height PNG, decodes it again and reads the module grid
+// back by sampling the middle of each module
+func decodePNG(t *testing.T, code barcode.Barcode, width, height int) (img image.Image, symbol image.Rectangle, modules [][]bool) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := labels.WritePNG(&buf, code, width, height); err != nil {
+		t.Fatalf("WritePNG: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("decoding PNG: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(width, height) {
+		t.Fatalf("PNG is %v, want %dx%d", got, width, height)
+	}
+
+	symbol = darkBounds(img)
+	cols, rows := code.Bounds().Dx(), code.Bounds().Dy()
+	modules = make([][]bool, rows)
+	for y := range modules {
+		modules[y] = make([]bool, cols)
+		py := symbol.Min.Y + (2*y+1)*symbol.Dy()/(2*rows)
+		for x := range modules[y] {
+			px := symbol.Min.X + (2*x+1)*symbol.Dx()/(2*cols)
+			modules[y][x] = dark(img.At(px, py))
+		}
+	}
+	return img, symbol, modules
+}
+
+// sameModules compares a grid read back from an image with a freshly encoded symbol
+func sameModules(t *testing.T, got [][]bool, want barcode.Barcode) {
+	t.Helper()
+
+	b := want.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			if got[y][x] != dark(want.At(b.Min.X+x, b.Min.Y+y)) {
+				t.Fatalf("module (%d, %d) = %v, want %v", x, y, got[y][x], !got[y][x])
+			}
+		}
+	}
+}
+
+func TestQRCodePNG(t *testing.T) {
+	const link = "https://assets.example.com/assets/6f1c2b9e-3d4a-4f6b-9a8e-2c1d0e7f5a3b"
+	code, err := labels.QRCode(link)
+	if err != nil {
+		t.Fatalf("QRCode: %v", err)
+	}
+	if code.Content() != link || code.Metadata().CodeKind != "QR Code" {
+		t.Fatalf("QRCode = %s %q, want a QR code of the link", code.Metadata().CodeKind, code.Content())
+	}
+
+	_, symbol, modules := decodePNG(t, code, 400, 400)
+	want, err := qr.Encode(link, qr.M, qr.Auto)
+	if err != nil {
+		t.Fatalf("qr.Encode: %v", err)
+	}
+	sameModules(t, modules, want)
+
+	// Scanners need four blank modules around the symbol
+	module := symbol.Dx() / want.Bounds().Dx()
+	for _, margin := range []int{symbol.Min.X, symbol.Min.Y, 400 - symbol.Max.X, 400 - symbol.Max.Y} {
+		if margin < 4*module {
+			t.Errorf("quiet zone %dpx, want at least %dpx", margin, 4*module)
+		}
+	}
+}
+
+func TestCode128PNG(t *testing.T) {
+	code, err := labels.Code128("TAG-0042")
+	if err != nil {
+		t.Fatalf("Code128: %v", err)
+	}
+
+	img, symbol, modules := decodePNG(t, code, 600, 80)
+	want, err := code128.Encode("TAG-0042")
+	if err != nil {
+		t.Fatalf("code128.Encode: %v", err)
+	}
+	sameModules(t, modules, want)
+
+	// Bars run the full height, with ten blank modules either side
+	if symbol.Min.Y != 0 || symbol.Max.Y != img.Bounds().Dy() {
+		t.Errorf("bars span rows %d-%d, want the full height", symbol.Min.Y, symbol.Max.Y)
+	}
+	module := symbol.Dx() / want.Bounds().Dx()
+	if symbol.Min.X < 10*module || 600-symbol.Max.X < 10*module {
+		t.Errorf("quiet zone %dpx and %dpx, want at least %dpx", symbol.Min.X, 600-symbol.Max.X, 10*module)
+	}
+
+	if _, err := labels.Code128(""); !errors.Is(err, labels.ErrNoTag) {
+		t.Errorf("Code128 without a tag error = %v, want ErrNoTag", err)
+	}
+	if err := labels.WritePNG(new(bytes.Buffer), code, 20, 20); err == nil {
+		t.Error("WritePNG smaller than a module per bar succeeded")
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	code, err := labels.QRCode("https://assets.example.com/assets/1")
+	if err != nil {
+		t.Fatalf("QRCode: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := labels.WriteSVG(&buf, code, 200, 200); err != nil {
+		t.Fatalf("WriteSVG: %v", err)
+	}
+	var svg struct {
+		Width   string `xml:"width,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Path    struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("SVG does not parse: %v\n%s", err, buf.String())
+	}
+	size := code.Bounds().Dx()
+	if want := "-4 -4 " + strconv.Itoa(size+8) + " " + strconv.Itoa(size+8); svg.Width != "200" || svg.ViewBox != want || svg.Path.D == "" {
+		t.Errorf("SVG width %q viewBox %q, want 200 and %q with a path", svg.Width, svg.ViewBox, want)
+	}
+}
@@ -0,0 +1,151 @@
+package labels
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/boombuler/barcode"
+	"github.com/go-pdf/fpdf"
+)
+
+// Layout describes a sheet of labels. All measurements are in millimetres.
+type Layout struct {
+	Name        string
+	PageWidth   float64
+	PageHeight  float64
+	Columns     int
+	Rows        int
+	LabelWidth  float64
+	LabelHeight float64
+	MarginTop   float64
+	MarginLeft  float64
+	GapX        float64
+	GapY        float64
+}
+
+// Layouts are the supported label sheets, keyed by the name used in the API
+var Layouts = map[string]Layout{
+	"avery-5160": {Name: "Avery 5160 (US Letter, 30 per sheet)", PageWidth: 215.9, PageHeight: 279.4,
+		Columns: 3, Rows: 10, LabelWidth: 66.675, LabelHeight: 25.4, MarginTop: 12.7, MarginLeft: 4.7625, GapX: 3.175},
+	"avery-5163": {Name: "Avery 5163 (US Letter, 10 per sheet)", PageWidth: 215.9, PageHeight: 279.4,
+		Columns: 2, Rows: 5, LabelWidth: 101.6, LabelHeight: 50.8, MarginTop: 12.7, MarginLeft: 3.96875, GapX: 4.7625},
+	"avery-l7160": {Name: "Avery L7160 (A4, 21 per sheet)", PageWidth: 210, PageHeight: 297,
+		Columns: 3, Rows: 7, LabelWidth: 63.5, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 7.21, GapX: 2.54},
+	"avery-l7163": {Name: "Avery L7163 (A4, 14 per sheet)", PageWidth: 210, PageHeight: 297,
+		Columns: 2, Rows: 7, LabelWidth: 99.1, LabelHeight: 38.1, MarginTop: 15.15, MarginLeft: 4.65, GapX: 2.5},
+}
+
+// DefaultLayout is used when a request does not name one
+const DefaultLayout = "avery-5160"
+
+// LayoutNames lists the supported layouts in a stable order
+func LayoutNames() []string {
+	names := make([]string, 0, len(Layouts))
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	labelPadding = 2.0  // mm inside each label edge
+	pixelsPerMM  = 12.0 // about 300 dpi for the embedded code images
+)
+
+// WriteSheet renders labels onto as many pages of the layout as needed and writes the PDF
+func WriteSheet(w io.Writer, layout Layout, labels []Label) error {
+	pdf := fpdf.NewCustom(&fpdf.InitType{
+		UnitStr: "mm",
+		Size:    fpdf.SizeType{Wd: layout.PageWidth, Ht: layout.PageHeight},
+	})
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	perPage := layout.Columns * layout.Rows
+	for i, label := range labels {
+		if i%perPage == 0 {
+			pdf.AddPage()
+		}
+		slot := i % perPage
+		x := layout.MarginLeft + float64(slot%layout.Columns)*(layout.LabelWidth+layout.GapX)
+		y := layout.MarginTop + float64(slot/layout.Columns)*(layout.LabelHeight+layout.GapY)
+
+		if err := drawLabel(pdf, translate, fmt.Sprintf("label-%d", i), x, y, layout.LabelWidth, layout.LabelHeight, label); err != nil {
+			return err
+		}
+	}
+
+	if len(labels) == 0 {
+		pdf.AddPage()
+	}
+
+	return pdf.Output(w)
+}
+
+// drawLabel places the QR code on the left and the text and tag barcode to its right
+func drawLabel(pdf *fpdf.Fpdf, translate func(string) string, name string, x, y, width, height float64, label Label) error {
+	side := height - 2*labelPadding
+	textX := x + labelPadding
+	textWidth := width - 2*labelPadding
+
+	if label.Link != "" {
+		code, err := QRCode(label.Link)
+		if err != nil {
+			return err
+		}
+		if err := placeImage(pdf, name+"-qr", code, x+labelPadding, y+labelPadding, side, side); err != nil {
+			return err
+		}
+		textX += side + labelPadding
+		textWidth -= side + labelPadding
+	}
+
+	// Text takes the top of the label, the barcode the bottom third
+	fontSize := 8.0
+	if height > 40 {
+		fontSize = 11
+	}
+	lineHeight := fontSize * 0.4
+
+	pdf.SetFont("Helvetica", "B", fontSize)
+	pdf.SetXY(textX, y+labelPadding)
+	pdf.CellFormat(textWidth, lineHeight, translate(label.Title), "", 2, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", fontSize-1)
+	pdf.CellFormat(textWidth, lineHeight, translate(label.Subtitle), "", 2, "L", false, 0, "")
+
+	if label.Tag == "" {
+		return nil
+	}
+
+	barHeight := side / 3
+	barY := y + height - labelPadding - barHeight - lineHeight
+	code, err := Code128(label.Tag)
+	if err != nil {
+		return err
+	}
+	if err := placeImage(pdf, name+"-tag", code, textX, barY, textWidth, barHeight); err != nil {
+		return err
+	}
+	pdf.SetXY(textX, barY+barHeight)
+	pdf.CellFormat(textWidth, lineHeight, translate(label.Tag), "", 0, "C", false, 0, "")
+
+	return nil
+}
+
+// placeImage rasterises a code at print resolution and embeds it in the PDF
+func placeImage(pdf *fpdf.Fpdf, name string, code barcode.Barcode, x, y, width, height float64) error {
+	var buf bytes.Buffer
+	if err := WritePNG(&buf, code, int(width*pixelsPerMM), int(height*pixelsPerMM)); err != nil {
+		return err
+	}
+
+	options := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader(name, options, &buf)
+	pdf.ImageOptions(name, x, y, width, height, false, options, 0, "")
+
+	return pdf.Error()
+}
@@ -0,0 +1,87 @@
+package labels_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/labels"
+)
+
+var (
+	pdfPage  = regexp.MustCompile(`/Type /Page\b[^s]`)
+	pdfImage = regexp.MustCompile(`/Subtype /Image`)
+	pdfSize  = regexp.MustCompile(`/MediaBox \[0 0 ([0-9.]+) ([0-9.]+)\]`)
+)
+
+func TestWriteSheetPagination(t *testing.T) {
+	tests := []struct {
+		layout    string
+		labels    int
+		wantPages int
+	}{
+		{"avery-5160", 0, 1},
+		{"avery-5160", 1, 1},
+		{"avery-5160", 30, 1},
+		{"avery-5160", 31, 2},
+		{"avery-5160", 61, 3},
+		{"avery-l7163", 14, 1},
+		{"avery-l7163", 15, 2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.layout, tt.labels), func(t *testing.T) {
+			layout := labels.Layouts[tt.layout]
+			sheet := make([]labels.Label, tt.labels)
+			for i := range sheet {
+				sheet[i] = labels.Label{
+					Link:     fmt.Sprintf("https://assets.example.com/assets/%d", i),
+					Tag:      fmt.Sprintf("TAG-%d", i),
+					Title:    "Lenovo T14",
+					Subtitle: "London",
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := labels.WriteSheet(&buf, layout, sheet); err != nil {
+				t.Fatalf("WriteSheet: %v", err)
+			}
+			pdf := buf.String()
+
+			if pages := len(pdfPage.FindAllString(pdf, -1)); pages != tt.wantPages {
+				t.Errorf("sheet has %d pages, want %d", pages, tt.wantPages)
+			}
+			// Each label carries a QR code and a barcode
+			if images := len(pdfImage.FindAllString(pdf, -1)); images != 2*tt.labels {
+				t.Errorf("sheet embeds %d images, want %d", images, 2*tt.labels)
+			}
+			// Pages are the layout's size, in points
+			size := pdfSize.FindStringSubmatch(pdf)
+			if want := []string{fmt.Sprintf("%.2f", layout.PageWidth*72/25.4), fmt.Sprintf("%.2f", layout.PageHeight*72/25.4)}; size == nil || size[1] != want[0] || size[2] != want[1] {
+				t.Errorf("page MediaBox = %v, want %v", size, want)
+			}
+		})
+	}
+}
+
+func TestWriteSheetWithoutCodes(t *testing.T) {
+	var buf bytes.Buffer
+	err := labels.WriteSheet(&buf, labels.Layouts[labels.DefaultLayout], []labels.Label{{Title: "Spare charger"}})
+	if err != nil {
+		t.Fatalf("WriteSheet: %v", err)
+	}
+	if images := len(pdfImage.FindAllString(buf.String(), -1)); images != 0 {
+		t.Errorf("label without a link or tag embeds %d images, want none", images)
+	}
+}
+
+func TestLayoutsFitTheirPage(t *testing.T) {
+	for _, name := range labels.LayoutNames() {
+		l := labels.Layouts[name]
+		right := l.MarginLeft + float64(l.Columns)*l.LabelWidth + float64(l.Columns-1)*l.GapX
+		bottom := l.MarginTop + float64(l.Rows)*l.LabelHeight + float64(l.Rows-1)*l.GapY
+		if right > l.PageWidth || bottom > l.PageHeight {
+			t.Errorf("%s labels reach %.1fx%.1fmm on a %.1fx%.1fmm page", name, right, bottom, l.PageWidth, l.PageHeight)
+		}
+	}
+}
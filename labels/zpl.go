@@ -0,0 +1,260 @@
+package labels
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ZPLFields are the asset fields available to ZPL templates
+type ZPLFields struct {
+	Tag     string
+	Model   string
+	Company string
+	Serial  string
+	// Link is encoded as a QR code when set
+	Link string
+}
+
+// LabelSize is a thermal label size in inches
+type LabelSize struct {
+	Width  float64
+	Height float64
+}
+
+// LabelSizes are the common thermal label stock sizes, keyed by the name used in the API
+var LabelSizes = map[string]LabelSize{
+	"2x1":       {Width: 2, Height: 1},
+	"2.25x1.25": {Width: 2.25, Height: 1.25},
+	"3x1":       {Width: 3, Height: 1},
+	"3x2":       {Width: 3, Height: 2},
+	"4x2":       {Width: 4, Height: 2},
+	"4x6":       {Width: 4, Height: 6},
+}
+
+// ParseLabelSize accepts a preset name from LabelSizes or any "WIDTHxHEIGHT" size in inches
+func ParseLabelSize(s string) (LabelSize, error) {
+	if size, ok := LabelSizes[s]; ok {
+		return size, nil
+	}
+
+	var size LabelSize
+	width, height, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return size, fmt.Errorf("label size %q is not WIDTHxHEIGHT in inches", s)
+	}
+	var err error
+	if size.Width, err = strconv.ParseFloat(width, 64); err != nil {
+		return size, fmt.Errorf("invalid label width %q", width)
+	}
+	if size.Height, err = strconv.ParseFloat(height, 64); err != nil {
+		return size, fmt.Errorf("invalid label height %q", height)
+	}
+	// Zebra desktop and industrial printers top out at 8.5 inch wide media
+	if size.Width < 0.5 || size.Width > 8.5 || size.Height < 0.25 || size.Height > 12 {
+		return size, fmt.Errorf("label size %q is outside what thermal printers accept", s)
+	}
+
+	return size, nil
+}
+
+// Printer resolutions supported by Zebra print heads, in dots per inch
+var printerDPIs = map[int]bool{152: true, 203: true, 300: true, 600: true}
+
+// Defaults used when a request does not choose
+const (
+	DefaultZPLTemplate = "standard"
+	DefaultLabelSize   = "2x1"
+	DefaultDPI         = 203
+
+	maxCopies = 500
+)
+
+// zplLayout is what templates are executed with: escaped fields plus positions in dots
+type zplLayout struct {
+	Tag, Model, Company, Serial, Link string
+
+	Width, Height, Margin int
+	QRMagnification       int
+	TextX, TextWidth      int
+	FontHeight, LineGap   int
+	BarcodeY              int
+	BarcodeHeight         int
+	BarcodeModule         int
+	Copies                int
+}
+
+// ZPLTemplate is a text/template producing one ZPL label
+type ZPLTemplate struct {
+	// QRCode reserves the left of the label for a QR code of the asset link
+	QRCode bool
+	// RequiresTag marks templates that are nothing but the tag's barcode without one
+	RequiresTag bool
+	template    *template.Template
+}
+
+// ZPLTemplates are the available label templates. All text goes through ^FH so field data can
+// safely contain ZPL control characters.
+var ZPLTemplates = map[string]*ZPLTemplate{
+	"standard": newZPLTemplate("standard", true, false, `^XA
+^CI28
+^PW{{.Width}}
+^LL{{.Height}}
+{{if .QRMagnification}}^FO{{.Margin}},{{.Margin}}^BQN,2,{{.QRMagnification}}^FH^FDMA,{{.Link}}^FS
+{{end}}^FO{{.TextX}},{{.Margin}}^A0N,{{.FontHeight}},{{.FontHeight}}^FB{{.TextWidth}},1,0,L^FH^FD{{.Model}}^FS
+^FO{{.TextX}},{{add .Margin .LineGap}}^A0N,{{.FontHeight}},{{.FontHeight}}^FB{{.TextWidth}},1,0,L^FH^FD{{.Company}}^FS
+{{if .Serial}}^FO{{.TextX}},{{add .Margin .LineGap .LineGap}}^A0N,{{.FontHeight}},{{.FontHeight}}^FB{{.TextWidth}},1,0,L^FH^FDS/N {{.Serial}}^FS
+{{end}}{{if .Tag}}^FO{{.TextX}},{{.BarcodeY}}^BY{{.BarcodeModule}}^BCN,{{.BarcodeHeight}},Y,N,N^FH^FD{{.Tag}}^FS
+{{end}}^PQ{{.Copies}}
+^XZ
+`),
+	"barcode": newZPLTemplate("barcode", false, true, `^XA
+^CI28
+^PW{{.Width}}
+^LL{{.Height}}
+^FO{{.Margin}},{{.Margin}}^A0N,{{.FontHeight}},{{.FontHeight}}^FB{{.TextWidth}},1,0,C^FH^FD{{.Model}}^FS
+^FO{{.Margin}},{{.BarcodeY}}^BY{{.BarcodeModule}}^BCN,{{.BarcodeHeight}},Y,N,N^FH^FD{{.Tag}}^FS
+^PQ{{.Copies}}
+^XZ
+`),
+}
+
+func newZPLTemplate(name string, qrCode, requiresTag bool, text string) *ZPLTemplate {
+	return &ZPLTemplate{
+		QRCode:      qrCode,
+		RequiresTag: requiresTag,
+		template:    template.Must(template.New(name).Funcs(template.FuncMap{"add": add}).Parse(text)),
+	}
+}
+
+func add(values ...int) int {
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// ZPLTemplateNames lists the available templates in a stable order
+func ZPLTemplateNames() []string {
+	names := make([]string, 0, len(ZPLTemplates))
+	for name := range ZPLTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// zplEscape hex-escapes the characters ZPL treats specially, for use in ^FH fields
+func zplEscape(s string) string {
+	return strings.NewReplacer("_", "_5F", "^", "_5E", "~", "_7E", "\r", "", "\n", " ").Replace(s)
+}
+
+// RenderZPL writes the ZPL for one asset label. It returns ErrNoTag when the template needs a tag
+// the asset does not have.
+func RenderZPL(w io.Writer, templateName string, size LabelSize, dpi, copies int, fields ZPLFields) error {
+	tmpl, ok := ZPLTemplates[templateName]
+	if !ok {
+		return fmt.Errorf("unknown ZPL template %q", templateName)
+	}
+	if tmpl.RequiresTag && fields.Tag == "" {
+		return ErrNoTag
+	}
+	if !printerDPIs[dpi] {
+		return fmt.Errorf("unsupported printer resolution %d dpi", dpi)
+	}
+	if copies < 1 {
+		copies = 1
+	}
+	if copies > maxCopies {
+		return fmt.Errorf("at most %d copies can be printed at once", maxCopies)
+	}
+
+	layout := zplLayout{
+		Tag:     zplEscape(fields.Tag),
+		Model:   zplEscape(fields.Model),
+		Company: zplEscape(fields.Company),
+		Serial:  zplEscape(fields.Serial),
+		Link:    zplEscape(fields.Link),
+		Width:   int(size.Width * float64(dpi)),
+		Height:  int(size.Height * float64(dpi)),
+		Margin:  dpi / 16,
+		Copies:  copies,
+	}
+
+	inner := layout.Height - 2*layout.Margin
+	layout.FontHeight = inner / 6
+	layout.LineGap = layout.FontHeight + layout.FontHeight/4
+	layout.BarcodeHeight = inner / 4
+	// Leave room under the bars for the human readable line ^BC prints
+	layout.BarcodeY = layout.Height - layout.Margin - layout.BarcodeHeight - layout.FontHeight
+	layout.BarcodeModule = max(1, dpi/100)
+	layout.TextX = layout.Margin
+	layout.TextWidth = layout.Width - 2*layout.Margin
+
+	if tmpl.QRCode && fields.Link != "" {
+		// Size the QR code so it fills the label height, counting its quiet zone
+		code, err := QRCode(fields.Link)
+		if err != nil {
+			return err
+		}
+		modules := code.Bounds().Dx() + 8
+		layout.QRMagnification = min(10, max(1, inner/modules))
+		qrWidth := layout.QRMagnification * modules
+		layout.TextX = layout.Margin + qrWidth
+		layout.TextWidth = layout.Width - layout.TextX - layout.Margin
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.template.Execute(&buf, layout); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// SendToPrinter streams ZPL to a printer listening for raw jobs, usually on TCP port 9100
+func SendToPrinter(ctx context.Context, address string, zpl []byte) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return fmt.Errorf("error connecting to printer %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+	}
+
+	if _, err := conn.Write(zpl); err != nil {
+		return fmt.Errorf("error sending label to printer %s: %w", address, err)
+	}
+
+	return nil
+}
+
+// ParsePrinters reads a "name=host:port,name=host:port" list of raw TCP printers
+func ParsePrinters(s string) (map[string]string, error) {
+	printers := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, address, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("printer %q is not name=host:port", entry)
+		}
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, fmt.Errorf("printer %q: %w", name, err)
+		}
+		printers[strings.TrimSpace(name)] = address
+	}
+	return printers, nil
+}
@@ -0,0 +1,234 @@
+package labels_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/labels"
+)
+
+func TestRenderZPL(t *testing.T) {
+	fields := labels.ZPLFields{
+		Tag:     "TAG_1^2",
+		Model:   "ThinkPad ^XZ~JR T14",
+		Company: "Lenovo\nUK",
+		Serial:  "SN-1",
+		Link:    "https://assets.example.com/assets/1",
+	}
+
+	// 2x1in at 203dpi is 406x203 dots, with a 12 dot margin around 179 dots of content
+	code, err := labels.QRCode(fields.Link)
+	if err != nil {
+		t.Fatalf("QRCode: %v", err)
+	}
+	modules := code.Bounds().Dx() + 8
+	magnification := min(10, 179/modules)
+	textX := 12 + magnification*modules
+
+	tests := []struct {
+		template string
+		fields   labels.ZPLFields
+		copies   int
+		want     string
+	}{
+		{"standard", fields, 3, fmt.Sprintf(`^XA
+^CI28
+^PW406
+^LL203
+^FO12,12^BQN,2,%[1]d^FH^FDMA,https://assets.example.com/assets/1^FS
+^FO%[2]d,12^A0N,29,29^FB%[3]d,1,0,L^FH^FDThinkPad _5EXZ_7EJR T14^FS
+^FO%[2]d,48^A0N,29,29^FB%[3]d,1,0,L^FH^FDLenovo UK^FS
+^FO%[2]d,84^A0N,29,29^FB%[3]d,1,0,L^FH^FDS/N SN-1^FS
+^FO%[2]d,118^BY2^BCN,44,Y,N,N^FH^FDTAG_5F1_5E2^FS
+^PQ3
+^XZ
+`, magnification, textX, 406-textX-12)},
+		// Without a link or serial the text takes the whole width and the serial line is left out
+		{"standard", labels.ZPLFields{Tag: "TAG-1", Model: "T14", Company: "Lenovo"}, 0, `^XA
+^CI28
+^PW406
+^LL203
+^FO12,12^A0N,29,29^FB382,1,0,L^FH^FDT14^FS
+^FO12,48^A0N,29,29^FB382,1,0,L^FH^FDLenovo^FS
+^FO12,118^BY2^BCN,44,Y,N,N^FH^FDTAG-1^FS
+^PQ1
+^XZ
+`},
+		{"barcode", fields, 1, `^XA
+^CI28
+^PW406
+^LL203
+^FO12,12^A0N,29,29^FB382,1,0,C^FH^FDThinkPad _5EXZ_7EJR T14^FS
+^FO12,118^BY2^BCN,44,Y,N,N^FH^FDTAG_5F1_5E2^FS
+^PQ1
+^XZ
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := labels.RenderZPL(&buf, tt.template, labels.LabelSizes["2x1"], 203, tt.copies, tt.fields); err != nil {
+				t.Fatalf("RenderZPL: %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("RenderZPL =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+			// Field data never ends a label early or starts a command
+			if strings.Count(buf.String(), "^XZ") != 1 || strings.Contains(buf.String(), "~") {
+				t.Errorf("field data leaked ZPL commands:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestRenderZPLErrors(t *testing.T) {
+	size := labels.LabelSizes[labels.DefaultLabelSize]
+	tests := []struct {
+		name     string
+		template string
+		dpi      int
+		copies   int
+	}{
+		{"unknown template", "fancy", 203, 1},
+		{"unsupported resolution", "standard", 250, 1},
+		{"too many copies", "standard", 203, 501},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := labels.RenderZPL(io.Discard, tt.template, size, tt.dpi, tt.copies, labels.ZPLFields{Tag: "TAG-1"}); err == nil {
+				t.Error("RenderZPL succeeded")
+			}
+		})
+	}
+
+	// The standard template leaves the barcode off instead
+	if err := labels.RenderZPL(io.Discard, "barcode", size, 203, 1, labels.ZPLFields{Model: "T14"}); !errors.Is(err, labels.ErrNoTag) {
+		t.Errorf("RenderZPL(barcode) without a tag error = %v, want ErrNoTag", err)
+	}
+	if err := labels.RenderZPL(io.Discard, "standard", size, 203, 1, labels.ZPLFields{Model: "T14"}); err != nil {
+		t.Errorf("RenderZPL(standard) without a tag: %v", err)
+	}
+}
+
+func TestParseLabelSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    labels.LabelSize
+		wantErr bool
+	}{
+		{"4x6", labels.LabelSize{Width: 4, Height: 6}, false},
+		{"2.5X1.5", labels.LabelSize{Width: 2.5, Height: 1.5}, false},
+		{"2", labels.LabelSize{}, true},
+		{"ax1", labels.LabelSize{}, true},
+		{"10x1", labels.LabelSize{}, true},
+	}
+	for _, tt := range tests {
+		got, err := labels.ParseLabelSize(tt.in)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("ParseLabelSize(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParsePrinters(t *testing.T) {
+	printers, err := labels.ParsePrinters(" desk=10.0.0.5:9100, ,warehouse=zebra.local:9100")
+	if err != nil || len(printers) != 2 || printers["desk"] != "10.0.0.5:9100" || printers["warehouse"] != "zebra.local:9100" {
+		t.Errorf("ParsePrinters = %v, %v; want desk and warehouse", printers, err)
+	}
+	for _, bad := range []string{"desk", "desk=10.0.0.5"} {
+		if _, err := labels.ParsePrinters(bad); err == nil {
+			t.Errorf("ParsePrinters(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSendToPrinter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	var zpl bytes.Buffer
+	if err := labels.RenderZPL(&zpl, "barcode", labels.LabelSizes["2x1"], 203, 2, labels.ZPLFields{Tag: "TAG-1", Model: "T14"}); err != nil {
+		t.Fatalf("RenderZPL: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := labels.SendToPrinter(ctx, listener.Addr().String(), zpl.Bytes()); err != nil {
+		t.Fatalf("SendToPrinter: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if !bytes.Equal(got, zpl.Bytes()) {
+			t.Errorf("printer received %q, want %q", got, zpl.Bytes())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("printer received nothing")
+	}
+}
+
+func TestSendToPrinterErrors(t *testing.T) {
+	// A printer that is off refuses the connection
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	if err := labels.SendToPrinter(context.Background(), address, []byte("^XA^XZ")); err == nil || !strings.Contains(err.Error(), address) {
+		t.Errorf("SendToPrinter to a closed port error = %v, want one naming %s", err, address)
+	}
+
+	// A printer that stops reading holds the job up until the request's deadline
+	stalled, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	defer stalled.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := stalled.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	defer func() {
+		select {
+		case conn := <-accepted:
+			conn.Close()
+		default:
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = labels.SendToPrinter(ctx, stalled.Addr().String(), make([]byte, 64<<20))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("SendToPrinter to a stalled printer error = %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("SendToPrinter gave up after %v, want about the 200ms deadline", elapsed)
+	}
+}
@@ -1,52 +1,297 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/config"
 	"github.com/cameo1221/Go-Asset/db"
 	"github.com/cameo1221/Go-Asset/handler"
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/metrics"
+	"github.com/cameo1221/Go-Asset/middleware"
 	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/oidc"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+	"github.com/cameo1221/Go-Asset/tlsconfig"
+	"github.com/cameo1221/Go-Asset/tracing"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
 )
 
+// buildTime is stamped at build time with -ldflags "-X main.buildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var buildTime string
+
+// jobs tracks background work so shutdown can wait for it to stop
+var jobs sync.WaitGroup
+
+// startJob runs fn in the background until ctx is cancelled at shutdown
+func startJob(ctx context.Context, name string, fn func(ctx context.Context)) {
+	jobs.Add(1)
+	go func() {
+		defer jobs.Done()
+		fn(ctx)
+		log.Printf("Stopped %s", name)
+	}()
+}
+
 func main() {
+	// ctx is cancelled on SIGTERM or SIGINT; background jobs run until then
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Error reading configuration: %v", err)
+	}
+	models.QueryTimeout = cfg.QueryTimeout
+	handler.StreamWriteTimeout = cfg.StreamWriteTimeout
+
+	// Everything, including the standard log package, is written as JSON lines
+	logger := logging.New(os.Stdout, cfg.LogLevel)
+	slog.SetDefault(logger)
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.TracesExporter)
+	if err != nil {
+		log.Fatalf("Error setting up tracing: %v", err)
+	}
+
 	// Initialize your database connection
-	database, err := db.Connect()
+	database, err := db.Connect(cfg.Database)
 	if err != nil {
 		log.Fatalf("Error connecting to database: %v", err)
 	}
 
+	// Bring the schema up to date before serving requests
+	if err := database.Migrate(); err != nil {
+		log.Fatalf("Error migrating database: %v", err)
+	}
+
 	// Initialize your asset model with the database connection
 	assetModel := &models.AssetModel{DB: database.Conn}
 	adminModel := &models.AdminModel{DB: database.Conn}
 	employeeModel := &models.EmployeeModel{DB: database.Conn}
 	employeeAssetModel := &models.EmployeeAssetModel{DB: database.Conn}
 	sessionModel := &models.SessionModel{DB: database.Conn}
+	apiKeyModel := &models.APIKeyModel{DB: database.Conn}
+	twoFactorModel := &models.TwoFactorModel{DB: database.Conn}
+	identityModel := &models.IdentityModel{DB: database.Conn}
+	offboardingModel := &models.OffboardingModel{DB: database.Conn}
+	kitModel := &models.KitModel{DB: database.Conn}
+	assetRequestModel := &models.AssetRequestModel{DB: database.Conn}
+	reservationModel := &models.ReservationModel{DB: database.Conn}
+	auditModel := &models.AuditModel{DB: database.Conn}
+	importModel := &models.ImportModel{DB: database.Conn}
+	statsModel := &models.StatsModel{DB: database.Conn}
 
 	// Initialize your asset handler with the asset model
 	assetHandler := handler.NewAssetHandler(assetModel)
-	adminHandler := handler.NewAdminHandler(adminModel)
 	employeeHandler := handler.NewEmployeeHandler(employeeModel)
 	employeeAssetHandler := handler.NewEmployeeassetHandler(employeeAssetModel)
-	sessionHandler := handler.NewSessionHandler(sessionModel)
+	// Rate limits and login failures are kept in this process; a store shared between replicas
+	// can implement the same interfaces
+	limits := ratelimit.NewMemory()
+	authenticator := auth.NewAuthenticator(adminModel, sessionModel, limits, cfg.Lockout)
+	authenticator.SecureCookies = cfg.SecureCookies
+	authenticator.CertificateIdentities = cfg.CertificateIdentities
+	authenticator.APIKeys = apiKeyModel
+	authenticator.ClientAddr = middleware.ClientAddr(cfg.TrustProxyHeaders)
+	authenticator.SessionTimeouts = cfg.SessionTimeouts
+	authenticator.TwoFactor = twoFactorModel
+	authenticator.TOTPIssuer = cfg.TOTPIssuer
+	authenticator.Employees = employeeModel
+	authenticator.Identities = identityModel
+	authenticator.ProvisionEmployees = cfg.OIDCProvisionEmployees
+	authenticator.DisablePasswordLogin = !cfg.PasswordLogin
+	adminHandler := handler.NewAdminHandler(adminModel, authenticator)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyModel)
+	sessionHandler := handler.NewSessionHandler(sessionModel, authenticator)
+	twoFactorHandler := handler.NewTwoFactorHandler(authenticator)
+	meHandler := handler.NewMeHandler(sessionModel, assetModel)
+
+	// Logging in, giving the second factor, single sign-on and the calendar feeds, which check their
+	// own tokens, are reachable without a session
+	publicRoutes := append([]string{handler.LoginRoute, handler.SecondFactorRoute}, handler.CalendarFeedRoutes...)
+	var oidcHandler *handler.OIDCHandler
+	if cfg.OIDC.Enabled() {
+		provider := oidc.NewProvider(cfg.OIDC, &http.Client{Timeout: 10 * time.Second})
+		oidcHandler = handler.NewOIDCHandler(authenticator, provider, cfg.OIDCPostLoginURL)
+		publicRoutes = append(publicRoutes, handler.OIDCLoginRoute, handler.OIDCCallbackRoute)
+	}
+
+	if cfg.BootstrapAdminEmail != "" {
+		created, err := auth.EnsureAdmin(ctx, adminModel, cfg.BootstrapAdminEmail, cfg.BootstrapAdminPassword)
+		if err != nil {
+			log.Fatalf("Error creating bootstrap admin: %v", err)
+		}
+		if created {
+			log.Printf("Created admin %s", cfg.BootstrapAdminEmail)
+		}
+	}
+	offboardingHandler := handler.NewOffboardingHandler(offboardingModel)
+	kitHandler := handler.NewKitHandler(kitModel)
+	assetRequestHandler := handler.NewAssetRequestHandler(assetRequestModel)
+	reservationHandler := handler.NewReservationHandler(reservationModel, cfg.BaseURL, cfg.CalendarFeedKey)
+	auditHandler := handler.NewAuditHandler(auditModel)
+	importHandler := handler.NewImportHandler(importModel)
+
+	labelHandler := handler.NewLabelHandler(assetModel, cfg.BaseURL, cfg.Printers)
+	healthHandler := handler.NewHealthHandler(database, handler.ReadBuildInfo(buildTime))
 
 
 
 	// Initialize a new mux router
 	router := mux.NewRouter()
+	router.Use(middleware.Timeout(cfg.RequestTimeout, cfg.StreamTimeout, handler.StreamRoutes...))
+
+	// Each request gets a span named after its route, continuing the caller's trace if it sent one
+	router.Use(otelmux.Middleware(tracing.ServiceName))
+
+	// Requests are logged with their request ID and trace ID; secrets in bodies are redacted,
+	// and so are one-time codes on the routes that take them
+	routeRedactFields := make(map[string][]string, len(handler.OneTimeCodeRoutes))
+	for _, route := range handler.OneTimeCodeRoutes {
+		routeRedactFields[route] = []string{"code"}
+	}
+	router.Use(middleware.Logging(logger, middleware.LoggingOptions{
+		RedactFields:      cfg.LogRedactFields,
+		RouteRedactFields: routeRedactFields,
+		BodyLimit:         cfg.LogBodyLimit,
+	}))
+
+	// Requests are counted under their route template once mux has matched them
+	appMetrics := metrics.New(database.Conn, statsModel)
+	router.Use(appMetrics.Middleware)
+
+	// Probes and metrics are registered first and stay outside authentication
+	handler.RegisterHealthRoutes(router, healthHandler)
+	router.Handle("/metrics", appMetrics.Handler()).Methods("GET")
+
+	// Every other route needs a session, an API key or a mapped client certificate, except
+	// the public login routes. Clients are limited by IP before authentication so guessing sessions is
+	// throttled too, logins more strictly still, and authenticated callers get their own budget.
+	clientIP := middleware.ClientIP(cfg.TrustProxyHeaders)
+	api := router.NewRoute().Subrouter()
+	api.Use(middleware.RateLimit(limits, cfg.RateLimit, clientIP))
+	api.Use(middleware.RateLimit(limits, cfg.LoginRateLimit, middleware.OnlyRoutes(clientIP, publicRoutes...)))
+	api.Use(middleware.CSRF(publicRoutes...))
+	api.Use(authenticator.Middleware(publicRoutes...))
+	api.Use(middleware.RateLimit(limits, cfg.AdminRateLimit, middleware.ByPrincipal))
 
 	// Register asset routes with the router
-	handler.RegisterAssetRoutes(router, assetHandler)
-	handler.RegisterAdminRoutes(router, adminHandler)
-	handler.RegisterEmployeeRoutes(router, employeeHandler)
-	handler.RegisterEmployeeassetRoutes(router, employeeAssetHandler)
-	handler.RegisterSessionRoutes(router, sessionHandler)
+	handler.RegisterAssetRoutes(api, assetHandler)
+	handler.RegisterAdminRoutes(api, adminHandler)
+	handler.RegisterEmployeeRoutes(api, employeeHandler)
+	handler.RegisterEmployeeassetRoutes(api, employeeAssetHandler)
+	// Single sign-on goes before the session routes, whose /sessions/{id} would match it
+	if oidcHandler != nil {
+		handler.RegisterOIDCRoutes(api, oidcHandler)
+	}
+	handler.RegisterSessionRoutes(api, sessionHandler)
+	handler.RegisterTwoFactorRoutes(api, twoFactorHandler)
+	handler.RegisterAPIKeyRoutes(api, apiKeyHandler)
+	handler.RegisterOffboardingRoutes(api, offboardingHandler)
+	handler.RegisterKitRoutes(api, kitHandler)
+	handler.RegisterAssetRequestRoutes(api, assetRequestHandler)
+	handler.RegisterReservationRoutes(api, reservationHandler)
+	handler.RegisterAuditRoutes(api, auditHandler)
+	handler.RegisterLabelRoutes(api, labelHandler)
+	handler.RegisterImportRoutes(api, importHandler)
+	handler.RegisterMeRoutes(api, meHandler)
+
 
+	// CORS answers preflights before routing, and the security headers cover every response
+	var root http.Handler = router
+	root = middleware.CORS(cfg.CORS, router)(root)
+	root = middleware.SecurityHeaders(cfg.Security)(root)
 
-	// Start the HTTP server
+	// Start the HTTP server. The timeouts stop slow clients from holding connections open.
 	port := ":8080"
-	fmt.Printf("Server listening on port %s\n", port)
-	log.Fatal(http.ListenAndServe(port, router))
+	server := &http.Server{
+		Addr:              port,
+		Handler:           root,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+
+	// With a certificate configured the server speaks only HTTPS, and picks up a renewed
+	// certificate without a restart
+	if cfg.TLS.Enabled() {
+		reloader, err := tlsconfig.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			log.Fatalf("Error loading TLS certificate: %v", err)
+		}
+		if server.TLSConfig, err = tlsconfig.ServerConfig(cfg.TLS, reloader); err != nil {
+			log.Fatalf("Error configuring TLS: %v", err)
+		}
+		startJob(ctx, "certificate reloader", func(ctx context.Context) {
+			reloader.Watch(ctx, cfg.TLSReloadInterval)
+		})
+	}
+
+	if cfg.SessionPurgeInterval > 0 {
+		startJob(ctx, "session purge", func(ctx context.Context) {
+			authenticator.PurgeSessions(ctx, cfg.SessionPurgeInterval, cfg.SessionRetention)
+		})
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Server listening on port %s\n", port)
+		if server.TLSConfig != nil {
+			serveErr <- server.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		database.Close()
+		shutdownTracing(context.Background())
+		log.Fatalf("Error starting server: %v", err)
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down")
+
+	// Stop accepting connections and let in-flight requests finish, up to the deadline
+	shutdownCtx := context.Background()
+	if cfg.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, cfg.ShutdownTimeout)
+		defer cancel()
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error draining requests: %v", err)
+		server.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Error serving: %v", err)
+	}
+
+	// Background jobs saw ctx cancelled with the signal
+	jobs.Wait()
+
+	// Flush spans before the process exits
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Printf("Error flushing traces: %v", err)
+	}
+
+	if err := database.Close(); err != nil {
+		log.Printf("Error closing database: %v", err)
+	}
+	log.Println("Server stopped")
 }
@@ -0,0 +1,165 @@
+// Package metrics exposes Prometheus metrics for HTTP traffic, the database pool and inventory
+// counts. Requests are labelled with the gorilla/mux route template, so /assets/{id} is one series
+// however many assets there are.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// namespace prefixes every metric the application defines
+const namespace = "go_asset"
+
+// countsTimeout bounds the inventory queries run on each scrape
+const countsTimeout = 5 * time.Second
+
+// Metrics holds the registry served at /metrics and the HTTP instruments
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New registers the HTTP instruments, Go runtime metrics, pool gauges for db and the inventory
+// gauges read from stats
+func New(db *sql.DB, stats models.StatsRepository) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method and route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "go_asset"),
+		newCountsCollector(stats),
+	)
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// statusRecorder remembers the status code a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if sr.status == 0 {
+		sr.status = status
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streamed responses
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// Middleware counts and times each request under its route template. Register it with
+// router.Use so the matched route is known.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		next.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		m.duration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		m.requests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+	})
+}
+
+// countsCollector queries the inventory counts on every scrape
+type countsCollector struct {
+	stats       models.StatsRepository
+	assets      *prometheus.Desc
+	assignments *prometheus.Desc
+	sessions    *prometheus.Desc
+}
+
+func newCountsCollector(stats models.StatsRepository) *countsCollector {
+	return &countsCollector{
+		stats: stats,
+		assets: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "assets"),
+			"Assets that have not been archived, by status.", []string{"status"}, nil),
+		assignments: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "active_assignments"),
+			"Assets currently assigned to an employee.", nil, nil),
+		sessions: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "active_sessions"),
+			"Admin sessions that have not expired.", nil, nil),
+	}
+}
+
+func (cc *countsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.assets
+	ch <- cc.assignments
+	ch <- cc.sessions
+}
+
+func (cc *countsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), countsTimeout)
+	defer cancel()
+
+	counts, err := cc.stats.GetCounts(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(cc.assets, err)
+		return
+	}
+
+	// Report every known status so a count that drops to zero shows as zero, not as a gap
+	for _, status := range []string{models.AssetStatusAvailable, models.AssetStatusAssigned, models.AssetStatusLost, models.AssetStatusWrittenOff} {
+		if _, ok := counts.AssetsByStatus[status]; !ok {
+			counts.AssetsByStatus[status] = 0
+		}
+	}
+	for status, n := range counts.AssetsByStatus {
+		ch <- prometheus.MustNewConstMetric(cc.assets, prometheus.GaugeValue, float64(n), status)
+	}
+	ch <- prometheus.MustNewConstMetric(cc.assignments, prometheus.GaugeValue, float64(counts.ActiveAssignments))
+	ch <- prometheus.MustNewConstMetric(cc.sessions, prometheus.GaugeValue, float64(counts.ActiveSessions))
+}
@@ -0,0 +1,63 @@
+package metrics_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
+
+	"github.com/cameo1221/Go-Asset/metrics"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/memory"
+)
+
+func TestMetrics(t *testing.T) {
+	// The pool is never used, only its stats are read
+	db, err := sql.Open("postgres", "host=/nowhere sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := memory.New()
+	if err := store.CreateAsset(context.Background(), &models.Asset{Model: "T14", Company: "Lenovo", Serial: "SN-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := metrics.New(db, store)
+	router := mux.NewRouter()
+	router.Use(m.Middleware)
+	router.HandleFunc("/assets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	}).Methods("GET")
+	router.Handle("/metrics", m.Handler()).Methods("GET")
+
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/assets/"+uuid.NewString(), nil))
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`go_asset_http_requests_total{code="404",method="GET",route="/assets/{id}"} 2`,
+		`go_asset_http_request_duration_seconds_count{method="GET",route="/assets/{id}"} 2`,
+		`go_asset_assets{status="available"} 1`,
+		`go_asset_assets{status="lost"} 0`,
+		`go_asset_active_assignments 0`,
+		`go_asset_active_sessions 0`,
+		`go_sql_open_connections{db_name="go_asset"} 0`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics is missing %s", want)
+		}
+	}
+}
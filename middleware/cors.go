@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CORSOptions configures which browser origins may call the API
+type CORSOptions struct {
+	// AllowedOrigins are the origins, such as https://assets.example.com, allowed to call the
+	// API; "*" allows any origin but cannot be combined with AllowCredentials
+	AllowedOrigins []string
+	// AllowedMethods caps the methods a preflight may approve; each route's own methods apply too
+	AllowedMethods []string
+	// AllowedHeaders are the request headers a browser may send
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers a browser lets scripts read
+	ExposedHeaders []string
+	// AllowCredentials lets browsers send cookies and read the response
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight answer
+	MaxAge time.Duration
+}
+
+// preflightMethods are the methods tried against the router to find what a path accepts
+var preflightMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORS answers preflight OPTIONS requests and adds the CORS headers to responses for allowed
+// origins. A preflight is approved for a method only if routes has a route accepting that method
+// on the path, so the answer follows the .Methods(...) each route was registered with. It wraps the
+// router rather than being registered with router.Use, because mux never matches OPTIONS to a
+// route that does not list it.
+func CORS(opts CORSOptions, routes *mux.Router) func(http.Handler) http.Handler {
+	origins := make(map[string]bool, len(opts.AllowedOrigins))
+	anyOrigin := false
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	allowedMethods := make(map[string]bool, len(opts.AllowedMethods))
+	for _, method := range opts.AllowedMethods {
+		allowedMethods[strings.ToUpper(method)] = true
+	}
+	allowedHeaders := make(map[string]bool, len(opts.AllowedHeaders))
+	for _, header := range opts.AllowedHeaders {
+		allowedHeaders[http.CanonicalHeaderKey(header)] = true
+	}
+	exposed := strings.Join(opts.ExposedHeaders, ", ")
+
+	allowOrigin := func(h http.Header, origin string) {
+		if anyOrigin && !opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			// The answer depends on the origin, so caches must keep one per origin
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		if opts.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(origins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			allowed := origin != "" && (origins[origin] || anyOrigin && !opts.AllowCredentials)
+
+			requested := r.Header.Get("Access-Control-Request-Method")
+			if r.Method != http.MethodOptions || origin == "" || requested == "" {
+				if allowed {
+					allowOrigin(w.Header(), origin)
+					if exposed != "" {
+						w.Header().Set("Access-Control-Expose-Headers", exposed)
+					}
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Preflight
+			methods := routeMethods(routes, r)
+			if len(methods) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+
+			var approved []string
+			for _, method := range methods {
+				if allowedMethods[method] {
+					approved = append(approved, method)
+				}
+			}
+			headers, headersOK := requestedHeaders(r, allowedHeaders)
+			if !allowed || !slices.Contains(approved, strings.ToUpper(requested)) || !headersOK {
+				// Without the CORS headers the browser refuses the real request
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			allowOrigin(w.Header(), origin)
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(approved, ", "))
+			if len(headers) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			}
+			if opts.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// routeMethods lists the methods routes accepts for the request's path
+func routeMethods(routes *mux.Router, r *http.Request) []string {
+	var methods []string
+	for _, method := range preflightMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		var match mux.RouteMatch
+		if routes.Match(probe, &match) && match.MatchErr == nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// requestedHeaders checks the headers a preflight asks for against the allowed ones
+func requestedHeaders(r *http.Request, allowed map[string]bool) ([]string, bool) {
+	var headers []string
+	for _, value := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, header := range strings.Split(value, ",") {
+			header = http.CanonicalHeaderKey(strings.TrimSpace(header))
+			if header == "" {
+				continue
+			}
+			if !allowed[header] {
+				return nil, false
+			}
+			headers = append(headers, header)
+		}
+	}
+	return headers, true
+}
@@ -0,0 +1,142 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/middleware"
+)
+
+func corsRouter(opts middleware.CORSOptions) http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/assets", ok).Methods("GET", "POST")
+	router.HandleFunc("/assets/{id}", ok).Methods("GET", "PUT", "DELETE")
+	return middleware.CORS(opts, router)(router)
+}
+
+var corsOptions = middleware.CORSOptions{
+	AllowedOrigins:   []string{"https://app.example.com"},
+	AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+	AllowedHeaders:   []string{"Authorization", "Content-Type", "X-CSRF-Token"},
+	ExposedHeaders:   []string{"X-Request-ID"},
+	AllowCredentials: true,
+	MaxAge:           10 * time.Minute,
+}
+
+func preflight(h http.Handler, origin, path, method, headers string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		req.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h := corsRouter(corsOptions)
+
+	rec := preflight(h, "https://app.example.com", "/assets/42", "PUT", "content-type, x-csrf-token")
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, PUT, DELETE",
+		"Access-Control-Allow-Headers":     "Content-Type, X-Csrf-Token",
+		"Access-Control-Max-Age":           "600",
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("preflight = %d, want 204", rec.Code)
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+
+	refused := []struct {
+		name                          string
+		origin, path, method, headers string
+	}{
+		{"method the route lacks", "https://app.example.com", "/assets", "DELETE", ""},
+		{"unknown origin", "https://evil.example.com", "/assets/42", "PUT", ""},
+		{"header not allowed", "https://app.example.com", "/assets/42", "PUT", "X-Secret"},
+	}
+	for _, tt := range refused {
+		rec := preflight(h, tt.origin, tt.path, tt.method, tt.headers)
+		if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("%s: preflight = %d with Allow-Origin %q, want 204 without CORS headers",
+				tt.name, rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	if rec := preflight(h, "https://app.example.com", "/nowhere", "GET", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("preflight for an unknown path = %d, want 404", rec.Code)
+	}
+}
+
+func TestCORSRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/assets", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	corsRouter(corsOptions).ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Allow-Origin = %q, want the origin", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID" {
+		t.Errorf("Expose-Headers = %q, want X-Request-ID", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Origin" {
+		t.Errorf("Vary = %q, want Origin", got)
+	}
+
+	opts := corsOptions
+	opts.AllowedOrigins = []string{"*"}
+	opts.AllowCredentials = false
+	rec = httptest.NewRecorder()
+	corsRouter(opts).ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Allow-Origin with any origin allowed = %q, want *", got)
+	}
+
+	rec = httptest.NewRecorder()
+	corsRouter(middleware.CORSOptions{}).ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Allow-Origin with CORS off = %q", got)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := middleware.SecurityHeaders(middleware.SecurityOptions{HSTSMaxAge: 365 * 24 * time.Hour})(http.NotFoundHandler())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/nowhere", nil))
+
+	want := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"Content-Security-Policy":   "frame-ancestors 'none'",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "no-referrer",
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+
+	h = middleware.SecurityHeaders(middleware.SecurityOptions{FrameAncestors: "https://app.example.com"})(http.NotFoundHandler())
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("Strict-Transport-Security") != "" || rec.Header().Get("X-Frame-Options") != "" {
+		t.Errorf("headers %v: want no HSTS by default and no X-Frame-Options when framing is allowed", rec.Header())
+	}
+	if got := rec.Header().Get("Content-Security-Policy"); got != "frame-ancestors https://app.example.com" {
+		t.Errorf("Content-Security-Policy = %q", got)
+	}
+}
@@ -0,0 +1,281 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in both directions. An ID sent by the caller, such as a
+// load balancer, is kept so log lines can be matched up across services.
+const RequestIDHeader = "X-Request-ID"
+
+// redacted replaces the value of every sensitive field in a logged body or query
+const redacted = "[REDACTED]"
+
+// quietPaths are probed or scraped every few seconds and are not worth logging
+var quietPaths = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+	"/version": true,
+}
+
+// requestIDPattern limits which caller-supplied IDs are trusted, so a header cannot forge log lines
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// LoggingOptions controls what Logging writes about request bodies
+type LoggingOptions struct {
+	// RedactFields are matched case-insensitively against JSON keys and form fields; any key
+	// containing one of them, such as new_password for password, has its value replaced
+	RedactFields []string
+	// RouteRedactFields are redacted on top of RedactFields on the routes they are keyed by,
+	// written as "METHOD /path/template", for names too common to hide everywhere
+	RouteRedactFields map[string][]string
+	// BodyLimit is how many bytes of a request body are logged; zero logs no bodies
+	BodyLimit int
+}
+
+// Logging assigns each request an ID, puts a logger carrying it in the request context for
+// handlers and models, and logs one line per request once the response is written: method,
+// route, status, duration, bytes written and the start of the body with secrets redacted.
+// Register it with router.Use after the tracing middleware so the route and trace ID are known.
+func Logging(logger *slog.Logger, opts LoggingOptions) func(http.Handler) http.Handler {
+	defaultRedactor := newRedactor(opts.RedactFields)
+	routeRedactors := make(map[string]*redactor, len(opts.RouteRedactFields))
+	for route, fields := range opts.RouteRedactFields {
+		routeRedactors[route] = newRedactor(append(append([]string(nil), opts.RedactFields...), fields...))
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !requestIDPattern.MatchString(id) {
+				id = uuid.NewString()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			requestLogger := logger.With("request_id", id)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				requestLogger = requestLogger.With("trace_id", span.TraceID().String())
+			}
+			r = r.WithContext(logging.WithLogger(r.Context(), requestLogger))
+
+			if quietPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var body *bodyCapture
+			if opts.BodyLimit > 0 && r.Body != nil && r.Body != http.NoBody {
+				body = &bodyCapture{ReadCloser: r.Body, limit: opts.BodyLimit}
+				r.Body = body
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w}
+			start := time.Now()
+
+			// Logged from a defer so that streams aborted with a panic are still recorded
+			defer func() {
+				panicked := recover()
+
+				status := recorder.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				redactor, ok := routeRedactors[r.Method+" "+routeTemplate(r)]
+				if !ok {
+					redactor = defaultRedactor
+				}
+				attrs := []slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("route", routeTemplate(r)),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+					slog.Int64("bytes", recorder.bytes),
+				}
+				if r.URL.RawQuery != "" {
+					attrs = append(attrs, slog.String("query", redactor.form(r.URL.RawQuery)))
+				}
+				if body != nil && len(body.buf) > 0 {
+					attrs = append(attrs, slog.String("body", redactor.body(body.buf, body.truncated)))
+					if body.truncated {
+						attrs = append(attrs, slog.Bool("body_truncated", true))
+					}
+				}
+
+				level := slog.LevelInfo
+				switch {
+				case panicked != nil:
+					level = slog.LevelError
+					attrs = append(attrs, slog.Bool("aborted", true))
+				case status >= 500:
+					level = slog.LevelError
+				case status >= 400:
+					level = slog.LevelWarn
+				}
+				requestLogger.LogAttrs(r.Context(), level, "request", attrs...)
+
+				if panicked != nil {
+					panic(panicked)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// routeTemplate is the mux route the request matched, such as /assets/{id}
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return ""
+}
+
+// responseRecorder notes the status and size of the response
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.status == 0 {
+		rr.status = status
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+	}
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer to flush streamed responses
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// bodyCapture keeps the first bytes of a request body as the handler reads it, so large uploads
+// are never buffered just for the log
+type bodyCapture struct {
+	io.ReadCloser
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+func (bc *bodyCapture) Read(p []byte) (int, error) {
+	n, err := bc.ReadCloser.Read(p)
+	keep := min(n, bc.limit-len(bc.buf))
+	bc.buf = append(bc.buf, p[:keep]...)
+	if n > keep {
+		bc.truncated = true
+	}
+	return n, err
+}
+
+// redactor hides the values of sensitive fields in logged bodies and queries
+type redactor struct {
+	fields    []string
+	jsonField *regexp.Regexp
+	formField *regexp.Regexp
+}
+
+func newRedactor(fields []string) *redactor {
+	rd := &redactor{}
+	var quoted []string
+	for _, field := range fields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			rd.fields = append(rd.fields, field)
+			quoted = append(quoted, regexp.QuoteMeta(field))
+		}
+	}
+	if len(quoted) == 0 {
+		return rd
+	}
+
+	names := `(?:` + strings.Join(quoted, "|") + `)`
+	// Used on bodies that are cut off or are not valid JSON; a string value cut off by the limit
+	// is still matched without its closing quote
+	rd.jsonField = regexp.MustCompile(`(?i)("[^"]*` + names + `[^"]*"\s*:\s*)(?:"(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+	rd.formField = regexp.MustCompile(`(?i)((?:^|&)[^=&]*` + names + `[^=&]*=)[^&]*`)
+	return rd
+}
+
+func (rd *redactor) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range rd.fields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// body redacts a captured request body. Complete JSON bodies are redacted field by field at any
+// depth; anything else is redacted by pattern as JSON and as a form.
+func (rd *redactor) body(b []byte, truncated bool) string {
+	if len(rd.fields) == 0 {
+		return string(b)
+	}
+
+	if !truncated {
+		var value any
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err == nil && !decoder.More() {
+			if redactedJSON, err := json.Marshal(rd.value(value)); err == nil {
+				return string(redactedJSON)
+			}
+		}
+	}
+
+	s := rd.jsonField.ReplaceAllString(string(b), `${1}"`+redacted+`"`)
+	return rd.formField.ReplaceAllString(s, `${1}`+redacted)
+}
+
+func (rd *redactor) value(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			if rd.sensitive(key) {
+				v[key] = redacted
+			} else {
+				v[key] = rd.value(inner)
+			}
+		}
+	case []any:
+		for i, inner := range v {
+			v[i] = rd.value(inner)
+		}
+	}
+	return v
+}
+
+// form redacts a URL-encoded query or form body
+func (rd *redactor) form(s string) string {
+	if rd.formField == nil {
+		return s
+	}
+	return rd.formField.ReplaceAllString(s, `${1}`+redacted)
+}
@@ -0,0 +1,229 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/middleware"
+	"github.com/gorilla/mux"
+)
+
+// serve sends req through a router logging to a buffer and returns the decoded log lines
+func serve(t *testing.T, opts middleware.LoggingOptions, req *http.Request) (*httptest.ResponseRecorder, []map[string]any) {
+	t.Helper()
+
+	var out bytes.Buffer
+	router := mux.NewRouter()
+	router.Use(middleware.Logging(logging.New(&out, slog.LevelInfo), opts))
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		logging.FromContext(r.Context()).Info("handled")
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hello"))
+	}
+	router.HandleFunc("/admins/{id}", handler)
+	router.HandleFunc("/healthz", handler)
+	router.HandleFunc("/missing", handler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var lines []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var entry map[string]any
+		if err := json.Unmarshal(line, &entry); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return rec, lines
+}
+
+// requestLine is the line Logging wrote after the response
+func requestLine(t *testing.T, lines []map[string]any) map[string]any {
+	t.Helper()
+	for _, line := range lines {
+		if line["msg"] == "request" {
+			return line
+		}
+	}
+	t.Fatalf("no request line in %v", lines)
+	return nil
+}
+
+var options = middleware.LoggingOptions{RedactFields: []string{"password", "token"}, BodyLimit: 1024}
+
+func TestRequestLine(t *testing.T) {
+	rec, lines := serve(t, options, httptest.NewRequest("GET", "/admins/7", nil))
+
+	id := rec.Header().Get(middleware.RequestIDHeader)
+	if id == "" {
+		t.Fatal("no request ID in response")
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want the handler's and the request's", len(lines))
+	}
+	for _, line := range lines {
+		if line["request_id"] != id {
+			t.Errorf("%v line has request_id %v, want %s", line["msg"], line["request_id"], id)
+		}
+	}
+
+	line := requestLine(t, lines)
+	want := map[string]any{"method": "GET", "route": "/admins/{id}", "path": "/admins/7", "status": 200.0, "bytes": 5.0, "level": "INFO"}
+	for key, value := range want {
+		if line[key] != value {
+			t.Errorf("%s = %v, want %v", key, line[key], value)
+		}
+	}
+	if _, ok := line["duration_ms"]; !ok {
+		t.Error("no duration_ms")
+	}
+
+	_, lines = serve(t, options, httptest.NewRequest("GET", "/missing", nil))
+	if line := requestLine(t, lines); line["level"] != "WARN" || line["status"] != 404.0 {
+		t.Errorf("client error logged at %v with status %v, want WARN and 404", line["level"], line["status"])
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	req := httptest.NewRequest("GET", "/admins/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "lb-1234.abc")
+	rec, lines := serve(t, options, req)
+	if got := rec.Header().Get(middleware.RequestIDHeader); got != "lb-1234.abc" {
+		t.Errorf("response request ID = %q, want the caller's", got)
+	}
+	if got := requestLine(t, lines)["request_id"]; got != "lb-1234.abc" {
+		t.Errorf("logged request ID = %v, want the caller's", got)
+	}
+
+	req = httptest.NewRequest("GET", "/admins/7", nil)
+	req.Header.Set(middleware.RequestIDHeader, "forged\nline")
+	rec, _ = serve(t, options, req)
+	if got := rec.Header().Get(middleware.RequestIDHeader); got == "" || strings.Contains(got, "forged") {
+		t.Errorf("response request ID = %q, want a fresh one", got)
+	}
+}
+
+func TestBodyRedaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		limit       int
+		want        []string
+		truncated   bool
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"name":"root","Password":"hunter2","nested":{"session_token":"abc123"},"list":[{"new_password":"hunter2"}],"count":12345678901234567890}`,
+			want:        []string{`"name":"root"`, `"Password":"[REDACTED]"`, `"session_token":"[REDACTED]"`, `"new_password":"[REDACTED]"`, `12345678901234567890`},
+		},
+		{
+			name:        "truncated json",
+			contentType: "application/json",
+			body:        `{"name":"root","password":"hunter2hunter2hunter2"}`,
+			limit:       32,
+			want:        []string{`"name":"root"`, `"password":"[REDACTED]"`},
+			truncated:   true,
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        `name=root&password=hunter2&token=abc123`,
+			want:        []string{`name=root`, `password=[REDACTED]`, `token=[REDACTED]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := options
+			if tt.limit > 0 {
+				opts.BodyLimit = tt.limit
+			}
+			req := httptest.NewRequest("POST", "/admins/7?access_token=abc123&page=2", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+
+			_, lines := serve(t, opts, req)
+			line := requestLine(t, lines)
+			body, _ := line["body"].(string)
+
+			for _, secret := range []string{"hunter2", "abc123"} {
+				if strings.Contains(body, secret) {
+					t.Errorf("body %q leaks %s", body, secret)
+				}
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body %q does not contain %s", body, want)
+				}
+			}
+			if got := line["body_truncated"] == true; got != tt.truncated {
+				t.Errorf("body_truncated = %v, want %v", got, tt.truncated)
+			}
+			if line["query"] != "access_token=[REDACTED]&page=2" {
+				t.Errorf("query = %v, want the token redacted", line["query"])
+			}
+		})
+	}
+}
+
+func TestBodyLimitZero(t *testing.T) {
+	opts := middleware.LoggingOptions{RedactFields: options.RedactFields}
+	_, lines := serve(t, opts, httptest.NewRequest("POST", "/admins/7", strings.NewReader(`{"name":"root"}`)))
+	if body, ok := requestLine(t, lines)["body"]; ok {
+		t.Errorf("body %v logged with no body limit", body)
+	}
+}
+
+func TestQuietPaths(t *testing.T) {
+	rec, lines := serve(t, options, httptest.NewRequest("GET", "/healthz", nil))
+	if rec.Header().Get(middleware.RequestIDHeader) == "" {
+		t.Error("no request ID on a quiet path")
+	}
+	for _, line := range lines {
+		if line["msg"] == "request" {
+			t.Errorf("probe logged: %v", line)
+		}
+	}
+}
+
+func TestRouteRedaction(t *testing.T) {
+	opts := options
+	opts.RouteRedactFields = map[string][]string{"POST /admins/{id}": {"code"}}
+
+	tests := []struct {
+		name   string
+		req    *http.Request
+		leaked bool
+	}{
+		{"route with codes", httptest.NewRequest("POST", "/admins/7", strings.NewReader(`{"code":"123456"}`)), false},
+		{"other method", httptest.NewRequest("PUT", "/admins/7", strings.NewReader(`{"code":"123456"}`)), true},
+		{"other route", httptest.NewRequest("POST", "/missing", strings.NewReader(`{"code":"123456"}`)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, lines := serve(t, opts, tt.req)
+			body, _ := requestLine(t, lines)["body"].(string)
+			if leaked := strings.Contains(body, "123456"); leaked != tt.leaked {
+				t.Errorf("body %q logged the code: %v, want %v", body, leaked, tt.leaked)
+			}
+			if !strings.Contains(body, `"code"`) {
+				t.Errorf("body %q lost the code field", body)
+			}
+		})
+	}
+}
@@ -2,9 +2,6 @@ package middleware
 
 import (
     "net/http"
-    "log"
-    "io"
-    "bytes"
 )
 
 func JSONContentTypeMiddleware(next http.Handler) http.Handler {
@@ -13,21 +10,3 @@ func JSONContentTypeMiddleware(next http.Handler) http.Handler {
         next.ServeHTTP(w, r)
     })
 }
-
-func LoggingMiddleware(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        log.Println("REQUEST:", r)
-        // Read request body into bytes
-        var bodyBytes []byte
-        if r.Body != nil {
-            bodyBytes, _ = io.ReadAll(r.Body)
-        }
-
-        // Restore the io.ReadCloser to its original state
-        r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-
-        log.Println("REQUEST BODY:", string(bodyBytes))
-
-        next.ServeHTTP(w, r)
-    })
-}
@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+)
+
+// RateLimitKey names the bucket a request is charged to; an empty key exempts the request
+type RateLimitKey func(r *http.Request) string
+
+// RateLimit charges each request to the bucket key names and answers 429 Too Many Requests with
+// Retry-After once it is empty. Responses carry the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the tightest limit applied to them. If the store fails the request is
+// let through, since an outage of a shared store should not take the API down with it.
+func RateLimit(store ratelimit.Store, limit ratelimit.Limit, key RateLimitKey) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !limit.Enabled() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			name := key(r)
+			if name == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			decision, err := store.Take(r.Context(), name, limit)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("rate limit store failed", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w.Header(), limit, decision)
+			if !decision.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+				http.Error(w, "Too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setRateLimitHeaders reports the decision unless an earlier limiter already reported a tighter one
+func setRateLimitHeaders(h http.Header, limit ratelimit.Limit, decision ratelimit.Decision) {
+	if current := h.Get("RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining <= decision.Remaining && decision.Allowed {
+			return
+		}
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Per)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ClientIP keys requests by the address of the client. With trustProxy the rightmost address in
+// X-Forwarded-For is used, which is the one added by our own load balancer; only enable it behind
+// a proxy that sets the header, or clients can pick their own bucket.
+func ClientIP(trustProxy bool) RateLimitKey {
+	addr := ClientAddr(trustProxy)
+	return func(r *http.Request) string {
+		return "ip:" + addr(r)
+	}
+}
+
+// ClientAddr returns the address of the client the same way ClientIP does
+func ClientAddr(trustProxy bool) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		if trustProxy {
+			forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+			if last := strings.TrimSpace(forwarded[len(forwarded)-1]); last != "" {
+				return last
+			}
+		}
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+}
+
+// ByPrincipal keys authenticated requests by admin, employee, service or API key and exempts the
+// rest. Register it after the authentication middleware.
+func ByPrincipal(r *http.Request) string {
+	if admin, ok := auth.AdminFromContext(r.Context()); ok {
+		return "admin:" + admin.ID.String()
+	}
+	if employee, ok := auth.EmployeeFromContext(r.Context()); ok {
+		return "employee:" + employee.ID.String()
+	}
+	if service, ok := auth.ServiceFromContext(r.Context()); ok {
+		return "service:" + service
+	}
+	if key, ok := auth.APIKeyFromContext(r.Context()); ok {
+		return "api_key:" + key.ID.String()
+	}
+	return ""
+}
+
+// OnlyRoutes applies key to requests for the given routes, written as "METHOD /path/template",
+// and exempts the rest. The route name is part of the key, so each route has its own bucket.
+func OnlyRoutes(key RateLimitKey, routes ...string) RateLimitKey {
+	limited := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		limited[route] = true
+	}
+	return func(r *http.Request) string {
+		route := r.Method + " " + routeTemplate(r)
+		if !limited[route] {
+			return ""
+		}
+		if name := key(r); name != "" {
+			return route + " " + name
+		}
+		return ""
+	}
+}
@@ -0,0 +1,121 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/auth"
+	"github.com/cameo1221/Go-Asset/middleware"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/ratelimit"
+)
+
+func ok(w http.ResponseWriter, r *http.Request) {}
+
+func send(router http.Handler, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middleware.RateLimit(ratelimit.NewMemory(), ratelimit.Limit{Requests: 2, Per: time.Minute}, middleware.ClientIP(false)))
+	router.HandleFunc("/assets", ok)
+
+	rec := send(router, "GET", "/assets", "192.0.2.1:1000")
+	want := map[string]string{"RateLimit-Limit": "2", "RateLimit-Remaining": "1", "RateLimit-Reset": "30", "RateLimit-Policy": "2;w=60"}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+
+	send(router, "GET", "/assets", "192.0.2.1:1001")
+	rec = send(router, "GET", "/assets", "192.0.2.1:1002")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("third request = %d with Retry-After %q, want 429 after 30s", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	if rec := send(router, "GET", "/assets", "192.0.2.2:1000"); rec.Code != http.StatusOK {
+		t.Errorf("another client = %d, want 200", rec.Code)
+	}
+}
+
+func TestOnlyRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	limit := ratelimit.Limit{Requests: 1, Per: time.Minute}
+	router.Use(middleware.RateLimit(ratelimit.NewMemory(), limit, middleware.OnlyRoutes(middleware.ClientIP(false), "POST /sessions")))
+	router.HandleFunc("/sessions", ok).Methods("GET", "POST")
+
+	send(router, "POST", "/sessions", "192.0.2.1:1000")
+	if rec := send(router, "POST", "/sessions", "192.0.2.1:1000"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second login = %d, want 429", rec.Code)
+	}
+	if rec := send(router, "GET", "/sessions", "192.0.2.1:1000"); rec.Code != http.StatusOK {
+		t.Errorf("other method on the route = %d, want 200", rec.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.7")
+
+	if got := middleware.ClientIP(false)(req); got != "ip:10.0.0.1" {
+		t.Errorf("ClientIP without proxy = %q, want the peer", got)
+	}
+	if got := middleware.ClientIP(true)(req); got != "ip:198.51.100.7" {
+		t.Errorf("ClientIP behind proxy = %q, want the address the proxy added", got)
+	}
+}
+
+func TestByPrincipal(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if got := middleware.ByPrincipal(req); got != "" {
+		t.Errorf("ByPrincipal without a principal = %q, want the request exempt", got)
+	}
+
+	admin := &models.Admin{ID: uuid.New()}
+	adminReq := req.WithContext(auth.WithAdmin(req.Context(), admin))
+	if got := middleware.ByPrincipal(adminReq); got != "admin:"+admin.ID.String() {
+		t.Errorf("ByPrincipal = %q, want keyed by the admin", got)
+	}
+
+	serviceReq := req.WithContext(auth.WithService(req.Context(), "mdm"))
+	if got := middleware.ByPrincipal(serviceReq); got != "service:mdm" {
+		t.Errorf("ByPrincipal = %q, want keyed by the service", got)
+	}
+
+	key := &models.APIKey{ID: uuid.New()}
+	keyReq := req.WithContext(auth.WithAPIKey(req.Context(), key))
+	if got := middleware.ByPrincipal(keyReq); got != "api_key:"+key.ID.String() {
+		t.Errorf("ByPrincipal = %q, want keyed by the API key", got)
+	}
+}
+
+// brokenStore fails every call
+type brokenStore struct{}
+
+func (brokenStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Decision, error) {
+	return ratelimit.Decision{}, errors.New("store unavailable")
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(middleware.RateLimit(brokenStore{}, ratelimit.Limit{Requests: 1, Per: time.Minute}, middleware.ClientIP(false)))
+	router.HandleFunc("/assets", ok)
+
+	if rec := send(router, "GET", "/assets", "192.0.2.1:1000"); rec.Code != http.StatusOK {
+		t.Errorf("request with a failing store = %d, want it let through", rec.Code)
+	}
+}
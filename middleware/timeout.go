@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Timeout gives every request a deadline. Model calls run under the request context, so queries
+// still in flight when it expires are cancelled. The streaming routes, written as
+// "METHOD /path/template", get streamTimeout instead, since exporting a large table legitimately
+// takes longer than any other request; their handlers bound each write on their own. A zero
+// timeout leaves the requests it applies to unbounded.
+func Timeout(timeout, streamTimeout time.Duration, streaming ...string) func(http.Handler) http.Handler {
+	streams := make(map[string]bool, len(streaming))
+	for _, route := range streaming {
+		streams[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := timeout
+			if streams[r.Method+" "+routeTemplate(r)] {
+				limit = streamTimeout
+			}
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), limit)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
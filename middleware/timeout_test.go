@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cameo1221/Go-Asset/middleware"
+)
+
+func TestTimeout(t *testing.T) {
+	deadlines := make(map[string]time.Duration)
+	record := func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		if !ok {
+			deadlines[r.URL.Path] = 0
+			return
+		}
+		deadlines[r.URL.Path] = time.Until(deadline).Round(time.Minute)
+	}
+
+	for _, tt := range []struct {
+		name               string
+		timeout, streaming time.Duration
+		want               map[string]time.Duration
+	}{
+		{"both", time.Minute, time.Hour, map[string]time.Duration{"/assets": time.Hour, "/assets/1": time.Minute}},
+		{"unbounded streams", time.Minute, 0, map[string]time.Duration{"/assets": 0, "/assets/1": time.Minute}},
+		{"unbounded", 0, 0, map[string]time.Duration{"/assets": 0, "/assets/1": 0}},
+	} {
+		router := mux.NewRouter()
+		router.Use(middleware.Timeout(tt.timeout, tt.streaming, "GET /assets"))
+		router.HandleFunc("/assets", record).Methods("GET")
+		router.HandleFunc("/assets/{id}", record).Methods("GET")
+
+		for path, want := range tt.want {
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+			if got := deadlines[path]; got != want {
+				t.Errorf("%s: GET %s has a deadline in %s, want %s", tt.name, path, got, want)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"time"
 	"fmt"
@@ -21,10 +22,13 @@ type AdminModel struct {
 	DB *sql.DB
 }
 
-func (am *AdminModel) CreateAdmin(admin *Admin) error {
+func (am *AdminModel) CreateAdmin(ctx context.Context, admin *Admin) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	admin.ID = uuid.New()
 
-	err := am.DB.QueryRow("INSERT INTO admin (id, name, email, Password, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",admin.ID, admin.Name, admin.Email, admin.Password, time.Now()).Scan(&admin.ID)
+	err := am.DB.QueryRowContext(ctx, "INSERT INTO admin (id, name, email, Password, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id",admin.ID, admin.Name, admin.Email, admin.Password, time.Now()).Scan(&admin.ID)
 
 
 	if err != nil {
@@ -35,29 +39,38 @@ func (am *AdminModel) CreateAdmin(admin *Admin) error {
 }
 
 
-func (am *AdminModel) UpdateAdmin(admin *Admin) error {
+func (am *AdminModel) UpdateAdmin(ctx context.Context, admin *Admin) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE admin
 		SET name = $1, email = $2, password = $3, archive_at = $4
 		WHERE id = $5
 	`
 	
-	_, err := am.DB.Exec(query, admin.Name, admin.Email, admin.Password, admin.ArchivedAt, admin.ID)
+	_, err := am.DB.ExecContext(ctx, query, admin.Name, admin.Email, admin.Password, admin.ArchivedAt, admin.ID)
 	return err
 }
 
-func (am *AdminModel) ArchiveAdmin(id uuid.UUID) error {
+func (am *AdminModel) ArchiveAdmin(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE admin
 		SET archive_at = $1
 		WHERE id = $2
 	`
 
-	_, err := am.DB.Exec(query, time.Now(), id)
+	_, err := am.DB.ExecContext(ctx, query, time.Now(), id)
 	return err
 }
 
-func (am *AdminModel) GetAdminByID(id uuid.UUID) (*Admin, error) {
+func (am *AdminModel) GetAdminByID(ctx context.Context, id uuid.UUID) (*Admin, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		SELECT id, name, email, password, created_at, archive_at
 		FROM admin
@@ -65,7 +78,7 @@ func (am *AdminModel) GetAdminByID(id uuid.UUID) (*Admin, error) {
 	`
 
 	admin := &Admin{}
-	err := am.DB.QueryRow(query, id).Scan(&admin.ID, &admin.Name, &admin.Email, &admin.Password, &admin.CreatedAt, &admin.ArchivedAt)
+	err := am.DB.QueryRowContext(ctx, query, id).Scan(&admin.ID, &admin.Name, &admin.Email, &admin.Password, &admin.CreatedAt, &admin.ArchivedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -73,31 +86,67 @@ func (am *AdminModel) GetAdminByID(id uuid.UUID) (*Admin, error) {
 	return admin, nil
 }
 
-func (am *AdminModel) GetAllAdmins() ([]*Admin, error) {
+// GetAdminByEmail retrieves the active admin with an email address, ignoring case
+func (am *AdminModel) GetAdminByEmail(ctx context.Context, email string) (*Admin, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		SELECT id, name, email, password, created_at, archive_at
 		FROM admin
+		WHERE lower(email) = lower($1) AND archive_at IS NULL
+		ORDER BY created_at
+		LIMIT 1
 	`
 
-	rows, err := am.DB.Query(query)
+	admin := &Admin{}
+	err := am.DB.QueryRowContext(ctx, query, email).Scan(&admin.ID, &admin.Name, &admin.Email, &admin.Password, &admin.CreatedAt, &admin.ArchivedAt)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+
+	return admin, nil
+}
+
+func (am *AdminModel) GetAllAdmins(ctx context.Context) ([]*Admin, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
 	var admins []*Admin
+	err := am.ForEachAdmin(ctx, func(admin *Admin) error {
+		admins = append(admins, admin)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return admins, nil
+}
+
+// ForEachAdmin calls fn for each admin as it is read from the database cursor
+func (am *AdminModel) ForEachAdmin(ctx context.Context, fn func(*Admin) error) error {
+	query := `
+		SELECT id, name, email, password, created_at, archive_at
+		FROM admin
+	`
+
+	rows, err := am.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
 	for rows.Next() {
 		admin := &Admin{}
 		err := rows.Scan(&admin.ID, &admin.Name, &admin.Email, &admin.Password, &admin.CreatedAt, &admin.ArchivedAt)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(admin); err != nil {
+			return err
 		}
-		admins = append(admins, admin)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
 	}
 
-	return admins, nil
+	return rows.Err()
 }
@@ -0,0 +1,211 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAdminLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		admin.Name = "Grace Brewster Hopper"
+		if err := repos.Admins.UpdateAdmin(ctx, admin); err != nil {
+			t.Fatalf("UpdateAdmin: %v", err)
+		}
+		got, err := repos.Admins.GetAdminByID(ctx, admin.ID)
+		if err != nil {
+			t.Fatalf("GetAdminByID: %v", err)
+		}
+		if got.Name != admin.Name || got.Email != admin.Email {
+			t.Errorf("GetAdminByID = %+v, want name %q email %q", got, admin.Name, admin.Email)
+		}
+
+		if err := repos.Admins.ArchiveAdmin(ctx, admin.ID); err != nil {
+			t.Fatalf("ArchiveAdmin: %v", err)
+		}
+		admins, err := repos.Admins.GetAllAdmins(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAdmins: %v", err)
+		}
+		if len(admins) != 1 || admins[0].ArchivedAt == nil {
+			t.Errorf("GetAllAdmins = %+v, want the one archived admin", admins)
+		}
+
+		var seen int
+		if err := repos.Admins.ForEachAdmin(ctx, func(*models.Admin) error { seen++; return nil }); err != nil || seen != 1 {
+			t.Errorf("ForEachAdmin = %v after %d admins, want nil after 1", err, seen)
+		}
+
+		if _, err := repos.Admins.GetAdminByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAdminByID(missing) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		session := &models.Session{AdminID: admin.ID}
+		if err := repos.Sessions.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession: %v", err)
+		}
+		got, err := repos.Sessions.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID: %v", err)
+		}
+		if got.AdminID != admin.ID || !got.Archive_at.After(time.Now()) {
+			t.Errorf("GetSessionByID = %+v, want an unexpired session for admin %s", got, admin.ID)
+		}
+
+		expires := time.Now().Add(time.Hour).Truncate(time.Second)
+		got.Archive_at = expires
+		if err := repos.Sessions.UpdateSession(ctx, got); err != nil {
+			t.Fatalf("UpdateSession: %v", err)
+		}
+		got, err = repos.Sessions.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID: %v", err)
+		}
+		if !got.Archive_at.Equal(expires) {
+			t.Errorf("after update archive_at = %v, want %v", got.Archive_at, expires)
+		}
+
+		if err := repos.Sessions.ArchiveSession(ctx, session.ID); err != nil {
+			t.Fatalf("ArchiveSession: %v", err)
+		}
+		got, err = repos.Sessions.GetSessionByID(ctx, session.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID: %v", err)
+		}
+		if got.Archive_at.After(time.Now()) {
+			t.Errorf("ArchiveSession left archive_at in the future: %v", got.Archive_at)
+		}
+
+		sessions, err := repos.Sessions.GetAllSessions(ctx)
+		if err != nil || len(sessions) != 1 {
+			t.Errorf("GetAllSessions = %d sessions, %v; want 1", len(sessions), err)
+		}
+
+		if err := repos.Sessions.DeleteSession(ctx, session.ID); err != nil {
+			t.Fatalf("DeleteSession: %v", err)
+		}
+		if _, err := repos.Sessions.GetSessionByID(ctx, session.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSessionByID(deleted) error = %v, want sql.ErrNoRows", err)
+		}
+		var seen int
+		if err := repos.Sessions.ForEachSession(ctx, func(*models.Session) error { seen++; return nil }); err != nil || seen != 0 {
+			t.Errorf("ForEachSession = %v after %d sessions, want nil after 0", err, seen)
+		}
+	})
+}
+
+func TestCreateSessionUnknownAdmin(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		if err := repos.Sessions.CreateSession(context.Background(), &models.Session{AdminID: uuid.New()}); err == nil {
+			t.Error("CreateSession for a missing admin succeeded")
+		}
+	})
+}
+
+func TestSessionTokens(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		current := &models.Session{AdminID: admin.ID, TokenHash: "current"}
+		other := &models.Session{AdminID: admin.ID, TokenHash: "other"}
+		for _, session := range []*models.Session{current, other} {
+			if err := repos.Sessions.CreateSession(ctx, session); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+		}
+		if !current.ExpiresAt.After(time.Now().Add(models.DefaultSessionLifetime - time.Minute)) {
+			t.Errorf("CreateSession expires_at = %v, want a day from now", current.ExpiresAt)
+		}
+
+		got, err := repos.Sessions.GetSessionByTokenHash(ctx, "current")
+		if err != nil || got.ID != current.ID {
+			t.Fatalf("GetSessionByTokenHash = %+v, %v; want session %s", got, err, current.ID)
+		}
+		if _, err := repos.Sessions.GetSessionByTokenHash(ctx, ""); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSessionByTokenHash(\"\") error = %v, want sql.ErrNoRows", err)
+		}
+
+		if err := repos.Sessions.RotateSessionToken(ctx, current.ID, "rotated"); err != nil {
+			t.Fatalf("RotateSessionToken: %v", err)
+		}
+		if _, err := repos.Sessions.GetSessionByTokenHash(ctx, "current"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("old token hash error = %v, want sql.ErrNoRows", err)
+		}
+
+		ended, err := repos.Sessions.ArchiveAdminSessions(ctx, admin.ID, current.ID)
+		if err != nil || ended != 1 {
+			t.Fatalf("ArchiveAdminSessions = %d, %v; want 1 session ended", ended, err)
+		}
+		if err := repos.Sessions.RotateSessionToken(ctx, other.ID, "revived"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("RotateSessionToken(ended) error = %v, want sql.ErrNoRows", err)
+		}
+
+		// Renewing an ended session does not bring it back
+		got, err = repos.Sessions.GetSessionByID(ctx, other.ID)
+		if err != nil {
+			t.Fatalf("GetSessionByID: %v", err)
+		}
+		got.Archive_at = time.Now().Add(time.Hour)
+		if err := repos.Sessions.UpdateSession(ctx, got); err != nil {
+			t.Fatalf("UpdateSession: %v", err)
+		}
+		if got, err = repos.Sessions.GetSessionByID(ctx, other.ID); err != nil || got.Archive_at.After(time.Now()) {
+			t.Errorf("renewed ended session = %+v, %v; want it still ended", got, err)
+		}
+
+		purged, err := repos.Sessions.PurgeSessions(ctx, time.Now().Add(time.Minute))
+		if err != nil || purged != 1 {
+			t.Fatalf("PurgeSessions = %d, %v; want the ended session purged", purged, err)
+		}
+		if _, err := repos.Sessions.GetSessionByID(ctx, other.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetSessionByID(purged) error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := repos.Sessions.GetSessionByID(ctx, current.ID); err != nil {
+			t.Errorf("GetSessionByID(current) error = %v, want the live session kept", err)
+		}
+	})
+}
+
+func TestGetAdminByEmail(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		got, err := repos.Admins.GetAdminByEmail(ctx, strings.ToUpper(admin.Email))
+		if err != nil {
+			t.Fatalf("GetAdminByEmail: %v", err)
+		}
+		if got.ID != admin.ID || got.Password != admin.Password {
+			t.Errorf("GetAdminByEmail = %+v, want admin %s with its password hash", got, admin.ID)
+		}
+
+		if err := repos.Admins.ArchiveAdmin(ctx, admin.ID); err != nil {
+			t.Fatalf("ArchiveAdmin: %v", err)
+		}
+		if _, err := repos.Admins.GetAdminByEmail(ctx, admin.Email); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAdminByEmail(archived) error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := repos.Admins.GetAdminByEmail(ctx, "nobody@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAdminByEmail(missing) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
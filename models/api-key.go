@@ -0,0 +1,225 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrAPIKeyNotFound is returned when a key does not exist
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyInactive is returned when rotating or revoking a key that is revoked or expired
+	ErrAPIKeyInactive = errors.New("API key is revoked or expired")
+)
+
+// APIKey lets a script or service call the API without logging in. The secret is never stored,
+// only its hash; Prefix is the start of the key, kept so a key can be looked up and recognised.
+type APIKey struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Prefix string    `json:"prefix"`
+	Hash   string    `json:"-"`
+	// Scopes are the permissions the key grants, such as assets:read
+	Scopes []string `json:"scopes"`
+	// AllowedIPs are the addresses and CIDR ranges the key may be used from; empty allows any
+	AllowedIPs []string   `json:"allowed_ips"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Active reports whether the key can still be used at the given time
+func (k *APIKey) Active(at time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || at.Before(*k.ExpiresAt))
+}
+
+// APIKeyModel represents the model for API key operations
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+const apiKeyColumns = `id, name, prefix, hash, scopes, allowed_ips, created_by, expires_at, last_used_at,
+	last_used_ip, revoked_at, created_at`
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	key := &APIKey{}
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes), pq.Array(&key.AllowedIPs),
+		&key.CreatedBy, &key.ExpiresAt, &key.LastUsedAt, &key.LastUsedIP, &key.RevokedAt, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// execer is the subset of *sql.DB and *sql.Tx an insert needs
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertAPIKey(ctx context.Context, db execer, key *APIKey) error {
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+	key.LastUsedAt, key.LastUsedIP, key.RevokedAt = nil, "", nil
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO api_key (id, name, prefix, hash, scopes, allowed_ips, created_by, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, key.ID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), pq.Array(key.AllowedIPs), key.CreatedBy,
+		key.ExpiresAt, key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating API key: %w", err)
+	}
+	return nil
+}
+
+// CreateAPIKey stores a new key. The caller generates the key and sets its prefix and hash.
+func (km *APIKeyModel) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return insertAPIKey(ctx, km.DB, key)
+}
+
+// GetAPIKeyByID retrieves a key by its ID
+func (km *APIKeyModel) GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	key, err := scanAPIKey(km.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_key WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// GetAPIKeyByPrefix retrieves the key with the given prefix, whether or not it is still active
+func (km *APIKeyModel) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	key, err := scanAPIKey(km.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_key WHERE prefix = $1`, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// GetAllAPIKeys retrieves every key, revoked and expired ones included
+func (km *APIKeyModel) GetAllAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var keys []*APIKey
+	err := km.ForEachAPIKey(ctx, func(key *APIKey) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ForEachAPIKey calls fn for each key, oldest first, as it is read from the database cursor
+func (km *APIKeyModel) ForEachAPIKey(ctx context.Context, fn func(*APIKey) error) error {
+	rows, err := km.DB.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_key ORDER BY created_at, id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RevokeAPIKey stops a key from being used
+func (km *APIKeyModel) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var revokedAt *time.Time
+	err := km.DB.QueryRowContext(ctx, `SELECT revoked_at FROM api_key WHERE id = $1`, id).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if revokedAt != nil {
+		return ErrAPIKeyInactive
+	}
+
+	_, err = km.DB.ExecContext(ctx, `UPDATE api_key SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL`, time.Now(), id)
+	return err
+}
+
+// RotateAPIKey stores replacement, which takes over the old key's name, scopes, IP ranges and
+// expiry, and retires the old key at retireAt, or at its own expiry if that is sooner. A grace
+// period lets callers switch over without failed requests.
+func (km *APIKeyModel) RotateAPIKey(ctx context.Context, id uuid.UUID, replacement *APIKey, retireAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := km.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	old, err := scanAPIKey(tx.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_key WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !old.Active(time.Now()) {
+		return ErrAPIKeyInactive
+	}
+
+	if old.ExpiresAt == nil || retireAt.Before(*old.ExpiresAt) {
+		if _, err := tx.ExecContext(ctx, `UPDATE api_key SET expires_at = $1 WHERE id = $2`, retireAt, id); err != nil {
+			return err
+		}
+	}
+
+	replacement.Name, replacement.Scopes, replacement.AllowedIPs = old.Name, old.Scopes, old.AllowedIPs
+	replacement.ExpiresAt = old.ExpiresAt
+	if err := insertAPIKey(ctx, tx, replacement); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// TouchAPIKey records that a key was just used, and from where
+func (km *APIKeyModel) TouchAPIKey(ctx context.Context, id uuid.UUID, ip string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := km.DB.ExecContext(ctx, `UPDATE api_key SET last_used_at = $1, last_used_ip = $2 WHERE id = $3`, time.Now(), ip, id)
+	return err
+}
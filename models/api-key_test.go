@@ -0,0 +1,94 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		expires := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+		key := &models.APIKey{
+			Name:       "hr-sync",
+			Prefix:     "ga_first",
+			Hash:       "hash-1",
+			Scopes:     []string{"employees:read", "employees:write"},
+			AllowedIPs: []string{"10.0.0.0/8"},
+			CreatedBy:  &admin.ID,
+			ExpiresAt:  &expires,
+		}
+		if err := repos.APIKeys.CreateAPIKey(ctx, key); err != nil {
+			t.Fatalf("CreateAPIKey: %v", err)
+		}
+		if err := repos.APIKeys.CreateAPIKey(ctx, &models.APIKey{Name: "dup", Prefix: "ga_first", Hash: "hash-2"}); err == nil {
+			t.Error("CreateAPIKey with a duplicate prefix succeeded")
+		}
+
+		got, err := repos.APIKeys.GetAPIKeyByPrefix(ctx, "ga_first")
+		if err != nil {
+			t.Fatalf("GetAPIKeyByPrefix: %v", err)
+		}
+		if got.ID != key.ID || got.Hash != "hash-1" || len(got.Scopes) != 2 || got.AllowedIPs[0] != "10.0.0.0/8" ||
+			got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) || *got.CreatedBy != admin.ID {
+			t.Errorf("GetAPIKeyByPrefix = %+v, want the stored key", got)
+		}
+		if _, err := repos.APIKeys.GetAPIKeyByID(ctx, uuid.New()); !errors.Is(err, models.ErrAPIKeyNotFound) {
+			t.Errorf("GetAPIKeyByID(missing) error = %v, want ErrAPIKeyNotFound", err)
+		}
+
+		if err := repos.APIKeys.TouchAPIKey(ctx, key.ID, "10.1.2.3"); err != nil {
+			t.Fatalf("TouchAPIKey: %v", err)
+		}
+		if got, _ := repos.APIKeys.GetAPIKeyByID(ctx, key.ID); got.LastUsedAt == nil || got.LastUsedIP != "10.1.2.3" {
+			t.Errorf("after TouchAPIKey last used %v from %q", got.LastUsedAt, got.LastUsedIP)
+		}
+
+		// The replacement inherits everything but the secret; the old key retires at the grace deadline
+		retireAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		replacement := &models.APIKey{Prefix: "ga_second", Hash: "hash-3", CreatedBy: &admin.ID}
+		if err := repos.APIKeys.RotateAPIKey(ctx, key.ID, replacement, retireAt); err != nil {
+			t.Fatalf("RotateAPIKey: %v", err)
+		}
+		if replacement.Name != "hr-sync" || len(replacement.Scopes) != 2 || replacement.ExpiresAt == nil || !replacement.ExpiresAt.Equal(expires) {
+			t.Errorf("replacement = %+v, want the old key's name, scopes and expiry", replacement)
+		}
+		old, err := repos.APIKeys.GetAPIKeyByID(ctx, key.ID)
+		if err != nil {
+			t.Fatalf("GetAPIKeyByID: %v", err)
+		}
+		if old.ExpiresAt == nil || !old.ExpiresAt.Equal(retireAt) || !old.Active(time.Now()) || old.Active(retireAt) {
+			t.Errorf("old key expires at %v, want %v", old.ExpiresAt, retireAt)
+		}
+
+		if err := repos.APIKeys.RevokeAPIKey(ctx, key.ID); err != nil {
+			t.Fatalf("RevokeAPIKey: %v", err)
+		}
+		if err := repos.APIKeys.RevokeAPIKey(ctx, key.ID); !errors.Is(err, models.ErrAPIKeyInactive) {
+			t.Errorf("second RevokeAPIKey error = %v, want ErrAPIKeyInactive", err)
+		}
+		if err := repos.APIKeys.RotateAPIKey(ctx, key.ID, &models.APIKey{Prefix: "ga_third", Hash: "hash-4"}, time.Now()); !errors.Is(err, models.ErrAPIKeyInactive) {
+			t.Errorf("RotateAPIKey of a revoked key error = %v, want ErrAPIKeyInactive", err)
+		}
+		if err := repos.APIKeys.RevokeAPIKey(ctx, uuid.New()); !errors.Is(err, models.ErrAPIKeyNotFound) {
+			t.Errorf("RevokeAPIKey(missing) error = %v, want ErrAPIKeyNotFound", err)
+		}
+
+		keys, err := repos.APIKeys.GetAllAPIKeys(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAPIKeys: %v", err)
+		}
+		if len(keys) != 2 || keys[0].ID != key.ID || keys[0].RevokedAt == nil || keys[1].ID != replacement.ID {
+			t.Errorf("GetAllAPIKeys = %+v, want the revoked key then its replacement", keys)
+		}
+	})
+}
@@ -0,0 +1,364 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Asset request statuses. Requests move from the employee's manager to an asset manager and end
+// either fulfilled with an assignment or rejected with a reason.
+const (
+	AssetRequestPendingManager      = "pending_manager"
+	AssetRequestPendingAssetManager = "pending_asset_manager"
+	AssetRequestFulfilled           = "fulfilled"
+	AssetRequestRejected            = "rejected"
+)
+
+var (
+	// ErrAssetRequestNotFound is returned when a request does not exist
+	ErrAssetRequestNotFound = errors.New("asset request not found")
+	// ErrAssetRequestClosed is returned when acting on a request that is already fulfilled or rejected
+	ErrAssetRequestClosed = errors.New("asset request is already closed")
+	// ErrNotApprover is returned when someone other than the current approver decides a request
+	ErrNotApprover = errors.New("not the approver for this stage of the request")
+	// ErrNoAssetAvailable is returned when no in-stock asset can fulfil a request
+	ErrNoAssetAvailable = errors.New("no available asset to fulfil request")
+	// ErrNotWaitingForManager is returned when escalating a request its manager has already decided
+	ErrNotWaitingForManager = errors.New("asset request is not waiting for a manager")
+)
+
+// AssetRequest is an employee's request for equipment
+type AssetRequest struct {
+	ID               uuid.UUID  `json:"id"`
+	EmployeeID       uuid.UUID  `json:"employee_id"`
+	Category         string     `json:"category"`
+	Justification    string     `json:"justification"`
+	Status           string     `json:"status"`
+	ManagerID        *uuid.UUID `json:"manager_id,omitempty"`
+	ManagerDecidedAt *time.Time `json:"manager_decided_at,omitempty"`
+	ApproverID       *uuid.UUID `json:"approver_id,omitempty"`
+	DecidedAt        *time.Time `json:"decided_at,omitempty"`
+	RejectionReason  string     `json:"rejection_reason,omitempty"`
+	EmployeeAssetID  *uuid.UUID `json:"employee_asset_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	// EscalatedBy is the admin who took the request past its manager, and EscalationReason why
+	EscalatedBy      *uuid.UUID `json:"escalated_by,omitempty"`
+	EscalationReason string     `json:"escalation_reason,omitempty"`
+}
+
+// AssetRequestModel represents the model for asset request operations
+type AssetRequestModel struct {
+	DB *sql.DB
+}
+
+const assetRequestColumns = `id, employee_id, category, justification, status, manager_id, manager_decided_at,
+	approver_id, decided_at, rejection_reason, employee_asset_id, created_at, escalated_by, escalation_reason`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAssetRequest(row rowScanner) (*AssetRequest, error) {
+	request := &AssetRequest{}
+	err := row.Scan(&request.ID, &request.EmployeeID, &request.Category, &request.Justification, &request.Status,
+		&request.ManagerID, &request.ManagerDecidedAt, &request.ApproverID, &request.DecidedAt,
+		&request.RejectionReason, &request.EmployeeAssetID, &request.CreatedAt, &request.EscalatedBy, &request.EscalationReason)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// CreateAssetRequest files a request and routes it to the employee's manager,
+// or straight to asset managers when the employee has none
+func (arm *AssetRequestModel) CreateAssetRequest(ctx context.Context, request *AssetRequest) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	err := arm.DB.QueryRowContext(ctx, `SELECT manager_id FROM employee WHERE id = $1 AND archive_at IS NULL`, request.EmployeeID).
+		Scan(&request.ManagerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrEmployeeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	request.ID = uuid.New()
+	request.CreatedAt = time.Now()
+	request.Status = AssetRequestPendingManager
+	if request.ManagerID == nil {
+		request.Status = AssetRequestPendingAssetManager
+	}
+
+	_, err = arm.DB.ExecContext(ctx, `
+		INSERT INTO asset_request (id, employee_id, category, justification, status, manager_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, request.ID, request.EmployeeID, request.Category, request.Justification, request.Status, request.ManagerID, request.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating asset request: %w", err)
+	}
+
+	return nil
+}
+
+// GetAssetRequestByID retrieves a request by its ID
+func (arm *AssetRequestModel) GetAssetRequestByID(ctx context.Context, id uuid.UUID) (*AssetRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	request, err := scanAssetRequest(arm.DB.QueryRowContext(ctx, `SELECT `+assetRequestColumns+` FROM asset_request WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAssetRequestNotFound
+	}
+	return request, err
+}
+
+// GetAllAssetRequests retrieves all requests, optionally only those in the given status
+func (arm *AssetRequestModel) GetAllAssetRequests(ctx context.Context, status string) ([]*AssetRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var requests []*AssetRequest
+	err := arm.ForEachAssetRequest(ctx, status, func(request *AssetRequest) error {
+		requests = append(requests, request)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ForEachAssetRequest calls fn for each request, optionally only those in the given status,
+// as it is read from the database cursor
+func (arm *AssetRequestModel) ForEachAssetRequest(ctx context.Context, status string, fn func(*AssetRequest) error) error {
+	rows, err := arm.DB.QueryContext(ctx, `
+		SELECT `+assetRequestColumns+`
+		FROM asset_request
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at
+	`, status)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		request, err := scanAssetRequest(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(request); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// lockAssetRequest loads a request for update and makes sure it is still open
+func lockAssetRequest(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*AssetRequest, error) {
+	request, err := scanAssetRequest(tx.QueryRowContext(ctx, `SELECT `+assetRequestColumns+` FROM asset_request WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAssetRequestNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if request.Status == AssetRequestFulfilled || request.Status == AssetRequestRejected {
+		return nil, ErrAssetRequestClosed
+	}
+	return request, nil
+}
+
+// checkApprover verifies approverID, the authenticated employee or admin, may decide the request
+// at its current stage: the employee's manager first, then any active admin
+func checkApprover(ctx context.Context, tx *sql.Tx, request *AssetRequest, approverID uuid.UUID) error {
+	if request.Status == AssetRequestPendingManager {
+		if request.ManagerID == nil || *request.ManagerID != approverID {
+			return ErrNotApprover
+		}
+		return nil
+	}
+	return checkAdmin(ctx, tx, approverID)
+}
+
+// checkAdmin verifies adminID is an active admin
+func checkAdmin(ctx context.Context, tx *sql.Tx, adminID uuid.UUID) error {
+	var isAdmin bool
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM admin WHERE id = $1 AND archive_at IS NULL)`, adminID).Scan(&isAdmin)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return ErrNotApprover
+	}
+	return nil
+}
+
+// EscalateAssetRequest lets an active admin take a request waiting for a manager who cannot decide
+// it, such as one who has left or cannot log in, straight to the asset manager stage. The admin and
+// their reason are recorded on the request.
+func (arm *AssetRequestModel) EscalateAssetRequest(ctx context.Context, id, adminID uuid.UUID, reason string) (*AssetRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := arm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	request, err := lockAssetRequest(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAdmin(ctx, tx, adminID); err != nil {
+		return nil, err
+	}
+	if request.Status != AssetRequestPendingManager {
+		return nil, ErrNotWaitingForManager
+	}
+
+	now := time.Now()
+	request.Status = AssetRequestPendingAssetManager
+	request.ManagerDecidedAt = &now
+	request.EscalatedBy = &adminID
+	request.EscalationReason = reason
+	_, err = tx.ExecContext(ctx, `
+		UPDATE asset_request
+		SET status = $1, manager_decided_at = $2, escalated_by = $3, escalation_reason = $4
+		WHERE id = $5
+	`, request.Status, now, adminID, reason, request.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, tx.Commit()
+}
+
+// ApproveAssetRequest advances a request. The manager's approval hands it to asset managers; an asset
+// manager's approval fulfils it by assigning assetID, or the oldest available asset in the requested
+// category when assetID is nil.
+func (arm *AssetRequestModel) ApproveAssetRequest(ctx context.Context, id, approverID uuid.UUID, assetID *uuid.UUID) (*AssetRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := arm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	request, err := lockAssetRequest(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApprover(ctx, tx, request, approverID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if request.Status == AssetRequestPendingManager {
+		request.Status = AssetRequestPendingAssetManager
+		request.ManagerDecidedAt = &now
+		_, err = tx.ExecContext(ctx, `UPDATE asset_request SET status = $1, manager_decided_at = $2 WHERE id = $3`,
+			request.Status, now, request.ID)
+		if err != nil {
+			return nil, err
+		}
+		return request, tx.Commit()
+	}
+
+	var chosen uuid.UUID
+	if assetID != nil {
+		err = tx.QueryRowContext(ctx, `
+			SELECT id FROM asset
+			WHERE id = $1 AND status = $2 AND archive_at IS NULL
+			FOR UPDATE
+		`, *assetID, AssetStatusAvailable).Scan(&chosen)
+	} else {
+		err = tx.QueryRowContext(ctx, `
+			SELECT id FROM asset
+			WHERE category = $1 AND status = $2 AND archive_at IS NULL
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		`, request.Category, AssetStatusAvailable).Scan(&chosen)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoAssetAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	assignment := &EmployeeAsset{AssetID: chosen, EmployeeID: request.EmployeeID, CreatedAt: now}
+	if err := assignAsset(ctx, tx, assignment); err != nil {
+		return nil, err
+	}
+
+	request.Status = AssetRequestFulfilled
+	request.ApproverID = &approverID
+	request.DecidedAt = &now
+	request.EmployeeAssetID = &assignment.ID
+	_, err = tx.ExecContext(ctx, `
+		UPDATE asset_request
+		SET status = $1, approver_id = $2, decided_at = $3, employee_asset_id = $4
+		WHERE id = $5
+	`, request.Status, approverID, now, assignment.ID, request.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, tx.Commit()
+}
+
+// RejectAssetRequest closes a request at its current stage and records why
+func (arm *AssetRequestModel) RejectAssetRequest(ctx context.Context, id, approverID uuid.UUID, reason string) (*AssetRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := arm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	request, err := lockAssetRequest(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApprover(ctx, tx, request, approverID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if request.Status == AssetRequestPendingManager {
+		request.ManagerDecidedAt = &now
+	} else {
+		request.ApproverID = &approverID
+	}
+	request.Status = AssetRequestRejected
+	request.DecidedAt = &now
+	request.RejectionReason = reason
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE asset_request
+		SET status = $1, manager_decided_at = $2, approver_id = $3, decided_at = $4, rejection_reason = $5
+		WHERE id = $6
+	`, request.Status, request.ManagerDecidedAt, request.ApproverID, now, reason, request.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return request, tx.Commit()
+}
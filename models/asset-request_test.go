@@ -0,0 +1,172 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAssetRequestApproval(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+		manager := mustEmployee(t, repos, "manager", nil)
+		employee := mustEmployee(t, repos, "engineer", manager)
+		asset := mustAsset(t, repos, "laptop", "London")
+
+		request := &models.AssetRequest{EmployeeID: employee.ID, Category: "laptop", Justification: "new hire"}
+		if err := repos.AssetRequests.CreateAssetRequest(ctx, request); err != nil {
+			t.Fatalf("CreateAssetRequest: %v", err)
+		}
+		if request.Status != models.AssetRequestPendingManager {
+			t.Fatalf("new request status = %q, want %q", request.Status, models.AssetRequestPendingManager)
+		}
+
+		steps := []struct {
+			name       string
+			approver   uuid.UUID
+			wantErr    error
+			wantStatus string
+		}{
+			{"admin before manager", admin.ID, models.ErrNotApprover, ""},
+			{"manager", manager.ID, nil, models.AssetRequestPendingAssetManager},
+			{"manager again", manager.ID, models.ErrNotApprover, ""},
+			{"admin", admin.ID, nil, models.AssetRequestFulfilled},
+			{"admin again", admin.ID, models.ErrAssetRequestClosed, ""},
+		}
+		for _, step := range steps {
+			t.Run(step.name, func(t *testing.T) {
+				got, err := repos.AssetRequests.ApproveAssetRequest(ctx, request.ID, step.approver, nil)
+				if !errors.Is(err, step.wantErr) {
+					t.Fatalf("ApproveAssetRequest error = %v, want %v", err, step.wantErr)
+				}
+				if err == nil && got.Status != step.wantStatus {
+					t.Errorf("ApproveAssetRequest status = %q, want %q", got.Status, step.wantStatus)
+				}
+			})
+		}
+
+		got, err := repos.AssetRequests.GetAssetRequestByID(ctx, request.ID)
+		if err != nil {
+			t.Fatalf("GetAssetRequestByID: %v", err)
+		}
+		if got.EmployeeAssetID == nil {
+			t.Fatal("fulfilled request has no assignment")
+		}
+		mapping, err := repos.EmployeeAssets.GetEmployeeAssetByID(ctx, *got.EmployeeAssetID)
+		if err != nil || mapping.AssetID != asset.Id {
+			t.Errorf("request assignment = %v, %v; want asset %s", mapping, err, asset.Id)
+		}
+
+		tests := []struct {
+			status string
+			want   int
+		}{
+			{"", 1},
+			{models.AssetRequestFulfilled, 1},
+			{models.AssetRequestRejected, 0},
+		}
+		for _, tt := range tests {
+			requests, err := repos.AssetRequests.GetAllAssetRequests(ctx, tt.status)
+			if err != nil || len(requests) != tt.want {
+				t.Errorf("GetAllAssetRequests(%q) = %d requests, %v; want %d", tt.status, len(requests), err, tt.want)
+			}
+		}
+		var seen int
+		err = repos.AssetRequests.ForEachAssetRequest(ctx, "", func(*models.AssetRequest) error { seen++; return nil })
+		if err != nil || seen != 1 {
+			t.Errorf("ForEachAssetRequest = %v after %d requests, want nil after 1", err, seen)
+		}
+	})
+}
+
+func TestAssetRequestRejection(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+		employee := mustEmployee(t, repos, "engineer", nil)
+
+		// Without a manager the request goes straight to asset managers
+		request := &models.AssetRequest{EmployeeID: employee.ID, Category: "laptop"}
+		if err := repos.AssetRequests.CreateAssetRequest(ctx, request); err != nil {
+			t.Fatalf("CreateAssetRequest: %v", err)
+		}
+		if request.Status != models.AssetRequestPendingAssetManager {
+			t.Fatalf("new request status = %q, want %q", request.Status, models.AssetRequestPendingAssetManager)
+		}
+
+		if _, err := repos.AssetRequests.ApproveAssetRequest(ctx, request.ID, admin.ID, nil); !errors.Is(err, models.ErrNoAssetAvailable) {
+			t.Errorf("ApproveAssetRequest without stock error = %v, want ErrNoAssetAvailable", err)
+		}
+
+		got, err := repos.AssetRequests.RejectAssetRequest(ctx, request.ID, admin.ID, "no stock")
+		if err != nil {
+			t.Fatalf("RejectAssetRequest: %v", err)
+		}
+		if got.Status != models.AssetRequestRejected || got.RejectionReason != "no stock" {
+			t.Errorf("RejectAssetRequest = %+v, want rejected with a reason", got)
+		}
+
+		if _, err := repos.AssetRequests.RejectAssetRequest(ctx, uuid.New(), admin.ID, ""); !errors.Is(err, models.ErrAssetRequestNotFound) {
+			t.Errorf("RejectAssetRequest(missing) error = %v, want ErrAssetRequestNotFound", err)
+		}
+		if err := repos.AssetRequests.CreateAssetRequest(ctx, &models.AssetRequest{EmployeeID: uuid.New()}); !errors.Is(err, models.ErrEmployeeNotFound) {
+			t.Errorf("CreateAssetRequest(missing employee) error = %v, want ErrEmployeeNotFound", err)
+		}
+	})
+}
+
+func TestAssetRequestEscalation(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+		manager := mustEmployee(t, repos, "manager", nil)
+		employee := mustEmployee(t, repos, "engineer", manager)
+		mustAsset(t, repos, "laptop", "London")
+
+		request := &models.AssetRequest{EmployeeID: employee.ID, Category: "laptop", EscalatedBy: &admin.ID}
+		if err := repos.AssetRequests.CreateAssetRequest(ctx, request); err != nil {
+			t.Fatalf("CreateAssetRequest: %v", err)
+		}
+		if got, err := repos.AssetRequests.GetAssetRequestByID(ctx, request.ID); err != nil || got.EscalatedBy != nil {
+			t.Fatalf("new request = %+v, %v; want it not escalated", got, err)
+		}
+
+		if _, err := repos.AssetRequests.EscalateAssetRequest(ctx, request.ID, manager.ID, "on leave"); !errors.Is(err, models.ErrNotApprover) {
+			t.Errorf("escalation by the manager error = %v, want ErrNotApprover", err)
+		}
+		if _, err := repos.AssetRequests.EscalateAssetRequest(ctx, uuid.New(), admin.ID, "on leave"); !errors.Is(err, models.ErrAssetRequestNotFound) {
+			t.Errorf("escalating a missing request error = %v, want ErrAssetRequestNotFound", err)
+		}
+
+		escalated, err := repos.AssetRequests.EscalateAssetRequest(ctx, request.ID, admin.ID, "manager has left")
+		if err != nil {
+			t.Fatalf("EscalateAssetRequest: %v", err)
+		}
+		got, err := repos.AssetRequests.GetAssetRequestByID(ctx, request.ID)
+		if err != nil {
+			t.Fatalf("GetAssetRequestByID: %v", err)
+		}
+		for _, r := range []*models.AssetRequest{escalated, got} {
+			if r.Status != models.AssetRequestPendingAssetManager || r.EscalatedBy == nil || *r.EscalatedBy != admin.ID ||
+				r.EscalationReason != "manager has left" || r.ManagerDecidedAt == nil {
+				t.Errorf("escalated request = %+v, want it with the admins, escalated by %s", r, admin.ID)
+			}
+		}
+
+		if _, err := repos.AssetRequests.EscalateAssetRequest(ctx, request.ID, admin.ID, "again"); !errors.Is(err, models.ErrNotWaitingForManager) {
+			t.Errorf("escalating twice error = %v, want ErrNotWaitingForManager", err)
+		}
+		if _, err := repos.AssetRequests.ApproveAssetRequest(ctx, request.ID, manager.ID, nil); !errors.Is(err, models.ErrNotApprover) {
+			t.Errorf("manager approval after escalation error = %v, want ErrNotApprover", err)
+		}
+		if got, err := repos.AssetRequests.ApproveAssetRequest(ctx, request.ID, admin.ID, nil); err != nil || got.Status != models.AssetRequestFulfilled {
+			t.Errorf("admin approval after escalation = %+v, %v; want fulfilled", got, err)
+		}
+	})
+}
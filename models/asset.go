@@ -1,8 +1,11 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -14,17 +17,72 @@ type Asset struct {
 	Id         uuid.UUID  `json:"Id,omitempty" db:"Id"`
 	Model      string     `json:"Model,omitempty" db:"Model"`
 	Company    string     `json:"Company,omitempty" db:"Company"`
+	Tag        string     `json:"Tag,omitempty" db:"tag"`
+	Serial     string     `json:"Serial,omitempty" db:"serial"`
+	Category   string     `json:"Category,omitempty" db:"category"`
+	Location   string     `json:"Location,omitempty" db:"location"`
+	Status     string     `json:"Status,omitempty" db:"status"`
 	CreatedAt  time.Time  `json:"createdAt,omitempty" db:"created_at"`
 	ArchivedAt *time.Time `json:"archivedAt,omitempty" db:"archive_at"`
 }
 
+// Asset statuses tracked over an asset's lifecycle
+const (
+	AssetStatusAvailable  = "available"
+	AssetStatusAssigned   = "assigned"
+	AssetStatusLost       = "lost"
+	AssetStatusWrittenOff = "written_off"
+)
+
+var (
+	// ErrInvalidAssetStatus is returned when an asset is given a status outside the lifecycle
+	ErrInvalidAssetStatus = errors.New("invalid asset status")
+	// ErrAssetStatusConflict is returned when a status would disagree with the asset's assignments:
+	// an assigned asset must have a current assignment, and any other status must have none
+	ErrAssetStatusConflict = errors.New("asset status does not match its assignments")
+)
+
+// ValidAssetStatus reports whether status is one of the asset lifecycle statuses
+func ValidAssetStatus(status string) bool {
+	switch status {
+	case AssetStatusAvailable, AssetStatusAssigned, AssetStatusLost, AssetStatusWrittenOff:
+		return true
+	}
+	return false
+}
+
 type AssetModel struct {
 	DB *sql.DB
 }
 
-func (am *AssetModel) CreateAsset(asset *Asset) error {
+// assetColumns is the column list scanAsset expects, in order
+const assetColumns = `id, model, company, tag, serial, category, location, status, created_at, archive_at`
+
+func scanAsset(row rowScanner) (*Asset, error) {
+	var asset Asset
+	err := row.Scan(&asset.Id, &asset.Model, &asset.Company, &asset.Tag, &asset.Serial, &asset.Category, &asset.Location, &asset.Status, &asset.CreatedAt, &asset.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+func (am *AssetModel) CreateAsset(ctx context.Context, asset *Asset) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	asset.Id = uuid.New()
-	err := am.DB.QueryRow("INSERT INTO asset (id, model, company, created_at) VALUES ($1, $2, $3, $4) RETURNING id",asset.Id, asset.Model, asset.Company,time.Now()).Scan(&asset.Id)
+	if asset.Status == "" {
+		asset.Status = AssetStatusAvailable
+	}
+	if !ValidAssetStatus(asset.Status) {
+		return ErrInvalidAssetStatus
+	}
+	// A new asset has no assignment yet; it becomes assigned through one
+	if asset.Status == AssetStatusAssigned {
+		return ErrAssetStatusConflict
+	}
+	err := am.DB.QueryRowContext(ctx, "INSERT INTO asset (id, model, company, tag, serial, category, location, status, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id",asset.Id, asset.Model, asset.Company, asset.Tag, asset.Serial, asset.Category, asset.Location, asset.Status, time.Now()).Scan(&asset.Id)
 
 	if err != nil {
 		return fmt.Errorf("error creating asset: %w", err)
@@ -33,21 +91,58 @@ func (am *AssetModel) CreateAsset(asset *Asset) error {
 	return nil
 }
 
-func (am *AssetModel) UpdateAsset(asset *Asset) error {
-	stmt := `UPDATE asset SET model = $1, company = $2 WHERE id = $3`
+// UpdateAsset saves the asset's details. An empty status leaves it unchanged; any other status has
+// to agree with whether the asset currently has an assignment.
+func (am *AssetModel) UpdateAsset(ctx context.Context, asset *Asset) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if asset.Status != "" && !ValidAssetStatus(asset.Status) {
+		return ErrInvalidAssetStatus
+	}
 
-	_, err := am.DB.Exec(stmt, asset.Model, asset.Company, asset.Id)
+	tx, err := am.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	if asset.Status != "" {
+		var assigned bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM employee_asset_mapping WHERE asset_id = a.id AND archive_at IS NULL)
+			FROM asset AS a
+			WHERE a.id = $1
+			FOR UPDATE
+		`, asset.Id).Scan(&assigned)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if assigned != (asset.Status == AssetStatusAssigned) {
+			return ErrAssetStatusConflict
+		}
+	}
+
+	stmt := `UPDATE asset SET model = $1, company = $2, tag = $3, serial = $4, category = $5, location = $6, status = COALESCE(NULLIF($7, ''), status) WHERE id = $8`
+
+	_, err = tx.ExecContext(ctx, stmt, asset.Model, asset.Company, asset.Tag, asset.Serial, asset.Category, asset.Location, asset.Status, asset.Id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (am *AssetModel) ArchiveAsset(id uuid.UUID) error {
+func (am *AssetModel) ArchiveAsset(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	stmt := `UPDATE asset SET archive_at = $1 WHERE id = $2`
 
-	_, err := am.DB.Exec(stmt, time.Now(), id)
+	_, err := am.DB.ExecContext(ctx, stmt, time.Now(), id)
 	if err != nil {
 		return err
 	}
@@ -55,42 +150,118 @@ func (am *AssetModel) ArchiveAsset(id uuid.UUID) error {
 	return nil
 }
 
-func (am *AssetModel) GetAssetByID(id uuid.UUID) (*Asset, error) {
-	stmt := `SELECT id, model, company, created_at, archive_at FROM asset WHERE id = $1`
+func (am *AssetModel) GetAssetByID(ctx context.Context, id uuid.UUID) (*Asset, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
-	row := am.DB.QueryRow(stmt, id)
+	stmt := `SELECT ` + assetColumns + ` FROM asset WHERE id = $1`
 
-	var asset Asset
-	err := row.Scan(&asset.Id, &asset.Model, &asset.Company, &asset.CreatedAt, &asset.ArchivedAt)
+	row := am.DB.QueryRowContext(ctx, stmt, id)
+
+	asset, err := scanAsset(row)
 	if err != nil {
 		return nil, err
 	}
 
-	return &asset, nil
+	return asset, nil
 }
 
-func (am *AssetModel) GetAllAssets() ([]*Asset, error) {
-	stmt := `SELECT id, model, company, created_at, archive_at FROM asset`
-	rows, err := am.DB.Query(stmt)
+// AssetFilter narrows asset listings; empty fields match everything
+type AssetFilter struct {
+	Status   string
+	Category string
+	Location string
+	Company  string
+}
+
+// where builds the WHERE clause and arguments for the filter
+func (f AssetFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	add := func(column, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	add("status", f.Status)
+	add("category", f.Category)
+	add("location", f.Location)
+	add("company", f.Company)
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func (am *AssetModel) GetAllAssets(ctx context.Context) ([]*Asset, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return am.GetAssets(ctx, AssetFilter{})
+}
+
+// GetAssets retrieves the assets matching filter
+func (am *AssetModel) GetAssets(ctx context.Context, filter AssetFilter) ([]*Asset, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var assets []*Asset
+	err := am.ForEachAsset(ctx, filter, func(asset *Asset) error {
+		assets = append(assets, asset)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var assets []*Asset
+	return assets, nil
+}
+
+// ForEachAsset calls fn for each asset matching filter as it is read from the database cursor.
+// Iteration stops at the first error from fn, which is returned.
+func (am *AssetModel) ForEachAsset(ctx context.Context, filter AssetFilter, fn func(*Asset) error) error {
+	where, args := filter.where()
+	stmt := `SELECT ` + assetColumns + ` FROM asset` + where + ` ORDER BY created_at`
+	return am.forEach(ctx, fn, stmt, args...)
+}
+
+// ForEachAssignedAsset calls fn for each asset currently assigned to one employee, in the order
+// they were handed over
+func (am *AssetModel) ForEachAssignedAsset(ctx context.Context, employeeID uuid.UUID, fn func(*Asset) error) error {
+	stmt := `
+		SELECT ` + assetColumns + `
+		FROM (
+			SELECT a.*, m.created_at AS assigned_at
+			FROM employee_asset_mapping m
+			JOIN asset a ON a.id = m.asset_id
+			WHERE m.employee_id = $1 AND m.archive_at IS NULL
+		) assigned
+		ORDER BY assigned_at
+	`
+	return am.forEach(ctx, fn, stmt, employeeID)
+}
+
+// forEach runs a query selecting assetColumns and calls fn for each row as it is read
+func (am *AssetModel) forEach(ctx context.Context, fn func(*Asset) error, stmt string, args ...interface{}) error {
+	rows, err := am.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
 
 	for rows.Next() {
-		var asset Asset
-		err := rows.Scan(&asset.Id, &asset.Model, &asset.Company, &asset.CreatedAt, &asset.ArchivedAt)
+		asset, err := scanAsset(rows)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(asset); err != nil {
+			return err
 		}
-		assets = append(assets, &asset)
-	}
-
-	if err = rows.Err(); err != nil {
-		return nil, err
 	}
 
-	return assets, nil
+	return rows.Err()
 }
@@ -0,0 +1,218 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAssetLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		asset := mustAsset(t, repos, "laptop", "London")
+
+		got, err := repos.Assets.GetAssetByID(ctx, asset.Id)
+		if err != nil {
+			t.Fatalf("GetAssetByID: %v", err)
+		}
+		if got.Serial != asset.Serial || got.Status != models.AssetStatusAvailable {
+			t.Errorf("GetAssetByID = %+v, want serial %q and status %q", got, asset.Serial, models.AssetStatusAvailable)
+		}
+
+		got.Location = "Paris"
+		got.Status = ""
+		if err := repos.Assets.UpdateAsset(ctx, got); err != nil {
+			t.Fatalf("UpdateAsset: %v", err)
+		}
+		got, err = repos.Assets.GetAssetByID(ctx, asset.Id)
+		if err != nil {
+			t.Fatalf("GetAssetByID: %v", err)
+		}
+		if got.Location != "Paris" || got.Status != models.AssetStatusAvailable {
+			t.Errorf("after update got location %q status %q, want Paris and an unchanged status", got.Location, got.Status)
+		}
+
+		if err := repos.Assets.ArchiveAsset(ctx, asset.Id); err != nil {
+			t.Fatalf("ArchiveAsset: %v", err)
+		}
+		got, err = repos.Assets.GetAssetByID(ctx, asset.Id)
+		if err != nil {
+			t.Fatalf("GetAssetByID: %v", err)
+		}
+		if got.ArchivedAt == nil {
+			t.Error("ArchiveAsset did not set archivedAt")
+		}
+
+		if _, err := repos.Assets.GetAssetByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetAssetByID(missing) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestCreateAssetDuplicateSerial(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		asset := mustAsset(t, repos, "laptop", "London")
+
+		duplicate := &models.Asset{Model: "X1", Company: "Lenovo", Serial: asset.Serial}
+		if err := repos.Assets.CreateAsset(context.Background(), duplicate); err == nil {
+			t.Error("CreateAsset with a duplicate serial succeeded")
+		}
+	})
+}
+
+func TestGetAssets(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		laptop := mustAsset(t, repos, "laptop", "London")
+		monitor := mustAsset(t, repos, "monitor", "London")
+		remote := mustAsset(t, repos, "laptop", "Paris")
+
+		tests := []struct {
+			name   string
+			filter models.AssetFilter
+			want   []uuid.UUID
+		}{
+			{"all", models.AssetFilter{}, []uuid.UUID{laptop.Id, monitor.Id, remote.Id}},
+			{"category", models.AssetFilter{Category: "laptop"}, []uuid.UUID{laptop.Id, remote.Id}},
+			{"location and category", models.AssetFilter{Category: "laptop", Location: "Paris"}, []uuid.UUID{remote.Id}},
+			{"status", models.AssetFilter{Status: models.AssetStatusLost}, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assets, err := repos.Assets.GetAssets(ctx, tt.filter)
+				if err != nil {
+					t.Fatalf("GetAssets: %v", err)
+				}
+				if got := assetIDs(assets); !equalIDs(got, tt.want) {
+					t.Errorf("GetAssets(%+v) = %v, want %v", tt.filter, got, tt.want)
+				}
+			})
+		}
+
+		all, err := repos.Assets.GetAllAssets(ctx)
+		if err != nil {
+			t.Fatalf("GetAllAssets: %v", err)
+		}
+		if len(all) != 3 {
+			t.Errorf("GetAllAssets returned %d assets, want 3", len(all))
+		}
+
+		stop := errors.New("stop")
+		var seen int
+		err = repos.Assets.ForEachAsset(ctx, models.AssetFilter{}, func(*models.Asset) error {
+			seen++
+			return stop
+		})
+		if !errors.Is(err, stop) || seen != 1 {
+			t.Errorf("ForEachAsset = %v after %d assets, want the callback error after 1", err, seen)
+		}
+	})
+}
+
+func assetIDs(assets []*models.Asset) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, asset := range assets {
+		ids = append(ids, asset.Id)
+	}
+	return ids
+}
+
+// equalIDs compares ids in order
+func equalIDs(got, want []uuid.UUID) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAssetStatusFollowsAssignments(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		assigned := mustAsset(t, repos, "laptop", "London")
+		stock := mustAsset(t, repos, "laptop", "London")
+		mustAssign(t, repos, employee, assigned)
+
+		if err := repos.Assets.CreateAsset(ctx, &models.Asset{Serial: uuid.NewString(), Status: "stolen"}); !errors.Is(err, models.ErrInvalidAssetStatus) {
+			t.Errorf("CreateAsset with an unknown status error = %v, want ErrInvalidAssetStatus", err)
+		}
+		if err := repos.Assets.CreateAsset(ctx, &models.Asset{Serial: uuid.NewString(), Status: models.AssetStatusAssigned}); !errors.Is(err, models.ErrAssetStatusConflict) {
+			t.Errorf("CreateAsset already assigned error = %v, want ErrAssetStatusConflict", err)
+		}
+
+		tests := []struct {
+			name   string
+			asset  *models.Asset
+			status string
+			want   error
+		}{
+			{"unknown status", stock, "stolen", models.ErrInvalidAssetStatus},
+			{"assigned without an assignment", stock, models.AssetStatusAssigned, models.ErrAssetStatusConflict},
+			{"back in stock while assigned", assigned, models.AssetStatusAvailable, models.ErrAssetStatusConflict},
+			{"written off while assigned", assigned, models.AssetStatusWrittenOff, models.ErrAssetStatusConflict},
+			{"assigned and assigned", assigned, models.AssetStatusAssigned, nil},
+			{"written off from stock", stock, models.AssetStatusWrittenOff, nil},
+		}
+		for _, tt := range tests {
+			update := *tt.asset
+			update.Status = tt.status
+			if err := repos.Assets.UpdateAsset(ctx, &update); !errors.Is(err, tt.want) {
+				t.Errorf("%s: UpdateAsset error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		got, err := repos.Assets.GetAssetByID(ctx, stock.Id)
+		if err != nil {
+			t.Fatalf("GetAssetByID: %v", err)
+		}
+		if got.Status != models.AssetStatusWrittenOff {
+			t.Errorf("stock asset status = %q, want %q", got.Status, models.AssetStatusWrittenOff)
+		}
+	})
+}
+
+func TestForEachAssignedAsset(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		other := mustEmployee(t, repos, "engineer", nil)
+		phone := mustAsset(t, repos, "phone", "London")
+		laptop := mustAsset(t, repos, "laptop", "London")
+		returned := mustAssign(t, repos, employee, mustAsset(t, repos, "laptop", "London"))
+		mustAssign(t, repos, employee, laptop)
+		mustAssign(t, repos, employee, phone)
+		mustAssign(t, repos, other, mustAsset(t, repos, "laptop", "London"))
+		if err := repos.EmployeeAssets.ArchiveEmployeeAsset(ctx, returned.ID); err != nil {
+			t.Fatalf("ArchiveEmployeeAsset: %v", err)
+		}
+
+		var got []*models.Asset
+		err := repos.Assets.ForEachAssignedAsset(ctx, employee.ID, func(asset *models.Asset) error {
+			got = append(got, asset)
+			return nil
+		})
+		if err != nil || len(got) != 2 || got[0].Id != laptop.Id || got[1].Id != phone.Id {
+			t.Fatalf("ForEachAssignedAsset = %v, %v; want the laptop then the phone, in the order they were handed over", assetIDs(got), err)
+		}
+		if got[0].Serial != laptop.Serial || got[0].Status != models.AssetStatusAssigned {
+			t.Errorf("assigned laptop = %+v, want its details with status assigned", got[0])
+		}
+
+		seen := 0
+		err = repos.Assets.ForEachAssignedAsset(ctx, uuid.New(), func(*models.Asset) error { seen++; return nil })
+		if err != nil || seen != 0 {
+			t.Errorf("ForEachAssignedAsset(unknown employee) = %v after %d assets, want none", err, seen)
+		}
+	})
+}
@@ -0,0 +1,368 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Audit statuses
+const (
+	AuditOpen   = "open"
+	AuditClosed = "closed"
+)
+
+// Scan classifications. Missing assets are never scanned, so they only appear in reports.
+const (
+	ScanFound         = "found"
+	ScanUnexpected    = "unexpected"
+	ScanWrongLocation = "wrong_location"
+)
+
+var (
+	// ErrAuditNotFound is returned when an audit does not exist
+	ErrAuditNotFound = errors.New("audit not found")
+	// ErrAuditClosed is returned when scanning into or closing an audit that is already closed
+	ErrAuditClosed = errors.New("audit is closed")
+)
+
+// Audit is a stocktake campaign, optionally scoped to a location and/or asset category
+type Audit struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Location  string     `json:"location,omitempty"`
+	Category  string     `json:"category,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+}
+
+// AuditScan is one asset tag or serial number read during an audit
+type AuditScan struct {
+	ID        uuid.UUID  `json:"id"`
+	AuditID   uuid.UUID  `json:"audit_id"`
+	Code      string     `json:"code"`
+	Location  string     `json:"location,omitempty"`
+	AssetID   *uuid.UUID `json:"asset_id,omitempty"`
+	Result    string     `json:"result"`
+	ScannedAt time.Time  `json:"scanned_at"`
+}
+
+// AuditReport reconciles an audit's scans against the assets expected in its scope
+type AuditReport struct {
+	Audit         *Audit       `json:"audit"`
+	Found         []*AuditScan `json:"found"`
+	WrongLocation []*AuditScan `json:"wrong_location"`
+	Unexpected    []*AuditScan `json:"unexpected"`
+	Missing       []*Asset     `json:"missing"`
+	MarkedLost    bool         `json:"marked_lost,omitempty"`
+}
+
+// AuditModel represents the model for inventory audit operations
+type AuditModel struct {
+	DB *sql.DB
+}
+
+const auditColumns = `id, name, location, category, status, created_at, closed_at`
+
+func scanAudit(row rowScanner) (*Audit, error) {
+	audit := &Audit{}
+	err := row.Scan(&audit.ID, &audit.Name, &audit.Location, &audit.Category, &audit.Status, &audit.CreatedAt, &audit.ClosedAt)
+	if err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// CreateAudit opens a new audit campaign
+func (am *AuditModel) CreateAudit(ctx context.Context, audit *Audit) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	audit.ID = uuid.New()
+	audit.Status = AuditOpen
+	audit.CreatedAt = time.Now()
+
+	_, err := am.DB.ExecContext(ctx, `INSERT INTO inventory_audit (id, name, location, category, status, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		audit.ID, audit.Name, audit.Location, audit.Category, audit.Status, audit.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating audit: %w", err)
+	}
+
+	return nil
+}
+
+// GetAuditByID retrieves an audit by its ID
+func (am *AuditModel) GetAuditByID(ctx context.Context, id uuid.UUID) (*Audit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	audit, err := scanAudit(am.DB.QueryRowContext(ctx, `SELECT `+auditColumns+` FROM inventory_audit WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAuditNotFound
+	}
+	return audit, err
+}
+
+// GetAllAudits retrieves all audits, newest first
+func (am *AuditModel) GetAllAudits(ctx context.Context) ([]*Audit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var audits []*Audit
+	err := am.ForEachAudit(ctx, func(audit *Audit) error {
+		audits = append(audits, audit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return audits, nil
+}
+
+// ForEachAudit calls fn for each audit, newest first, as it is read from the database cursor
+func (am *AuditModel) ForEachAudit(ctx context.Context, fn func(*Audit) error) error {
+	rows, err := am.DB.QueryContext(ctx, `SELECT `+auditColumns+` FROM inventory_audit ORDER BY created_at DESC`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		audit, err := scanAudit(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(audit); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ClassifyScan decides how a scanned asset relates to the audit. The scan's own location wins
+// over the audit's, so a roaming audit can record where each item was actually seen.
+func ClassifyScan(audit *Audit, asset *Asset, location string) string {
+	if location == "" {
+		location = audit.Location
+	}
+
+	switch {
+	case asset == nil:
+		return ScanUnexpected
+	case audit.Category != "" && asset.Category != audit.Category:
+		return ScanUnexpected
+	case location != "" && asset.Location != location:
+		return ScanWrongLocation
+	default:
+		return ScanFound
+	}
+}
+
+// RecordScan looks up the scanned tag or serial number and stores the classified result. The audit
+// is locked for share, so a scan cannot land in an audit that is closing; a code that is one
+// asset's tag and another's serial number is read as the tag.
+func (am *AuditModel) RecordScan(ctx context.Context, scan *AuditScan) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := am.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	audit, err := scanAudit(tx.QueryRowContext(ctx, `SELECT `+auditColumns+` FROM inventory_audit WHERE id = $1 FOR SHARE`, scan.AuditID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAuditNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if audit.Status != AuditOpen {
+		return ErrAuditClosed
+	}
+
+	asset, err := scanAsset(tx.QueryRowContext(ctx, `
+		SELECT `+assetColumns+`
+		FROM asset
+		WHERE (tag = $1 OR serial = $1) AND $1 <> '' AND archive_at IS NULL
+		ORDER BY tag = $1 DESC
+		LIMIT 1
+	`, scan.Code))
+	if errors.Is(err, sql.ErrNoRows) {
+		asset, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	scan.ID = uuid.New()
+	scan.ScannedAt = time.Now()
+	scan.Result = ClassifyScan(audit, asset, scan.Location)
+	if asset != nil {
+		scan.AssetID = &asset.Id
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_scan (id, audit_id, code, location, asset_id, result, scanned_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, scan.ID, scan.AuditID, scan.Code, scan.Location, scan.AssetID, scan.Result, scan.ScannedAt)
+	if err != nil {
+		return fmt.Errorf("error recording scan: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetAuditReport reconciles the scans recorded so far against the audit's scope
+func (am *AuditModel) GetAuditReport(ctx context.Context, id uuid.UUID) (*AuditReport, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	audit, err := am.GetAuditByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return buildAuditReport(ctx, am.DB, audit)
+}
+
+// queryer is the subset of *sql.DB and *sql.Tx the report needs
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func buildAuditReport(ctx context.Context, db queryer, audit *Audit) (*AuditReport, error) {
+	report := &AuditReport{Audit: audit}
+
+	// An asset scanned more than once, or an unknown code read again, is reported by its latest scan
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, audit_id, code, location, asset_id, result, scanned_at
+		FROM (
+			SELECT DISTINCT ON (COALESCE(asset_id::text, code)) *
+			FROM audit_scan
+			WHERE audit_id = $1
+			ORDER BY COALESCE(asset_id::text, code), scanned_at DESC
+		) latest
+		ORDER BY scanned_at
+	`, audit.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		scan := &AuditScan{}
+		err := rows.Scan(&scan.ID, &scan.AuditID, &scan.Code, &scan.Location, &scan.AssetID, &scan.Result, &scan.ScannedAt)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		switch scan.Result {
+		case ScanFound:
+			report.Found = append(report.Found, scan)
+		case ScanWrongLocation:
+			report.WrongLocation = append(report.WrongLocation, scan)
+		default:
+			report.Unexpected = append(report.Unexpected, scan)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Anything in scope that was never seen, wherever it was scanned, is missing
+	missing, err := db.QueryContext(ctx, `
+		SELECT `+assetColumns+`
+		FROM asset a
+		WHERE a.archive_at IS NULL
+		  AND a.status NOT IN ($2, $3)
+		  AND ($4 = '' OR a.location = $4)
+		  AND ($5 = '' OR a.category = $5)
+		  AND NOT EXISTS (SELECT 1 FROM audit_scan s WHERE s.audit_id = $1 AND s.asset_id = a.id)
+		ORDER BY a.tag, a.serial
+	`, audit.ID, AssetStatusLost, AssetStatusWrittenOff, audit.Location, audit.Category)
+	if err != nil {
+		return nil, err
+	}
+	defer missing.Close()
+
+	for missing.Next() {
+		asset, err := scanAsset(missing)
+		if err != nil {
+			return nil, err
+		}
+		report.Missing = append(report.Missing, asset)
+	}
+	if err := missing.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// CloseAudit closes the audit and returns its final report. When markMissingLost is set every
+// missing asset is flagged as lost in the same transaction, and whoever held one no longer does.
+func (am *AuditModel) CloseAudit(ctx context.Context, id uuid.UUID, markMissingLost bool) (*AuditReport, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := am.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	audit, err := scanAudit(tx.QueryRowContext(ctx, `SELECT `+auditColumns+` FROM inventory_audit WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAuditNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if audit.Status != AuditOpen {
+		return nil, ErrAuditClosed
+	}
+
+	now := time.Now()
+	audit.Status = AuditClosed
+	audit.ClosedAt = &now
+	if _, err := tx.ExecContext(ctx, `UPDATE inventory_audit SET status = $1, closed_at = $2 WHERE id = $3`, audit.Status, now, audit.ID); err != nil {
+		return nil, err
+	}
+
+	report, err := buildAuditReport(ctx, tx, audit)
+	if err != nil {
+		return nil, err
+	}
+
+	if markMissingLost && len(report.Missing) > 0 {
+		ids := make([]string, len(report.Missing))
+		for i, asset := range report.Missing {
+			ids[i] = asset.Id.String()
+			asset.Status = AssetStatusLost
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE asset SET status = $1 WHERE id = ANY($2::uuid[])`, AssetStatusLost, pq.Array(ids))
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE employee_asset_mapping SET archive_at = $1 WHERE asset_id = ANY($2::uuid[]) AND archive_at IS NULL`, now, pq.Array(ids))
+		if err != nil {
+			return nil, err
+		}
+		report.MarkedLost = true
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestAudit(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		found := mustAsset(t, repos, "laptop", "London")
+		moved := mustAsset(t, repos, "laptop", "London")
+		missing := mustAsset(t, repos, "laptop", "London")
+		monitor := mustAsset(t, repos, "monitor", "London")
+
+		audit := &models.Audit{Name: "Q1 London laptops", Location: "London", Category: "laptop"}
+		if err := repos.Audits.CreateAudit(ctx, audit); err != nil {
+			t.Fatalf("CreateAudit: %v", err)
+		}
+
+		scans := []struct {
+			name     string
+			code     string
+			location string
+			want     string
+		}{
+			{"by tag", found.Tag, "London", models.ScanFound},
+			{"by serial", moved.Serial, "Berlin", models.ScanWrongLocation},
+			{"out of scope", monitor.Tag, "London", models.ScanUnexpected},
+			{"unknown code", "NOPE", "London", models.ScanUnexpected},
+		}
+		for _, tt := range scans {
+			t.Run(tt.name, func(t *testing.T) {
+				scan := &models.AuditScan{AuditID: audit.ID, Code: tt.code, Location: tt.location}
+				if err := repos.Audits.RecordScan(ctx, scan); err != nil {
+					t.Fatalf("RecordScan: %v", err)
+				}
+				if scan.Result != tt.want {
+					t.Errorf("RecordScan result = %q, want %q", scan.Result, tt.want)
+				}
+			})
+		}
+
+		report, err := repos.Audits.GetAuditReport(ctx, audit.ID)
+		if err != nil {
+			t.Fatalf("GetAuditReport: %v", err)
+		}
+		if len(report.Found) != 1 || len(report.WrongLocation) != 1 || len(report.Unexpected) != 2 {
+			t.Errorf("GetAuditReport = %d found, %d wrong location, %d unexpected; want 1, 1, 2",
+				len(report.Found), len(report.WrongLocation), len(report.Unexpected))
+		}
+		if len(report.Missing) != 1 || report.Missing[0].Id != missing.Id {
+			t.Errorf("GetAuditReport missing = %v, want %s", assetIDs(report.Missing), missing.Id)
+		}
+
+		report, err = repos.Audits.CloseAudit(ctx, audit.ID, true)
+		if err != nil {
+			t.Fatalf("CloseAudit: %v", err)
+		}
+		if !report.MarkedLost || report.Audit.Status != models.AuditClosed {
+			t.Errorf("CloseAudit = %+v, want a closed audit with missing assets marked lost", report.Audit)
+		}
+		asset, err := repos.Assets.GetAssetByID(ctx, missing.Id)
+		if err != nil || asset.Status != models.AssetStatusLost {
+			t.Errorf("missing asset = %v, %v; want status lost", asset, err)
+		}
+
+		if _, err := repos.Audits.CloseAudit(ctx, audit.ID, false); !errors.Is(err, models.ErrAuditClosed) {
+			t.Errorf("CloseAudit(closed) error = %v, want ErrAuditClosed", err)
+		}
+		if err := repos.Audits.RecordScan(ctx, &models.AuditScan{AuditID: audit.ID, Code: found.Tag}); !errors.Is(err, models.ErrAuditClosed) {
+			t.Errorf("RecordScan(closed) error = %v, want ErrAuditClosed", err)
+		}
+
+		got, err := repos.Audits.GetAuditByID(ctx, audit.ID)
+		if err != nil || got.ClosedAt == nil {
+			t.Errorf("GetAuditByID = %v, %v; want a closed audit", got, err)
+		}
+		audits, err := repos.Audits.GetAllAudits(ctx)
+		if err != nil || len(audits) != 1 {
+			t.Errorf("GetAllAudits = %d audits, %v; want 1", len(audits), err)
+		}
+		var seen int
+		if err := repos.Audits.ForEachAudit(ctx, func(*models.Audit) error { seen++; return nil }); err != nil || seen != 1 {
+			t.Errorf("ForEachAudit = %v after %d audits, want nil after 1", err, seen)
+		}
+		if _, err := repos.Audits.GetAuditByID(ctx, uuid.New()); !errors.Is(err, models.ErrAuditNotFound) {
+			t.Errorf("GetAuditByID(missing) error = %v, want ErrAuditNotFound", err)
+		}
+	})
+}
+
+func TestAuditRescansAndLostAssignments(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+
+		// An older asset whose serial number happens to be the next asset's tag
+		confusable := &models.Asset{Model: "T14", Company: "Lenovo", Tag: "OLD-1", Serial: "TAG-CLASH", Category: "laptop", Location: "London"}
+		if err := repos.Assets.CreateAsset(ctx, confusable); err != nil {
+			t.Fatalf("CreateAsset: %v", err)
+		}
+		tagged := &models.Asset{Model: "T14", Company: "Lenovo", Tag: "TAG-CLASH", Serial: uuid.NewString(), Category: "laptop", Location: "London"}
+		if err := repos.Assets.CreateAsset(ctx, tagged); err != nil {
+			t.Fatalf("CreateAsset: %v", err)
+		}
+		employee := mustEmployee(t, repos, "engineer", nil)
+		held := mustAsset(t, repos, "laptop", "London")
+		mustAssign(t, repos, employee, held)
+
+		audit := &models.Audit{Name: "Q2 London", Location: "London"}
+		if err := repos.Audits.CreateAudit(ctx, audit); err != nil {
+			t.Fatalf("CreateAudit: %v", err)
+		}
+		for _, location := range []string{"London", "Berlin"} {
+			scan := &models.AuditScan{AuditID: audit.ID, Code: "TAG-CLASH", Location: location}
+			if err := repos.Audits.RecordScan(ctx, scan); err != nil {
+				t.Fatalf("RecordScan: %v", err)
+			}
+			if scan.AssetID == nil || *scan.AssetID != tagged.Id {
+				t.Fatalf("RecordScan matched %v, want the asset tagged TAG-CLASH %s", scan.AssetID, tagged.Id)
+			}
+		}
+		for i := 0; i < 2; i++ {
+			if err := repos.Audits.RecordScan(ctx, &models.AuditScan{AuditID: audit.ID, Code: "NOPE"}); err != nil {
+				t.Fatalf("RecordScan: %v", err)
+			}
+		}
+
+		report, err := repos.Audits.CloseAudit(ctx, audit.ID, true)
+		if err != nil {
+			t.Fatalf("CloseAudit: %v", err)
+		}
+		if len(report.Found) != 0 || len(report.WrongLocation) != 1 || len(report.Unexpected) != 1 {
+			t.Errorf("CloseAudit = %d found, %d wrong location, %d unexpected; want each scanned code once by its latest scan: 0, 1, 1",
+				len(report.Found), len(report.WrongLocation), len(report.Unexpected))
+		}
+		if got := assetIDs(report.Missing); len(got) != 2 {
+			t.Errorf("CloseAudit missing = %v, want %s and %s", got, confusable.Id, held.Id)
+		}
+
+		asset, err := repos.Assets.GetAssetByID(ctx, held.Id)
+		if err != nil || asset.Status != models.AssetStatusLost {
+			t.Errorf("held asset = %v, %v; want status lost", asset, err)
+		}
+		if count, err := repos.Employees.CountActiveAssets(ctx, employee.ID); err != nil || count != 0 {
+			t.Errorf("employee still holds %d assets, %v; want the lost one handed back", count, err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/logging"
+	"github.com/lib/pq"
+)
+
+// QueryTimeout bounds each model call, including every statement of its transaction. It is set
+// once at startup; zero leaves calls bounded only by the caller's context. The ForEach methods
+// are not covered, since how long they run depends on how fast the caller consumes rows.
+var QueryTimeout = 10 * time.Second
+
+// withQueryTimeout derives the context a model call runs its statements under. A call that runs
+// out of time is logged with the request's logger when it is cancelled, naming the model method.
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if QueryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	return queryCtx, func() {
+		// Only the query timeout is worth reporting; the request's own deadline is logged with it
+		if errors.Is(queryCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			call := "unknown"
+			if pc, _, _, ok := runtime.Caller(1); ok {
+				call = runtime.FuncForPC(pc).Name()
+			}
+			logging.FromContext(ctx).Warn("model call exceeded query timeout", "call", call, "timeout", QueryTimeout.String())
+		}
+		cancel()
+	}
+}
+
+// IsQueryCanceled reports whether err means Postgres abandoned a statement, either because its
+// context was cancelled mid-flight or because it ran into statement_timeout
+func IsQueryCanceled(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
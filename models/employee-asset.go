@@ -1,12 +1,22 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrEmployeeOffboarding is returned when assigning an asset to an employee whose offboarding
+	// is in progress, since its checklist would not include the asset
+	ErrEmployeeOffboarding = errors.New("employee is being offboarded")
+	// ErrAssetUnavailable is returned when assigning an asset that is archived, missing or not in stock
+	ErrAssetUnavailable = errors.New("asset is not available to assign")
+)
+
 type EmployeeAsset struct {
 	ID         uuid.UUID `json:"id"`
 	AssetID    uuid.UUID `json:"asset_id"`
@@ -19,30 +29,92 @@ type EmployeeAssetModel struct {
 	DB *sql.DB
 }
 
-func (eam *EmployeeAssetModel) CreateEmployeeAsset(employeeAsset *EmployeeAsset) error {
+func (eam *EmployeeAssetModel) CreateEmployeeAsset(ctx context.Context, employeeAsset *EmployeeAsset) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := eam.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := assignAsset(ctx, tx, employeeAsset); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkAssignee locks the employee against a concurrent offboarding and returns ErrEmployeeNotFound
+// for a missing or archived employee, or ErrEmployeeOffboarding while their offboarding is open
+func checkAssignee(ctx context.Context, tx *sql.Tx, employeeID uuid.UUID) error {
+	var archived bool
+	err := tx.QueryRowContext(ctx, `SELECT archive_at IS NOT NULL FROM employee WHERE id = $1 FOR SHARE`, employeeID).Scan(&archived)
+	if errors.Is(err, sql.ErrNoRows) || archived {
+		return ErrEmployeeNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var offboarding bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM employee_offboarding WHERE employee_id = $1 AND completed_at IS NULL)
+	`, employeeID).Scan(&offboarding)
+	if err != nil {
+		return err
+	}
+	if offboarding {
+		return ErrEmployeeOffboarding
+	}
+
+	return nil
+}
+
+// assignAsset inserts the mapping and marks the asset as assigned within tx. The asset must be in
+// stock, so it is never with two people at once.
+func assignAsset(ctx context.Context, tx *sql.Tx, employeeAsset *EmployeeAsset) error {
+	if err := checkAssignee(ctx, tx, employeeAsset.EmployeeID); err != nil {
+		return err
+	}
+
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT status FROM asset WHERE id = $1 AND archive_at IS NULL FOR UPDATE`, employeeAsset.AssetID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) || status != AssetStatusAvailable {
+		return ErrAssetUnavailable
+	}
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO employee_asset_mapping (id, asset_id, employee_id, created_at)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id
 	`
-    employeeAsset.ID = uuid.New()
+	employeeAsset.ID = uuid.New()
 
-	err := eam.DB.QueryRow(query, employeeAsset.ID, employeeAsset.AssetID, employeeAsset.EmployeeID, employeeAsset.CreatedAt).Scan(&employeeAsset.ID)
+	err = tx.QueryRowContext(ctx, query, employeeAsset.ID, employeeAsset.AssetID, employeeAsset.EmployeeID, employeeAsset.CreatedAt).Scan(&employeeAsset.ID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	_, err = tx.ExecContext(ctx, `UPDATE asset SET status = $1 WHERE id = $2`, AssetStatusAssigned, employeeAsset.AssetID)
+	return err
 }
 
-func (eam *EmployeeAssetModel) UpdateEmployeeAsset(employeeAsset *EmployeeAsset) error {
+func (eam *EmployeeAssetModel) UpdateEmployeeAsset(ctx context.Context, employeeAsset *EmployeeAsset) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE employee_asset_mapping
 		SET asset_id = $2, employee_id = $3, created_at = $4
 		WHERE id = $1
 	`
 
-	_, err := eam.DB.Exec(query, employeeAsset.ID, employeeAsset.AssetID, employeeAsset.EmployeeID, employeeAsset.CreatedAt)
+	_, err := eam.DB.ExecContext(ctx, query, employeeAsset.ID, employeeAsset.AssetID, employeeAsset.EmployeeID, employeeAsset.CreatedAt)
 	if err != nil {
 		return err
 	}
@@ -50,22 +122,42 @@ func (eam *EmployeeAssetModel) UpdateEmployeeAsset(employeeAsset *EmployeeAsset)
 	return nil
 }
 
-func (eam *EmployeeAssetModel) ArchiveEmployeeAsset(id uuid.UUID) error {
+func (eam *EmployeeAssetModel) ArchiveEmployeeAsset(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE employee_asset_mapping
 		SET archive_at = $1
 		WHERE id = $2
+		RETURNING asset_id
 	`
 
-	_, err := eam.DB.Exec(query, time.Now(), id)
+	tx, err := eam.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	var assetID uuid.UUID
+	err = tx.QueryRowContext(ctx, query, time.Now(), id).Scan(&assetID)
+	if err != nil {
+		return err
+	}
+
+	// Only hand the asset back to stock if nothing else has changed its status
+	_, err = tx.ExecContext(ctx, `UPDATE asset SET status = $1 WHERE id = $2 AND status = $3`, AssetStatusAvailable, assetID, AssetStatusAssigned)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (eam *EmployeeAssetModel) GetEmployeeAssetByID(id uuid.UUID) (*EmployeeAsset, error) {
+func (eam *EmployeeAssetModel) GetEmployeeAssetByID(ctx context.Context, id uuid.UUID) (*EmployeeAsset, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		SELECT id, asset_id, employee_id, created_at, archive_at
 		FROM employee_asset_mapping
@@ -73,7 +165,7 @@ func (eam *EmployeeAssetModel) GetEmployeeAssetByID(id uuid.UUID) (*EmployeeAsse
 	`
 
 	employeeAsset := &EmployeeAsset{}
-	err := eam.DB.QueryRow(query, id).Scan(&employeeAsset.ID, &employeeAsset.AssetID, &employeeAsset.EmployeeID, &employeeAsset.CreatedAt, &employeeAsset.ArchivedAt)
+	err := eam.DB.QueryRowContext(ctx, query, id).Scan(&employeeAsset.ID, &employeeAsset.AssetID, &employeeAsset.EmployeeID, &employeeAsset.CreatedAt, &employeeAsset.ArchivedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -81,32 +173,51 @@ func (eam *EmployeeAssetModel) GetEmployeeAssetByID(id uuid.UUID) (*EmployeeAsse
 	return employeeAsset, nil
 }
 
-func (eam *EmployeeAssetModel) GetAllEmployeeAssets() ([]*EmployeeAsset, error) {
+func (eam *EmployeeAssetModel) GetAllEmployeeAssets(ctx context.Context) ([]*EmployeeAsset, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var employeeAssets []*EmployeeAsset
+	err := eam.ForEachEmployeeAsset(ctx, func(employeeAsset *EmployeeAsset) error {
+		employeeAssets = append(employeeAssets, employeeAsset)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return employeeAssets, nil
+}
+
+// ForEachEmployeeAsset calls fn for each assignment as it is read from the database cursor
+func (eam *EmployeeAssetModel) ForEachEmployeeAsset(ctx context.Context, fn func(*EmployeeAsset) error) error {
 	query := `
 		SELECT id, asset_id, employee_id, created_at, archive_at
 		FROM employee_asset_mapping
+		ORDER BY created_at
 	`
 
-	rows, err := eam.DB.Query(query)
+	return eam.forEach(ctx, fn, query)
+}
+
+// forEach runs a query selecting assignments and calls fn for each row as it is read
+func (eam *EmployeeAssetModel) forEach(ctx context.Context, fn func(*EmployeeAsset) error, query string, args ...interface{}) error {
+	rows, err := eam.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	var employeeAssets []*EmployeeAsset
-
 	for rows.Next() {
 		var employeeAsset EmployeeAsset
 		err := rows.Scan(&employeeAsset.ID, &employeeAsset.AssetID, &employeeAsset.EmployeeID, &employeeAsset.CreatedAt, &employeeAsset.ArchivedAt)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(&employeeAsset); err != nil {
+			return err
 		}
-		employeeAssets = append(employeeAssets, &employeeAsset)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
 	}
 
-	return employeeAssets, nil
+	return rows.Err()
 }
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,25 +15,33 @@ type Employee struct {
 	Name       string    `json:"name"`
 	Email      string    `json:"email"`
 	Role       string    `json:"role"`
+	Department string    `json:"department"`
+	ManagerID  *uuid.UUID `json:"manager_id,omitempty"`
 	CreatedAt  time.Time `json:"created_at"`
 	ArchivedAt *time.Time `json:"archive_at,omitempty"`
 }
 
+// ErrEmployeeHasAssets is returned when archiving an employee who still holds assets
+var ErrEmployeeHasAssets = errors.New("employee still has assets assigned")
+
 // EmployeeModel represents the model for employee operations
 type EmployeeModel struct {
 	DB *sql.DB
 }
 
 // CreateEmployee creates a new employee in the database
-func (em *EmployeeModel) CreateEmployee(employee *Employee) error {
+func (em *EmployeeModel) CreateEmployee(ctx context.Context, employee *Employee) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
-		INSERT INTO employee (id, name, email, role, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO employee (id, name, email, role, department, manager_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`
 
 	employee.ID = uuid.New()
-	err := em.DB.QueryRow(query, employee.ID,employee.Name, employee.Email, employee.Role, employee.CreatedAt).Scan(&employee.ID)
+	err := em.DB.QueryRowContext(ctx, query, employee.ID,employee.Name, employee.Email, employee.Role, employee.Department, employee.ManagerID, employee.CreatedAt).Scan(&employee.ID)
 	if err != nil {
 		return err
 	}
@@ -40,40 +50,87 @@ func (em *EmployeeModel) CreateEmployee(employee *Employee) error {
 	return nil
 }
 
-// UpdateEmployee updates an existing employee in the database
-func (em *EmployeeModel) UpdateEmployee(employee *Employee) error {
+// UpdateEmployee updates an existing employee in the database. ArchivedAt is left alone; employees
+// are archived through ArchiveEmployee or offboarding, which check they hold no assets.
+func (em *EmployeeModel) UpdateEmployee(ctx context.Context, employee *Employee) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		UPDATE employee
-		SET name = $1, email = $2, role = $3, archive_at = $4
-		WHERE id = $5
+		SET name = $1, email = $2, role = $3, department = $4, manager_id = $5
+		WHERE id = $6
 	`
 
-	_, err := em.DB.Exec(query, employee.Name, employee.Email, employee.Role, employee.ArchivedAt, employee.ID)
+	_, err := em.DB.ExecContext(ctx, query, employee.Name, employee.Email, employee.Role, employee.Department, employee.ManagerID, employee.ID)
 	return err
 }
 
-// ArchiveEmployee archives an existing employee in the database
-func (em *EmployeeModel) ArchiveEmployee(id uuid.UUID) error {
+// ArchiveEmployee archives an existing employee in the database.
+// Employees with active asset assignments must go through offboarding first.
+func (em *EmployeeModel) ArchiveEmployee(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	// The check and the update are one statement so an assignment cannot slip in between them
 	query := `
 		UPDATE employee
 		SET archive_at = $1
 		WHERE id = $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM employee_asset_mapping
+			WHERE employee_id = $2 AND archive_at IS NULL
+		  )
 	`
 
-	_, err := em.DB.Exec(query, time.Now(), id)
-	return err
+	result, err := em.DB.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	archived, err := result.RowsAffected()
+	if err != nil || archived > 0 {
+		return err
+	}
+
+	outstanding, err := em.CountActiveAssets(ctx, id)
+	if err != nil {
+		return err
+	}
+	if outstanding > 0 {
+		return ErrEmployeeHasAssets
+	}
+	return nil
+}
+
+// CountActiveAssets returns how many assets are currently assigned to an employee
+func (em *EmployeeModel) CountActiveAssets(ctx context.Context, id uuid.UUID) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*)
+		FROM employee_asset_mapping
+		WHERE employee_id = $1 AND archive_at IS NULL
+	`
+
+	var count int
+	err := em.DB.QueryRowContext(ctx, query, id).Scan(&count)
+	return count, err
 }
 
 // GetEmployeeByID retrieves an employee from the database by its ID
-func (em *EmployeeModel) GetEmployeeByID(id uuid.UUID) (*Employee, error) {
+func (em *EmployeeModel) GetEmployeeByID(ctx context.Context, id uuid.UUID) (*Employee, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, email, role, created_at, archive_at
+		SELECT id, name, email, role, department, manager_id, created_at, archive_at
 		FROM employee
 		WHERE id = $1
 	`
 
 	employee := &Employee{}
-	err := em.DB.QueryRow(query, id).Scan(&employee.ID, &employee.Name, &employee.Email, &employee.Role, &employee.CreatedAt, &employee.ArchivedAt)
+	err := em.DB.QueryRowContext(ctx, query, id).Scan(&employee.ID, &employee.Name, &employee.Email, &employee.Role, &employee.Department, &employee.ManagerID, &employee.CreatedAt, &employee.ArchivedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -81,32 +138,69 @@ func (em *EmployeeModel) GetEmployeeByID(id uuid.UUID) (*Employee, error) {
 	return employee, nil
 }
 
-// GetAllEmployees retrieves all employees from the database
-func (em *EmployeeModel) GetAllEmployees() ([]*Employee, error) {
+// GetEmployeeByEmail retrieves the oldest active employee with an email address, ignoring case
+func (em *EmployeeModel) GetEmployeeByEmail(ctx context.Context, email string) (*Employee, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, name, email, role, created_at, archive_at
+		SELECT id, name, email, role, department, manager_id, created_at, archive_at
 		FROM employee
+		WHERE lower(email) = lower($1) AND archive_at IS NULL
+		ORDER BY created_at
+		LIMIT 1
 	`
 
-	rows, err := em.DB.Query(query)
+	employee := &Employee{}
+	err := em.DB.QueryRowContext(ctx, query, email).Scan(&employee.ID, &employee.Name, &employee.Email, &employee.Role, &employee.Department, &employee.ManagerID, &employee.CreatedAt, &employee.ArchivedAt)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+
+	return employee, nil
+}
+
+// GetAllEmployees retrieves all employees from the database
+func (em *EmployeeModel) GetAllEmployees(ctx context.Context) ([]*Employee, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
 	var employees []*Employee
+	err := em.ForEachEmployee(ctx, func(employee *Employee) error {
+		employees = append(employees, employee)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return employees, nil
+}
+
+// ForEachEmployee calls fn for each employee as it is read from the database cursor
+func (em *EmployeeModel) ForEachEmployee(ctx context.Context, fn func(*Employee) error) error {
+	query := `
+		SELECT id, name, email, role, department, manager_id, created_at, archive_at
+		FROM employee
+		ORDER BY created_at
+	`
+
+	rows, err := em.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
 	for rows.Next() {
 		employee := &Employee{}
-		err := rows.Scan(&employee.ID, &employee.Name, &employee.Email, &employee.Role, &employee.CreatedAt, &employee.ArchivedAt)
+		err := rows.Scan(&employee.ID, &employee.Name, &employee.Email, &employee.Role, &employee.Department, &employee.ManagerID, &employee.CreatedAt, &employee.ArchivedAt)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := fn(employee); err != nil {
+			return err
 		}
-		employees = append(employees, employee)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, err
 	}
 
-	return employees, nil
+	return rows.Err()
 }
@@ -0,0 +1,280 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestEmployeeLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		manager := mustEmployee(t, repos, "manager", nil)
+		employee := mustEmployee(t, repos, "engineer", manager)
+
+		got, err := repos.Employees.GetEmployeeByID(ctx, employee.ID)
+		if err != nil {
+			t.Fatalf("GetEmployeeByID: %v", err)
+		}
+		if got.ManagerID == nil || *got.ManagerID != manager.ID {
+			t.Errorf("GetEmployeeByID manager = %v, want %s", got.ManagerID, manager.ID)
+		}
+
+		got.Department = "Research"
+		got.ArchivedAt = &got.CreatedAt
+		if err := repos.Employees.UpdateEmployee(ctx, got); err != nil {
+			t.Fatalf("UpdateEmployee: %v", err)
+		}
+		got, err = repos.Employees.GetEmployeeByID(ctx, employee.ID)
+		if err != nil {
+			t.Fatalf("GetEmployeeByID: %v", err)
+		}
+		if got.Department != "Research" {
+			t.Errorf("after update department = %q, want Research", got.Department)
+		}
+		if got.ArchivedAt != nil {
+			t.Error("UpdateEmployee archived the employee; only ArchiveEmployee and offboarding may")
+		}
+
+		employees, err := repos.Employees.GetAllEmployees(ctx)
+		if err != nil || len(employees) != 2 {
+			t.Errorf("GetAllEmployees = %d employees, %v; want 2", len(employees), err)
+		}
+		var seen int
+		if err := repos.Employees.ForEachEmployee(ctx, func(*models.Employee) error { seen++; return nil }); err != nil || seen != 2 {
+			t.Errorf("ForEachEmployee = %v after %d employees, want nil after 2", err, seen)
+		}
+
+		if _, err := repos.Employees.GetEmployeeByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetEmployeeByID(missing) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestArchiveEmployee(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		mapping := mustAssign(t, repos, employee, mustAsset(t, repos, "laptop", "London"))
+
+		count, err := repos.Employees.CountActiveAssets(ctx, employee.ID)
+		if err != nil || count != 1 {
+			t.Fatalf("CountActiveAssets = %d, %v; want 1", count, err)
+		}
+		if err := repos.Employees.ArchiveEmployee(ctx, employee.ID); !errors.Is(err, models.ErrEmployeeHasAssets) {
+			t.Fatalf("ArchiveEmployee with assets error = %v, want ErrEmployeeHasAssets", err)
+		}
+
+		if err := repos.EmployeeAssets.ArchiveEmployeeAsset(ctx, mapping.ID); err != nil {
+			t.Fatalf("ArchiveEmployeeAsset: %v", err)
+		}
+		if err := repos.Employees.ArchiveEmployee(ctx, employee.ID); err != nil {
+			t.Fatalf("ArchiveEmployee: %v", err)
+		}
+		got, err := repos.Employees.GetEmployeeByID(ctx, employee.ID)
+		if err != nil {
+			t.Fatalf("GetEmployeeByID: %v", err)
+		}
+		if got.ArchivedAt == nil {
+			t.Error("ArchiveEmployee did not set archivedAt")
+		}
+	})
+}
+
+func TestEmployeeAssetLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		other := mustEmployee(t, repos, "engineer", nil)
+		asset := mustAsset(t, repos, "laptop", "London")
+		mapping := mustAssign(t, repos, employee, asset)
+
+		assetStatus := func(want string) {
+			t.Helper()
+			got, err := repos.Assets.GetAssetByID(ctx, asset.Id)
+			if err != nil {
+				t.Fatalf("GetAssetByID: %v", err)
+			}
+			if got.Status != want {
+				t.Errorf("asset status = %q, want %q", got.Status, want)
+			}
+		}
+		assetStatus(models.AssetStatusAssigned)
+
+		mapping.EmployeeID = other.ID
+		if err := repos.EmployeeAssets.UpdateEmployeeAsset(ctx, mapping); err != nil {
+			t.Fatalf("UpdateEmployeeAsset: %v", err)
+		}
+		got, err := repos.EmployeeAssets.GetEmployeeAssetByID(ctx, mapping.ID)
+		if err != nil {
+			t.Fatalf("GetEmployeeAssetByID: %v", err)
+		}
+		if got.EmployeeID != other.ID {
+			t.Errorf("after update employee = %s, want %s", got.EmployeeID, other.ID)
+		}
+
+		mappings, err := repos.EmployeeAssets.GetAllEmployeeAssets(ctx)
+		if err != nil || len(mappings) != 1 {
+			t.Errorf("GetAllEmployeeAssets = %d mappings, %v; want 1", len(mappings), err)
+		}
+		var seen int
+		if err := repos.EmployeeAssets.ForEachEmployeeAsset(ctx, func(*models.EmployeeAsset) error { seen++; return nil }); err != nil || seen != 1 {
+			t.Errorf("ForEachEmployeeAsset = %v after %d mappings, want nil after 1", err, seen)
+		}
+
+		if err := repos.EmployeeAssets.ArchiveEmployeeAsset(ctx, mapping.ID); err != nil {
+			t.Fatalf("ArchiveEmployeeAsset: %v", err)
+		}
+		assetStatus(models.AssetStatusAvailable)
+
+		if err := repos.EmployeeAssets.ArchiveEmployeeAsset(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ArchiveEmployeeAsset(missing) error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := repos.EmployeeAssets.GetEmployeeAssetByID(ctx, uuid.New()); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetEmployeeAssetByID(missing) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestOffboarding(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		laptop := mustAsset(t, repos, "laptop", "London")
+		phone := mustAsset(t, repos, "phone", "London")
+		mustAssign(t, repos, employee, laptop)
+		mustAssign(t, repos, employee, phone)
+
+		if _, err := repos.Offboardings.GetOffboardingByEmployeeID(ctx, employee.ID); !errors.Is(err, models.ErrOffboardingNotFound) {
+			t.Fatalf("GetOffboardingByEmployeeID before start error = %v, want ErrOffboardingNotFound", err)
+		}
+
+		offboarding, err := repos.Offboardings.StartOffboarding(ctx, employee.ID)
+		if err != nil {
+			t.Fatalf("StartOffboarding: %v", err)
+		}
+		if len(offboarding.Items) != 2 {
+			t.Fatalf("StartOffboarding items = %d, want 2", len(offboarding.Items))
+		}
+		again, err := repos.Offboardings.StartOffboarding(ctx, employee.ID)
+		if err != nil || again.ID != offboarding.ID {
+			t.Errorf("StartOffboarding again = %v, %v; want the open offboarding %s", again, err, offboarding.ID)
+		}
+
+		if err := repos.Offboardings.CompleteOffboarding(ctx, employee.ID); !errors.Is(err, models.ErrOffboardingIncomplete) {
+			t.Errorf("CompleteOffboarding with pending items error = %v, want ErrOffboardingIncomplete", err)
+		}
+
+		resolutions := map[uuid.UUID]string{
+			laptop.Id: models.OffboardingItemReturned,
+			phone.Id:  models.OffboardingItemLost,
+		}
+		wantStatus := map[uuid.UUID]string{
+			laptop.Id: models.AssetStatusAvailable,
+			phone.Id:  models.AssetStatusLost,
+		}
+
+		tests := []struct {
+			name    string
+			item    uuid.UUID
+			status  string
+			wantErr error
+		}{
+			{"unknown status", offboarding.Items[0].ID, "stolen", models.ErrInvalidOffboardingStatus},
+			{"unknown item", uuid.New(), models.OffboardingItemReturned, models.ErrOffboardingNotFound},
+			{"first item", offboarding.Items[0].ID, resolutions[offboarding.Items[0].AssetID], nil},
+			{"second item", offboarding.Items[1].ID, resolutions[offboarding.Items[1].AssetID], nil},
+			{"resolved item", offboarding.Items[0].ID, models.OffboardingItemWrittenOff, models.ErrOffboardingItemResolved},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := repos.Offboardings.ResolveOffboardingItem(ctx, employee.ID, tt.item, tt.status, "")
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ResolveOffboardingItem error = %v, want %v", err, tt.wantErr)
+				}
+			})
+		}
+
+		for assetID, want := range wantStatus {
+			asset, err := repos.Assets.GetAssetByID(ctx, assetID)
+			if err != nil {
+				t.Fatalf("GetAssetByID: %v", err)
+			}
+			if asset.Status != want {
+				t.Errorf("asset %s status = %q, want %q", asset.Serial, asset.Status, want)
+			}
+		}
+
+		if err := repos.Offboardings.CompleteOffboarding(ctx, employee.ID); err != nil {
+			t.Fatalf("CompleteOffboarding: %v", err)
+		}
+		got, err := repos.Employees.GetEmployeeByID(ctx, employee.ID)
+		if err != nil {
+			t.Fatalf("GetEmployeeByID: %v", err)
+		}
+		if got.ArchivedAt == nil {
+			t.Error("CompleteOffboarding did not archive the employee")
+		}
+	})
+}
+
+func TestAssignmentGuards(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		leaver := mustEmployee(t, repos, "engineer", nil)
+		laptop := mustAsset(t, repos, "laptop", "London")
+		lost := mustAsset(t, repos, "laptop", "London")
+		archived := mustAsset(t, repos, "laptop", "London")
+
+		mustAssign(t, repos, employee, laptop)
+		lost.Status = models.AssetStatusLost
+		if err := repos.Assets.UpdateAsset(ctx, lost); err != nil {
+			t.Fatalf("UpdateAsset: %v", err)
+		}
+		if err := repos.Assets.ArchiveAsset(ctx, archived.Id); err != nil {
+			t.Fatalf("ArchiveAsset: %v", err)
+		}
+		if _, err := repos.Offboardings.StartOffboarding(ctx, leaver.ID); err != nil {
+			t.Fatalf("StartOffboarding: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			employee uuid.UUID
+			asset    uuid.UUID
+			want     error
+		}{
+			{"asset with someone else", mustEmployee(t, repos, "engineer", nil).ID, laptop.Id, models.ErrAssetUnavailable},
+			{"lost asset", employee.ID, lost.Id, models.ErrAssetUnavailable},
+			{"archived asset", employee.ID, archived.Id, models.ErrAssetUnavailable},
+			{"missing asset", employee.ID, uuid.New(), models.ErrAssetUnavailable},
+			{"missing employee", uuid.New(), mustAsset(t, repos, "laptop", "London").Id, models.ErrEmployeeNotFound},
+			{"employee being offboarded", leaver.ID, mustAsset(t, repos, "laptop", "London").Id, models.ErrEmployeeOffboarding},
+		}
+		for _, tt := range tests {
+			mapping := &models.EmployeeAsset{AssetID: tt.asset, EmployeeID: tt.employee}
+			if err := repos.EmployeeAssets.CreateEmployeeAsset(ctx, mapping); !errors.Is(err, tt.want) {
+				t.Errorf("%s: CreateEmployeeAsset error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		// Once the offboarding is over the employee is archived and cannot be given anything either
+		if err := repos.Offboardings.CompleteOffboarding(ctx, leaver.ID); err != nil {
+			t.Fatalf("CompleteOffboarding: %v", err)
+		}
+		mapping := &models.EmployeeAsset{AssetID: mustAsset(t, repos, "laptop", "London").Id, EmployeeID: leaver.ID}
+		if err := repos.EmployeeAssets.CreateEmployeeAsset(ctx, mapping); !errors.Is(err, models.ErrEmployeeNotFound) {
+			t.Errorf("assigning to an archived employee error = %v, want ErrEmployeeNotFound", err)
+		}
+		if _, err := repos.Kits.OnboardEmployee(ctx, leaver.ID, "", false); !errors.Is(err, models.ErrEmployeeNotFound) {
+			t.Errorf("onboarding an archived employee error = %v, want ErrEmployeeNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrIdentityExists is returned when linking an identity that another login linked first
+var ErrIdentityExists = errors.New("identity is already linked")
+
+// Identity is a user at the OpenID Connect provider, named by its issuer and subject, and the admin
+// or employee they log in as. Exactly one of AdminID and EmployeeID is set. Email is the address
+// the provider gave when the identity was linked.
+type Identity struct {
+	Issuer     string     `json:"issuer"`
+	Subject    string     `json:"subject"`
+	AdminID    *uuid.UUID `json:"admin_id,omitempty"`
+	EmployeeID *uuid.UUID `json:"employee_id,omitempty"`
+	Email      string     `json:"email"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// IdentityModel represents the model for identities at the OpenID Connect provider
+type IdentityModel struct {
+	DB *sql.DB
+}
+
+// GetIdentity retrieves the identity with a subject at an issuer
+func (im *IdentityModel) GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	identity := &Identity{}
+	err := im.DB.QueryRowContext(ctx, `
+		SELECT issuer, subject, admin_id, employee_id, email, created_at
+		FROM oidc_identity
+		WHERE issuer = $1 AND subject = $2
+	`, issuer, subject).Scan(&identity.Issuer, &identity.Subject, &identity.AdminID, &identity.EmployeeID,
+		&identity.Email, &identity.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// CreateIdentity links an identity to its admin or employee. It returns ErrIdentityExists if the
+// identity is already linked.
+func (im *IdentityModel) CreateIdentity(ctx context.Context, identity *Identity) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	identity.CreatedAt = time.Now()
+	_, err := im.DB.ExecContext(ctx, `
+		INSERT INTO oidc_identity (issuer, subject, admin_id, employee_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, identity.Issuer, identity.Subject, identity.AdminID, identity.EmployeeID, identity.Email, identity.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrIdentityExists
+	}
+	if err != nil {
+		return fmt.Errorf("error linking identity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestIdentities(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+		employee := mustEmployee(t, repos, "Engineer", nil)
+		const issuer = "https://idp.example.com"
+
+		if _, err := repos.Identities.GetIdentity(ctx, issuer, "grace"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetIdentity before linking error = %v, want sql.ErrNoRows", err)
+		}
+		if err := repos.Identities.CreateIdentity(ctx, &models.Identity{Issuer: issuer, Subject: "grace", AdminID: &admin.ID, Email: admin.Email}); err != nil {
+			t.Fatalf("CreateIdentity(admin): %v", err)
+		}
+		if err := repos.Identities.CreateIdentity(ctx, &models.Identity{Issuer: issuer, Subject: "ada", EmployeeID: &employee.ID, Email: employee.Email}); err != nil {
+			t.Fatalf("CreateIdentity(employee): %v", err)
+		}
+
+		identity, err := repos.Identities.GetIdentity(ctx, issuer, "grace")
+		if err != nil || identity.AdminID == nil || *identity.AdminID != admin.ID || identity.EmployeeID != nil || identity.CreatedAt.IsZero() {
+			t.Errorf("GetIdentity = %+v, %v; want a link to admin %s", identity, err, admin.ID)
+		}
+		if _, err := repos.Identities.GetIdentity(ctx, "https://other.example.com", "grace"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetIdentity(other issuer) error = %v, want sql.ErrNoRows", err)
+		}
+
+		if err := repos.Identities.CreateIdentity(ctx, &models.Identity{Issuer: issuer, Subject: "grace", EmployeeID: &employee.ID}); !errors.Is(err, models.ErrIdentityExists) {
+			t.Errorf("CreateIdentity(linked subject) error = %v, want ErrIdentityExists", err)
+		}
+		if err := repos.Identities.CreateIdentity(ctx, &models.Identity{Issuer: issuer, Subject: "both", AdminID: &admin.ID, EmployeeID: &employee.ID}); err == nil {
+			t.Error("CreateIdentity for an admin and an employee succeeded")
+		}
+		if err := repos.Identities.CreateIdentity(ctx, &models.Identity{Issuer: issuer, Subject: "nobody"}); err == nil {
+			t.Error("CreateIdentity for no one succeeded")
+		}
+		missing := uuid.New()
+		if err := repos.Identities.CreateIdentity(ctx, &models.Identity{Issuer: issuer, Subject: "ghost", EmployeeID: &missing}); err == nil {
+			t.Error("CreateIdentity for a missing employee succeeded")
+		}
+	})
+}
+
+func TestGetEmployeeByEmail(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "Engineer", nil)
+
+		got, err := repos.Employees.GetEmployeeByEmail(ctx, strings.ToUpper(employee.Email))
+		if err != nil || got.ID != employee.ID {
+			t.Errorf("GetEmployeeByEmail = %+v, %v; want employee %s", got, err, employee.ID)
+		}
+
+		if err := repos.Employees.ArchiveEmployee(ctx, employee.ID); err != nil {
+			t.Fatalf("ArchiveEmployee: %v", err)
+		}
+		if _, err := repos.Employees.GetEmployeeByEmail(ctx, employee.Email); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetEmployeeByEmail(archived) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
+
+func TestEmployeeSessions(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "Engineer", nil)
+
+		session := &models.Session{EmployeeID: &employee.ID}
+		if err := repos.Sessions.CreateSession(ctx, session); err != nil {
+			t.Fatalf("CreateSession(employee): %v", err)
+		}
+		got, err := repos.Sessions.GetSessionByID(ctx, session.ID)
+		if err != nil || got.AdminID != uuid.Nil || got.EmployeeID == nil || *got.EmployeeID != employee.ID {
+			t.Errorf("GetSessionByID = %+v, %v; want employee %s's session", got, err, employee.ID)
+		}
+
+		if err := repos.Sessions.CreateSession(ctx, &models.Session{}); err == nil {
+			t.Error("CreateSession for no one succeeded")
+		}
+	})
+}
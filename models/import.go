@@ -0,0 +1,432 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrUnknownImportKind is returned for an import target other than assets, employees or employeeassets
+var ErrUnknownImportKind = errors.New("unknown import kind")
+
+// ImportRow is one CSV record after column mapping, keyed by field name
+type ImportRow struct {
+	Line   int
+	Values map[string]string
+}
+
+// ImportError explains why a row cannot be imported
+type ImportError struct {
+	Line    int    `json:"line"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportReport summarises an import or a dry run of one
+type ImportReport struct {
+	Kind      string        `json:"kind"`
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+	Rows      int           `json:"rows"`
+	Inserted  int64         `json:"inserted"`
+	Updated   int64         `json:"updated"`
+	Unchanged int64         `json:"unchanged"`
+	Errors    []ImportError `json:"errors,omitempty"`
+}
+
+// importSpec describes how one kind of CSV is validated and merged. Rows are copied into a
+// temporary table named after the kind, with a leading line column, before any SQL runs.
+type importSpec struct {
+	table    string
+	fields   []string
+	required []string
+	// key must be unique within the file; it is what the upsert matches existing rows on
+	key      string
+	validate func(row ImportRow) []ImportError
+	// check returns (line, field, message) for rows that conflict with the database or with other
+	// rows of the file
+	check string
+	// existing counts rows that will update (or, for assignments, already match) existing data
+	existing string
+	// update and insert merge the staged rows; either may be empty
+	update string
+	insert string
+	after  []string
+}
+
+var importSpecs = map[string]importSpec{
+	"assets": {
+		table:    "import_asset",
+		fields:   []string{"model", "company", "tag", "serial", "category", "location", "status"},
+		required: []string{"model", "company", "serial"},
+		key:      "serial",
+		validate: validateAssetImport,
+		check: `
+			SELECT i.line, 'tag', 'tag already belongs to asset ' || a.serial
+			FROM import_asset i JOIN asset a ON a.tag = i.tag
+			WHERE i.tag <> '' AND a.serial <> i.serial
+			UNION ALL
+			SELECT i.line, 'tag', 'duplicate of line ' || min(j.line)
+			FROM import_asset i JOIN import_asset j ON j.tag = i.tag AND j.line < i.line
+			WHERE i.tag <> ''
+			GROUP BY i.line
+			UNION ALL
+			SELECT i.line, 'serial', 'duplicate of line ' || min(j.line)
+			FROM import_asset i JOIN import_asset j ON j.serial = i.serial AND j.line < i.line
+			WHERE i.serial <> ''
+			GROUP BY i.line
+			UNION ALL
+			SELECT i.line, 'status', 'asset has no assignment; assign it with an employeeassets import'
+			FROM import_asset i
+			WHERE i.status = 'assigned'
+			  AND NOT EXISTS (
+				SELECT 1 FROM asset a JOIN employee_asset_mapping m ON m.asset_id = a.id AND m.archive_at IS NULL
+				WHERE a.serial = i.serial
+			  )
+			UNION ALL
+			SELECT i.line, 'status', 'asset is assigned to ' || e.email || '; end the assignment first'
+			FROM import_asset i
+			JOIN asset a ON a.serial = i.serial
+			JOIN employee_asset_mapping m ON m.asset_id = a.id AND m.archive_at IS NULL
+			JOIN employee e ON e.id = m.employee_id
+			WHERE i.status = 'available'
+		`,
+		existing: `SELECT COUNT(*) FROM import_asset i WHERE EXISTS (SELECT 1 FROM asset a WHERE a.serial = i.serial)`,
+		// Empty optional cells leave the stored value alone
+		update: `
+			UPDATE asset a
+			SET model = i.model, company = i.company,
+			    tag = COALESCE(NULLIF(i.tag, ''), a.tag),
+			    category = COALESCE(NULLIF(i.category, ''), a.category),
+			    location = COALESCE(NULLIF(i.location, ''), a.location),
+			    status = COALESCE(NULLIF(i.status, ''), a.status)
+			FROM import_asset i
+			WHERE a.serial = i.serial
+		`,
+		insert: `
+			INSERT INTO asset (id, model, company, tag, serial, category, location, status, created_at)
+			SELECT gen_random_uuid(), i.model, i.company, i.tag, i.serial, i.category, i.location,
+			       COALESCE(NULLIF(i.status, ''), 'available'), now()
+			FROM import_asset i
+			WHERE NOT EXISTS (SELECT 1 FROM asset a WHERE a.serial = i.serial)
+		`,
+	},
+	"employees": {
+		table:    "import_employee",
+		fields:   []string{"name", "email", "role", "department", "manager_email"},
+		required: []string{"name", "email"},
+		key:      "email",
+		validate: validateEmployeeImport,
+		check: `
+			SELECT i.line, 'manager_email', 'no employee with email ' || i.manager_email
+			FROM import_employee i
+			WHERE i.manager_email <> ''
+			  AND NOT EXISTS (SELECT 1 FROM employee e WHERE lower(e.email) = lower(i.manager_email) AND e.archive_at IS NULL)
+			  AND NOT EXISTS (SELECT 1 FROM import_employee j WHERE lower(j.email) = lower(i.manager_email))
+		`,
+		existing: `SELECT COUNT(*) FROM import_employee i WHERE EXISTS (SELECT 1 FROM employee e WHERE lower(e.email) = lower(i.email) AND e.archive_at IS NULL)`,
+		update: `
+			UPDATE employee e
+			SET name = i.name,
+			    role = COALESCE(NULLIF(i.role, ''), e.role),
+			    department = COALESCE(NULLIF(i.department, ''), e.department)
+			FROM import_employee i
+			WHERE lower(e.email) = lower(i.email) AND e.archive_at IS NULL
+		`,
+		insert: `
+			INSERT INTO employee (id, name, email, role, department, created_at)
+			SELECT gen_random_uuid(), i.name, i.email, i.role, i.department, now()
+			FROM import_employee i
+			WHERE NOT EXISTS (SELECT 1 FROM employee e WHERE lower(e.email) = lower(i.email) AND e.archive_at IS NULL)
+		`,
+		// Managers are linked once every employee in the file exists. Archived rows keep their
+		// addresses, so every match is on the active employee alone.
+		after: []string{`
+			UPDATE employee e
+			SET manager_id = m.id
+			FROM import_employee i JOIN employee m ON lower(m.email) = lower(i.manager_email) AND m.archive_at IS NULL
+			WHERE lower(e.email) = lower(i.email) AND e.archive_at IS NULL AND i.manager_email <> ''
+		`},
+	},
+	"employeeassets": {
+		table:    "import_employee_asset",
+		fields:   []string{"asset_serial", "employee_email"},
+		required: []string{"asset_serial", "employee_email"},
+		key:      "asset_serial",
+		validate: validateEmployeeAssetImport,
+		check: `
+			SELECT i.line, 'asset_serial', 'no asset with serial ' || i.asset_serial
+			FROM import_employee_asset i
+			WHERE NOT EXISTS (SELECT 1 FROM asset a WHERE a.serial = i.asset_serial AND a.archive_at IS NULL)
+			UNION ALL
+			SELECT i.line, 'employee_email', 'no employee with email ' || i.employee_email
+			FROM import_employee_asset i
+			WHERE NOT EXISTS (SELECT 1 FROM employee e WHERE lower(e.email) = lower(i.employee_email) AND e.archive_at IS NULL)
+			UNION ALL
+			SELECT i.line, 'asset_serial', 'asset is assigned to ' || e.email
+			FROM import_employee_asset i
+			JOIN asset a ON a.serial = i.asset_serial
+			JOIN employee_asset_mapping m ON m.asset_id = a.id AND m.archive_at IS NULL
+			JOIN employee e ON e.id = m.employee_id
+			WHERE lower(e.email) <> lower(i.employee_email)
+		`,
+		// Assignments that already exist are left as they are, which makes re-running a file safe
+		existing: `
+			SELECT COUNT(*)
+			FROM import_employee_asset i
+			JOIN asset a ON a.serial = i.asset_serial
+			JOIN employee_asset_mapping m ON m.asset_id = a.id AND m.archive_at IS NULL
+			JOIN employee e ON e.id = m.employee_id AND lower(e.email) = lower(i.employee_email)
+		`,
+		insert: `
+			WITH assigned AS (
+				INSERT INTO employee_asset_mapping (id, asset_id, employee_id, created_at)
+				SELECT DISTINCT ON (a.id) gen_random_uuid(), a.id, e.id, now()
+				FROM import_employee_asset i
+				JOIN asset a ON a.serial = i.asset_serial AND a.archive_at IS NULL
+				JOIN employee e ON lower(e.email) = lower(i.employee_email) AND e.archive_at IS NULL
+				WHERE NOT EXISTS (SELECT 1 FROM employee_asset_mapping m WHERE m.asset_id = a.id AND m.archive_at IS NULL)
+				RETURNING asset_id
+			)
+			UPDATE asset SET status = 'assigned' WHERE id IN (SELECT asset_id FROM assigned)
+		`,
+	},
+}
+
+// ImportFields lists the fields a CSV of the given kind may map columns to
+func ImportFields(kind string) ([]string, []string, error) {
+	spec, ok := importSpecs[kind]
+	if !ok {
+		return nil, nil, ErrUnknownImportKind
+	}
+	return spec.fields, spec.required, nil
+}
+
+func validateAssetImport(row ImportRow) []ImportError {
+	if status := row.Values["status"]; status != "" && !ValidAssetStatus(status) {
+		return []ImportError{{Line: row.Line, Field: "status", Message: fmt.Sprintf("unknown status %q", status)}}
+	}
+	return nil
+}
+
+func validateEmail(row ImportRow, field string) []ImportError {
+	value := row.Values[field]
+	if value == "" {
+		return nil
+	}
+	if address, err := mail.ParseAddress(value); err != nil || address.Address != value {
+		return []ImportError{{Line: row.Line, Field: field, Message: fmt.Sprintf("invalid email %q", value)}}
+	}
+	return nil
+}
+
+func validateEmployeeImport(row ImportRow) []ImportError {
+	return append(validateEmail(row, "email"), validateEmail(row, "manager_email")...)
+}
+
+func validateEmployeeAssetImport(row ImportRow) []ImportError {
+	return validateEmail(row, "employee_email")
+}
+
+// ImportModel represents the model for bulk CSV imports
+type ImportModel struct {
+	DB *sql.DB
+}
+
+// Import validates rows and, unless dryRun is set or any row is invalid, merges them in one
+// transaction. Rows are staged with COPY and then upserted on the kind's key (serial for assets,
+// email for employees); a dry run performs the same checks and rolls back.
+func (im *ImportModel) Import(ctx context.Context, kind string, rows []ImportRow, dryRun bool) (*ImportReport, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	spec, ok := importSpecs[kind]
+	if !ok {
+		return nil, ErrUnknownImportKind
+	}
+
+	report := &ImportReport{Kind: kind, DryRun: dryRun, Rows: len(rows)}
+	report.Errors = validateImportRows(spec, rows)
+
+	tx, err := im.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if !dryRun {
+		// Keep concurrent writers from slipping rows in between the update and the insert
+		if _, err := tx.ExecContext(ctx, `LOCK TABLE asset, employee, employee_asset_mapping IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := stageImportRows(ctx, tx, spec, rows); err != nil {
+		return nil, err
+	}
+
+	conflicts, err := checkImportRows(ctx, tx, spec)
+	if err != nil {
+		return nil, err
+	}
+	report.Errors = appendImportErrors(report.Errors, conflicts)
+
+	var existing int64
+	if err := tx.QueryRowContext(ctx, spec.existing).Scan(&existing); err != nil {
+		return nil, err
+	}
+
+	if dryRun || len(report.Errors) > 0 {
+		if spec.update != "" {
+			report.Updated = existing
+		} else {
+			report.Unchanged = existing
+		}
+		report.Inserted = int64(len(rows)) - existing
+		return report, nil
+	}
+
+	if spec.update != "" {
+		if report.Updated, err = execCount(ctx, tx, spec.update); err != nil {
+			return nil, err
+		}
+	} else {
+		report.Unchanged = existing
+	}
+	if report.Inserted, err = execCount(ctx, tx, spec.insert); err != nil {
+		return nil, err
+	}
+	for _, stmt := range spec.after {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	report.Committed = true
+
+	return report, nil
+}
+
+// ValidateImport runs the checks that need no database: required fields, formats and keys
+// repeated within the file
+func ValidateImport(kind string, rows []ImportRow) ([]ImportError, error) {
+	spec, ok := importSpecs[kind]
+	if !ok {
+		return nil, ErrUnknownImportKind
+	}
+	return validateImportRows(spec, rows), nil
+}
+
+// validateImportRows checks required fields, per-kind formats and duplicate keys within the file
+func validateImportRows(spec importSpec, rows []ImportRow) []ImportError {
+	var errs []ImportError
+	seen := make(map[string]int)
+
+	for _, row := range rows {
+		for _, field := range spec.required {
+			if row.Values[field] == "" {
+				errs = append(errs, ImportError{Line: row.Line, Field: field, Message: "required"})
+			}
+		}
+		errs = append(errs, spec.validate(row)...)
+
+		key := strings.ToLower(row.Values[spec.key])
+		if key == "" {
+			continue
+		}
+		if first, ok := seen[key]; ok {
+			errs = append(errs, ImportError{Line: row.Line, Field: spec.key, Message: fmt.Sprintf("duplicate of line %d", first)})
+			continue
+		}
+		seen[key] = row.Line
+	}
+
+	return errs
+}
+
+// appendImportErrors adds the conflicts that are not already reported for the same line and field,
+// such as a repeated key that validateImportRows found before the rows were staged
+func appendImportErrors(errs, conflicts []ImportError) []ImportError {
+	reported := make(map[ImportError]bool, len(errs))
+	for _, e := range errs {
+		reported[ImportError{Line: e.Line, Field: e.Field}] = true
+	}
+	for _, e := range conflicts {
+		if !reported[ImportError{Line: e.Line, Field: e.Field}] {
+			errs = append(errs, e)
+		}
+	}
+	return errs
+}
+
+// stageImportRows copies the rows into a temporary table that is dropped with the transaction
+func stageImportRows(ctx context.Context, tx *sql.Tx, spec importSpec, rows []ImportRow) error {
+	columns := make([]string, len(spec.fields))
+	for i, field := range spec.fields {
+		columns[i] = field + " TEXT NOT NULL DEFAULT ''"
+	}
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TEMP TABLE %s (line INTEGER NOT NULL, %s) ON COMMIT DROP`,
+		spec.table, strings.Join(columns, ", ")))
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(spec.table, append([]string{"line"}, spec.fields...)...))
+	if err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(spec.fields)+1)
+	for _, row := range rows {
+		values[0] = row.Line
+		for i, field := range spec.fields {
+			values[i+1] = row.Values[field]
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			stmt.Close()
+			return fmt.Errorf("error staging line %d: %w", row.Line, err)
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+// checkImportRows runs the kind's conflict query against the staged rows
+func checkImportRows(ctx context.Context, tx *sql.Tx, spec importSpec) ([]ImportError, error) {
+	rows, err := tx.QueryContext(ctx, spec.check+` ORDER BY 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errs []ImportError
+	for rows.Next() {
+		var e ImportError
+		if err := rows.Scan(&e.Line, &e.Field, &e.Message); err != nil {
+			return nil, err
+		}
+		errs = append(errs, e)
+	}
+
+	return errs, rows.Err()
+}
+
+func execCount(ctx context.Context, tx *sql.Tx, stmt string) (int64, error) {
+	result, err := tx.ExecContext(ctx, stmt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
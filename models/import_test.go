@@ -0,0 +1,283 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func row(line int, values map[string]string) models.ImportRow {
+	return models.ImportRow{Line: line, Values: values}
+}
+
+func TestImportAssets(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		existing := mustAsset(t, repos, "laptop", "London")
+
+		rows := []models.ImportRow{
+			row(2, map[string]string{"model": "X1", "company": "Lenovo", "serial": existing.Serial, "location": "Paris"}),
+			row(3, map[string]string{"model": "XPS", "company": "Dell", "serial": "DELL-1", "tag": "T-1", "category": "laptop"}),
+		}
+
+		report, err := repos.Imports.Import(ctx, "assets", rows, true)
+		if err != nil {
+			t.Fatalf("Import(dry run): %v", err)
+		}
+		if report.Committed || report.Updated != 1 || report.Inserted != 1 {
+			t.Errorf("dry run report = %+v, want 1 update and 1 insert, uncommitted", report)
+		}
+		if assets, _ := repos.Assets.GetAllAssets(ctx); len(assets) != 1 {
+			t.Errorf("dry run left %d assets, want 1", len(assets))
+		}
+
+		report, err = repos.Imports.Import(ctx, "assets", rows, false)
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if !report.Committed || report.Updated != 1 || report.Inserted != 1 {
+			t.Errorf("report = %+v, want 1 update and 1 insert, committed", report)
+		}
+		got, err := repos.Assets.GetAssetByID(ctx, existing.Id)
+		if err != nil {
+			t.Fatalf("GetAssetByID: %v", err)
+		}
+		if got.Model != "X1" || got.Location != "Paris" || got.Tag != existing.Tag {
+			t.Errorf("updated asset = %+v, want model X1 in Paris keeping tag %q", got, existing.Tag)
+		}
+	})
+}
+
+func TestImportErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		rows      []models.ImportRow
+		wantErr   error
+		wantField string
+	}{
+		{
+			name:    "unknown kind",
+			kind:    "kits",
+			wantErr: models.ErrUnknownImportKind,
+		},
+		{
+			name:      "missing required field",
+			kind:      "assets",
+			rows:      []models.ImportRow{row(2, map[string]string{"model": "X1", "company": "Lenovo"})},
+			wantField: "serial",
+		},
+		{
+			name:      "bad status",
+			kind:      "assets",
+			rows:      []models.ImportRow{row(2, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-1", "status": "broken"})},
+			wantField: "status",
+		},
+		{
+			name: "tag repeated in the file",
+			kind: "assets",
+			rows: []models.ImportRow{
+				row(2, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-1", "tag": "T-1"}),
+				row(3, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-2", "tag": "T-1"}),
+			},
+			wantField: "tag",
+		},
+		{
+			name:      "assigned without an assignment",
+			kind:      "assets",
+			rows:      []models.ImportRow{row(2, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-1", "status": "assigned"})},
+			wantField: "status",
+		},
+		{
+			name:      "bad email",
+			kind:      "employees",
+			rows:      []models.ImportRow{row(2, map[string]string{"name": "Ada", "email": "ada"})},
+			wantField: "email",
+		},
+		{
+			name:      "unknown manager",
+			kind:      "employees",
+			rows:      []models.ImportRow{row(2, map[string]string{"name": "Ada", "email": "ada@example.com", "manager_email": "boss@example.com"})},
+			wantField: "manager_email",
+		},
+		{
+			name:      "unknown asset",
+			kind:      "employeeassets",
+			rows:      []models.ImportRow{row(2, map[string]string{"asset_serial": "NOPE", "employee_email": "ada@example.com"})},
+			wantField: "asset_serial",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+				report, err := repos.Imports.Import(context.Background(), tt.kind, tt.rows, false)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Import error = %v, want %v", err, tt.wantErr)
+				}
+				if err != nil {
+					return
+				}
+				if report.Committed {
+					t.Error("Import with invalid rows committed")
+				}
+				var fields []string
+				for _, e := range report.Errors {
+					fields = append(fields, e.Field)
+				}
+				if len(fields) == 0 || fields[0] != tt.wantField {
+					t.Errorf("Import errors on %v, want %q", fields, tt.wantField)
+				}
+			})
+		})
+	}
+}
+
+func TestImportAssetConflicts(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "Engineer", nil)
+		assigned := mustAsset(t, repos, "laptop", "London")
+		mustAssign(t, repos, employee, assigned)
+
+		rows := []models.ImportRow{
+			row(2, map[string]string{"model": "X1", "company": "Lenovo", "serial": assigned.Serial, "status": "available"}),
+			row(3, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-1", "tag": "T-1"}),
+			row(4, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-2", "tag": "T-1"}),
+			row(5, map[string]string{"model": "X1", "company": "Lenovo", "serial": "S-1", "tag": "T-2"}),
+		}
+		report, err := repos.Imports.Import(ctx, "assets", rows, false)
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		want := []models.ImportError{
+			{Line: 2, Field: "status", Message: "asset is assigned to " + employee.Email + "; end the assignment first"},
+			{Line: 4, Field: "tag", Message: "duplicate of line 3"},
+			{Line: 5, Field: "serial", Message: "duplicate of line 3"},
+		}
+		if report.Committed || !sameImportErrors(report.Errors, want) {
+			t.Errorf("Import errors = %+v, want %+v, uncommitted", report.Errors, want)
+		}
+
+		// The status that matches the assignment imports
+		rows[0].Values["status"] = models.AssetStatusAssigned
+		report, err = repos.Imports.Import(ctx, "assets", rows[:1], false)
+		if err != nil || !report.Committed || len(report.Errors) != 0 {
+			t.Errorf("Import(assigned) = %+v, %v; want it committed", report, err)
+		}
+	})
+}
+
+// sameImportErrors compares errors regardless of their order within a line
+func sameImportErrors(got, want []models.ImportError) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[models.ImportError]int)
+	for _, e := range got {
+		seen[e]++
+	}
+	for _, e := range want {
+		if seen[e] == 0 {
+			return false
+		}
+		seen[e]--
+	}
+	return true
+}
+
+func TestImportEmployeesAndAssignments(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		asset := mustAsset(t, repos, "laptop", "London")
+
+		employees := []models.ImportRow{
+			row(2, map[string]string{"name": "Ada", "email": "ada@example.com", "manager_email": "grace@example.com"}),
+			row(3, map[string]string{"name": "Grace", "email": "grace@example.com"}),
+		}
+		report, err := repos.Imports.Import(ctx, "employees", employees, false)
+		if err != nil || !report.Committed || report.Inserted != 2 {
+			t.Fatalf("Import(employees) = %+v, %v; want 2 inserted", report, err)
+		}
+
+		assignments := []models.ImportRow{
+			row(2, map[string]string{"asset_serial": asset.Serial, "employee_email": "ADA@example.com"}),
+		}
+		for _, want := range []struct{ inserted, unchanged int64 }{{1, 0}, {0, 1}} {
+			report, err := repos.Imports.Import(ctx, "employeeassets", assignments, false)
+			if err != nil || !report.Committed || report.Inserted != want.inserted || report.Unchanged != want.unchanged {
+				t.Errorf("Import(employeeassets) = %+v, %v; want %d inserted, %d unchanged", report, err, want.inserted, want.unchanged)
+			}
+		}
+
+		all, err := repos.Employees.GetAllEmployees(ctx)
+		if err != nil {
+			t.Fatalf("GetAllEmployees: %v", err)
+		}
+		var ada, grace *models.Employee
+		for _, e := range all {
+			switch e.Email {
+			case "ada@example.com":
+				ada = e
+			case "grace@example.com":
+				grace = e
+			}
+		}
+		if ada == nil || grace == nil || ada.ManagerID == nil || *ada.ManagerID != grace.ID {
+			t.Fatalf("imported employees = %v, want Ada managed by Grace", all)
+		}
+		if count, err := repos.Employees.CountActiveAssets(ctx, ada.ID); err != nil || count != 1 {
+			t.Errorf("Ada holds %d assets, %v; want 1", count, err)
+		}
+	})
+}
+
+func TestImportSkipsArchivedEmployees(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+
+		// Ada and her old manager have left; their rows keep their addresses
+		var leavers []*models.Employee
+		for _, email := range []string{"ada@example.com", "boss@example.com"} {
+			leaver := &models.Employee{Name: "Leaver", Email: email, Role: "engineer"}
+			if err := repos.Employees.CreateEmployee(ctx, leaver); err != nil {
+				t.Fatalf("CreateEmployee: %v", err)
+			}
+			if _, err := repos.Offboardings.StartOffboarding(ctx, leaver.ID); err != nil {
+				t.Fatalf("StartOffboarding: %v", err)
+			}
+			if err := repos.Offboardings.CompleteOffboarding(ctx, leaver.ID); err != nil {
+				t.Fatalf("CompleteOffboarding: %v", err)
+			}
+			leavers = append(leavers, leaver)
+		}
+
+		report, err := repos.Imports.Import(ctx, "employees", []models.ImportRow{
+			row(2, map[string]string{"name": "Ada", "email": "ada@example.com", "manager_email": "boss@example.com"}),
+		}, false)
+		if err != nil || report.Committed || len(report.Errors) != 1 || report.Errors[0].Field != "manager_email" {
+			t.Fatalf("Import managed by a leaver = %+v, %v; want an error on manager_email", report, err)
+		}
+
+		report, err = repos.Imports.Import(ctx, "employees", []models.ImportRow{
+			row(2, map[string]string{"name": "Ada", "email": "ADA@example.com", "role": "manager"}),
+		}, false)
+		if err != nil || !report.Committed || report.Inserted != 1 || report.Updated != 0 {
+			t.Fatalf("Import of a returning employee = %+v, %v; want 1 inserted and the leaver left alone", report, err)
+		}
+
+		old, err := repos.Employees.GetEmployeeByID(ctx, leavers[0].ID)
+		if err != nil {
+			t.Fatalf("GetEmployeeByID: %v", err)
+		}
+		if old.Name != "Leaver" || old.Role != "engineer" || old.ArchivedAt == nil {
+			t.Errorf("archived employee = %+v, want it unchanged", old)
+		}
+		current, err := repos.Employees.GetEmployeeByEmail(ctx, "ada@example.com")
+		if err != nil || current.ID == old.ID || current.Role != "manager" {
+			t.Errorf("GetEmployeeByEmail = %+v, %v; want the imported employee", current, err)
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrKitNotFound is returned when no kit matches the requested name or employee
+	ErrKitNotFound = errors.New("kit not found")
+	// ErrEmployeeNotFound is returned when an employee does not exist or has been archived
+	ErrEmployeeNotFound = errors.New("employee not found")
+	// ErrKitShortfall is returned by strict onboarding when stock cannot cover the kit
+	ErrKitShortfall = errors.New("not enough assets in stock to fulfil kit")
+)
+
+// Kit is a template of asset categories handed to new hires in a role or department
+type Kit struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role,omitempty"`
+	Department string     `json:"department,omitempty"`
+	Items      []KitItem  `json:"items"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ArchivedAt *time.Time `json:"archive_at,omitempty"`
+}
+
+// KitItem is a number of assets of one category
+type KitItem struct {
+	Category string `json:"category"`
+	Quantity int    `json:"quantity"`
+}
+
+// KitShortfall reports a kit line that could not be fully covered from stock
+type KitShortfall struct {
+	Category  string `json:"category"`
+	Requested int    `json:"requested"`
+	Assigned  int    `json:"assigned"`
+}
+
+// Onboarding is the outcome of provisioning a kit for an employee
+type Onboarding struct {
+	EmployeeID  uuid.UUID        `json:"employee_id"`
+	Kit         string           `json:"kit"`
+	Assignments []*EmployeeAsset `json:"assignments"`
+	Shortfalls  []KitShortfall   `json:"shortfalls,omitempty"`
+}
+
+// KitModel represents the model for onboarding kit operations
+type KitModel struct {
+	DB *sql.DB
+}
+
+// CreateKit creates a new kit and its items
+func (km *KitModel) CreateKit(ctx context.Context, kit *Kit) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	kit.ID = uuid.New()
+	kit.CreatedAt = time.Now()
+
+	tx, err := km.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO kit (id, name, role, department, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		kit.ID, kit.Name, kit.Role, kit.Department, kit.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating kit: %w", err)
+	}
+
+	if err := insertKitItems(ctx, tx, kit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateKit replaces a kit's matching rules and items
+func (km *KitModel) UpdateKit(ctx context.Context, kit *Kit) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := km.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE kit SET name = $1, role = $2, department = $3 WHERE id = $4`,
+		kit.Name, kit.Role, kit.Department, kit.ID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM kit_item WHERE kit_id = $1`, kit.ID); err != nil {
+		return err
+	}
+
+	if err := insertKitItems(ctx, tx, kit); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func insertKitItems(ctx context.Context, tx *sql.Tx, kit *Kit) error {
+	for _, item := range kit.Items {
+		_, err := tx.ExecContext(ctx, `INSERT INTO kit_item (kit_id, category, quantity) VALUES ($1, $2, $3)`,
+			kit.ID, item.Category, item.Quantity)
+		if err != nil {
+			return fmt.Errorf("error adding kit item %q: %w", item.Category, err)
+		}
+	}
+	return nil
+}
+
+// ArchiveKit archives an existing kit
+func (km *KitModel) ArchiveKit(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := km.DB.ExecContext(ctx, `UPDATE kit SET archive_at = $1 WHERE id = $2`, time.Now(), id)
+	return err
+}
+
+// GetKitByID retrieves a kit and its items by ID
+func (km *KitModel) GetKitByID(ctx context.Context, id uuid.UUID) (*Kit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return km.getKit(ctx, `WHERE id = $1`, id)
+}
+
+// GetKitByName retrieves an active kit and its items by name
+func (km *KitModel) GetKitByName(ctx context.Context, name string) (*Kit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return km.getKit(ctx, `WHERE name = $1 AND archive_at IS NULL`, name)
+}
+
+// GetKitForEmployee finds the active kit matching an employee's role, falling back to their department
+func (km *KitModel) GetKitForEmployee(ctx context.Context, employee *Employee) (*Kit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return km.getKit(ctx, `
+		WHERE archive_at IS NULL
+		  AND ((role <> '' AND role = $1) OR (department <> '' AND department = $2))
+		ORDER BY (role = $1) DESC, created_at
+		LIMIT 1
+	`, employee.Role, employee.Department)
+}
+
+func (km *KitModel) getKit(ctx context.Context, where string, args ...interface{}) (*Kit, error) {
+	kit := &Kit{}
+	err := km.DB.QueryRowContext(ctx, `SELECT id, name, role, department, created_at, archive_at FROM kit `+where, args...).
+		Scan(&kit.ID, &kit.Name, &kit.Role, &kit.Department, &kit.CreatedAt, &kit.ArchivedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKitNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kit.Items, err = km.getKitItems(ctx, kit.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return kit, nil
+}
+
+func (km *KitModel) getKitItems(ctx context.Context, kitID uuid.UUID) ([]KitItem, error) {
+	rows, err := km.DB.QueryContext(ctx, `SELECT category, quantity FROM kit_item WHERE kit_id = $1 ORDER BY category`, kitID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []KitItem
+	for rows.Next() {
+		var item KitItem
+		if err := rows.Scan(&item.Category, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// GetAllKits retrieves all kits with their items
+func (km *KitModel) GetAllKits(ctx context.Context) ([]*Kit, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	rows, err := km.DB.QueryContext(ctx, `SELECT id, name, role, department, created_at, archive_at FROM kit ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var kits []*Kit
+	for rows.Next() {
+		kit := &Kit{}
+		err := rows.Scan(&kit.ID, &kit.Name, &kit.Role, &kit.Department, &kit.CreatedAt, &kit.ArchivedAt)
+		if err != nil {
+			return nil, err
+		}
+		kits = append(kits, kit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, kit := range kits {
+		kit.Items, err = km.getKitItems(ctx, kit.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return kits, nil
+}
+
+// OnboardEmployee assigns available assets for every kit item in a single transaction.
+// An empty kitName selects the kit matching the employee's role or department.
+// Lines that cannot be covered from stock are reported as shortfalls; when strict is set
+// any shortfall rolls the whole onboarding back and ErrKitShortfall is returned with an
+// onboarding that lists only the shortfalls.
+func (km *KitModel) OnboardEmployee(ctx context.Context, employeeID uuid.UUID, kitName string, strict bool) (*Onboarding, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := km.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	employee := &Employee{ID: employeeID}
+	err = tx.QueryRowContext(ctx, `SELECT role, department FROM employee WHERE id = $1 AND archive_at IS NULL FOR UPDATE`, employeeID).
+		Scan(&employee.Role, &employee.Department)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEmployeeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAssignee(ctx, tx, employeeID); err != nil {
+		return nil, err
+	}
+
+	var kit *Kit
+	if kitName != "" {
+		kit, err = km.GetKitByName(ctx, kitName)
+	} else {
+		kit, err = km.GetKitForEmployee(ctx, employee)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	onboarding := &Onboarding{EmployeeID: employeeID, Kit: kit.Name}
+	now := time.Now()
+
+	for _, item := range kit.Items {
+		// SKIP LOCKED lets concurrent onboardings pick disjoint assets instead of queueing
+		rows, err := tx.QueryContext(ctx, `
+			SELECT id
+			FROM asset
+			WHERE category = $1 AND status = $2 AND archive_at IS NULL
+			ORDER BY created_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		`, item.Category, AssetStatusAvailable, item.Quantity)
+		if err != nil {
+			return nil, err
+		}
+
+		var assetIDs []uuid.UUID
+		for rows.Next() {
+			var id uuid.UUID
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			assetIDs = append(assetIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, assetID := range assetIDs {
+			assignment := &EmployeeAsset{AssetID: assetID, EmployeeID: employeeID, CreatedAt: now}
+			if err := assignAsset(ctx, tx, assignment); err != nil {
+				return nil, err
+			}
+			onboarding.Assignments = append(onboarding.Assignments, assignment)
+		}
+
+		if len(assetIDs) < item.Quantity {
+			onboarding.Shortfalls = append(onboarding.Shortfalls, KitShortfall{
+				Category:  item.Category,
+				Requested: item.Quantity,
+				Assigned:  len(assetIDs),
+			})
+		}
+	}
+
+	if strict && len(onboarding.Shortfalls) > 0 {
+		// Nothing was assigned once the transaction rolls back
+		onboarding.Assignments = nil
+		return onboarding, ErrKitShortfall
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return onboarding, nil
+}
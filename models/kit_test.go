@@ -0,0 +1,148 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func mustKit(t *testing.T, repos modeltest.Repositories, name, role string, items ...models.KitItem) *models.Kit {
+	t.Helper()
+
+	kit := &models.Kit{Name: name, Role: role, Department: "Engineering", Items: items}
+	if err := repos.Kits.CreateKit(context.Background(), kit); err != nil {
+		t.Fatalf("CreateKit: %v", err)
+	}
+	return kit
+}
+
+func TestKitLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		kit := mustKit(t, repos, "engineer", "engineer", models.KitItem{Category: "laptop", Quantity: 1})
+		mustKit(t, repos, "default", "")
+
+		if err := repos.Kits.CreateKit(ctx, &models.Kit{Name: "engineer"}); err == nil {
+			t.Error("CreateKit with a duplicate name succeeded")
+		}
+		if err := repos.Kits.CreateKit(ctx, &models.Kit{Name: "empty line", Items: []models.KitItem{{Category: "laptop"}}}); err == nil {
+			t.Error("CreateKit with a zero quantity item succeeded")
+		}
+
+		kit.Items = []models.KitItem{{Category: "laptop", Quantity: 1}, {Category: "monitor", Quantity: 2}}
+		if err := repos.Kits.UpdateKit(ctx, kit); err != nil {
+			t.Fatalf("UpdateKit: %v", err)
+		}
+		got, err := repos.Kits.GetKitByID(ctx, kit.ID)
+		if err != nil {
+			t.Fatalf("GetKitByID: %v", err)
+		}
+		if len(got.Items) != 2 {
+			t.Errorf("after update kit has %d items, want 2", len(got.Items))
+		}
+
+		tests := []struct {
+			name     string
+			employee *models.Employee
+			want     string
+			wantErr  error
+		}{
+			{"role match", &models.Employee{Role: "engineer"}, "engineer", nil},
+			{"department fallback", &models.Employee{Role: "designer", Department: "Engineering"}, "engineer", nil},
+			{"no match", &models.Employee{Role: "designer", Department: "Sales"}, "", models.ErrKitNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				got, err := repos.Kits.GetKitForEmployee(ctx, tt.employee)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetKitForEmployee error = %v, want %v", err, tt.wantErr)
+				}
+				if err == nil && got.Name != tt.want {
+					t.Errorf("GetKitForEmployee = %q, want %q", got.Name, tt.want)
+				}
+			})
+		}
+
+		kits, err := repos.Kits.GetAllKits(ctx)
+		if err != nil || len(kits) != 2 || kits[0].Name != "default" {
+			t.Errorf("GetAllKits = %v, %v; want default and engineer by name", kits, err)
+		}
+
+		if err := repos.Kits.ArchiveKit(ctx, kit.ID); err != nil {
+			t.Fatalf("ArchiveKit: %v", err)
+		}
+		if _, err := repos.Kits.GetKitByName(ctx, "engineer"); !errors.Is(err, models.ErrKitNotFound) {
+			t.Errorf("GetKitByName(archived) error = %v, want ErrKitNotFound", err)
+		}
+		if _, err := repos.Kits.GetKitByID(ctx, uuid.New()); !errors.Is(err, models.ErrKitNotFound) {
+			t.Errorf("GetKitByID(missing) error = %v, want ErrKitNotFound", err)
+		}
+	})
+}
+
+func TestOnboardEmployee(t *testing.T) {
+	tests := []struct {
+		name       string
+		laptops    int
+		kit        string
+		strict     bool
+		wantErr    error
+		wantAssign int
+		wantShort  int
+	}{
+		{"covered", 2, "", false, nil, 3, 0},
+		{"named kit", 2, "engineer", true, nil, 3, 0},
+		{"shortfall", 1, "", false, nil, 2, 1},
+		{"strict shortfall", 1, "", true, models.ErrKitShortfall, 0, 1},
+		{"unknown kit", 2, "designer", false, models.ErrKitNotFound, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+				ctx := context.Background()
+				mustKit(t, repos, "engineer", "engineer",
+					models.KitItem{Category: "laptop", Quantity: 2},
+					models.KitItem{Category: "monitor", Quantity: 1})
+				for i := 0; i < tt.laptops; i++ {
+					mustAsset(t, repos, "laptop", "London")
+				}
+				mustAsset(t, repos, "monitor", "London")
+				employee := mustEmployee(t, repos, "engineer", nil)
+
+				onboarding, err := repos.Kits.OnboardEmployee(ctx, employee.ID, tt.kit, tt.strict)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("OnboardEmployee error = %v, want %v", err, tt.wantErr)
+				}
+				if onboarding != nil && len(onboarding.Shortfalls) != tt.wantShort {
+					t.Errorf("OnboardEmployee shortfalls = %v, want %d", onboarding.Shortfalls, tt.wantShort)
+				}
+				if onboarding != nil && len(onboarding.Assignments) != tt.wantAssign {
+					t.Errorf("OnboardEmployee reported %d assignments, want %d", len(onboarding.Assignments), tt.wantAssign)
+				}
+
+				// Strict shortfalls roll back, so only committed assignments count
+				count, err := repos.Employees.CountActiveAssets(ctx, employee.ID)
+				if err != nil {
+					t.Fatalf("CountActiveAssets: %v", err)
+				}
+				if count != tt.wantAssign {
+					t.Errorf("employee holds %d assets, want %d", count, tt.wantAssign)
+				}
+			})
+		})
+	}
+}
+
+func TestOnboardUnknownEmployee(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		_, err := repos.Kits.OnboardEmployee(context.Background(), uuid.New(), "", false)
+		if !errors.Is(err, models.ErrEmployeeNotFound) {
+			t.Errorf("OnboardEmployee(missing) error = %v, want ErrEmployeeNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/db/dbtest"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestMain(m *testing.M) {
+	dbtest.Main(m)
+}
+
+// mustAsset creates an available asset in category with a unique serial
+func mustAsset(t *testing.T, repos modeltest.Repositories, category, location string) *models.Asset {
+	t.Helper()
+
+	serial := uuid.NewString()
+	asset := &models.Asset{
+		Model:    "ThinkPad T14",
+		Company:  "Lenovo",
+		Tag:      "TAG-" + serial[:8],
+		Serial:   serial,
+		Category: category,
+		Location: location,
+	}
+	if err := repos.Assets.CreateAsset(context.Background(), asset); err != nil {
+		t.Fatalf("CreateAsset: %v", err)
+	}
+	return asset
+}
+
+// mustEmployee creates an employee, optionally reporting to manager
+func mustEmployee(t *testing.T, repos modeltest.Repositories, role string, manager *models.Employee) *models.Employee {
+	t.Helper()
+
+	employee := &models.Employee{
+		Name:       "Ada Lovelace",
+		Email:      uuid.NewString() + "@example.com",
+		Role:       role,
+		Department: "Engineering",
+		CreatedAt:  time.Now(),
+	}
+	if manager != nil {
+		employee.ManagerID = &manager.ID
+	}
+	if err := repos.Employees.CreateEmployee(context.Background(), employee); err != nil {
+		t.Fatalf("CreateEmployee: %v", err)
+	}
+	return employee
+}
+
+// mustAdmin creates an admin
+func mustAdmin(t *testing.T, repos modeltest.Repositories) *models.Admin {
+	t.Helper()
+
+	admin := &models.Admin{
+		Name:      "Grace Hopper",
+		Email:     uuid.NewString() + "@example.com",
+		Password:  "secret",
+		CreatedAt: time.Now(),
+	}
+	if err := repos.Admins.CreateAdmin(context.Background(), admin); err != nil {
+		t.Fatalf("CreateAdmin: %v", err)
+	}
+	return admin
+}
+
+// mustAssign assigns asset to employee
+func mustAssign(t *testing.T, repos modeltest.Repositories, employee *models.Employee, asset *models.Asset) *models.EmployeeAsset {
+	t.Helper()
+
+	mapping := &models.EmployeeAsset{AssetID: asset.Id, EmployeeID: employee.ID, CreatedAt: time.Now()}
+	if err := repos.EmployeeAssets.CreateEmployeeAsset(context.Background(), mapping); err != nil {
+		t.Fatalf("CreateEmployeeAsset: %v", err)
+	}
+	return mapping
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func adminKey(a *models.Admin) uuid.UUID       { return a.ID }
+func adminCreatedAt(a *models.Admin) time.Time { return a.CreatedAt }
+
+func (s *Store) CreateAdmin(ctx context.Context, admin *models.Admin) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	admin.ID = uuid.New()
+
+	stored := *admin
+	stored.CreatedAt = time.Now()
+	stored.ArchivedAt = nil
+	s.admins[stored.ID] = &stored
+
+	return nil
+}
+
+func (s *Store) UpdateAdmin(ctx context.Context, admin *models.Admin) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.admins[admin.ID]; ok {
+		existing.Name = admin.Name
+		existing.Email = admin.Email
+		existing.Password = admin.Password
+		existing.ArchivedAt = admin.ArchivedAt
+	}
+	return nil
+}
+
+func (s *Store) ArchiveAdmin(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if admin, ok := s.admins[id]; ok {
+		admin.ArchivedAt = now()
+	}
+	return nil
+}
+
+func (s *Store) GetAdminByID(ctx context.Context, id uuid.UUID) (*models.Admin, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	admin, ok := s.admins[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *admin
+	return &c, nil
+}
+
+// GetAdminByEmail returns the oldest active admin with the email address, ignoring case
+func (s *Store) GetAdminByEmail(ctx context.Context, email string) (*models.Admin, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	admins := sortedCopies(s.admins, adminKey, adminCreatedAt)
+	s.mu.RUnlock()
+
+	for _, admin := range admins {
+		if admin.ArchivedAt == nil && strings.EqualFold(admin.Email, email) {
+			return admin, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) GetAllAdmins(ctx context.Context) ([]*models.Admin, error) {
+	var admins []*models.Admin
+	err := s.ForEachAdmin(ctx, func(admin *models.Admin) error {
+		admins = append(admins, admin)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return admins, nil
+}
+
+// ForEachAdmin calls fn for each admin, oldest first
+func (s *Store) ForEachAdmin(ctx context.Context, fn func(*models.Admin) error) error {
+	s.mu.RLock()
+	admins := sortedCopies(s.admins, adminKey, adminCreatedAt)
+	s.mu.RUnlock()
+
+	return each(ctx, admins, fn)
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func apiKeyKey(k *models.APIKey) uuid.UUID       { return k.ID }
+func apiKeyCreatedAt(k *models.APIKey) time.Time { return k.CreatedAt }
+
+// copyAPIKey copies a key along with its slices, so callers cannot change the stored key
+func copyAPIKey(k *models.APIKey) *models.APIKey {
+	c := *k
+	c.Scopes = append([]string{}, k.Scopes...)
+	c.AllowedIPs = append([]string{}, k.AllowedIPs...)
+	return &c
+}
+
+// insertAPIKey stores a new key; the caller holds the write lock
+func (s *Store) insertAPIKey(key *models.APIKey) error {
+	for _, existing := range s.apiKeys {
+		if existing.Prefix == key.Prefix {
+			return fmt.Errorf("%w: api_key prefix %s", ErrUniqueViolation, key.Prefix)
+		}
+	}
+	if key.CreatedBy != nil {
+		if _, ok := s.admins[*key.CreatedBy]; !ok {
+			return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, *key.CreatedBy)
+		}
+	}
+
+	key.ID = uuid.New()
+	key.CreatedAt = time.Now()
+	key.LastUsedAt, key.LastUsedIP, key.RevokedAt = nil, "", nil
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+	s.apiKeys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+// CreateAPIKey stores a new key. The caller generates the key and sets its prefix and hash.
+func (s *Store) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.insertAPIKey(key)
+}
+
+// GetAPIKeyByID retrieves a key by its ID
+func (s *Store) GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return nil, models.ErrAPIKeyNotFound
+	}
+	return copyAPIKey(key), nil
+}
+
+// GetAPIKeyByPrefix retrieves the key with the given prefix, whether or not it is still active
+func (s *Store) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Prefix == prefix {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, models.ErrAPIKeyNotFound
+}
+
+// GetAllAPIKeys retrieves every key, revoked and expired ones included
+func (s *Store) GetAllAPIKeys(ctx context.Context) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	err := s.ForEachAPIKey(ctx, func(key *models.APIKey) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// ForEachAPIKey calls fn for each key, oldest first
+func (s *Store) ForEachAPIKey(ctx context.Context, fn func(*models.APIKey) error) error {
+	s.mu.RLock()
+	keys := sortedCopies(s.apiKeys, apiKeyKey, apiKeyCreatedAt)
+	for i, key := range keys {
+		keys[i] = copyAPIKey(key)
+	}
+	s.mu.RUnlock()
+
+	return each(ctx, keys, fn)
+}
+
+// RevokeAPIKey stops a key from being used
+func (s *Store) RevokeAPIKey(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.apiKeys[id]
+	if !ok {
+		return models.ErrAPIKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return models.ErrAPIKeyInactive
+	}
+	key.RevokedAt = now()
+	return nil
+}
+
+// RotateAPIKey stores replacement with the old key's name, scopes, IP ranges and expiry, and
+// retires the old key at retireAt or its own expiry, whichever is sooner
+func (s *Store) RotateAPIKey(ctx context.Context, id uuid.UUID, replacement *models.APIKey, retireAt time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.apiKeys[id]
+	if !ok {
+		return models.ErrAPIKeyNotFound
+	}
+	if !old.Active(time.Now()) {
+		return models.ErrAPIKeyInactive
+	}
+
+	replacement.Name = old.Name
+	replacement.Scopes = append([]string{}, old.Scopes...)
+	replacement.AllowedIPs = append([]string{}, old.AllowedIPs...)
+	replacement.ExpiresAt = old.ExpiresAt
+	if err := s.insertAPIKey(replacement); err != nil {
+		return err
+	}
+
+	if old.ExpiresAt == nil || retireAt.Before(*old.ExpiresAt) {
+		old.ExpiresAt = &retireAt
+	}
+	return nil
+}
+
+// TouchAPIKey records that a key was just used, and from where
+func (s *Store) TouchAPIKey(ctx context.Context, id uuid.UUID, ip string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.apiKeys[id]; ok {
+		key.LastUsedAt = now()
+		key.LastUsedIP = ip
+	}
+	return nil
+}
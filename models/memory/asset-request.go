@@ -0,0 +1,251 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func assetRequestKey(r *models.AssetRequest) uuid.UUID       { return r.ID }
+func assetRequestCreatedAt(r *models.AssetRequest) time.Time { return r.CreatedAt }
+
+// CreateAssetRequest files a request and routes it to the employee's manager,
+// or straight to asset managers when the employee has none
+func (s *Store) CreateAssetRequest(ctx context.Context, request *models.AssetRequest) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	employee, ok := s.employees[request.EmployeeID]
+	if !ok || employee.ArchivedAt != nil {
+		return models.ErrEmployeeNotFound
+	}
+
+	request.ManagerID = employee.ManagerID
+	request.ID = uuid.New()
+	request.CreatedAt = time.Now()
+	request.Status = models.AssetRequestPendingManager
+	if request.ManagerID == nil {
+		request.Status = models.AssetRequestPendingAssetManager
+	}
+
+	stored := *request
+	stored.ManagerDecidedAt = nil
+	stored.ApproverID = nil
+	stored.DecidedAt = nil
+	stored.RejectionReason = ""
+	stored.EmployeeAssetID = nil
+	stored.EscalatedBy = nil
+	stored.EscalationReason = ""
+	s.assetRequests[stored.ID] = &stored
+
+	return nil
+}
+
+// GetAssetRequestByID retrieves a request by its ID
+func (s *Store) GetAssetRequestByID(ctx context.Context, id uuid.UUID) (*models.AssetRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	request, ok := s.assetRequests[id]
+	if !ok {
+		return nil, models.ErrAssetRequestNotFound
+	}
+	c := *request
+	return &c, nil
+}
+
+// GetAllAssetRequests retrieves all requests, optionally only those in the given status
+func (s *Store) GetAllAssetRequests(ctx context.Context, status string) ([]*models.AssetRequest, error) {
+	var requests []*models.AssetRequest
+	err := s.ForEachAssetRequest(ctx, status, func(request *models.AssetRequest) error {
+		requests = append(requests, request)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// ForEachAssetRequest calls fn for each request, optionally only those in the given status, oldest first
+func (s *Store) ForEachAssetRequest(ctx context.Context, status string, fn func(*models.AssetRequest) error) error {
+	s.mu.RLock()
+	all := sortedCopies(s.assetRequests, assetRequestKey, assetRequestCreatedAt)
+	s.mu.RUnlock()
+
+	var requests []*models.AssetRequest
+	for _, request := range all {
+		if status == "" || request.Status == status {
+			requests = append(requests, request)
+		}
+	}
+
+	return each(ctx, requests, fn)
+}
+
+// openAssetRequest returns a request that is still awaiting a decision; the caller holds the lock
+func (s *Store) openAssetRequest(id uuid.UUID) (*models.AssetRequest, error) {
+	request, ok := s.assetRequests[id]
+	if !ok {
+		return nil, models.ErrAssetRequestNotFound
+	}
+	if request.Status == models.AssetRequestFulfilled || request.Status == models.AssetRequestRejected {
+		return nil, models.ErrAssetRequestClosed
+	}
+	return request, nil
+}
+
+// checkApprover verifies approverID, the authenticated employee or admin, may decide the request
+// at its current stage: the employee's manager first, then any active admin
+func (s *Store) checkApprover(request *models.AssetRequest, approverID uuid.UUID) error {
+	if request.Status == models.AssetRequestPendingManager {
+		if request.ManagerID == nil || *request.ManagerID != approverID {
+			return models.ErrNotApprover
+		}
+		return nil
+	}
+
+	return s.checkAdmin(approverID)
+}
+
+// checkAdmin verifies adminID is an active admin; the caller holds the lock
+func (s *Store) checkAdmin(adminID uuid.UUID) error {
+	if admin, ok := s.admins[adminID]; !ok || admin.ArchivedAt != nil {
+		return models.ErrNotApprover
+	}
+	return nil
+}
+
+// EscalateAssetRequest lets an active admin take a request waiting for a manager who cannot decide
+// it, such as one who has left or cannot log in, straight to the asset manager stage. The admin and
+// their reason are recorded on the request.
+func (s *Store) EscalateAssetRequest(ctx context.Context, id, adminID uuid.UUID, reason string) (*models.AssetRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, err := s.openAssetRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkAdmin(adminID); err != nil {
+		return nil, err
+	}
+	if request.Status != models.AssetRequestPendingManager {
+		return nil, models.ErrNotWaitingForManager
+	}
+
+	now := time.Now()
+	request.Status = models.AssetRequestPendingAssetManager
+	request.ManagerDecidedAt = &now
+	request.EscalatedBy = &adminID
+	request.EscalationReason = reason
+
+	c := *request
+	return &c, nil
+}
+
+// ApproveAssetRequest advances a request. The manager's approval hands it to asset managers; an asset
+// manager's approval fulfils it by assigning assetID, or the oldest available asset in the requested
+// category when assetID is nil.
+func (s *Store) ApproveAssetRequest(ctx context.Context, id, approverID uuid.UUID, assetID *uuid.UUID) (*models.AssetRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, err := s.openAssetRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkApprover(request, approverID); err != nil {
+		return nil, err
+	}
+
+	decidedAt := time.Now()
+
+	if request.Status == models.AssetRequestPendingManager {
+		request.Status = models.AssetRequestPendingAssetManager
+		request.ManagerDecidedAt = &decidedAt
+		c := *request
+		return &c, nil
+	}
+
+	var chosen *models.Asset
+	if assetID != nil {
+		if asset, ok := s.assets[*assetID]; ok && asset.Status == models.AssetStatusAvailable && asset.ArchivedAt == nil {
+			chosen = asset
+		}
+	} else {
+		for _, asset := range sortedCopies(s.assets, assetKey, assetCreatedAt) {
+			if asset.Category == request.Category && asset.Status == models.AssetStatusAvailable && asset.ArchivedAt == nil {
+				chosen = asset
+				break
+			}
+		}
+	}
+	if chosen == nil {
+		return nil, models.ErrNoAssetAvailable
+	}
+
+	assignment := &models.EmployeeAsset{AssetID: chosen.Id, EmployeeID: request.EmployeeID, CreatedAt: decidedAt}
+	if err := s.assignAsset(assignment); err != nil {
+		return nil, err
+	}
+
+	request.Status = models.AssetRequestFulfilled
+	request.ApproverID = &approverID
+	request.DecidedAt = &decidedAt
+	request.EmployeeAssetID = &assignment.ID
+
+	c := *request
+	return &c, nil
+}
+
+// RejectAssetRequest closes a request at its current stage and records why
+func (s *Store) RejectAssetRequest(ctx context.Context, id, approverID uuid.UUID, reason string) (*models.AssetRequest, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	request, err := s.openAssetRequest(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkApprover(request, approverID); err != nil {
+		return nil, err
+	}
+
+	decidedAt := time.Now()
+	if request.Status == models.AssetRequestPendingManager {
+		request.ManagerDecidedAt = &decidedAt
+	} else {
+		request.ApproverID = &approverID
+	}
+	request.Status = models.AssetRequestRejected
+	request.DecidedAt = &decidedAt
+	request.RejectionReason = reason
+
+	c := *request
+	return &c, nil
+}
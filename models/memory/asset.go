@@ -0,0 +1,196 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func assetKey(a *models.Asset) uuid.UUID       { return a.Id }
+func assetCreatedAt(a *models.Asset) time.Time { return a.CreatedAt }
+
+// checkAssetUnique enforces the partial unique indexes on non-empty tags and serials
+func (s *Store) checkAssetUnique(asset *models.Asset) error {
+	for _, other := range s.assets {
+		if other.Id == asset.Id {
+			continue
+		}
+		if asset.Tag != "" && other.Tag == asset.Tag {
+			return fmt.Errorf("%w: tag %q", ErrUniqueViolation, asset.Tag)
+		}
+		if asset.Serial != "" && other.Serial == asset.Serial {
+			return fmt.Errorf("%w: serial %q", ErrUniqueViolation, asset.Serial)
+		}
+	}
+	return nil
+}
+
+// assigned reports whether the asset has a current assignment; the caller holds the lock
+func (s *Store) assigned(assetID uuid.UUID) bool {
+	for _, mapping := range s.employeeAssets {
+		if mapping.AssetID == assetID && mapping.ArchivedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) CreateAsset(ctx context.Context, asset *models.Asset) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	asset.Id = uuid.New()
+	if asset.Status == "" {
+		asset.Status = models.AssetStatusAvailable
+	}
+	if !models.ValidAssetStatus(asset.Status) {
+		return models.ErrInvalidAssetStatus
+	}
+	if asset.Status == models.AssetStatusAssigned {
+		return models.ErrAssetStatusConflict
+	}
+
+	stored := *asset
+	stored.CreatedAt = time.Now()
+	stored.ArchivedAt = nil
+	if err := s.checkAssetUnique(&stored); err != nil {
+		return fmt.Errorf("error creating asset: %w", err)
+	}
+	s.assets[stored.Id] = &stored
+
+	return nil
+}
+
+func (s *Store) UpdateAsset(ctx context.Context, asset *models.Asset) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if asset.Status != "" && !models.ValidAssetStatus(asset.Status) {
+		return models.ErrInvalidAssetStatus
+	}
+
+	existing, ok := s.assets[asset.Id]
+	if !ok {
+		return nil
+	}
+	if asset.Status != "" && s.assigned(asset.Id) != (asset.Status == models.AssetStatusAssigned) {
+		return models.ErrAssetStatusConflict
+	}
+
+	updated := *existing
+	updated.Model = asset.Model
+	updated.Company = asset.Company
+	updated.Tag = asset.Tag
+	updated.Serial = asset.Serial
+	updated.Category = asset.Category
+	updated.Location = asset.Location
+	if asset.Status != "" {
+		updated.Status = asset.Status
+	}
+	if err := s.checkAssetUnique(&updated); err != nil {
+		return err
+	}
+	s.assets[asset.Id] = &updated
+
+	return nil
+}
+
+func (s *Store) ArchiveAsset(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if asset, ok := s.assets[id]; ok {
+		asset.ArchivedAt = now()
+	}
+	return nil
+}
+
+func (s *Store) GetAssetByID(ctx context.Context, id uuid.UUID) (*models.Asset, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	asset, ok := s.assets[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *asset
+	return &c, nil
+}
+
+func (s *Store) GetAllAssets(ctx context.Context) ([]*models.Asset, error) {
+	return s.GetAssets(ctx, models.AssetFilter{})
+}
+
+// GetAssets retrieves the assets matching filter
+func (s *Store) GetAssets(ctx context.Context, filter models.AssetFilter) ([]*models.Asset, error) {
+	var assets []*models.Asset
+	err := s.ForEachAsset(ctx, filter, func(asset *models.Asset) error {
+		assets = append(assets, asset)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return assets, nil
+}
+
+// ForEachAsset calls fn for each asset matching filter, oldest first
+func (s *Store) ForEachAsset(ctx context.Context, filter models.AssetFilter, fn func(*models.Asset) error) error {
+	s.mu.RLock()
+	all := sortedCopies(s.assets, assetKey, assetCreatedAt)
+	s.mu.RUnlock()
+
+	var assets []*models.Asset
+	for _, asset := range all {
+		if matchAsset(filter, asset) {
+			assets = append(assets, asset)
+		}
+	}
+
+	return each(ctx, assets, fn)
+}
+
+// ForEachAssignedAsset calls fn for each asset currently assigned to one employee, in the order
+// they were handed over
+func (s *Store) ForEachAssignedAsset(ctx context.Context, employeeID uuid.UUID, fn func(*models.Asset) error) error {
+	s.mu.RLock()
+	var assets []*models.Asset
+	for _, mapping := range sortedCopies(s.employeeAssets, employeeAssetKey, employeeAssetCreatedAt) {
+		if mapping.EmployeeID == employeeID && mapping.ArchivedAt == nil {
+			c := *s.assets[mapping.AssetID]
+			assets = append(assets, &c)
+		}
+	}
+	s.mu.RUnlock()
+
+	return each(ctx, assets, fn)
+}
+
+func matchAsset(f models.AssetFilter, asset *models.Asset) bool {
+	return (f.Status == "" || asset.Status == f.Status) &&
+		(f.Category == "" || asset.Category == f.Category) &&
+		(f.Location == "" || asset.Location == f.Location) &&
+		(f.Company == "" || asset.Company == f.Company)
+}
@@ -0,0 +1,247 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func auditKey(a *models.Audit) uuid.UUID       { return a.ID }
+func auditCreatedAt(a *models.Audit) time.Time { return a.CreatedAt }
+
+// CreateAudit opens a new audit campaign
+func (s *Store) CreateAudit(ctx context.Context, audit *models.Audit) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	audit.ID = uuid.New()
+	audit.Status = models.AuditOpen
+	audit.CreatedAt = time.Now()
+
+	stored := *audit
+	stored.ClosedAt = nil
+	s.audits[stored.ID] = &stored
+
+	return nil
+}
+
+// GetAuditByID retrieves an audit by its ID
+func (s *Store) GetAuditByID(ctx context.Context, id uuid.UUID) (*models.Audit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	audit, ok := s.audits[id]
+	if !ok {
+		return nil, models.ErrAuditNotFound
+	}
+	c := *audit
+	return &c, nil
+}
+
+// GetAllAudits retrieves all audits, newest first
+func (s *Store) GetAllAudits(ctx context.Context) ([]*models.Audit, error) {
+	var audits []*models.Audit
+	err := s.ForEachAudit(ctx, func(audit *models.Audit) error {
+		audits = append(audits, audit)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return audits, nil
+}
+
+// ForEachAudit calls fn for each audit, newest first
+func (s *Store) ForEachAudit(ctx context.Context, fn func(*models.Audit) error) error {
+	s.mu.RLock()
+	audits := sortedCopies(s.audits, auditKey, auditCreatedAt)
+	s.mu.RUnlock()
+
+	for i, j := 0, len(audits)-1; i < j; i, j = i+1, j-1 {
+		audits[i], audits[j] = audits[j], audits[i]
+	}
+
+	return each(ctx, audits, fn)
+}
+
+// RecordScan looks up the scanned tag or serial number and stores the classified result; a code
+// that is one asset's tag and another's serial number is read as the tag
+func (s *Store) RecordScan(ctx context.Context, scan *models.AuditScan) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	audit, ok := s.audits[scan.AuditID]
+	if !ok {
+		return models.ErrAuditNotFound
+	}
+	if audit.Status != models.AuditOpen {
+		return models.ErrAuditClosed
+	}
+
+	// A tag match wins over a serial number match
+	var asset *models.Asset
+	if scan.Code != "" {
+		for _, candidate := range sortedCopies(s.assets, assetKey, assetCreatedAt) {
+			if candidate.ArchivedAt != nil {
+				continue
+			}
+			if candidate.Tag == scan.Code {
+				asset = candidate
+				break
+			}
+			if candidate.Serial == scan.Code && asset == nil {
+				asset = candidate
+			}
+		}
+	}
+
+	scan.ID = uuid.New()
+	scan.ScannedAt = time.Now()
+	scan.Result = models.ClassifyScan(audit, asset, scan.Location)
+	scan.AssetID = nil
+	if asset != nil {
+		scan.AssetID = &asset.Id
+	}
+
+	stored := *scan
+	s.scans = append(s.scans, &stored)
+
+	return nil
+}
+
+// GetAuditReport reconciles the scans recorded so far against the audit's scope
+func (s *Store) GetAuditReport(ctx context.Context, id uuid.UUID) (*models.AuditReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	audit, ok := s.audits[id]
+	if !ok {
+		return nil, models.ErrAuditNotFound
+	}
+	c := *audit
+	return s.buildAuditReport(&c), nil
+}
+
+// buildAuditReport sorts the audit's scans and lists what was never seen; the caller holds the lock
+func (s *Store) buildAuditReport(audit *models.Audit) *models.AuditReport {
+	report := &models.AuditReport{Audit: audit}
+	seen := make(map[uuid.UUID]bool)
+
+	// An asset scanned more than once, or an unknown code read again, is reported by its latest scan
+	scanKey := func(scan *models.AuditScan) string {
+		if scan.AssetID != nil {
+			return scan.AssetID.String()
+		}
+		return scan.Code
+	}
+	latest := make(map[string]int)
+	for i, scan := range s.scans {
+		if scan.AuditID == audit.ID {
+			latest[scanKey(scan)] = i
+		}
+	}
+
+	// Scans are appended as they are recorded, so they are already in scan order
+	for i, scan := range s.scans {
+		if scan.AuditID != audit.ID || latest[scanKey(scan)] != i {
+			continue
+		}
+		if scan.AssetID != nil {
+			seen[*scan.AssetID] = true
+		}
+
+		c := *scan
+		switch c.Result {
+		case models.ScanFound:
+			report.Found = append(report.Found, &c)
+		case models.ScanWrongLocation:
+			report.WrongLocation = append(report.WrongLocation, &c)
+		default:
+			report.Unexpected = append(report.Unexpected, &c)
+		}
+	}
+
+	// Anything in scope that was never seen, wherever it was scanned, is missing
+	for _, asset := range s.assets {
+		if asset.ArchivedAt != nil || seen[asset.Id] ||
+			asset.Status == models.AssetStatusLost || asset.Status == models.AssetStatusWrittenOff ||
+			(audit.Location != "" && asset.Location != audit.Location) ||
+			(audit.Category != "" && asset.Category != audit.Category) {
+			continue
+		}
+		c := *asset
+		report.Missing = append(report.Missing, &c)
+	}
+	sort.Slice(report.Missing, func(i, j int) bool {
+		a, b := report.Missing[i], report.Missing[j]
+		if a.Tag != b.Tag {
+			return a.Tag < b.Tag
+		}
+		return a.Serial < b.Serial
+	})
+
+	return report
+}
+
+// CloseAudit closes the audit and returns its final report. When markMissingLost is set every
+// missing asset is flagged as lost, and whoever held one no longer does.
+func (s *Store) CloseAudit(ctx context.Context, id uuid.UUID, markMissingLost bool) (*models.AuditReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	audit, ok := s.audits[id]
+	if !ok {
+		return nil, models.ErrAuditNotFound
+	}
+	if audit.Status != models.AuditOpen {
+		return nil, models.ErrAuditClosed
+	}
+
+	closedAt := time.Now()
+	audit.Status = models.AuditClosed
+	audit.ClosedAt = &closedAt
+
+	c := *audit
+	report := s.buildAuditReport(&c)
+
+	if markMissingLost && len(report.Missing) > 0 {
+		for _, asset := range report.Missing {
+			asset.Status = models.AssetStatusLost
+			s.assets[asset.Id].Status = models.AssetStatusLost
+			for _, mapping := range s.employeeAssets {
+				if mapping.AssetID == asset.Id && mapping.ArchivedAt == nil {
+					archivedAt := closedAt
+					mapping.ArchivedAt = &archivedAt
+				}
+			}
+		}
+		report.MarkedLost = true
+	}
+
+	return report, nil
+}
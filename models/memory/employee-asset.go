@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func employeeAssetKey(m *models.EmployeeAsset) uuid.UUID       { return m.ID }
+func employeeAssetCreatedAt(m *models.EmployeeAsset) time.Time { return m.CreatedAt }
+
+// checkAssignment enforces the mapping's asset and employee foreign keys
+func (s *Store) checkAssignment(employeeAsset *models.EmployeeAsset) error {
+	if _, ok := s.assets[employeeAsset.AssetID]; !ok {
+		return fmt.Errorf("%w: asset %s", ErrForeignKeyViolation, employeeAsset.AssetID)
+	}
+	if _, ok := s.employees[employeeAsset.EmployeeID]; !ok {
+		return fmt.Errorf("%w: employee %s", ErrForeignKeyViolation, employeeAsset.EmployeeID)
+	}
+	return nil
+}
+
+func (s *Store) CreateEmployeeAsset(ctx context.Context, employeeAsset *models.EmployeeAsset) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.assignAsset(employeeAsset)
+}
+
+// checkAssignee returns ErrEmployeeNotFound for a missing or archived employee, or
+// ErrEmployeeOffboarding while their offboarding is open; the caller holds the lock
+func (s *Store) checkAssignee(employeeID uuid.UUID) error {
+	employee, ok := s.employees[employeeID]
+	if !ok || employee.ArchivedAt != nil {
+		return models.ErrEmployeeNotFound
+	}
+	if offboarding := s.latestOffboarding(employeeID); offboarding != nil && offboarding.CompletedAt == nil {
+		return models.ErrEmployeeOffboarding
+	}
+	return nil
+}
+
+// assignAsset inserts the mapping and marks the asset as assigned; the caller holds the lock.
+// The asset must be in stock, so it is never with two people at once.
+func (s *Store) assignAsset(employeeAsset *models.EmployeeAsset) error {
+	if err := s.checkAssignee(employeeAsset.EmployeeID); err != nil {
+		return err
+	}
+	asset, ok := s.assets[employeeAsset.AssetID]
+	if !ok || asset.ArchivedAt != nil || asset.Status != models.AssetStatusAvailable {
+		return models.ErrAssetUnavailable
+	}
+
+	employeeAsset.ID = uuid.New()
+
+	stored := *employeeAsset
+	stored.ArchivedAt = nil
+	s.employeeAssets[stored.ID] = &stored
+	asset.Status = models.AssetStatusAssigned
+
+	return nil
+}
+
+func (s *Store) UpdateEmployeeAsset(ctx context.Context, employeeAsset *models.EmployeeAsset) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.employeeAssets[employeeAsset.ID]
+	if !ok {
+		return nil
+	}
+	if err := s.checkAssignment(employeeAsset); err != nil {
+		return err
+	}
+
+	existing.AssetID = employeeAsset.AssetID
+	existing.EmployeeID = employeeAsset.EmployeeID
+	existing.CreatedAt = employeeAsset.CreatedAt
+	return nil
+}
+
+// ArchiveEmployeeAsset ends an assignment and hands the asset back to stock if it is still assigned
+func (s *Store) ArchiveEmployeeAsset(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mapping, ok := s.employeeAssets[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	mapping.ArchivedAt = now()
+
+	if asset := s.assets[mapping.AssetID]; asset.Status == models.AssetStatusAssigned {
+		asset.Status = models.AssetStatusAvailable
+	}
+	return nil
+}
+
+func (s *Store) GetEmployeeAssetByID(ctx context.Context, id uuid.UUID) (*models.EmployeeAsset, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mapping, ok := s.employeeAssets[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *mapping
+	return &c, nil
+}
+
+func (s *Store) GetAllEmployeeAssets(ctx context.Context) ([]*models.EmployeeAsset, error) {
+	var employeeAssets []*models.EmployeeAsset
+	err := s.ForEachEmployeeAsset(ctx, func(employeeAsset *models.EmployeeAsset) error {
+		employeeAssets = append(employeeAssets, employeeAsset)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return employeeAssets, nil
+}
+
+// ForEachEmployeeAsset calls fn for each assignment, oldest first
+func (s *Store) ForEachEmployeeAsset(ctx context.Context, fn func(*models.EmployeeAsset) error) error {
+	s.mu.RLock()
+	employeeAssets := sortedCopies(s.employeeAssets, employeeAssetKey, employeeAssetCreatedAt)
+	s.mu.RUnlock()
+
+	return each(ctx, employeeAssets, fn)
+}
@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func employeeKey(e *models.Employee) uuid.UUID       { return e.ID }
+func employeeCreatedAt(e *models.Employee) time.Time { return e.CreatedAt }
+
+// checkManager enforces the manager_id foreign key
+func (s *Store) checkManager(employee *models.Employee) error {
+	if employee.ManagerID == nil {
+		return nil
+	}
+	if _, ok := s.employees[*employee.ManagerID]; !ok && *employee.ManagerID != employee.ID {
+		return fmt.Errorf("%w: manager %s", ErrForeignKeyViolation, *employee.ManagerID)
+	}
+	return nil
+}
+
+// checkEmailUnique enforces the unique index on the email addresses of active employees, ignoring case
+func (s *Store) checkEmailUnique(employee *models.Employee) error {
+	for _, other := range s.employees {
+		if other.ID != employee.ID && other.ArchivedAt == nil && strings.EqualFold(other.Email, employee.Email) {
+			return fmt.Errorf("%w: email %q", ErrUniqueViolation, employee.Email)
+		}
+	}
+	return nil
+}
+
+// CreateEmployee stores a new employee with the CreatedAt the caller supplied
+func (s *Store) CreateEmployee(ctx context.Context, employee *models.Employee) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	employee.ID = uuid.New()
+
+	stored := *employee
+	stored.ArchivedAt = nil
+	if err := s.checkManager(&stored); err != nil {
+		return err
+	}
+	if err := s.checkEmailUnique(&stored); err != nil {
+		return err
+	}
+	s.employees[stored.ID] = &stored
+
+	return nil
+}
+
+func (s *Store) UpdateEmployee(ctx context.Context, employee *models.Employee) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.employees[employee.ID]
+	if !ok {
+		return nil
+	}
+	if err := s.checkManager(employee); err != nil {
+		return err
+	}
+	if err := s.checkEmailUnique(employee); err != nil {
+		return err
+	}
+
+	existing.Name = employee.Name
+	existing.Email = employee.Email
+	existing.Role = employee.Role
+	existing.Department = employee.Department
+	existing.ManagerID = employee.ManagerID
+	return nil
+}
+
+// ArchiveEmployee archives an employee who holds no assets
+func (s *Store) ArchiveEmployee(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.countActiveAssets(id) > 0 {
+		return models.ErrEmployeeHasAssets
+	}
+	if employee, ok := s.employees[id]; ok {
+		employee.ArchivedAt = now()
+	}
+	return nil
+}
+
+// CountActiveAssets returns how many assets are currently assigned to an employee
+func (s *Store) CountActiveAssets(ctx context.Context, id uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.countActiveAssets(id), nil
+}
+
+func (s *Store) countActiveAssets(employeeID uuid.UUID) int {
+	count := 0
+	for _, mapping := range s.employeeAssets {
+		if mapping.EmployeeID == employeeID && mapping.ArchivedAt == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *Store) GetEmployeeByID(ctx context.Context, id uuid.UUID) (*models.Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	employee, ok := s.employees[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *employee
+	return &c, nil
+}
+
+// GetEmployeeByEmail returns the oldest active employee with the email address, ignoring case
+func (s *Store) GetEmployeeByEmail(ctx context.Context, email string) (*models.Employee, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	employees := sortedCopies(s.employees, employeeKey, employeeCreatedAt)
+	s.mu.RUnlock()
+
+	for _, employee := range employees {
+		if employee.ArchivedAt == nil && strings.EqualFold(employee.Email, email) {
+			return employee, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *Store) GetAllEmployees(ctx context.Context) ([]*models.Employee, error) {
+	var employees []*models.Employee
+	err := s.ForEachEmployee(ctx, func(employee *models.Employee) error {
+		employees = append(employees, employee)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return employees, nil
+}
+
+// ForEachEmployee calls fn for each employee, oldest first
+func (s *Store) ForEachEmployee(ctx context.Context, fn func(*models.Employee) error) error {
+	s.mu.RLock()
+	employees := sortedCopies(s.employees, employeeKey, employeeCreatedAt)
+	s.mu.RUnlock()
+
+	return each(ctx, employees, fn)
+}
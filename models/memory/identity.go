@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// identityKey is the primary key of an identity
+type identityKey struct {
+	issuer, subject string
+}
+
+// GetIdentity returns the identity with a subject at an issuer
+func (s *Store) GetIdentity(ctx context.Context, issuer, subject string) (*models.Identity, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.identities[identityKey{issuer, subject}]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *identity
+	return &c, nil
+}
+
+// CreateIdentity links an identity to an existing admin or employee
+func (s *Store) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identityKey{identity.Issuer, identity.Subject}
+	if _, ok := s.identities[key]; ok {
+		return models.ErrIdentityExists
+	}
+	if (identity.AdminID == nil) == (identity.EmployeeID == nil) {
+		return fmt.Errorf("%w: an identity belongs to an admin or an employee", ErrCheckViolation)
+	}
+	if identity.AdminID != nil {
+		if _, ok := s.admins[*identity.AdminID]; !ok {
+			return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, *identity.AdminID)
+		}
+	}
+	if identity.EmployeeID != nil {
+		if _, ok := s.employees[*identity.EmployeeID]; !ok {
+			return fmt.Errorf("%w: employee %s", ErrForeignKeyViolation, *identity.EmployeeID)
+		}
+	}
+
+	identity.CreatedAt = time.Now()
+	stored := *identity
+	s.identities[key] = &stored
+	return nil
+}
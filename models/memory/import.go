@@ -0,0 +1,357 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// Import validates rows and, unless dryRun is set or any row is invalid, merges them. It runs the
+// same checks as the Postgres model, which does most of them in SQL against the staged rows.
+func (s *Store) Import(ctx context.Context, kind string, rows []models.ImportRow, dryRun bool) (*models.ImportReport, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	errs, err := models.ValidateImport(kind, rows)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.ImportReport{Kind: kind, DryRun: dryRun, Rows: len(rows), Errors: errs}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var merge importMerge
+	switch kind {
+	case "assets":
+		merge = s.importAssets
+	case "employees":
+		merge = s.importEmployees
+	default:
+		merge = s.importEmployeeAssets
+	}
+
+	conflicts, existing, apply := merge(rows)
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Line < conflicts[j].Line })
+	report.Errors = append(report.Errors, conflicts...)
+
+	updates := kind != "employeeassets"
+	if dryRun || len(report.Errors) > 0 {
+		if updates {
+			report.Updated = existing
+		} else {
+			report.Unchanged = existing
+		}
+		report.Inserted = int64(len(rows)) - existing
+		return report, nil
+	}
+
+	updated, inserted, err := apply()
+	if err != nil {
+		return nil, err
+	}
+	if updates {
+		report.Updated = updated
+	} else {
+		report.Unchanged = existing
+	}
+	report.Inserted = inserted
+	report.Committed = true
+
+	return report, nil
+}
+
+// importMerge checks rows against the store and returns the conflicts, how many rows match
+// existing data, and a function that applies the import. The caller holds the lock.
+type importMerge func(rows []models.ImportRow) ([]models.ImportError, int64, func() (int64, int64, error))
+
+func (s *Store) assetsBySerial(serial string) []*models.Asset {
+	var assets []*models.Asset
+	for _, asset := range s.assets {
+		if asset.Serial == serial {
+			assets = append(assets, asset)
+		}
+	}
+	return assets
+}
+
+// activeEmployeesByEmail returns the employees who have not been archived with the email address,
+// ignoring case. A leaver's row keeps their address, and a file must not update or manage through it.
+func (s *Store) activeEmployeesByEmail(email string) []*models.Employee {
+	var employees []*models.Employee
+	for _, employee := range s.employees {
+		if employee.ArchivedAt == nil && strings.EqualFold(employee.Email, email) {
+			employees = append(employees, employee)
+		}
+	}
+	return employees
+}
+
+// activeAssignment returns the asset's current assignment, or nil
+func (s *Store) activeAssignment(assetID uuid.UUID) *models.EmployeeAsset {
+	for _, mapping := range s.employeeAssets {
+		if mapping.AssetID == assetID && mapping.ArchivedAt == nil {
+			return mapping
+		}
+	}
+	return nil
+}
+
+// coalesce keeps the stored value when the imported cell is empty
+func coalesce(value, stored string) string {
+	if value == "" {
+		return stored
+	}
+	return value
+}
+
+func (s *Store) importAssets(rows []models.ImportRow) ([]models.ImportError, int64, func() (int64, int64, error)) {
+	var errs []models.ImportError
+	var existing int64
+
+	// Repeated serials are the file's key, which models.ValidateImport already reports
+	tagLines := make(map[string]int)
+	for _, row := range rows {
+		v := row.Values
+		if v["tag"] != "" {
+			for _, asset := range s.assets {
+				if asset.Tag == v["tag"] && asset.Serial != v["serial"] {
+					errs = append(errs, models.ImportError{Line: row.Line, Field: "tag", Message: "tag already belongs to asset " + asset.Serial})
+				}
+			}
+			if first, ok := tagLines[v["tag"]]; ok {
+				errs = append(errs, models.ImportError{Line: row.Line, Field: "tag", Message: fmt.Sprintf("duplicate of line %d", first)})
+			} else {
+				tagLines[v["tag"]] = row.Line
+			}
+		}
+
+		// The status has to agree with the assignments, which only employeeassets imports change
+		var assignment *models.EmployeeAsset
+		matches := s.assetsBySerial(v["serial"])
+		for _, asset := range matches {
+			if mapping := s.activeAssignment(asset.Id); mapping != nil {
+				assignment = mapping
+			}
+		}
+		switch {
+		case v["status"] == models.AssetStatusAssigned && assignment == nil:
+			errs = append(errs, models.ImportError{Line: row.Line, Field: "status", Message: "asset has no assignment; assign it with an employeeassets import"})
+		case v["status"] == models.AssetStatusAvailable && assignment != nil:
+			errs = append(errs, models.ImportError{Line: row.Line, Field: "status",
+				Message: "asset is assigned to " + s.employees[assignment.EmployeeID].Email + "; end the assignment first"})
+		}
+
+		if len(matches) > 0 {
+			existing++
+		}
+	}
+
+	apply := func() (int64, int64, error) {
+		// Work on copies so a unique violation leaves the store untouched
+		next := make(map[uuid.UUID]*models.Asset, len(s.assets))
+		for id, asset := range s.assets {
+			c := *asset
+			next[id] = &c
+		}
+
+		var updated, inserted int64
+		createdAt := time.Now()
+		for _, row := range rows {
+			v := row.Values
+			matches := s.assetsBySerial(v["serial"])
+			for _, match := range matches {
+				asset := next[match.Id]
+				asset.Model = v["model"]
+				asset.Company = v["company"]
+				asset.Tag = coalesce(v["tag"], asset.Tag)
+				asset.Category = coalesce(v["category"], asset.Category)
+				asset.Location = coalesce(v["location"], asset.Location)
+				asset.Status = coalesce(v["status"], asset.Status)
+				updated++
+			}
+			if len(matches) > 0 {
+				continue
+			}
+
+			asset := &models.Asset{
+				Id:        uuid.New(),
+				Model:     v["model"],
+				Company:   v["company"],
+				Tag:       v["tag"],
+				Serial:    v["serial"],
+				Category:  v["category"],
+				Location:  v["location"],
+				Status:    coalesce(v["status"], models.AssetStatusAvailable),
+				CreatedAt: createdAt,
+			}
+			next[asset.Id] = asset
+			inserted++
+		}
+
+		tags := make(map[string]bool)
+		serials := make(map[string]bool)
+		for _, asset := range next {
+			if asset.Tag != "" && tags[asset.Tag] || asset.Serial != "" && serials[asset.Serial] {
+				return 0, 0, fmt.Errorf("%w: asset %q", ErrUniqueViolation, asset.Serial)
+			}
+			tags[asset.Tag] = true
+			serials[asset.Serial] = true
+		}
+
+		s.assets = next
+		return updated, inserted, nil
+	}
+
+	return errs, existing, apply
+}
+
+func (s *Store) importEmployees(rows []models.ImportRow) ([]models.ImportError, int64, func() (int64, int64, error)) {
+	var errs []models.ImportError
+	var existing int64
+
+	inFile := make(map[string]bool)
+	for _, row := range rows {
+		inFile[strings.ToLower(row.Values["email"])] = true
+	}
+
+	for _, row := range rows {
+		v := row.Values
+		if manager := v["manager_email"]; manager != "" && len(s.activeEmployeesByEmail(manager)) == 0 && !inFile[strings.ToLower(manager)] {
+			errs = append(errs, models.ImportError{Line: row.Line, Field: "manager_email", Message: "no employee with email " + manager})
+		}
+		if len(s.activeEmployeesByEmail(v["email"])) > 0 {
+			existing++
+		}
+	}
+
+	apply := func() (int64, int64, error) {
+		var updated, inserted int64
+		createdAt := time.Now()
+
+		// Decide which rows insert before touching anything, as the INSERT ... WHERE NOT EXISTS does
+		var inserts []models.ImportRow
+		for _, row := range rows {
+			v := row.Values
+			matches := s.activeEmployeesByEmail(v["email"])
+			for _, employee := range matches {
+				employee.Name = v["name"]
+				employee.Role = coalesce(v["role"], employee.Role)
+				employee.Department = coalesce(v["department"], employee.Department)
+				updated++
+			}
+			if len(matches) == 0 {
+				inserts = append(inserts, row)
+			}
+		}
+		for _, row := range inserts {
+			v := row.Values
+			employee := &models.Employee{
+				ID:         uuid.New(),
+				Name:       v["name"],
+				Email:      v["email"],
+				Role:       v["role"],
+				Department: v["department"],
+				CreatedAt:  createdAt,
+			}
+			s.employees[employee.ID] = employee
+			inserted++
+		}
+
+		// Managers are linked once every employee in the file exists
+		for _, row := range rows {
+			v := row.Values
+			if v["manager_email"] == "" {
+				continue
+			}
+			managers := s.activeEmployeesByEmail(v["manager_email"])
+			if len(managers) == 0 {
+				continue
+			}
+			managerID := managers[0].ID
+			for _, employee := range s.activeEmployeesByEmail(v["email"]) {
+				id := managerID
+				employee.ManagerID = &id
+			}
+		}
+
+		return updated, inserted, nil
+	}
+
+	return errs, existing, apply
+}
+
+func (s *Store) activeAssetBySerial(serial string) *models.Asset {
+	for _, asset := range s.assetsBySerial(serial) {
+		if asset.ArchivedAt == nil {
+			return asset
+		}
+	}
+	return nil
+}
+
+func (s *Store) activeEmployeeByEmail(email string) *models.Employee {
+	if employees := s.activeEmployeesByEmail(email); len(employees) > 0 {
+		return employees[0]
+	}
+	return nil
+}
+
+func (s *Store) importEmployeeAssets(rows []models.ImportRow) ([]models.ImportError, int64, func() (int64, int64, error)) {
+	var errs []models.ImportError
+	var existing int64
+
+	for _, row := range rows {
+		v := row.Values
+		if s.activeAssetBySerial(v["asset_serial"]) == nil {
+			errs = append(errs, models.ImportError{Line: row.Line, Field: "asset_serial", Message: "no asset with serial " + v["asset_serial"]})
+		}
+		if s.activeEmployeeByEmail(v["employee_email"]) == nil {
+			errs = append(errs, models.ImportError{Line: row.Line, Field: "employee_email", Message: "no employee with email " + v["employee_email"]})
+		}
+		for _, asset := range s.assetsBySerial(v["asset_serial"]) {
+			mapping := s.activeAssignment(asset.Id)
+			if mapping == nil {
+				continue
+			}
+			holder, ok := s.employees[mapping.EmployeeID]
+			if !ok {
+				continue
+			}
+			if strings.EqualFold(holder.Email, v["employee_email"]) {
+				// Assignments that already exist are left as they are, which makes re-running a file safe
+				existing++
+			} else {
+				errs = append(errs, models.ImportError{Line: row.Line, Field: "asset_serial", Message: "asset is assigned to " + holder.Email})
+			}
+		}
+	}
+
+	apply := func() (int64, int64, error) {
+		var inserted int64
+		createdAt := time.Now()
+
+		for _, row := range rows {
+			asset := s.activeAssetBySerial(row.Values["asset_serial"])
+			employee := s.activeEmployeeByEmail(row.Values["employee_email"])
+			if asset == nil || employee == nil || s.activeAssignment(asset.Id) != nil {
+				continue
+			}
+			if err := s.assignAsset(&models.EmployeeAsset{AssetID: asset.Id, EmployeeID: employee.ID, CreatedAt: createdAt}); err != nil {
+				return 0, 0, err
+			}
+			inserted++
+		}
+
+		return 0, inserted, nil
+	}
+
+	return errs, existing, apply
+}
@@ -0,0 +1,273 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func kitKey(k *models.Kit) uuid.UUID       { return k.ID }
+func kitCreatedAt(k *models.Kit) time.Time { return k.CreatedAt }
+
+// copyKit copies a kit with its items in category order, as getKitItems returns them
+func copyKit(kit *models.Kit) *models.Kit {
+	c := *kit
+	c.Items = nil
+	if len(kit.Items) > 0 {
+		c.Items = append([]models.KitItem(nil), kit.Items...)
+		sort.SliceStable(c.Items, func(i, j int) bool { return c.Items[i].Category < c.Items[j].Category })
+	}
+	return &c
+}
+
+// checkKit enforces the unique kit name and the positive quantity check
+func (s *Store) checkKit(kit *models.Kit) error {
+	for _, other := range s.kits {
+		if other.ID != kit.ID && other.Name == kit.Name {
+			return fmt.Errorf("%w: name %q", ErrUniqueViolation, kit.Name)
+		}
+	}
+	for _, item := range kit.Items {
+		if item.Quantity <= 0 {
+			return fmt.Errorf("error adding kit item %q: %w", item.Category, ErrCheckViolation)
+		}
+	}
+	return nil
+}
+
+// CreateKit creates a new kit and its items
+func (s *Store) CreateKit(ctx context.Context, kit *models.Kit) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kit.ID = uuid.New()
+	kit.CreatedAt = time.Now()
+
+	err := s.checkKit(kit)
+	if errors.Is(err, ErrUniqueViolation) {
+		return fmt.Errorf("error creating kit: %w", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	stored := *kit
+	stored.ArchivedAt = nil
+	stored.Items = append([]models.KitItem(nil), kit.Items...)
+	s.kits[stored.ID] = &stored
+
+	return nil
+}
+
+// UpdateKit replaces a kit's matching rules and items
+func (s *Store) UpdateKit(ctx context.Context, kit *models.Kit) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.kits[kit.ID]
+	if !ok {
+		// The update matches nothing, but items still cannot reference a missing kit
+		if len(kit.Items) > 0 {
+			return fmt.Errorf("error adding kit item %q: %w", kit.Items[0].Category, ErrForeignKeyViolation)
+		}
+		return nil
+	}
+	if err := s.checkKit(kit); err != nil {
+		return err
+	}
+
+	existing.Name = kit.Name
+	existing.Role = kit.Role
+	existing.Department = kit.Department
+	existing.Items = append([]models.KitItem(nil), kit.Items...)
+	return nil
+}
+
+// ArchiveKit archives an existing kit
+func (s *Store) ArchiveKit(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if kit, ok := s.kits[id]; ok {
+		kit.ArchivedAt = now()
+	}
+	return nil
+}
+
+// GetKitByID retrieves a kit and its items by ID
+func (s *Store) GetKitByID(ctx context.Context, id uuid.UUID) (*models.Kit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	kit, ok := s.kits[id]
+	if !ok {
+		return nil, models.ErrKitNotFound
+	}
+	return copyKit(kit), nil
+}
+
+// GetKitByName retrieves an active kit and its items by name
+func (s *Store) GetKitByName(ctx context.Context, name string) (*models.Kit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.kitByName(name)
+}
+
+func (s *Store) kitByName(name string) (*models.Kit, error) {
+	for _, kit := range s.kits {
+		if kit.Name == name && kit.ArchivedAt == nil {
+			return copyKit(kit), nil
+		}
+	}
+	return nil, models.ErrKitNotFound
+}
+
+// GetKitForEmployee finds the active kit matching an employee's role, falling back to their department
+func (s *Store) GetKitForEmployee(ctx context.Context, employee *models.Employee) (*models.Kit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.kitForEmployee(employee)
+}
+
+func (s *Store) kitForEmployee(employee *models.Employee) (*models.Kit, error) {
+	var byDepartment *models.Kit
+	for _, kit := range sortedCopies(s.kits, kitKey, kitCreatedAt) {
+		if kit.ArchivedAt != nil {
+			continue
+		}
+		if kit.Role != "" && kit.Role == employee.Role {
+			return copyKit(kit), nil
+		}
+		if byDepartment == nil && kit.Department != "" && kit.Department == employee.Department {
+			byDepartment = kit
+		}
+	}
+	if byDepartment == nil {
+		return nil, models.ErrKitNotFound
+	}
+	return copyKit(byDepartment), nil
+}
+
+// GetAllKits retrieves all kits with their items, ordered by name
+func (s *Store) GetAllKits(ctx context.Context) ([]*models.Kit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var kits []*models.Kit
+	for _, kit := range s.kits {
+		kits = append(kits, copyKit(kit))
+	}
+	sort.Slice(kits, func(i, j int) bool { return kits[i].Name < kits[j].Name })
+
+	return kits, nil
+}
+
+// OnboardEmployee assigns available assets for every kit item. As with the Postgres model,
+// a strict onboarding with shortfalls is returned alongside ErrKitShortfall and nothing is assigned.
+func (s *Store) OnboardEmployee(ctx context.Context, employeeID uuid.UUID, kitName string, strict bool) (*models.Onboarding, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkAssignee(employeeID); err != nil {
+		return nil, err
+	}
+	employee := s.employees[employeeID]
+
+	var kit *models.Kit
+	var err error
+	if kitName != "" {
+		kit, err = s.kitByName(kitName)
+	} else {
+		kit, err = s.kitForEmployee(employee)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	onboarding := &models.Onboarding{EmployeeID: employeeID, Kit: kit.Name}
+	assignedAt := time.Now()
+	picked := make(map[uuid.UUID]bool)
+	stock := sortedCopies(s.assets, assetKey, assetCreatedAt)
+
+	for _, item := range kit.Items {
+		assigned := 0
+		for _, asset := range stock {
+			if assigned == item.Quantity {
+				break
+			}
+			if asset.Category != item.Category || asset.Status != models.AssetStatusAvailable ||
+				asset.ArchivedAt != nil || picked[asset.Id] {
+				continue
+			}
+			picked[asset.Id] = true
+			onboarding.Assignments = append(onboarding.Assignments, &models.EmployeeAsset{
+				ID:         uuid.New(),
+				AssetID:    asset.Id,
+				EmployeeID: employeeID,
+				CreatedAt:  assignedAt,
+			})
+			assigned++
+		}
+
+		if assigned < item.Quantity {
+			onboarding.Shortfalls = append(onboarding.Shortfalls, models.KitShortfall{
+				Category:  item.Category,
+				Requested: item.Quantity,
+				Assigned:  assigned,
+			})
+		}
+	}
+
+	if strict && len(onboarding.Shortfalls) > 0 {
+		onboarding.Assignments = nil
+		return onboarding, models.ErrKitShortfall
+	}
+
+	for _, assignment := range onboarding.Assignments {
+		stored := *assignment
+		s.employeeAssets[stored.ID] = &stored
+		s.assets[stored.AssetID].Status = models.AssetStatusAssigned
+	}
+
+	return onboarding, nil
+}
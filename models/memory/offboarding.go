@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// offboardingAssetStatus maps a resolved item to the status its asset should end up in
+var offboardingAssetStatus = map[string]string{
+	models.OffboardingItemReturned:   models.AssetStatusAvailable,
+	models.OffboardingItemLost:       models.AssetStatusLost,
+	models.OffboardingItemWrittenOff: models.AssetStatusWrittenOff,
+}
+
+func copyOffboarding(offboarding *models.Offboarding) *models.Offboarding {
+	c := *offboarding
+	c.Items = nil
+	for _, item := range offboarding.Items {
+		itemCopy := *item
+		c.Items = append(c.Items, &itemCopy)
+	}
+	return &c
+}
+
+// latestOffboarding returns the employee's most recent offboarding, or nil
+func (s *Store) latestOffboarding(employeeID uuid.UUID) *models.Offboarding {
+	var latest *models.Offboarding
+	for _, offboarding := range s.offboardings {
+		if offboarding.EmployeeID != employeeID {
+			continue
+		}
+		if latest == nil || offboarding.CreatedAt.After(latest.CreatedAt) {
+			latest = offboarding
+		}
+	}
+	return latest
+}
+
+// StartOffboarding opens a checklist with one item per asset currently assigned to the employee.
+// If an offboarding is already in progress it is returned unchanged.
+func (s *Store) StartOffboarding(ctx context.Context, employeeID uuid.UUID) (*models.Offboarding, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing := s.latestOffboarding(employeeID); existing != nil && existing.CompletedAt == nil {
+		return copyOffboarding(existing), nil
+	}
+	if _, ok := s.employees[employeeID]; !ok {
+		return nil, fmt.Errorf("error creating offboarding: %w: employee %s", ErrForeignKeyViolation, employeeID)
+	}
+
+	offboarding := &models.Offboarding{
+		ID:         uuid.New(),
+		EmployeeID: employeeID,
+		CreatedAt:  time.Now(),
+	}
+
+	for _, mapping := range sortedCopies(s.employeeAssets, employeeAssetKey, employeeAssetCreatedAt) {
+		if mapping.EmployeeID != employeeID || mapping.ArchivedAt != nil {
+			continue
+		}
+		offboarding.Items = append(offboarding.Items, &models.OffboardingItem{
+			ID:              uuid.New(),
+			OffboardingID:   offboarding.ID,
+			EmployeeAssetID: mapping.ID,
+			AssetID:         mapping.AssetID,
+			Status:          models.OffboardingItemPending,
+		})
+	}
+
+	s.offboardings[offboarding.ID] = offboarding
+	return copyOffboarding(offboarding), nil
+}
+
+// GetOffboardingByEmployeeID retrieves the most recent offboarding for an employee together with its items
+func (s *Store) GetOffboardingByEmployeeID(ctx context.Context, employeeID uuid.UUID) (*models.Offboarding, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	offboarding := s.latestOffboarding(employeeID)
+	if offboarding == nil {
+		return nil, models.ErrOffboardingNotFound
+	}
+	return copyOffboarding(offboarding), nil
+}
+
+// ResolveOffboardingItem records what happened to an asset, ends its assignment and updates the asset status
+func (s *Store) ResolveOffboardingItem(ctx context.Context, employeeID, itemID uuid.UUID, status, note string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	assetStatus, ok := offboardingAssetStatus[status]
+	if !ok {
+		return models.ErrInvalidOffboardingStatus
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var item *models.OffboardingItem
+	for _, offboarding := range s.offboardings {
+		if offboarding.EmployeeID != employeeID || offboarding.CompletedAt != nil {
+			continue
+		}
+		for _, candidate := range offboarding.Items {
+			if candidate.ID == itemID {
+				item = candidate
+			}
+		}
+	}
+	if item == nil {
+		return models.ErrOffboardingNotFound
+	}
+	if item.Status != models.OffboardingItemPending {
+		return models.ErrOffboardingItemResolved
+	}
+
+	resolvedAt := time.Now()
+	item.Status = status
+	item.Note = note
+	item.ResolvedAt = &resolvedAt
+
+	if mapping, ok := s.employeeAssets[item.EmployeeAssetID]; ok && mapping.ArchivedAt == nil {
+		archivedAt := resolvedAt
+		mapping.ArchivedAt = &archivedAt
+	}
+	if asset, ok := s.assets[item.AssetID]; ok {
+		asset.Status = assetStatus
+	}
+
+	return nil
+}
+
+// CompleteOffboarding archives the employee once every checklist item has been resolved
+func (s *Store) CompleteOffboarding(ctx context.Context, employeeID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var open *models.Offboarding
+	for _, offboarding := range s.offboardings {
+		if offboarding.EmployeeID == employeeID && offboarding.CompletedAt == nil {
+			open = offboarding
+		}
+	}
+	if open == nil {
+		return models.ErrOffboardingNotFound
+	}
+
+	for _, item := range open.Items {
+		if item.Status == models.OffboardingItemPending {
+			return models.ErrOffboardingIncomplete
+		}
+	}
+
+	// Assets handed out after the checklist was opened are not on it
+	if s.countActiveAssets(employeeID) > 0 {
+		return models.ErrEmployeeHasAssets
+	}
+
+	completedAt := time.Now()
+	open.CompletedAt = &completedAt
+	if employee, ok := s.employees[employeeID]; ok {
+		archivedAt := completedAt
+		employee.ArchivedAt = &archivedAt
+	}
+
+	return nil
+}
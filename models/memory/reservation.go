@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// CreateReservation books an asset, rejecting overlaps the way the exclusion constraint does
+func (s *Store) CreateReservation(ctx context.Context, reservation *models.Reservation) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !reservation.EndsAt.After(reservation.StartsAt) {
+		return models.ErrInvalidReservation
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.reservations {
+		if other.AssetID == reservation.AssetID && other.ArchivedAt == nil &&
+			overlaps(other, reservation.StartsAt, reservation.EndsAt) {
+			return models.ErrReservationConflict
+		}
+	}
+	if _, ok := s.assets[reservation.AssetID]; !ok {
+		return fmt.Errorf("error creating reservation: %w: asset %s", ErrForeignKeyViolation, reservation.AssetID)
+	}
+	if _, ok := s.employees[reservation.EmployeeID]; !ok {
+		return fmt.Errorf("error creating reservation: %w: employee %s", ErrForeignKeyViolation, reservation.EmployeeID)
+	}
+
+	reservation.ID = uuid.New()
+	reservation.CreatedAt = time.Now()
+
+	stored := *reservation
+	stored.ArchivedAt = nil
+	s.reservations[stored.ID] = &stored
+
+	return nil
+}
+
+// overlaps reports whether a reservation intersects the half-open window [from, to)
+func overlaps(reservation *models.Reservation, from, to time.Time) bool {
+	return reservation.StartsAt.Before(to) && from.Before(reservation.EndsAt)
+}
+
+// ArchiveReservation cancels a reservation, freeing its time window
+func (s *Store) ArchiveReservation(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if reservation, ok := s.reservations[id]; ok {
+		reservation.ArchivedAt = now()
+	}
+	return nil
+}
+
+// GetReservationByID retrieves a reservation by its ID
+func (s *Store) GetReservationByID(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reservation, ok := s.reservations[id]
+	if !ok {
+		return nil, models.ErrReservationNotFound
+	}
+	c := *reservation
+	return &c, nil
+}
+
+// GetAssetReservations retrieves active reservations of an asset overlapping [from, to)
+func (s *Store) GetAssetReservations(ctx context.Context, assetID uuid.UUID, from, to time.Time) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, func(r *models.Reservation) bool { return r.AssetID == assetID }, from, to)
+}
+
+// GetEmployeeReservations retrieves active reservations held by an employee overlapping [from, to)
+func (s *Store) GetEmployeeReservations(ctx context.Context, employeeID uuid.UUID, from, to time.Time) ([]*models.Reservation, error) {
+	return s.queryReservations(ctx, func(r *models.Reservation) bool { return r.EmployeeID == employeeID }, from, to)
+}
+
+func (s *Store) queryReservations(ctx context.Context, match func(*models.Reservation) bool, from, to time.Time) ([]*models.Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reservations []*models.Reservation
+	for _, reservation := range s.reservations {
+		if match(reservation) && reservation.ArchivedAt == nil && overlaps(reservation, from, to) {
+			c := *reservation
+			reservations = append(reservations, &c)
+		}
+	}
+	sort.Slice(reservations, func(i, j int) bool { return reservations[i].StartsAt.Before(reservations[j].StartsAt) })
+
+	return reservations, nil
+}
+
+// GetAvailability returns an asset's reservations in [from, to) and the free slots between them
+func (s *Store) GetAvailability(ctx context.Context, assetID uuid.UUID, from, to time.Time) (*models.Availability, error) {
+	reservations, err := s.GetAssetReservations(ctx, assetID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Availability{
+		AssetID:      assetID,
+		From:         from,
+		To:           to,
+		Reservations: reservations,
+		Free:         models.FreeSlots(from, to, reservations),
+	}, nil
+}
@@ -0,0 +1,214 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+func sessionKey(s *models.Session) uuid.UUID       { return s.ID }
+func sessionCreatedAt(s *models.Session) time.Time { return s.CreatedAt }
+
+func (s *Store) GetAllSessions(ctx context.Context) ([]*models.Session, error) {
+	var sessions []*models.Session
+	err := s.ForEachSession(ctx, func(session *models.Session) error {
+		sessions = append(sessions, session)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// ForEachSession calls fn for each session, oldest first
+func (s *Store) ForEachSession(ctx context.Context, fn func(*models.Session) error) error {
+	s.mu.RLock()
+	sessions := sortedCopies(s.sessions, sessionKey, sessionCreatedAt)
+	s.mu.RUnlock()
+
+	return each(ctx, sessions, fn)
+}
+
+// CreateSession stores a session, defaulting its expiry and end the way the Postgres model does
+func (s *Store) CreateSession(ctx context.Context, session *models.Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if (session.AdminID == uuid.Nil) == (session.EmployeeID == nil) {
+		return fmt.Errorf("%w: a session belongs to an admin or an employee", ErrCheckViolation)
+	}
+	if session.EmployeeID != nil {
+		if _, ok := s.employees[*session.EmployeeID]; !ok {
+			return fmt.Errorf("%w: employee %s", ErrForeignKeyViolation, *session.EmployeeID)
+		}
+	} else if _, ok := s.admins[session.AdminID]; !ok {
+		return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, session.AdminID)
+	}
+	if session.TokenHash != "" {
+		for _, existing := range s.sessions {
+			if existing.TokenHash == session.TokenHash {
+				return fmt.Errorf("%w: admin_session token", ErrUniqueViolation)
+			}
+		}
+	}
+
+	session.ID = uuid.New()
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	if session.ExpiresAt.IsZero() {
+		session.ExpiresAt = session.CreatedAt.Add(models.DefaultSessionLifetime)
+	}
+	if session.Archive_at.IsZero() || session.Archive_at.After(session.ExpiresAt) {
+		session.Archive_at = session.ExpiresAt
+	}
+
+	stored := *session
+	s.sessions[stored.ID] = &stored
+
+	return nil
+}
+
+func (s *Store) GetSessionByID(ctx context.Context, id uuid.UUID) (*models.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *session
+	return &c, nil
+}
+
+// GetSessionByTokenHash retrieves the session whose token hashes to hash, ended or not
+func (s *Store) GetSessionByTokenHash(ctx context.Context, hash string) (*models.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if hash == "" {
+		return nil, sql.ErrNoRows
+	}
+	for _, session := range s.sessions {
+		if session.TokenHash == hash {
+			c := *session
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// UpdateSession moves the end of a session that has not ended and records when it was last seen
+func (s *Store) UpdateSession(ctx context.Context, session *models.Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.sessions[session.ID]; ok && existing.Archive_at.After(time.Now()) {
+		existing.Archive_at = session.Archive_at
+		existing.LastSeenAt = session.LastSeenAt
+	}
+	return nil
+}
+
+// RotateSessionToken replaces the token of a session that has not ended
+func (s *Store) RotateSessionToken(ctx context.Context, id uuid.UUID, tokenHash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok || !session.Archive_at.After(time.Now()) {
+		return sql.ErrNoRows
+	}
+	session.TokenHash = tokenHash
+	return nil
+}
+
+// ArchiveAdminSessions ends every session of an admin except the one with ID except
+func (s *Store) ArchiveAdminSessions(ctx context.Context, adminID, except uuid.UUID) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var ended int64
+	for _, session := range s.sessions {
+		if session.AdminID == adminID && session.ID != except && session.Archive_at.After(now) {
+			session.Archive_at = now
+			ended++
+		}
+	}
+	return ended, nil
+}
+
+// PurgeSessions deletes the sessions that ended before the given time
+func (s *Store) PurgeSessions(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, session := range s.sessions {
+		if session.Archive_at.Before(before) {
+			delete(s.sessions, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *Store) ArchiveSession(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[id]; ok {
+		session.Archive_at = time.Now()
+	}
+	return nil
+}
+
+func (s *Store) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// GetCounts counts assets by status, current assignments and unexpired sessions
+func (s *Store) GetCounts(ctx context.Context) (*models.Counts, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := &models.Counts{AssetsByStatus: make(map[string]int)}
+	for _, asset := range s.assets {
+		if asset.ArchivedAt == nil {
+			counts.AssetsByStatus[asset.Status]++
+		}
+	}
+	for _, mapping := range s.employeeAssets {
+		if mapping.ArchivedAt == nil {
+			counts.ActiveAssignments++
+		}
+	}
+	now := time.Now()
+	for _, session := range s.sessions {
+		if session.Archive_at.After(now) {
+			counts.ActiveSessions++
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,127 @@
+// Package memory is an in-process implementation of the models repositories. It keeps the same
+// semantics as the Postgres models, down to the constraint checks the schema enforces, so handler
+// tests can run without a database.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// Errors standing in for the constraint violations Postgres would report
+var (
+	// ErrUniqueViolation is returned when a write would duplicate a unique tag, serial or kit name
+	ErrUniqueViolation = errors.New("duplicate key value violates unique constraint")
+	// ErrForeignKeyViolation is returned when a write references a row that does not exist
+	ErrForeignKeyViolation = errors.New("insert or update violates foreign key constraint")
+	// ErrCheckViolation is returned when a value fails a check constraint
+	ErrCheckViolation = errors.New("new row violates check constraint")
+)
+
+// Store holds every table in maps guarded by a single lock, so each method is atomic the way a
+// transaction is in the Postgres models
+type Store struct {
+	mu sync.RWMutex
+
+	assets         map[uuid.UUID]*models.Asset
+	admins         map[uuid.UUID]*models.Admin
+	sessions       map[uuid.UUID]*models.Session
+	apiKeys        map[uuid.UUID]*models.APIKey
+	totps          map[uuid.UUID]*models.TOTP
+	recoveryCodes  []*recoveryCode
+	challenges     map[uuid.UUID]*models.LoginChallenge
+	identities     map[identityKey]*models.Identity
+	employees      map[uuid.UUID]*models.Employee
+	employeeAssets map[uuid.UUID]*models.EmployeeAsset
+	offboardings   map[uuid.UUID]*models.Offboarding
+	kits           map[uuid.UUID]*models.Kit
+	assetRequests  map[uuid.UUID]*models.AssetRequest
+	reservations   map[uuid.UUID]*models.Reservation
+	audits         map[uuid.UUID]*models.Audit
+	scans          []*models.AuditScan
+}
+
+var (
+	_ models.AssetRepository         = (*Store)(nil)
+	_ models.AdminRepository         = (*Store)(nil)
+	_ models.SessionRepository       = (*Store)(nil)
+	_ models.APIKeyRepository        = (*Store)(nil)
+	_ models.TwoFactorRepository     = (*Store)(nil)
+	_ models.IdentityRepository      = (*Store)(nil)
+	_ models.EmployeeRepository      = (*Store)(nil)
+	_ models.EmployeeAssetRepository = (*Store)(nil)
+	_ models.OffboardingRepository   = (*Store)(nil)
+	_ models.KitRepository           = (*Store)(nil)
+	_ models.AssetRequestRepository  = (*Store)(nil)
+	_ models.ReservationRepository   = (*Store)(nil)
+	_ models.AuditRepository         = (*Store)(nil)
+	_ models.ImportRepository        = (*Store)(nil)
+	_ models.StatsRepository         = (*Store)(nil)
+)
+
+// New creates an empty store
+func New() *Store {
+	return &Store{
+		assets:         make(map[uuid.UUID]*models.Asset),
+		admins:         make(map[uuid.UUID]*models.Admin),
+		sessions:       make(map[uuid.UUID]*models.Session),
+		apiKeys:        make(map[uuid.UUID]*models.APIKey),
+		totps:          make(map[uuid.UUID]*models.TOTP),
+		challenges:     make(map[uuid.UUID]*models.LoginChallenge),
+		identities:     make(map[identityKey]*models.Identity),
+		employees:      make(map[uuid.UUID]*models.Employee),
+		employeeAssets: make(map[uuid.UUID]*models.EmployeeAsset),
+		offboardings:   make(map[uuid.UUID]*models.Offboarding),
+		kits:           make(map[uuid.UUID]*models.Kit),
+		assetRequests:  make(map[uuid.UUID]*models.AssetRequest),
+		reservations:   make(map[uuid.UUID]*models.Reservation),
+		audits:         make(map[uuid.UUID]*models.Audit),
+	}
+}
+
+// sortedCopies returns copies of the values in m ordered by the given timestamp, oldest first.
+// Ties are broken on the ID so listings are deterministic.
+func sortedCopies[T any](m map[uuid.UUID]*T, id func(*T) uuid.UUID, at func(*T) time.Time) []*T {
+	values := make([]*T, 0, len(m))
+	for _, v := range m {
+		c := *v
+		values = append(values, &c)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		ti, tj := at(values[i]), at(values[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		a, b := id(values[i]), id(values[j])
+		return bytes.Compare(a[:], b[:]) < 0
+	})
+	return values
+}
+
+// each calls fn for every value outside the lock, stopping early if ctx is done, the way a
+// cursor would
+func each[T any](ctx context.Context, values []*T, fn func(*T) error) error {
+	for _, v := range values {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// now returns a pointer to the current time, for the nullable timestamp columns
+func now() *time.Time {
+	t := time.Now()
+	return &t
+}
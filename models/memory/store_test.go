@@ -0,0 +1,174 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/memory"
+)
+
+func newAsset(serial string) *models.Asset {
+	return &models.Asset{Model: "ThinkPad T14", Company: "Lenovo", Tag: "TAG-" + serial, Serial: serial, Category: "laptop"}
+}
+
+// The Postgres behaviour behind these is covered by the models tests; these pin down the errors
+// the store reports in place of the constraint violations
+func TestConstraintErrors(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+
+	asset := newAsset("S-1")
+	if err := store.CreateAsset(ctx, asset); err != nil {
+		t.Fatalf("CreateAsset: %v", err)
+	}
+	employee := &models.Employee{Name: "Ada Lovelace", Email: "ada@example.com", Role: "Engineer"}
+	if err := store.CreateEmployee(ctx, employee); err != nil {
+		t.Fatalf("CreateEmployee: %v", err)
+	}
+	missing := uuid.New()
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"duplicate tag", store.CreateAsset(ctx, &models.Asset{Tag: asset.Tag, Serial: "S-2"}), memory.ErrUniqueViolation},
+		{"duplicate serial", store.CreateAsset(ctx, &models.Asset{Tag: "TAG-2", Serial: asset.Serial}), memory.ErrUniqueViolation},
+		{"duplicate email", store.CreateEmployee(ctx, &models.Employee{Name: "Ada", Email: "ADA@example.com"}), memory.ErrUniqueViolation},
+		{"unknown manager", store.CreateEmployee(ctx, &models.Employee{Name: "Alan", Email: "alan@example.com", ManagerID: &missing}), memory.ErrForeignKeyViolation},
+		{"assignment to a missing asset", store.CreateEmployeeAsset(ctx, &models.EmployeeAsset{AssetID: missing, EmployeeID: employee.ID}), models.ErrAssetUnavailable},
+		{"assignment to a missing employee", store.CreateEmployeeAsset(ctx, &models.EmployeeAsset{AssetID: asset.Id, EmployeeID: missing}), models.ErrEmployeeNotFound},
+		{"session for no one", store.CreateSession(ctx, &models.Session{}), memory.ErrCheckViolation},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s error = %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	// Empty tags are not unique, like the partial index
+	for _, serial := range []string{"S-3", "S-4"} {
+		if err := store.CreateAsset(ctx, &models.Asset{Serial: serial}); err != nil {
+			t.Errorf("CreateAsset without a tag: %v", err)
+		}
+	}
+	if assets, _ := store.GetAllAssets(ctx); len(assets) != 3 {
+		t.Errorf("store holds %d assets after the failed writes, want 3", len(assets))
+	}
+}
+
+func TestStoreReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+
+	asset := newAsset("S-1")
+	if err := store.CreateAsset(ctx, asset); err != nil {
+		t.Fatalf("CreateAsset: %v", err)
+	}
+
+	// Changing what the caller passed in, or got back, does not change the stored row
+	asset.Location = "passed in"
+	got, err := store.GetAssetByID(ctx, asset.Id)
+	if err != nil {
+		t.Fatalf("GetAssetByID: %v", err)
+	}
+	got.Location = "got by id"
+	all, err := store.GetAllAssets(ctx)
+	if err != nil || len(all) != 1 {
+		t.Fatalf("GetAllAssets = %v, %v; want one asset", all, err)
+	}
+	all[0].Location = "listed"
+
+	got, err = store.GetAssetByID(ctx, asset.Id)
+	if err != nil || got.Location != "" {
+		t.Errorf("stored asset = %+v, %v; want it unchanged", got, err)
+	}
+}
+
+func TestStoreListsOldestFirst(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+
+	var serials []string
+	for i := 0; i < 5; i++ {
+		serial := fmt.Sprintf("S-%d", i)
+		serials = append(serials, serial)
+		if err := store.CreateAsset(ctx, newAsset(serial)); err != nil {
+			t.Fatalf("CreateAsset: %v", err)
+		}
+	}
+
+	var got []string
+	err := store.ForEachAsset(ctx, models.AssetFilter{}, func(a *models.Asset) error {
+		got = append(got, a.Serial)
+		return nil
+	})
+	if err != nil || fmt.Sprint(got) != fmt.Sprint(serials) {
+		t.Errorf("ForEachAsset = %v, %v; want %v", got, err, serials)
+	}
+}
+
+func TestStoreHonoursContext(t *testing.T) {
+	store := memory.New()
+	if err := store.CreateAsset(context.Background(), newAsset("S-1")); err != nil {
+		t.Fatalf("CreateAsset: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := store.CreateAsset(ctx, newAsset("S-2")); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateAsset with a canceled context error = %v, want context.Canceled", err)
+	}
+	called := false
+	err := store.ForEachAsset(ctx, models.AssetFilter{}, func(*models.Asset) error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || called {
+		t.Errorf("ForEachAsset with a canceled context = %v, called %v; want context.Canceled before any row", err, called)
+	}
+}
+
+func TestStoreConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+
+	// Every writer races for the same serial once and a unique one once, so exactly one of the
+	// shared writes may win
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- store.CreateAsset(ctx, &models.Asset{Serial: "shared"})
+			errs <- store.CreateAsset(ctx, newAsset(fmt.Sprintf("S-%d", i)))
+			if _, err := store.GetAllAssets(ctx); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	duplicates := 0
+	for err := range errs {
+		switch {
+		case errors.Is(err, memory.ErrUniqueViolation):
+			duplicates++
+		case err != nil:
+			t.Errorf("concurrent write: %v", err)
+		}
+	}
+	assets, err := store.GetAllAssets(ctx)
+	if err != nil || len(assets) != writers+1 || duplicates != writers-1 {
+		t.Errorf("store holds %d assets with %d duplicates rejected, %v; want %d and %d", len(assets), duplicates, err, writers+1, writers-1)
+	}
+}
@@ -0,0 +1,281 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+)
+
+// recoveryCode is a row of admin_recovery_code
+type recoveryCode struct {
+	adminID uuid.UUID
+	hash    string
+	used    bool
+}
+
+// GetTOTP retrieves an admin's authenticator, confirmed or not
+func (s *Store) GetTOTP(ctx context.Context, adminID uuid.UUID) (*models.TOTP, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	totp, ok := s.totps[adminID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	c := *totp
+	return &c, nil
+}
+
+// StartTOTP stores a new, unconfirmed authenticator for an admin, replacing one they never confirmed
+func (s *Store) StartTOTP(ctx context.Context, totp *models.TOTP) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.admins[totp.AdminID]; !ok {
+		return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, totp.AdminID)
+	}
+	if existing, ok := s.totps[totp.AdminID]; ok && existing.ConfirmedAt != nil {
+		return models.ErrTwoFactorEnabled
+	}
+
+	totp.ConfirmedAt, totp.LastStep, totp.CreatedAt = nil, 0, time.Now()
+	c := *totp
+	s.totps[totp.AdminID] = &c
+	return nil
+}
+
+// ConfirmTOTP turns on an admin's unconfirmed authenticator and replaces their recovery codes
+func (s *Store) ConfirmTOTP(ctx context.Context, adminID uuid.UUID, step int64, recoveryHashes []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totps[adminID]
+	if !ok || totp.ConfirmedAt != nil {
+		return sql.ErrNoRows
+	}
+	totp.ConfirmedAt = now()
+	totp.LastStep = step
+	s.replaceRecoveryCodes(adminID, recoveryHashes)
+	return nil
+}
+
+// UseTOTPStep records that a code for step was used, refusing a step at or before the last one
+func (s *Store) UseTOTPStep(ctx context.Context, adminID uuid.UUID, step int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totps[adminID]
+	if !ok || totp.ConfirmedAt == nil || totp.LastStep >= step {
+		return sql.ErrNoRows
+	}
+	totp.LastStep = step
+	return nil
+}
+
+// replaceRecoveryCodes swaps an admin's recovery codes; the caller holds the write lock
+func (s *Store) replaceRecoveryCodes(adminID uuid.UUID, hashes []string) {
+	kept := s.recoveryCodes[:0]
+	for _, code := range s.recoveryCodes {
+		if code.adminID != adminID {
+			kept = append(kept, code)
+		}
+	}
+	for _, hash := range hashes {
+		kept = append(kept, &recoveryCode{adminID: adminID, hash: hash})
+	}
+	s.recoveryCodes = kept
+}
+
+// ReplaceRecoveryCodes replaces all of an admin's recovery codes, used or not, with the given hashes
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, adminID uuid.UUID, hashes []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.admins[adminID]; !ok {
+		return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, adminID)
+	}
+	s.replaceRecoveryCodes(adminID, hashes)
+	return nil
+}
+
+// UseRecoveryCode spends the recovery code with the given hash
+func (s *Store) UseRecoveryCode(ctx context.Context, adminID uuid.UUID, hash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes {
+		if code.adminID == adminID && code.hash == hash && !code.used {
+			code.used = true
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+// CountRecoveryCodes returns how many unused recovery codes an admin has left
+func (s *Store) CountRecoveryCodes(ctx context.Context, adminID uuid.UUID) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var count int
+	for _, code := range s.recoveryCodes {
+		if code.adminID == adminID && !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// DeleteTOTP removes an admin's authenticator, recovery codes and pending logins
+func (s *Store) DeleteTOTP(ctx context.Context, adminID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, challenge := range s.challenges {
+		if challenge.AdminID == adminID {
+			delete(s.challenges, id)
+		}
+	}
+	s.replaceRecoveryCodes(adminID, nil)
+	delete(s.totps, adminID)
+	return nil
+}
+
+// CreateLoginChallenge stores a pending login. The caller sets the token hash and expiry.
+func (s *Store) CreateLoginChallenge(ctx context.Context, challenge *models.LoginChallenge) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.admins[challenge.AdminID]; !ok {
+		return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, challenge.AdminID)
+	}
+	for _, existing := range s.challenges {
+		if existing.TokenHash == challenge.TokenHash {
+			return fmt.Errorf("%w: admin_login_challenge token_hash", ErrUniqueViolation)
+		}
+	}
+
+	challenge.ID = uuid.New()
+	challenge.Attempts = 0
+	challenge.CreatedAt = time.Now()
+	c := *challenge
+	s.challenges[challenge.ID] = &c
+	return nil
+}
+
+// GetLoginChallengeByTokenHash retrieves the pending login whose token has the given hash
+func (s *Store) GetLoginChallengeByTokenHash(ctx context.Context, hash string) (*models.LoginChallenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, challenge := range s.challenges {
+		if challenge.TokenHash == hash {
+			c := *challenge
+			return &c, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// RestoreLoginChallenge puts back a pending login that was deleted to claim it, as given
+func (s *Store) RestoreLoginChallenge(ctx context.Context, challenge *models.LoginChallenge) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.admins[challenge.AdminID]; !ok {
+		return fmt.Errorf("%w: admin %s", ErrForeignKeyViolation, challenge.AdminID)
+	}
+	for _, existing := range s.challenges {
+		if existing.ID == challenge.ID || existing.TokenHash == challenge.TokenHash {
+			return fmt.Errorf("%w: admin_login_challenge", ErrUniqueViolation)
+		}
+	}
+
+	c := *challenge
+	s.challenges[challenge.ID] = &c
+	return nil
+}
+
+// DeleteLoginChallenge removes a pending login, returning sql.ErrNoRows if it was already gone
+func (s *Store) DeleteLoginChallenge(ctx context.Context, id uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.challenges[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(s.challenges, id)
+	return nil
+}
+
+// PurgeLoginChallenges deletes the pending logins that expired before the given time
+func (s *Store) PurgeLoginChallenges(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for id, challenge := range s.challenges {
+		if challenge.ExpiresAt.Before(before) {
+			delete(s.challenges, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
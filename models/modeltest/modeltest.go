@@ -0,0 +1,85 @@
+// Package modeltest runs the same test against every repository implementation: the in-memory
+// store, and the Postgres models on a fresh database from dbtest when PostgreSQL is available.
+package modeltest
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/db/dbtest"
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/memory"
+)
+
+// Repositories bundles one implementation of every repository
+type Repositories struct {
+	Assets         models.AssetRepository
+	Admins         models.AdminRepository
+	Sessions       models.SessionRepository
+	APIKeys        models.APIKeyRepository
+	TwoFactor      models.TwoFactorRepository
+	Identities     models.IdentityRepository
+	Employees      models.EmployeeRepository
+	EmployeeAssets models.EmployeeAssetRepository
+	Offboardings   models.OffboardingRepository
+	Kits           models.KitRepository
+	AssetRequests  models.AssetRequestRepository
+	Reservations   models.ReservationRepository
+	Audits         models.AuditRepository
+	Imports        models.ImportRepository
+	Stats          models.StatsRepository
+}
+
+// Postgres returns the Postgres backed models on db
+func Postgres(db *sql.DB) Repositories {
+	return Repositories{
+		Assets:         &models.AssetModel{DB: db},
+		Admins:         &models.AdminModel{DB: db},
+		Sessions:       &models.SessionModel{DB: db},
+		APIKeys:        &models.APIKeyModel{DB: db},
+		TwoFactor:      &models.TwoFactorModel{DB: db},
+		Identities:     &models.IdentityModel{DB: db},
+		Employees:      &models.EmployeeModel{DB: db},
+		EmployeeAssets: &models.EmployeeAssetModel{DB: db},
+		Offboardings:   &models.OffboardingModel{DB: db},
+		Kits:           &models.KitModel{DB: db},
+		AssetRequests:  &models.AssetRequestModel{DB: db},
+		Reservations:   &models.ReservationModel{DB: db},
+		Audits:         &models.AuditModel{DB: db},
+		Imports:        &models.ImportModel{DB: db},
+		Stats:          &models.StatsModel{DB: db},
+	}
+}
+
+// Memory returns a store as every repository
+func Memory(store *memory.Store) Repositories {
+	return Repositories{
+		Assets:         store,
+		Admins:         store,
+		Sessions:       store,
+		APIKeys:        store,
+		TwoFactor:      store,
+		Identities:     store,
+		Employees:      store,
+		EmployeeAssets: store,
+		Offboardings:   store,
+		Kits:           store,
+		AssetRequests:  store,
+		Reservations:   store,
+		Audits:         store,
+		Imports:        store,
+		Stats:          store,
+	}
+}
+
+// Run calls fn in a "memory" and a "postgres" subtest, each with empty repositories
+func Run(t *testing.T, fn func(t *testing.T, repos Repositories)) {
+	t.Helper()
+
+	t.Run("memory", func(t *testing.T) {
+		fn(t, Memory(memory.New()))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		fn(t, Postgres(dbtest.Open(t)))
+	})
+}
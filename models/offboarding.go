@@ -0,0 +1,313 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Offboarding item statuses. Every item must leave pending before the employee can be archived.
+const (
+	OffboardingItemPending    = "pending"
+	OffboardingItemReturned   = "returned"
+	OffboardingItemLost       = "lost"
+	OffboardingItemWrittenOff = "written_off"
+)
+
+var (
+	// ErrOffboardingNotFound is returned when an employee has no offboarding in progress
+	ErrOffboardingNotFound = errors.New("offboarding not found")
+	// ErrOffboardingIncomplete is returned when completing an offboarding with pending items
+	ErrOffboardingIncomplete = errors.New("offboarding has outstanding items")
+	// ErrInvalidOffboardingStatus is returned when resolving an item to an unknown status
+	ErrInvalidOffboardingStatus = errors.New("invalid offboarding item status")
+	// ErrOffboardingItemResolved is returned when resolving an item that is no longer pending
+	ErrOffboardingItemResolved = errors.New("offboarding item is already resolved")
+)
+
+// Offboarding is the checklist of assets an employee must hand back before leaving
+type Offboarding struct {
+	ID          uuid.UUID          `json:"id"`
+	EmployeeID  uuid.UUID          `json:"employee_id"`
+	Items       []*OffboardingItem `json:"items"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+// OffboardingItem tracks a single assignment that has to be reclaimed
+type OffboardingItem struct {
+	ID              uuid.UUID  `json:"id"`
+	OffboardingID   uuid.UUID  `json:"offboarding_id"`
+	EmployeeAssetID uuid.UUID  `json:"employee_asset_id"`
+	AssetID         uuid.UUID  `json:"asset_id"`
+	Status          string     `json:"status"`
+	Note            string     `json:"note,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+// OffboardingModel represents the model for offboarding operations
+type OffboardingModel struct {
+	DB *sql.DB
+}
+
+// offboardingAssetStatus maps a resolved item to the status its asset should end up in
+var offboardingAssetStatus = map[string]string{
+	OffboardingItemReturned:   AssetStatusAvailable,
+	OffboardingItemLost:       AssetStatusLost,
+	OffboardingItemWrittenOff: AssetStatusWrittenOff,
+}
+
+// StartOffboarding opens a checklist with one item per asset currently assigned to the employee.
+// If an offboarding is already in progress it is returned unchanged.
+func (om *OffboardingModel) StartOffboarding(ctx context.Context, employeeID uuid.UUID) (*Offboarding, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	existing, err := om.GetOffboardingByEmployeeID(ctx, employeeID)
+	if err == nil && existing.CompletedAt == nil {
+		return existing, nil
+	}
+	if err != nil && !errors.Is(err, ErrOffboardingNotFound) {
+		return nil, err
+	}
+
+	tx, err := om.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Assignments lock the employee too, so none can land after the checklist is drawn up
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM employee WHERE id = $1 FOR UPDATE`, employeeID); err != nil {
+		return nil, err
+	}
+
+	offboarding := &Offboarding{
+		ID:         uuid.New(),
+		EmployeeID: employeeID,
+		CreatedAt:  time.Now(),
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO employee_offboarding (id, employee_id, created_at) VALUES ($1, $2, $3)`,
+		offboarding.ID, offboarding.EmployeeID, offboarding.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error creating offboarding: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, asset_id
+		FROM employee_asset_mapping
+		WHERE employee_id = $1 AND archive_at IS NULL
+	`, employeeID)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		item := &OffboardingItem{
+			ID:            uuid.New(),
+			OffboardingID: offboarding.ID,
+			Status:        OffboardingItemPending,
+		}
+		if err := rows.Scan(&item.EmployeeAssetID, &item.AssetID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		offboarding.Items = append(offboarding.Items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, item := range offboarding.Items {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO offboarding_item (id, offboarding_id, employee_asset_id, asset_id, status)
+			VALUES ($1, $2, $3, $4, $5)
+		`, item.ID, item.OffboardingID, item.EmployeeAssetID, item.AssetID, item.Status)
+		if err != nil {
+			return nil, fmt.Errorf("error creating offboarding item: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return offboarding, nil
+}
+
+// GetOffboardingByEmployeeID retrieves the most recent offboarding for an employee together with its items
+func (om *OffboardingModel) GetOffboardingByEmployeeID(ctx context.Context, employeeID uuid.UUID) (*Offboarding, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, employee_id, created_at, completed_at
+		FROM employee_offboarding
+		WHERE employee_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	offboarding := &Offboarding{}
+	err := om.DB.QueryRowContext(ctx, query, employeeID).Scan(&offboarding.ID, &offboarding.EmployeeID, &offboarding.CreatedAt, &offboarding.CompletedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOffboardingNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := om.DB.QueryContext(ctx, `
+		SELECT id, offboarding_id, employee_asset_id, asset_id, status, note, resolved_at
+		FROM offboarding_item
+		WHERE offboarding_id = $1
+	`, offboarding.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &OffboardingItem{}
+		err := rows.Scan(&item.ID, &item.OffboardingID, &item.EmployeeAssetID, &item.AssetID, &item.Status, &item.Note, &item.ResolvedAt)
+		if err != nil {
+			return nil, err
+		}
+		offboarding.Items = append(offboarding.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return offboarding, nil
+}
+
+// ResolveOffboardingItem records what happened to an asset, ends its assignment and updates the asset status.
+// Each item is resolved once.
+func (om *OffboardingModel) ResolveOffboardingItem(ctx context.Context, employeeID, itemID uuid.UUID, status, note string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	assetStatus, ok := offboardingAssetStatus[status]
+	if !ok {
+		return ErrInvalidOffboardingStatus
+	}
+
+	tx, err := om.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	var employeeAssetID, assetID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		UPDATE offboarding_item AS i
+		SET status = $1, note = $2, resolved_at = $3
+		FROM employee_offboarding AS o
+		WHERE i.offboarding_id = o.id
+		  AND i.id = $4
+		  AND i.status = $6
+		  AND o.employee_id = $5
+		  AND o.completed_at IS NULL
+		RETURNING i.employee_asset_id, i.asset_id
+	`, status, note, now, itemID, employeeID, OffboardingItemPending).Scan(&employeeAssetID, &assetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT EXISTS (
+				SELECT 1
+				FROM offboarding_item AS i
+				JOIN employee_offboarding AS o ON o.id = i.offboarding_id
+				WHERE i.id = $1 AND o.employee_id = $2 AND o.completed_at IS NULL
+			)
+		`, itemID, employeeID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrOffboardingItemResolved
+		}
+		return ErrOffboardingNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE employee_asset_mapping SET archive_at = $1 WHERE id = $2 AND archive_at IS NULL`, now, employeeAssetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE asset SET status = $1 WHERE id = $2`, assetStatus, assetID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CompleteOffboarding archives the employee once every checklist item has been resolved
+func (om *OffboardingModel) CompleteOffboarding(ctx context.Context, employeeID uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := om.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var offboardingID uuid.UUID
+	err = tx.QueryRowContext(ctx, `
+		SELECT id
+		FROM employee_offboarding
+		WHERE employee_id = $1 AND completed_at IS NULL
+		FOR UPDATE
+	`, employeeID).Scan(&offboardingID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOffboardingNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var pending int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM offboarding_item WHERE offboarding_id = $1 AND status = $2`,
+		offboardingID, OffboardingItemPending).Scan(&pending)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return ErrOffboardingIncomplete
+	}
+
+	// Assets handed out after the checklist was opened are not on it
+	var outstanding int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM employee_asset_mapping WHERE employee_id = $1 AND archive_at IS NULL`,
+		employeeID).Scan(&outstanding)
+	if err != nil {
+		return err
+	}
+	if outstanding > 0 {
+		return ErrEmployeeHasAssets
+	}
+
+	now := time.Now()
+	if _, err := tx.ExecContext(ctx, `UPDATE employee_offboarding SET completed_at = $1 WHERE id = $2`, now, offboardingID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE employee SET archive_at = $1 WHERE id = $2`, now, employeeID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The repository interfaces describe what handlers need from each model. The Postgres backed
+// models implement them, as does the in-memory store in models/memory used for tests.
+
+// AssetRepository stores assets
+type AssetRepository interface {
+	CreateAsset(ctx context.Context, asset *Asset) error
+	UpdateAsset(ctx context.Context, asset *Asset) error
+	ArchiveAsset(ctx context.Context, id uuid.UUID) error
+	GetAssetByID(ctx context.Context, id uuid.UUID) (*Asset, error)
+	GetAllAssets(ctx context.Context) ([]*Asset, error)
+	GetAssets(ctx context.Context, filter AssetFilter) ([]*Asset, error)
+	ForEachAsset(ctx context.Context, filter AssetFilter, fn func(*Asset) error) error
+	ForEachAssignedAsset(ctx context.Context, employeeID uuid.UUID, fn func(*Asset) error) error
+}
+
+// AdminRepository stores admins
+type AdminRepository interface {
+	CreateAdmin(ctx context.Context, admin *Admin) error
+	UpdateAdmin(ctx context.Context, admin *Admin) error
+	ArchiveAdmin(ctx context.Context, id uuid.UUID) error
+	GetAdminByID(ctx context.Context, id uuid.UUID) (*Admin, error)
+	GetAdminByEmail(ctx context.Context, email string) (*Admin, error)
+	GetAllAdmins(ctx context.Context) ([]*Admin, error)
+	ForEachAdmin(ctx context.Context, fn func(*Admin) error) error
+}
+
+// SessionRepository stores admin sessions
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *Session) error
+	UpdateSession(ctx context.Context, session *Session) error
+	ArchiveSession(ctx context.Context, id uuid.UUID) error
+	DeleteSession(ctx context.Context, id uuid.UUID) error
+	GetSessionByID(ctx context.Context, id uuid.UUID) (*Session, error)
+	GetSessionByTokenHash(ctx context.Context, hash string) (*Session, error)
+	GetAllSessions(ctx context.Context) ([]*Session, error)
+	ForEachSession(ctx context.Context, fn func(*Session) error) error
+	RotateSessionToken(ctx context.Context, id uuid.UUID, tokenHash string) error
+	ArchiveAdminSessions(ctx context.Context, adminID, except uuid.UUID) (int64, error)
+	PurgeSessions(ctx context.Context, before time.Time) (int64, error)
+}
+
+// APIKeyRepository stores API keys
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	GetAPIKeyByID(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+	GetAllAPIKeys(ctx context.Context) ([]*APIKey, error)
+	ForEachAPIKey(ctx context.Context, fn func(*APIKey) error) error
+	RevokeAPIKey(ctx context.Context, id uuid.UUID) error
+	RotateAPIKey(ctx context.Context, id uuid.UUID, replacement *APIKey, retireAt time.Time) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID, ip string) error
+}
+
+// TwoFactorRepository stores admins' authenticators, recovery codes and logins waiting for a second factor
+type TwoFactorRepository interface {
+	GetTOTP(ctx context.Context, adminID uuid.UUID) (*TOTP, error)
+	StartTOTP(ctx context.Context, totp *TOTP) error
+	ConfirmTOTP(ctx context.Context, adminID uuid.UUID, step int64, recoveryHashes []string) error
+	UseTOTPStep(ctx context.Context, adminID uuid.UUID, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, adminID uuid.UUID, hashes []string) error
+	UseRecoveryCode(ctx context.Context, adminID uuid.UUID, hash string) error
+	CountRecoveryCodes(ctx context.Context, adminID uuid.UUID) (int, error)
+	DeleteTOTP(ctx context.Context, adminID uuid.UUID) error
+	CreateLoginChallenge(ctx context.Context, challenge *LoginChallenge) error
+	GetLoginChallengeByTokenHash(ctx context.Context, hash string) (*LoginChallenge, error)
+	RestoreLoginChallenge(ctx context.Context, challenge *LoginChallenge) error
+	DeleteLoginChallenge(ctx context.Context, id uuid.UUID) error
+	PurgeLoginChallenges(ctx context.Context, before time.Time) (int64, error)
+}
+
+// IdentityRepository links identities at the OpenID Connect provider to admins and employees
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, issuer, subject string) (*Identity, error)
+	CreateIdentity(ctx context.Context, identity *Identity) error
+}
+
+// EmployeeRepository stores employees
+type EmployeeRepository interface {
+	CreateEmployee(ctx context.Context, employee *Employee) error
+	UpdateEmployee(ctx context.Context, employee *Employee) error
+	ArchiveEmployee(ctx context.Context, id uuid.UUID) error
+	CountActiveAssets(ctx context.Context, id uuid.UUID) (int, error)
+	GetEmployeeByID(ctx context.Context, id uuid.UUID) (*Employee, error)
+	GetEmployeeByEmail(ctx context.Context, email string) (*Employee, error)
+	GetAllEmployees(ctx context.Context) ([]*Employee, error)
+	ForEachEmployee(ctx context.Context, fn func(*Employee) error) error
+}
+
+// EmployeeAssetRepository stores asset assignments
+type EmployeeAssetRepository interface {
+	CreateEmployeeAsset(ctx context.Context, employeeAsset *EmployeeAsset) error
+	UpdateEmployeeAsset(ctx context.Context, employeeAsset *EmployeeAsset) error
+	ArchiveEmployeeAsset(ctx context.Context, id uuid.UUID) error
+	GetEmployeeAssetByID(ctx context.Context, id uuid.UUID) (*EmployeeAsset, error)
+	GetAllEmployeeAssets(ctx context.Context) ([]*EmployeeAsset, error)
+	ForEachEmployeeAsset(ctx context.Context, fn func(*EmployeeAsset) error) error
+}
+
+// OffboardingRepository runs offboarding checklists
+type OffboardingRepository interface {
+	StartOffboarding(ctx context.Context, employeeID uuid.UUID) (*Offboarding, error)
+	GetOffboardingByEmployeeID(ctx context.Context, employeeID uuid.UUID) (*Offboarding, error)
+	ResolveOffboardingItem(ctx context.Context, employeeID, itemID uuid.UUID, status, note string) error
+	CompleteOffboarding(ctx context.Context, employeeID uuid.UUID) error
+}
+
+// KitRepository stores onboarding kits and provisions them
+type KitRepository interface {
+	CreateKit(ctx context.Context, kit *Kit) error
+	UpdateKit(ctx context.Context, kit *Kit) error
+	ArchiveKit(ctx context.Context, id uuid.UUID) error
+	GetKitByID(ctx context.Context, id uuid.UUID) (*Kit, error)
+	GetKitByName(ctx context.Context, name string) (*Kit, error)
+	GetKitForEmployee(ctx context.Context, employee *Employee) (*Kit, error)
+	GetAllKits(ctx context.Context) ([]*Kit, error)
+	OnboardEmployee(ctx context.Context, employeeID uuid.UUID, kitName string, strict bool) (*Onboarding, error)
+}
+
+// AssetRequestRepository runs the asset request approval workflow
+type AssetRequestRepository interface {
+	CreateAssetRequest(ctx context.Context, request *AssetRequest) error
+	GetAssetRequestByID(ctx context.Context, id uuid.UUID) (*AssetRequest, error)
+	GetAllAssetRequests(ctx context.Context, status string) ([]*AssetRequest, error)
+	ForEachAssetRequest(ctx context.Context, status string, fn func(*AssetRequest) error) error
+	ApproveAssetRequest(ctx context.Context, id, approverID uuid.UUID, assetID *uuid.UUID) (*AssetRequest, error)
+	RejectAssetRequest(ctx context.Context, id, approverID uuid.UUID, reason string) (*AssetRequest, error)
+	EscalateAssetRequest(ctx context.Context, id, adminID uuid.UUID, reason string) (*AssetRequest, error)
+}
+
+// ReservationRepository stores asset reservations
+type ReservationRepository interface {
+	CreateReservation(ctx context.Context, reservation *Reservation) error
+	ArchiveReservation(ctx context.Context, id uuid.UUID) error
+	GetReservationByID(ctx context.Context, id uuid.UUID) (*Reservation, error)
+	GetAssetReservations(ctx context.Context, assetID uuid.UUID, from, to time.Time) ([]*Reservation, error)
+	GetEmployeeReservations(ctx context.Context, employeeID uuid.UUID, from, to time.Time) ([]*Reservation, error)
+	GetAvailability(ctx context.Context, assetID uuid.UUID, from, to time.Time) (*Availability, error)
+}
+
+// AuditRepository runs inventory audits
+type AuditRepository interface {
+	CreateAudit(ctx context.Context, audit *Audit) error
+	GetAuditByID(ctx context.Context, id uuid.UUID) (*Audit, error)
+	GetAllAudits(ctx context.Context) ([]*Audit, error)
+	ForEachAudit(ctx context.Context, fn func(*Audit) error) error
+	RecordScan(ctx context.Context, scan *AuditScan) error
+	GetAuditReport(ctx context.Context, id uuid.UUID) (*AuditReport, error)
+	CloseAudit(ctx context.Context, id uuid.UUID, markMissingLost bool) (*AuditReport, error)
+}
+
+// ImportRepository merges bulk CSV imports
+type ImportRepository interface {
+	Import(ctx context.Context, kind string, rows []ImportRow, dryRun bool) (*ImportReport, error)
+}
+
+// StatsRepository counts the inventory for metrics
+type StatsRepository interface {
+	GetCounts(ctx context.Context) (*Counts, error)
+}
+
+var (
+	_ AssetRepository         = (*AssetModel)(nil)
+	_ AdminRepository         = (*AdminModel)(nil)
+	_ SessionRepository       = (*SessionModel)(nil)
+	_ APIKeyRepository        = (*APIKeyModel)(nil)
+	_ TwoFactorRepository     = (*TwoFactorModel)(nil)
+	_ IdentityRepository      = (*IdentityModel)(nil)
+	_ EmployeeRepository      = (*EmployeeModel)(nil)
+	_ EmployeeAssetRepository = (*EmployeeAssetModel)(nil)
+	_ OffboardingRepository   = (*OffboardingModel)(nil)
+	_ KitRepository           = (*KitModel)(nil)
+	_ AssetRequestRepository  = (*AssetRequestModel)(nil)
+	_ ReservationRepository   = (*ReservationModel)(nil)
+	_ AuditRepository         = (*AuditModel)(nil)
+	_ ImportRepository        = (*ImportModel)(nil)
+	_ StatsRepository         = (*StatsModel)(nil)
+)
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrReservationNotFound is returned when a reservation does not exist
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrReservationConflict is returned when a booking overlaps an existing one for the same asset
+	ErrReservationConflict = errors.New("asset is already reserved for part of that time")
+	// ErrInvalidReservation is returned when a booking does not end after it starts
+	ErrInvalidReservation = errors.New("reservation must end after it starts")
+)
+
+// Reservation books an asset for an employee over the half-open window [StartsAt, EndsAt)
+type Reservation struct {
+	ID         uuid.UUID  `json:"id"`
+	AssetID    uuid.UUID  `json:"asset_id"`
+	EmployeeID uuid.UUID  `json:"employee_id"`
+	StartsAt   time.Time  `json:"starts_at"`
+	EndsAt     time.Time  `json:"ends_at"`
+	Note       string     `json:"note,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ArchivedAt *time.Time `json:"archive_at,omitempty"`
+}
+
+// TimeSlot is a free window in an asset's calendar
+type TimeSlot struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Availability describes an asset's bookings and free time within a window
+type Availability struct {
+	AssetID      uuid.UUID      `json:"asset_id"`
+	From         time.Time      `json:"from"`
+	To           time.Time      `json:"to"`
+	Reservations []*Reservation `json:"reservations"`
+	Free         []TimeSlot     `json:"free"`
+}
+
+// ReservationModel represents the model for reservation operations
+type ReservationModel struct {
+	DB *sql.DB
+}
+
+const reservationColumns = `id, asset_id, employee_id, lower(during), upper(during), note, created_at, archive_at`
+
+func scanReservation(row rowScanner) (*Reservation, error) {
+	reservation := &Reservation{}
+	err := row.Scan(&reservation.ID, &reservation.AssetID, &reservation.EmployeeID, &reservation.StartsAt,
+		&reservation.EndsAt, &reservation.Note, &reservation.CreatedAt, &reservation.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// CreateReservation books an asset. Overlaps are rejected by the database's exclusion constraint.
+func (rm *ReservationModel) CreateReservation(ctx context.Context, reservation *Reservation) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if !reservation.EndsAt.After(reservation.StartsAt) {
+		return ErrInvalidReservation
+	}
+
+	reservation.ID = uuid.New()
+	reservation.CreatedAt = time.Now()
+
+	_, err := rm.DB.ExecContext(ctx, `
+		INSERT INTO asset_reservation (id, asset_id, employee_id, during, note, created_at)
+		VALUES ($1, $2, $3, tstzrange($4, $5, '[)'), $6, $7)
+	`, reservation.ID, reservation.AssetID, reservation.EmployeeID, reservation.StartsAt, reservation.EndsAt,
+		reservation.Note, reservation.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23P01" {
+		return ErrReservationConflict
+	}
+	if err != nil {
+		return fmt.Errorf("error creating reservation: %w", err)
+	}
+
+	return nil
+}
+
+// ArchiveReservation cancels a reservation, freeing its time window
+func (rm *ReservationModel) ArchiveReservation(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := rm.DB.ExecContext(ctx, `UPDATE asset_reservation SET archive_at = $1 WHERE id = $2`, time.Now(), id)
+	return err
+}
+
+// GetReservationByID retrieves a reservation by its ID
+func (rm *ReservationModel) GetReservationByID(ctx context.Context, id uuid.UUID) (*Reservation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	reservation, err := scanReservation(rm.DB.QueryRowContext(ctx, `SELECT `+reservationColumns+` FROM asset_reservation WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReservationNotFound
+	}
+	return reservation, err
+}
+
+// GetAssetReservations retrieves active reservations of an asset overlapping [from, to)
+func (rm *ReservationModel) GetAssetReservations(ctx context.Context, assetID uuid.UUID, from, to time.Time) ([]*Reservation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return rm.queryReservations(ctx, `asset_id = $1`, assetID, from, to)
+}
+
+// GetEmployeeReservations retrieves active reservations held by an employee overlapping [from, to)
+func (rm *ReservationModel) GetEmployeeReservations(ctx context.Context, employeeID uuid.UUID, from, to time.Time) ([]*Reservation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	return rm.queryReservations(ctx, `employee_id = $1`, employeeID, from, to)
+}
+
+func (rm *ReservationModel) queryReservations(ctx context.Context, where string, id uuid.UUID, from, to time.Time) ([]*Reservation, error) {
+	rows, err := rm.DB.QueryContext(ctx, `
+		SELECT `+reservationColumns+`
+		FROM asset_reservation
+		WHERE `+where+`
+		  AND archive_at IS NULL
+		  AND during && tstzrange($2, $3, '[)')
+		ORDER BY lower(during)
+	`, id, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []*Reservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// GetAvailability returns an asset's reservations in [from, to) and the free slots between them
+func (rm *ReservationModel) GetAvailability(ctx context.Context, assetID uuid.UUID, from, to time.Time) (*Availability, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	reservations, err := rm.GetAssetReservations(ctx, assetID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Availability{
+		AssetID:      assetID,
+		From:         from,
+		To:           to,
+		Reservations: reservations,
+		Free:         FreeSlots(from, to, reservations),
+	}, nil
+}
+
+// FreeSlots returns the gaps in [from, to) left by non-overlapping reservations sorted by start
+func FreeSlots(from, to time.Time, reservations []*Reservation) []TimeSlot {
+	var free []TimeSlot
+
+	cursor := from
+	for _, reservation := range reservations {
+		if reservation.StartsAt.After(cursor) {
+			free = append(free, TimeSlot{From: cursor, To: reservation.StartsAt})
+		}
+		if reservation.EndsAt.After(cursor) {
+			cursor = reservation.EndsAt
+		}
+	}
+	if to.After(cursor) {
+		free = append(free, TimeSlot{From: cursor, To: to})
+	}
+
+	return free
+}
@@ -0,0 +1,111 @@
+package models_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestCreateReservation(t *testing.T) {
+	day := time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)
+	at := func(hour int) time.Time { return day.Add(time.Duration(hour) * time.Hour) }
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		wantErr  error
+	}{
+		{"before", at(7), at(9), nil},
+		{"touching end", at(12), at(13), nil},
+		{"overlapping start", at(8), at(10), models.ErrReservationConflict},
+		{"inside", at(10), at(11), models.ErrReservationConflict},
+		{"empty", at(14), at(14), models.ErrInvalidReservation},
+		{"backwards", at(15), at(14), models.ErrInvalidReservation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+				ctx := context.Background()
+				asset := mustAsset(t, repos, "projector", "London")
+				employee := mustEmployee(t, repos, "engineer", nil)
+
+				booked := &models.Reservation{AssetID: asset.Id, EmployeeID: employee.ID, StartsAt: at(9), EndsAt: at(12)}
+				if err := repos.Reservations.CreateReservation(ctx, booked); err != nil {
+					t.Fatalf("CreateReservation: %v", err)
+				}
+
+				reservation := &models.Reservation{AssetID: asset.Id, EmployeeID: employee.ID, StartsAt: tt.from, EndsAt: tt.to}
+				if err := repos.Reservations.CreateReservation(ctx, reservation); !errors.Is(err, tt.wantErr) {
+					t.Errorf("CreateReservation error = %v, want %v", err, tt.wantErr)
+				}
+			})
+		})
+	}
+}
+
+func TestReservationQueries(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		day := time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)
+		at := func(hour int) time.Time { return day.Add(time.Duration(hour) * time.Hour) }
+
+		asset := mustAsset(t, repos, "projector", "London")
+		employee := mustEmployee(t, repos, "engineer", nil)
+		morning := &models.Reservation{AssetID: asset.Id, EmployeeID: employee.ID, StartsAt: at(9), EndsAt: at(11), Note: "standup"}
+		afternoon := &models.Reservation{AssetID: asset.Id, EmployeeID: employee.ID, StartsAt: at(14), EndsAt: at(16)}
+		for _, r := range []*models.Reservation{morning, afternoon} {
+			if err := repos.Reservations.CreateReservation(ctx, r); err != nil {
+				t.Fatalf("CreateReservation: %v", err)
+			}
+		}
+
+		got, err := repos.Reservations.GetReservationByID(ctx, morning.ID)
+		if err != nil {
+			t.Fatalf("GetReservationByID: %v", err)
+		}
+		if got.Note != "standup" || !got.StartsAt.Equal(morning.StartsAt) || !got.EndsAt.Equal(morning.EndsAt) {
+			t.Errorf("GetReservationByID = %+v, want %+v", got, morning)
+		}
+
+		byAsset, err := repos.Reservations.GetAssetReservations(ctx, asset.Id, at(10), at(15))
+		if err != nil || len(byAsset) != 2 || byAsset[0].ID != morning.ID {
+			t.Errorf("GetAssetReservations = %v, %v; want morning then afternoon", byAsset, err)
+		}
+		byEmployee, err := repos.Reservations.GetEmployeeReservations(ctx, employee.ID, at(12), at(24))
+		if err != nil || len(byEmployee) != 1 || byEmployee[0].ID != afternoon.ID {
+			t.Errorf("GetEmployeeReservations = %v, %v; want the afternoon", byEmployee, err)
+		}
+
+		availability, err := repos.Reservations.GetAvailability(ctx, asset.Id, at(8), at(18))
+		if err != nil {
+			t.Fatalf("GetAvailability: %v", err)
+		}
+		want := []models.TimeSlot{{From: at(8), To: at(9)}, {From: at(11), To: at(14)}, {From: at(16), To: at(18)}}
+		if len(availability.Free) != len(want) {
+			t.Fatalf("GetAvailability free = %v, want %v", availability.Free, want)
+		}
+		for i := range want {
+			if !availability.Free[i].From.Equal(want[i].From) || !availability.Free[i].To.Equal(want[i].To) {
+				t.Errorf("free slot %d = %v, want %v", i, availability.Free[i], want[i])
+			}
+		}
+
+		if err := repos.Reservations.ArchiveReservation(ctx, morning.ID); err != nil {
+			t.Fatalf("ArchiveReservation: %v", err)
+		}
+		rebook := &models.Reservation{AssetID: asset.Id, EmployeeID: employee.ID, StartsAt: at(9), EndsAt: at(10)}
+		if err := repos.Reservations.CreateReservation(ctx, rebook); err != nil {
+			t.Errorf("CreateReservation over an archived booking: %v", err)
+		}
+
+		if _, err := repos.Reservations.GetReservationByID(ctx, uuid.New()); !errors.Is(err, models.ErrReservationNotFound) {
+			t.Errorf("GetReservationByID(missing) error = %v, want ErrReservationNotFound", err)
+		}
+	})
+}
@@ -1,56 +1,110 @@
 package models
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// DefaultSessionLifetime is how long a session lasts when it is created without an expiry
+const DefaultSessionLifetime = 24 * time.Hour
+
+// Session is an admin's login, or an employee's when EmployeeID is set and AdminID is uuid.Nil. It
+// is authenticated by a random token of which only the hash is stored. Archive_at is when the
+// session ends; activity moves it forward, but never past ExpiresAt.
 type Session struct {
 	ID         uuid.UUID `json:"id"`
 	AdminID    uuid.UUID `json:"admin_id"`
+	EmployeeID *uuid.UUID `json:"employee_id,omitempty"`
+	TokenHash  string    `json:"-"`
 	Archive_at time.Time `json:"archive_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
 	CreatedAt  time.Time `json:"created_at"`
 }
 
+const sessionColumns = `id, admin_id, employee_id, token_hash, archive_at, expires_at, last_seen_at, created_at`
+
+func scanSession(row rowScanner) (*Session, error) {
+	session := &Session{}
+	err := row.Scan(&session.ID, &session.AdminID, &session.EmployeeID, &session.TokenHash, &session.Archive_at, &session.ExpiresAt,
+		&session.LastSeenAt, &session.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
 type SessionModel struct {
 	DB *sql.DB
 }
 
-func (sm *SessionModel) GetAllSessions() ([]*Session, error){
-	query := `Select * FROM admin_session`
-	rows, err := sm.DB.Query(query)
-	if err != nil{
-		return nil, err
-
-	}
-	defer rows.Close()
+func (sm *SessionModel) GetAllSessions(ctx context.Context) ([]*Session, error){
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
 	var sessions []*Session
-	for rows.Next(){
-		session := &Session{}
-		err := rows.Scan(&session.ID, &session.AdminID, &session.Archive_at, &session.CreatedAt)
-		if err != nil{
-			return nil, err
-		}
+	err := sm.ForEachSession(ctx, func(session *Session) error {
 		sessions = append(sessions, session)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
 
+// ForEachSession calls fn for each session as it is read from the database cursor
+func (sm *SessionModel) ForEachSession(ctx context.Context, fn func(*Session) error) error {
+	query := `SELECT ` + sessionColumns + ` FROM admin_session ORDER BY created_at, id`
+	rows, err := sm.DB.QueryContext(ctx, query)
+	if err != nil {
+		return err
 	}
-	if err:= rows.Err(); err != nil{
-		return nil, err
+	defer rows.Close()
 
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(session); err != nil {
+			return err
+		}
 	}
-	return sessions, nil
+	return rows.Err()
 }
-func (sm *SessionModel) CreateSession(session *Session) error {
-	archive_at := time.Now().Add(time.Minute * 24)
-	query := "INSERT INTO admin_session (id, admin_id, archive_at, created_at) VALUES ($1, $2, $3, $4) RETURNING id"
 
-    session.ID = uuid.New()
+// CreateSession stores a session. A session created without an expiry lasts
+// DefaultSessionLifetime, and one without an end runs until its expiry.
+func (sm *SessionModel) CreateSession(ctx context.Context, session *Session) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO admin_session (id, admin_id, employee_id, token_hash, archive_at, expires_at, last_seen_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id
+	`
+
+	session.ID = uuid.New()
 	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	if session.ExpiresAt.IsZero() {
+		session.ExpiresAt = session.CreatedAt.Add(DefaultSessionLifetime)
+	}
+	if session.Archive_at.IsZero() || session.Archive_at.After(session.ExpiresAt) {
+		session.Archive_at = session.ExpiresAt
+	}
 
-	err := sm.DB.QueryRow(query, session.ID, session.AdminID, archive_at, session.CreatedAt).Scan(&session.ID)
+	// Employee sessions have no admin
+	var adminID *uuid.UUID
+	if session.AdminID != uuid.Nil {
+		adminID = &session.AdminID
+	}
+	err := sm.DB.QueryRowContext(ctx, query, session.ID, adminID, session.EmployeeID, session.TokenHash, session.Archive_at,
+		session.ExpiresAt, session.LastSeenAt, session.CreatedAt).Scan(&session.ID)
 
 	if err != nil {
 		return err
@@ -59,46 +113,108 @@ func (sm *SessionModel) CreateSession(session *Session) error {
 	return nil
 }
 
-func (sm *SessionModel) GetSessionByID(id uuid.UUID) (*Session, error) {
-	query := `
-		SELECT admin_id,  archive_at, created_at
-		FROM admin_session
-		WHERE id = $1
-	`
+func (sm *SessionModel) GetSessionByID(ctx context.Context, id uuid.UUID) (*Session, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
 
-	session := &Session{}
-	err := sm.DB.QueryRow(query, id).Scan(&session.AdminID,&session.Archive_at, &session.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
+	query := `SELECT ` + sessionColumns + ` FROM admin_session WHERE id = $1`
+	return scanSession(sm.DB.QueryRowContext(ctx, query, id))
+}
 
-	session.ID = id
-	return session, nil
+// GetSessionByTokenHash retrieves the session whose token hashes to hash, ended or not
+func (sm *SessionModel) GetSessionByTokenHash(ctx context.Context, hash string) (*Session, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if hash == "" {
+		return nil, sql.ErrNoRows
+	}
+	query := `SELECT ` + sessionColumns + ` FROM admin_session WHERE token_hash = $1`
+	return scanSession(sm.DB.QueryRowContext(ctx, query, hash))
 }
-func (sm *SessionModel) UpdateSession(session *Session)error{
+
+// UpdateSession moves the end of a session and records when it was last seen. A session that has
+// already ended is left alone, so renewing it cannot race a logout back to life.
+func (sm *SessionModel) UpdateSession(ctx context.Context, session *Session)error{
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 	    UPDATE admin_session
-		SET archive_at = $1 
-		WHERE id = $2
+		SET archive_at = $1, last_seen_at = $2
+		WHERE id = $3 AND archive_at > $4
 	`
-	_, err := sm.DB.Exec(query,session.Archive_at,session.ID)
+	_, err := sm.DB.ExecContext(ctx, query,session.Archive_at,session.LastSeenAt,session.ID,time.Now())
 	return err
 }
 
-func (sm *SessionModel) ArchiveSession(id uuid.UUID) error{
+// RotateSessionToken replaces the token of a session that has not ended
+func (sm *SessionModel) RotateSessionToken(ctx context.Context, id uuid.UUID, tokenHash string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := sm.DB.ExecContext(ctx, `UPDATE admin_session SET token_hash = $1 WHERE id = $2 AND archive_at > $3`,
+		tokenHash, id, time.Now())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	return nil
+}
+
+// ArchiveAdminSessions ends every session of an admin except the one with ID except, which may be
+// uuid.Nil, and returns how many it ended
+func (sm *SessionModel) ArchiveAdminSessions(ctx context.Context, adminID, except uuid.UUID) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := sm.DB.ExecContext(ctx, `
+		UPDATE admin_session SET archive_at = $1
+		WHERE admin_id = $2 AND id <> $3 AND archive_at > $1
+	`, time.Now(), adminID, except)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PurgeSessions deletes the sessions that ended before the given time and returns how many
+func (sm *SessionModel) PurgeSessions(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := sm.DB.ExecContext(ctx, `DELETE FROM admin_session WHERE archive_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (sm *SessionModel) ArchiveSession(ctx context.Context, id uuid.UUID) error{
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `UPDATE admin_session SET archive_at = $1 WHERE id = $2`
-	_, err := sm.DB.Exec(query,time.Now(),id)
+	_, err := sm.DB.ExecContext(ctx, query,time.Now(),id)
 	if err != nil{
 		return err
 	}
 	return nil
 }
-func (sm *SessionModel) DeleteSession(id uuid.UUID) error {
+func (sm *SessionModel) DeleteSession(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	query := `
 		DELETE FROM admin_session
 		WHERE id = $1
 	`
 
-	_, err := sm.DB.Exec(query, id)
+	_, err := sm.DB.ExecContext(ctx, query, id)
 	return err
 }
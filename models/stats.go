@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Counts is a snapshot of the inventory, exported as metrics
+type Counts struct {
+	// AssetsByStatus counts assets that have not been archived, keyed by status
+	AssetsByStatus    map[string]int
+	ActiveAssignments int
+	ActiveSessions    int
+}
+
+// StatsModel represents the model for inventory counts
+type StatsModel struct {
+	DB *sql.DB
+}
+
+// GetCounts counts assets by status, current assignments and unexpired sessions
+func (sm *StatsModel) GetCounts(ctx context.Context) (*Counts, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	counts := &Counts{AssetsByStatus: make(map[string]int)}
+
+	rows, err := sm.DB.QueryContext(ctx, `SELECT status, COUNT(*) FROM asset WHERE archive_at IS NULL GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts.AssetsByStatus[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = sm.DB.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM employee_asset_mapping WHERE archive_at IS NULL),
+			(SELECT COUNT(*) FROM admin_session WHERE archive_at > $1)
+	`, time.Now()).Scan(&counts.ActiveAssignments, &counts.ActiveSessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
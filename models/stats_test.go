@@ -0,0 +1,51 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestGetCounts(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		employee := mustEmployee(t, repos, "engineer", nil)
+		mustAssign(t, repos, employee, mustAsset(t, repos, "laptop", "London"))
+		mustAsset(t, repos, "laptop", "London")
+		archived := mustAsset(t, repos, "laptop", "London")
+		if err := repos.Assets.ArchiveAsset(ctx, archived.Id); err != nil {
+			t.Fatalf("ArchiveAsset: %v", err)
+		}
+
+		admin := mustAdmin(t, repos)
+		live := &models.Session{AdminID: admin.ID}
+		ended := &models.Session{AdminID: admin.ID}
+		for _, session := range []*models.Session{live, ended} {
+			if err := repos.Sessions.CreateSession(ctx, session); err != nil {
+				t.Fatalf("CreateSession: %v", err)
+			}
+		}
+		if err := repos.Sessions.ArchiveSession(ctx, ended.ID); err != nil {
+			t.Fatalf("ArchiveSession: %v", err)
+		}
+
+		counts, err := repos.Stats.GetCounts(ctx)
+		if err != nil {
+			t.Fatalf("GetCounts: %v", err)
+		}
+		want := map[string]int{models.AssetStatusAssigned: 1, models.AssetStatusAvailable: 1}
+		if len(counts.AssetsByStatus) != len(want) {
+			t.Errorf("AssetsByStatus = %v, want %v", counts.AssetsByStatus, want)
+		}
+		for status, n := range want {
+			if counts.AssetsByStatus[status] != n {
+				t.Errorf("AssetsByStatus[%s] = %d, want %d", status, counts.AssetsByStatus[status], n)
+			}
+		}
+		if counts.ActiveAssignments != 1 || counts.ActiveSessions != 1 {
+			t.Errorf("GetCounts = %d assignments, %d sessions; want 1 and 1", counts.ActiveAssignments, counts.ActiveSessions)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrTwoFactorEnabled is returned when enrolling an admin whose authenticator is already confirmed
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// TOTP is an admin's authenticator app. It only guards logins once ConfirmedAt is set, after the
+// admin has entered a code from it. LastStep is the newest TOTP time step that has been used.
+type TOTP struct {
+	AdminID     uuid.UUID  `json:"admin_id"`
+	Secret      string     `json:"-"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	LastStep    int64      `json:"-"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// LoginChallenge is a login that passed the password check and waits for the second factor. Only
+// a hash of its token is stored.
+type LoginChallenge struct {
+	ID        uuid.UUID `json:"id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	TokenHash string    `json:"-"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TwoFactorModel represents the model for admins' second factors and pending logins
+type TwoFactorModel struct {
+	DB *sql.DB
+}
+
+// GetTOTP retrieves an admin's authenticator, confirmed or not
+func (tm *TwoFactorModel) GetTOTP(ctx context.Context, adminID uuid.UUID) (*TOTP, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	totp := &TOTP{}
+	err := tm.DB.QueryRowContext(ctx, `
+		SELECT admin_id, secret, confirmed_at, last_step, created_at FROM admin_totp WHERE admin_id = $1
+	`, adminID).Scan(&totp.AdminID, &totp.Secret, &totp.ConfirmedAt, &totp.LastStep, &totp.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return totp, nil
+}
+
+// StartTOTP stores a new, unconfirmed authenticator for an admin, replacing one they never
+// confirmed. It returns ErrTwoFactorEnabled if the admin already has a confirmed one.
+func (tm *TwoFactorModel) StartTOTP(ctx context.Context, totp *TOTP) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	totp.ConfirmedAt, totp.LastStep, totp.CreatedAt = nil, 0, time.Now()
+	result, err := tm.DB.ExecContext(ctx, `
+		INSERT INTO admin_totp (admin_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (admin_id) DO UPDATE SET secret = EXCLUDED.secret, last_step = 0, created_at = EXCLUDED.created_at
+		WHERE admin_totp.confirmed_at IS NULL
+	`, totp.AdminID, totp.Secret, totp.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error enrolling authenticator: %w", err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = ErrTwoFactorEnabled
+		}
+		return err
+	}
+	return nil
+}
+
+// ConfirmTOTP turns on an admin's unconfirmed authenticator, recording step as used, and replaces
+// their recovery codes with the given hashes. It returns sql.ErrNoRows if there is nothing to confirm.
+func (tm *TwoFactorModel) ConfirmTOTP(ctx context.Context, adminID uuid.UUID, step int64, recoveryHashes []string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := tm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE admin_totp SET confirmed_at = $1, last_step = $2 WHERE admin_id = $3 AND confirmed_at IS NULL
+	`, time.Now(), step, adminID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, adminID, recoveryHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a code for step was used. It returns sql.ErrNoRows if that step or a
+// later one was already used, so each code logs in once.
+func (tm *TwoFactorModel) UseTOTPStep(ctx context.Context, adminID uuid.UUID, step int64) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := tm.DB.ExecContext(ctx, `
+		UPDATE admin_totp SET last_step = $1 WHERE admin_id = $2 AND confirmed_at IS NOT NULL AND last_step < $1
+	`, step, adminID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, db execer, adminID uuid.UUID, hashes []string) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM admin_recovery_code WHERE admin_id = $1`, adminID); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, hash := range hashes {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO admin_recovery_code (id, admin_id, code_hash, created_at) VALUES ($1, $2, $3, $4)
+		`, uuid.New(), adminID, hash, now)
+		if err != nil {
+			return fmt.Errorf("error storing recovery code: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReplaceRecoveryCodes replaces all of an admin's recovery codes, used or not, with the given hashes
+func (tm *TwoFactorModel) ReplaceRecoveryCodes(ctx context.Context, adminID uuid.UUID, hashes []string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := tm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, adminID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseRecoveryCode spends the recovery code with the given hash. It returns sql.ErrNoRows if the
+// admin has no such unused code.
+func (tm *TwoFactorModel) UseRecoveryCode(ctx context.Context, adminID uuid.UUID, hash string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := tm.DB.ExecContext(ctx, `
+		UPDATE admin_recovery_code SET used_at = $1 WHERE admin_id = $2 AND code_hash = $3 AND used_at IS NULL
+	`, time.Now(), adminID, hash)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	return nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes an admin has left
+func (tm *TwoFactorModel) CountRecoveryCodes(ctx context.Context, adminID uuid.UUID) (int, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := tm.DB.QueryRowContext(ctx, `
+		SELECT count(*) FROM admin_recovery_code WHERE admin_id = $1 AND used_at IS NULL
+	`, adminID).Scan(&count)
+	return count, err
+}
+
+// DeleteTOTP removes an admin's authenticator, recovery codes and pending logins, so they log in
+// with their password alone until they enroll again
+func (tm *TwoFactorModel) DeleteTOTP(ctx context.Context, adminID uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := tm.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM admin_login_challenge WHERE admin_id = $1`,
+		`DELETE FROM admin_recovery_code WHERE admin_id = $1`,
+		`DELETE FROM admin_totp WHERE admin_id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, adminID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CreateLoginChallenge stores a pending login. The caller sets the token hash and expiry.
+func (tm *TwoFactorModel) CreateLoginChallenge(ctx context.Context, challenge *LoginChallenge) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	challenge.ID = uuid.New()
+	challenge.Attempts = 0
+	challenge.CreatedAt = time.Now()
+	_, err := tm.DB.ExecContext(ctx, `
+		INSERT INTO admin_login_challenge (id, admin_id, token_hash, expires_at, created_at) VALUES ($1, $2, $3, $4, $5)
+	`, challenge.ID, challenge.AdminID, challenge.TokenHash, challenge.ExpiresAt, challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating login challenge: %w", err)
+	}
+	return nil
+}
+
+// GetLoginChallengeByTokenHash retrieves the pending login whose token has the given hash, expired or not
+func (tm *TwoFactorModel) GetLoginChallengeByTokenHash(ctx context.Context, hash string) (*LoginChallenge, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	challenge := &LoginChallenge{}
+	err := tm.DB.QueryRowContext(ctx, `
+		SELECT id, admin_id, token_hash, attempts, expires_at, created_at FROM admin_login_challenge WHERE token_hash = $1
+	`, hash).Scan(&challenge.ID, &challenge.AdminID, &challenge.TokenHash, &challenge.Attempts, &challenge.ExpiresAt,
+		&challenge.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// RestoreLoginChallenge puts back a pending login that was deleted to claim it, as given, so a
+// wrong code can be retried with the attempt counted
+func (tm *TwoFactorModel) RestoreLoginChallenge(ctx context.Context, challenge *LoginChallenge) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	_, err := tm.DB.ExecContext(ctx, `
+		INSERT INTO admin_login_challenge (id, admin_id, token_hash, attempts, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, challenge.ID, challenge.AdminID, challenge.TokenHash, challenge.Attempts, challenge.ExpiresAt, challenge.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error restoring login challenge: %w", err)
+	}
+	return nil
+}
+
+// DeleteLoginChallenge removes a pending login. It returns sql.ErrNoRows if it was already gone,
+// so only one request can complete a login.
+func (tm *TwoFactorModel) DeleteLoginChallenge(ctx context.Context, id uuid.UUID) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := tm.DB.ExecContext(ctx, `DELETE FROM admin_login_challenge WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	return nil
+}
+
+// PurgeLoginChallenges deletes the pending logins that expired before the given time and returns how many
+func (tm *TwoFactorModel) PurgeLoginChallenges(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	result, err := tm.DB.ExecContext(ctx, `DELETE FROM admin_login_challenge WHERE expires_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,128 @@
+package models_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/models"
+	"github.com/cameo1221/Go-Asset/models/modeltest"
+)
+
+func TestTOTPLifecycle(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		if _, err := repos.TwoFactor.GetTOTP(ctx, admin.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetTOTP before enrolling error = %v, want sql.ErrNoRows", err)
+		}
+		for _, secret := range []string{"FIRST", "SECOND"} {
+			if err := repos.TwoFactor.StartTOTP(ctx, &models.TOTP{AdminID: admin.ID, Secret: secret}); err != nil {
+				t.Fatalf("StartTOTP(%s): %v", secret, err)
+			}
+		}
+		totp, err := repos.TwoFactor.GetTOTP(ctx, admin.ID)
+		if err != nil || totp.Secret != "SECOND" || totp.ConfirmedAt != nil {
+			t.Fatalf("GetTOTP = %+v, %v; want the second, unconfirmed secret", totp, err)
+		}
+		if err := repos.TwoFactor.UseTOTPStep(ctx, admin.ID, 10); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UseTOTPStep(unconfirmed) error = %v, want sql.ErrNoRows", err)
+		}
+
+		if err := repos.TwoFactor.ConfirmTOTP(ctx, admin.ID, 10, []string{"a", "b"}); err != nil {
+			t.Fatalf("ConfirmTOTP: %v", err)
+		}
+		if err := repos.TwoFactor.ConfirmTOTP(ctx, admin.ID, 11, nil); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("ConfirmTOTP again error = %v, want sql.ErrNoRows", err)
+		}
+		if err := repos.TwoFactor.StartTOTP(ctx, &models.TOTP{AdminID: admin.ID, Secret: "THIRD"}); !errors.Is(err, models.ErrTwoFactorEnabled) {
+			t.Errorf("StartTOTP(confirmed) error = %v, want ErrTwoFactorEnabled", err)
+		}
+
+		// Each step is used once, and never one older than the last
+		if err := repos.TwoFactor.UseTOTPStep(ctx, admin.ID, 10); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UseTOTPStep(confirming step) error = %v, want sql.ErrNoRows", err)
+		}
+		if err := repos.TwoFactor.UseTOTPStep(ctx, admin.ID, 11); err != nil {
+			t.Errorf("UseTOTPStep(next step): %v", err)
+		}
+
+		if err := repos.TwoFactor.UseRecoveryCode(ctx, admin.ID, "a"); err != nil {
+			t.Errorf("UseRecoveryCode: %v", err)
+		}
+		if err := repos.TwoFactor.UseRecoveryCode(ctx, admin.ID, "a"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("UseRecoveryCode(used) error = %v, want sql.ErrNoRows", err)
+		}
+		if count, err := repos.TwoFactor.CountRecoveryCodes(ctx, admin.ID); err != nil || count != 1 {
+			t.Errorf("CountRecoveryCodes = %d, %v; want 1", count, err)
+		}
+		if err := repos.TwoFactor.ReplaceRecoveryCodes(ctx, admin.ID, []string{"a", "c", "d"}); err != nil {
+			t.Fatalf("ReplaceRecoveryCodes: %v", err)
+		}
+		if count, err := repos.TwoFactor.CountRecoveryCodes(ctx, admin.ID); err != nil || count != 3 {
+			t.Errorf("CountRecoveryCodes after replacing = %d, %v; want 3", count, err)
+		}
+
+		if err := repos.TwoFactor.DeleteTOTP(ctx, admin.ID); err != nil {
+			t.Fatalf("DeleteTOTP: %v", err)
+		}
+		if _, err := repos.TwoFactor.GetTOTP(ctx, admin.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetTOTP after delete error = %v, want sql.ErrNoRows", err)
+		}
+		if count, err := repos.TwoFactor.CountRecoveryCodes(ctx, admin.ID); err != nil || count != 0 {
+			t.Errorf("CountRecoveryCodes after delete = %d, %v; want 0", count, err)
+		}
+	})
+}
+
+func TestLoginChallenges(t *testing.T) {
+	modeltest.Run(t, func(t *testing.T, repos modeltest.Repositories) {
+		ctx := context.Background()
+		admin := mustAdmin(t, repos)
+
+		live := &models.LoginChallenge{AdminID: admin.ID, TokenHash: "live", ExpiresAt: time.Now().Add(time.Minute)}
+		stale := &models.LoginChallenge{AdminID: admin.ID, TokenHash: "stale", ExpiresAt: time.Now().Add(-time.Minute)}
+		for _, challenge := range []*models.LoginChallenge{live, stale} {
+			if err := repos.TwoFactor.CreateLoginChallenge(ctx, challenge); err != nil {
+				t.Fatalf("CreateLoginChallenge: %v", err)
+			}
+		}
+
+		got, err := repos.TwoFactor.GetLoginChallengeByTokenHash(ctx, "live")
+		if err != nil || got.ID != live.ID || got.AdminID != admin.ID {
+			t.Fatalf("GetLoginChallengeByTokenHash = %+v, %v; want challenge %s", got, err, live.ID)
+		}
+
+		// A claimed challenge can be put back with its attempts counted
+		if err := repos.TwoFactor.DeleteLoginChallenge(ctx, live.ID); err != nil {
+			t.Fatalf("DeleteLoginChallenge: %v", err)
+		}
+		got.Attempts++
+		if err := repos.TwoFactor.RestoreLoginChallenge(ctx, got); err != nil {
+			t.Fatalf("RestoreLoginChallenge: %v", err)
+		}
+		if err := repos.TwoFactor.RestoreLoginChallenge(ctx, got); err == nil {
+			t.Error("RestoreLoginChallenge of a live challenge succeeded")
+		}
+		restored, err := repos.TwoFactor.GetLoginChallengeByTokenHash(ctx, "live")
+		if err != nil || restored.ID != live.ID || restored.Attempts != 1 || !restored.ExpiresAt.Equal(got.ExpiresAt) {
+			t.Errorf("restored challenge = %+v, %v; want %s with 1 attempt", restored, err, live.ID)
+		}
+
+		if purged, err := repos.TwoFactor.PurgeLoginChallenges(ctx, time.Now()); err != nil || purged != 1 {
+			t.Errorf("PurgeLoginChallenges = %d, %v; want the stale challenge purged", purged, err)
+		}
+		if err := repos.TwoFactor.DeleteLoginChallenge(ctx, live.ID); err != nil {
+			t.Fatalf("DeleteLoginChallenge: %v", err)
+		}
+		if err := repos.TwoFactor.DeleteLoginChallenge(ctx, live.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("DeleteLoginChallenge(deleted) error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := repos.TwoFactor.GetLoginChallengeByTokenHash(ctx, "live"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetLoginChallengeByTokenHash(deleted) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+}
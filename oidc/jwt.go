@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Claims are the ID token claims the service uses
+type Claims struct {
+	Issuer          string      `json:"iss"`
+	Subject         string      `json:"sub"`
+	Audience        audience    `json:"aud"`
+	AuthorizedParty string      `json:"azp"`
+	Expiry          numericDate `json:"exp"`
+	IssuedAt        numericDate `json:"iat"`
+	NotBefore       numericDate `json:"nbf"`
+	Nonce           string      `json:"nonce"`
+	Email           string      `json:"email"`
+	EmailVerified   boolClaim   `json:"email_verified"`
+	Name            string      `json:"name"`
+}
+
+// audience is a single string or an array of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// numericDate is seconds since the epoch, possibly with a fraction
+type numericDate float64
+
+// Time converts the date
+func (d numericDate) Time() time.Time {
+	return time.Unix(0, int64(float64(d)*float64(time.Second)))
+}
+
+// boolClaim is a boolean that some providers send as the string "true"
+type boolClaim bool
+
+func (b *boolClaim) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("email_verified: %s is not a boolean", data)
+	}
+	return nil
+}
+
+// jwtHeader is the protected header of a signed token
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jwt is a compact JWS split into its parts
+type jwt struct {
+	header    jwtHeader
+	payload   []byte
+	signed    string
+	signature []byte
+}
+
+func parseJWT(raw string) (*jwt, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a signed JWT", ErrInvalidToken)
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	token := &jwt{signed: parts[0] + "." + parts[1]}
+	if err := json.Unmarshal(headerJSON, &token.header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	if token.payload, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrInvalidToken, err)
+	}
+	if token.signature, err = base64.RawURLEncoding.DecodeString(parts[2]); err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	return token, nil
+}
+
+// algorithms are the signature algorithms accepted, by JWS name. HMAC and "none" are not, since
+// ID tokens have to be signed with the provider's published keys.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// verify checks the token's signature with key
+func (t *jwt) verify(key *publicKey) error {
+	alg := t.header.Algorithm
+	hash, ok := algorithms[alg]
+	if !ok {
+		return fmt.Errorf("%w: algorithm %q is not accepted", ErrInvalidToken, alg)
+	}
+	if key.algorithm != "" && key.algorithm != alg {
+		return fmt.Errorf("%w: key %q is for %s, not %s", ErrInvalidToken, key.id, key.algorithm, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(t.signed))
+	digest := h.Sum(nil)
+
+	var err error
+	switch k := key.key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(k, hash, digest, t.signature)
+		case "PS":
+			err = rsa.VerifyPSS(k, hash, digest, t.signature, nil)
+		default:
+			err = fmt.Errorf("%s needs an EC key", alg)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(t.signature) != 2*size {
+			err = fmt.Errorf("%s signature does not fit an EC key", alg)
+		} else if r, s := new(big.Int).SetBytes(t.signature[:size]), new(big.Int).SetBytes(t.signature[size:]); !ecdsa.Verify(k, digest, r, s) {
+			err = fmt.Errorf("ECDSA verification failed")
+		}
+	default:
+		err = fmt.Errorf("unsupported key type %T", key.key)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	return nil
+}
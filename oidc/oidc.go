@@ -0,0 +1,314 @@
+// Package oidc signs users in with an OpenID Connect provider, using the authorization code flow
+// with PKCE. The provider's endpoints are read from its discovery document, and ID tokens are
+// checked against the signing keys it publishes at its JWKS URL.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config identifies this service to the provider
+type Config struct {
+	// Issuer is the provider's issuer URL; its discovery document is served under it
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback the provider sends the user back to, registered with it
+	RedirectURL string
+	// Scopes are requested on login; openid is always added
+	Scopes []string
+}
+
+// Enabled reports whether a provider is configured
+func (c Config) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// Validate checks that a configured provider has what the flow needs
+func (c Config) Validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	issuer, err := url.Parse(c.Issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		return fmt.Errorf("issuer %q is not an http or https URL", c.Issuer)
+	}
+	if c.ClientID == "" {
+		return errors.New("a client ID is required")
+	}
+	redirect, err := url.Parse(c.RedirectURL)
+	if err != nil || !redirect.IsAbs() {
+		return fmt.Errorf("redirect URL %q is not an absolute URL", c.RedirectURL)
+	}
+	return nil
+}
+
+// ErrInvalidToken is wrapped by every reason an ID token is refused
+var ErrInvalidToken = errors.New("invalid ID token")
+
+// Error is an error response from the provider's token endpoint, or sent back to the callback
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return "identity provider: " + e.Code
+	}
+	return "identity provider: " + e.Code + ": " + e.Description
+}
+
+// metadata is the part of the discovery document the flow uses
+type metadata struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Provider talks to one OpenID Connect provider. Discovery happens on first use and is retried
+// until it succeeds, so the service starts even while the provider is unreachable.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     *keySet
+}
+
+// NewProvider returns a Provider for config, making its requests with client
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	return &Provider{config: config, client: client}
+}
+
+// Issuer is the provider's issuer URL, which with a subject identifies a user
+func (p *Provider) Issuer() string {
+	return p.config.Issuer
+}
+
+// discover fetches and caches the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	var md metadata
+	if err := p.getJSON(ctx, wellKnown, &md); err != nil {
+		return nil, fmt.Errorf("discovering identity provider: %w", err)
+	}
+	if md.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("discovering identity provider: issuer %q does not match %q", md.Issuer, p.config.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, errors.New("discovering identity provider: authorization, token or JWKS endpoint missing")
+	}
+
+	p.metadata = &md
+	p.keys = &keySet{url: md.JWKSURI, client: p.client}
+	return p.metadata, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// AuthRequest is a login in progress. It has to be kept, such as in a cookie, from AuthCodeURL
+// until the provider redirects back, and is checked then.
+type AuthRequest struct {
+	// State ties the callback to the browser that started the login
+	State string
+	// Nonce ties the ID token to this login
+	Nonce string
+	// Verifier is the PKCE code verifier; only its hash goes to the provider until the code exchange
+	Verifier string
+}
+
+// NewAuthRequest starts a login with fresh random values
+func NewAuthRequest() (*AuthRequest, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &AuthRequest{State: values[0], Nonce: values[1], Verifier: values[2]}, nil
+}
+
+// CodeChallenge is the S256 PKCE challenge of a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL is where to send the user's browser to log in at the provider
+func (p *Provider) AuthCodeURL(ctx context.Context, req *AuthRequest) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("authorization endpoint: %w", err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", req.State)
+	query.Set("nonce", req.Nonce)
+	query.Set("code_challenge", CodeChallenge(req.Verifier))
+	query.Set("code_challenge_method", "S256")
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// tokenResponse is the part of the token endpoint's answer the flow uses
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// Exchange trades the code the provider sent to the callback for an ID token, and returns the
+// token's claims once it is verified against req
+func (p *Provider) Exchange(ctx context.Context, code string, req *AuthRequest) (*Claims, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("code_verifier", req.Verifier)
+	form.Set("client_id", p.config.ClientID)
+	// client_secret_basic is the default; some providers only take the secret in the form
+	secretInForm := p.config.ClientSecret != "" && !slices.Contains(md.TokenEndpointAuthMethods, "client_secret_basic") &&
+		slices.Contains(md.TokenEndpointAuthMethods, "client_secret_post")
+	if secretInForm {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	tokenReq, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenReq.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" && !secretInForm {
+		tokenReq.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(tokenReq)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	defer resp.Body.Close()
+	body := io.LimitReader(resp.Body, 1<<20)
+
+	if resp.StatusCode != http.StatusOK {
+		providerErr := &Error{}
+		if err := json.NewDecoder(body).Decode(providerErr); err != nil || providerErr.Code == "" {
+			return nil, fmt.Errorf("exchanging code: %s", resp.Status)
+		}
+		return nil, providerErr
+	}
+	var token tokenResponse
+	if err := json.NewDecoder(body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: the provider returned none", ErrInvalidToken)
+	}
+
+	return p.VerifyIDToken(ctx, token.IDToken, req.Nonce)
+}
+
+// clockSkew is how far the provider's clock may be from ours when checking expiry
+const clockSkew = time.Minute
+
+// VerifyIDToken checks an ID token's signature against the provider's keys and that it was issued
+// by the provider, for this client and this login's nonce, and has not expired
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	token, err := parseJWT(raw)
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.keys.key(ctx, token.header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := token.verify(key); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(token.payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != p.config.Issuer:
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Issuer)
+	case !slices.Contains(claims.Audience, p.config.ClientID):
+		return nil, fmt.Errorf("%w: not issued to this client", ErrInvalidToken)
+	case len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID:
+		return nil, fmt.Errorf("%w: authorized party %q is not this client", ErrInvalidToken, claims.AuthorizedParty)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	case claims.Expiry == 0 || now.After(claims.Expiry.Time().Add(clockSkew)):
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.NotBefore != 0 && now.Add(clockSkew).Before(claims.NotBefore.Time()):
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	case nonce != "" && claims.Nonce != nonce:
+		return nil, fmt.Errorf("%w: nonce does not match this login", ErrInvalidToken)
+	}
+	return &claims, nil
+}
@@ -0,0 +1,156 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/oidc"
+	"github.com/cameo1221/Go-Asset/oidc/oidctest"
+)
+
+const redirectURL = "https://assets.example.com/sessions/oidc/callback"
+
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+	stub := oidctest.NewServer(t, "go-asset", "s3cret")
+	stub.SetUser(oidctest.User{Subject: "u-1", Email: "grace@example.com", EmailVerified: true, Name: "Grace"})
+	provider := oidc.NewProvider(stub.Config(redirectURL), nil)
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		t.Fatalf("NewAuthRequest: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, req)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	query := mustParse(t, authURL).Query()
+	if query.Get("code_challenge") != oidc.CodeChallenge(req.Verifier) || query.Get("code_challenge_method") != "S256" ||
+		query.Get("scope") != "openid email profile" || query.Get("redirect_uri") != redirectURL {
+		t.Errorf("AuthCodeURL = %s, want a PKCE request for the openid, email and profile scopes", authURL)
+	}
+
+	callback := oidctest.Authorize(t, authURL).Query()
+	if callback.Get("state") != req.State {
+		t.Fatalf("callback state = %q, want %q", callback.Get("state"), req.State)
+	}
+
+	// The verifier has to match the challenge the login started with
+	wrong := *req
+	wrong.Verifier = "not-the-verifier"
+	var providerErr *oidc.Error
+	if _, err := provider.Exchange(ctx, callback.Get("code"), &wrong); !errors.As(err, &providerErr) || providerErr.Code != "invalid_grant" {
+		t.Fatalf("Exchange with the wrong verifier = %v, want invalid_grant", err)
+	}
+
+	callback = oidctest.Authorize(t, authURL).Query()
+	claims, err := provider.Exchange(ctx, callback.Get("code"), req)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "u-1" || claims.Email != "grace@example.com" || !claims.EmailVerified || claims.Name != "Grace" || claims.Nonce != req.Nonce {
+		t.Errorf("claims = %+v, want Grace's with the login's nonce", claims)
+	}
+
+	// Codes are used once
+	if _, err := provider.Exchange(ctx, callback.Get("code"), req); err == nil {
+		t.Error("exchanging a code twice succeeded")
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	ctx := context.Background()
+	stub := oidctest.NewServer(t, "go-asset", "s3cret")
+	provider := oidc.NewProvider(stub.Config(redirectURL), nil)
+	user := oidctest.User{Subject: "u-1", Email: "grace@example.com"}
+
+	token := func(change func(claims map[string]any)) string {
+		claims := stub.Claims(user, "n-1")
+		if change != nil {
+			change(claims)
+		}
+		return stub.IDToken(claims)
+	}
+
+	if claims, err := provider.VerifyIDToken(ctx, token(nil), "n-1"); err != nil || claims.Subject != "u-1" || claims.EmailVerified {
+		t.Fatalf("VerifyIDToken = %+v, %v; want an unverified email for u-1", claims, err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, token(func(c map[string]any) {
+		c["aud"] = []string{"other", "go-asset"}
+		c["azp"] = "go-asset"
+		c["email_verified"] = "true"
+	}), "n-1"); err != nil {
+		t.Errorf("VerifyIDToken with several audiences: %v", err)
+	}
+
+	valid := token(nil)
+	parts := strings.Split(valid, ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	hmac := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"stub-key"}`)) + "." + parts[1] + "." + parts[2]
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"`+stub.URL+`","sub":"admin"}`)) + "." + parts[2]
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		nonce string
+	}{
+		{"wrong audience", token(func(c map[string]any) { c["aud"] = "other" }), "n-1"},
+		{"other authorized party", token(func(c map[string]any) { c["aud"] = []string{"go-asset", "other"}; c["azp"] = "other" }), "n-1"},
+		{"wrong issuer", token(func(c map[string]any) { c["iss"] = "https://evil.example.com" }), "n-1"},
+		{"expired", token(func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() }), "n-1"},
+		{"not valid yet", token(func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() }), "n-1"},
+		{"no subject", token(func(c map[string]any) { delete(c, "sub") }), "n-1"},
+		{"wrong nonce", valid, "n-2"},
+		{"tampered", tampered, "n-1"},
+		{"unsigned", none, "n-1"},
+		{"HMAC", hmac, "n-1"},
+		{"garbage", "not.a.jwt", "n-1"},
+	} {
+		if _, err := provider.VerifyIDToken(ctx, tt.token, tt.nonce); !errors.Is(err, oidc.ErrInvalidToken) {
+			t.Errorf("%s: VerifyIDToken = %v, want ErrInvalidToken", tt.name, err)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	stub := oidctest.NewServer(t, "go-asset", "s3cret")
+	config := stub.Config(redirectURL)
+	config.Issuer += "/"
+	provider := oidc.NewProvider(config, nil)
+
+	req, _ := oidc.NewAuthRequest()
+	if _, err := provider.AuthCodeURL(context.Background(), req); err == nil {
+		t.Error("AuthCodeURL succeeded for a provider claiming another issuer")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, tt := range []struct {
+		config oidc.Config
+		ok     bool
+	}{
+		{oidc.Config{}, true},
+		{oidc.Config{Issuer: "https://idp.example.com", ClientID: "go-asset", RedirectURL: redirectURL}, true},
+		{oidc.Config{Issuer: "idp.example.com", ClientID: "go-asset", RedirectURL: redirectURL}, false},
+		{oidc.Config{Issuer: "https://idp.example.com", RedirectURL: redirectURL}, false},
+		{oidc.Config{Issuer: "https://idp.example.com", ClientID: "go-asset", RedirectURL: "/callback"}, false},
+	} {
+		if err := tt.config.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok %t", tt.config, err, tt.ok)
+		}
+	}
+}
+
+func mustParse(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parsing %q: %v", raw, err)
+	}
+	return u
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often Memory drops buckets that have refilled and failures that expired
+const sweepInterval = time.Minute
+
+// Memory is a Store and LockoutStore held in this process
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*failures
+	lastSweep time.Time
+
+	// now is the clock, replaced in tests
+	now func() time.Time
+}
+
+type bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+type failures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+	forgetAfter time.Duration
+}
+
+var (
+	_ Store        = (*Memory)(nil)
+	_ LockoutStore = (*Memory)(nil)
+)
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failures),
+		now:      time.Now,
+	}
+}
+
+// refill tops the bucket up for the time since it was last used
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Requests), b.tokens+elapsed*b.limit.rate())
+		b.updated = now
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if !limit.Enabled() {
+		return Decision{Allowed: true}, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok || b.limit != limit {
+		b = &bucket{limit: limit, tokens: float64(limit.Requests), updated: now}
+		m.buckets[key] = b
+	}
+	b.refill(now)
+
+	decision := Decision{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = seconds((1 - b.tokens) / limit.rate())
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = seconds((float64(limit.Requests) - b.tokens) / limit.rate())
+	return decision, nil
+}
+
+func (m *Memory) Locked(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f, ok := m.failures[key]; ok {
+		if left := f.lockedUntil.Sub(m.now()); left > 0 {
+			return left, nil
+		}
+	}
+	return 0, nil
+}
+
+func (m *Memory) Fail(ctx context.Context, key string, policy LockoutPolicy) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	f, ok := m.failures[key]
+	if !ok || f.expired(now) {
+		f = &failures{}
+		m.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	f.forgetAfter = policy.Max
+
+	lock := policy.Duration(f.count)
+	if lock > 0 {
+		f.lockedUntil = now.Add(lock)
+	}
+	return lock, nil
+}
+
+func (m *Memory) Succeed(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+// expired reports whether the failures are old enough to forget
+func (f *failures) expired(now time.Time) bool {
+	return now.After(f.lockedUntil) && now.Sub(f.last) > f.forgetAfter
+}
+
+// sweep drops state that no longer changes any decision, so the maps do not grow with every
+// client ever seen. The caller holds mu.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Requests) {
+			delete(m.buckets, key)
+		}
+	}
+	for key, f := range m.failures {
+		if f.expired(now) {
+			delete(m.failures, key)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a Memory whose time only moves when the test advances it
+func clock() (*Memory, func(time.Duration)) {
+	m := NewMemory()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTake(t *testing.T) {
+	ctx := context.Background()
+	m, advance := clock()
+	limit := Limit{Requests: 3, Per: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		d, err := m.Take(ctx, "ip:1", limit)
+		if err != nil || !d.Allowed || d.Remaining != i {
+			t.Fatalf("Take = %+v, %v; want allowed with %d remaining", d, err, i)
+		}
+	}
+
+	d, _ := m.Take(ctx, "ip:1", limit)
+	if d.Allowed || d.RetryAfter != time.Second || d.Reset != 3*time.Second {
+		t.Errorf("Take on an empty bucket = %+v, want refused, retry after 1s, full in 3s", d)
+	}
+	if d, _ := m.Take(ctx, "ip:2", limit); !d.Allowed {
+		t.Error("a different key shares the bucket")
+	}
+
+	advance(time.Second)
+	if d, _ := m.Take(ctx, "ip:1", limit); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("Take after a refill = %+v, want allowed with none remaining", d)
+	}
+
+	advance(time.Hour)
+	if d, _ := m.Take(ctx, "ip:1", limit); d.Remaining != 2 {
+		t.Errorf("Take after an hour = %+v, want a full bucket less one", d)
+	}
+
+	if d, _ := m.Take(ctx, "ip:1", Limit{}); !d.Allowed {
+		t.Error("the zero limit refused a request")
+	}
+}
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	m, advance := clock()
+	limit := Limit{Requests: 1, Per: time.Second}
+
+	m.Take(ctx, "idle", limit)
+	m.Fail(ctx, "login:old", LockoutPolicy{Threshold: 5, Base: time.Second, Max: time.Minute})
+
+	advance(2 * sweepInterval)
+	m.Take(ctx, "busy", limit)
+
+	if _, ok := m.buckets["idle"]; ok {
+		t.Error("a refilled bucket was kept")
+	}
+	if _, ok := m.failures["login:old"]; ok {
+		t.Error("failures older than the policy's maximum were kept")
+	}
+	if _, ok := m.buckets["busy"]; !ok {
+		t.Error("the bucket in use was dropped")
+	}
+}
+
+func TestLockoutPolicy(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 3, Base: time.Minute, Max: 10 * time.Minute}
+	want := map[int]time.Duration{1: 0, 2: 0, 3: time.Minute, 4: 2 * time.Minute, 5: 4 * time.Minute, 6: 8 * time.Minute, 7: 10 * time.Minute, 100: 10 * time.Minute}
+	for failures, d := range want {
+		if got := policy.Duration(failures); got != d {
+			t.Errorf("Duration(%d) = %s, want %s", failures, got, d)
+		}
+	}
+	if (LockoutPolicy{}).Duration(100) != 0 {
+		t.Error("the zero policy locked an account")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+	m, advance := clock()
+	policy := LockoutPolicy{Threshold: 2, Base: time.Minute, Max: time.Hour}
+
+	if lock, _ := m.Fail(ctx, "login:a", policy); lock != 0 {
+		t.Fatalf("first failure locked for %s", lock)
+	}
+	if lock, _ := m.Fail(ctx, "login:a", policy); lock != time.Minute {
+		t.Fatalf("second failure locked for %s, want 1m", lock)
+	}
+	if locked, _ := m.Locked(ctx, "login:a"); locked != time.Minute {
+		t.Errorf("Locked = %s, want 1m", locked)
+	}
+
+	advance(time.Minute)
+	if locked, _ := m.Locked(ctx, "login:a"); locked != 0 {
+		t.Errorf("Locked after the lock ran out = %s", locked)
+	}
+	if lock, _ := m.Fail(ctx, "login:a", policy); lock != 2*time.Minute {
+		t.Errorf("third failure locked for %s, want the lock doubled to 2m", lock)
+	}
+
+	m.Succeed(ctx, "login:a")
+	if locked, _ := m.Locked(ctx, "login:a"); locked != 0 {
+		t.Errorf("Locked after success = %s", locked)
+	}
+	if lock, _ := m.Fail(ctx, "login:a", policy); lock != 0 {
+		t.Errorf("failure after success locked for %s, want the count restarted", lock)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := map[string]Limit{
+		"10/1m":  {Requests: 10, Per: time.Minute},
+		" 5/1s ": {Requests: 5, Per: time.Second},
+		"off":    {},
+		"0":      {},
+	}
+	for s, want := range tests {
+		if got, err := ParseLimit(s); err != nil || got != want {
+			t.Errorf("ParseLimit(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "10", "10/", "x/1m", "-1/1m", "10/0s", "10/soon"} {
+		if _, err := ParseLimit(s); err == nil {
+			t.Errorf("ParseLimit(%q) succeeded", s)
+		}
+	}
+}
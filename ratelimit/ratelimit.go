@@ -0,0 +1,109 @@
+// Package ratelimit provides token bucket rate limits and exponential lockouts after failed logins.
+// State lives behind the Store and LockoutStore interfaces: Memory keeps it in the process, which
+// is enough for a single instance; a store shared between replicas, such as Redis, can implement
+// the same interfaces so every instance enforces one limit.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests requests per Per, in bursts of up to Requests. The zero Limit allows
+// everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// rate is how many tokens are added to a bucket per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// ParseLimit reads a limit written as requests/period, such as "10/1m"; "off" or "0" disables it
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+
+	requests, per, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(requests)
+	if !ok || err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("%q is not a limit like 10/1m", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("%q is not a limit like 10/1m", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed bool
+	// Limit is the bucket's size and Remaining the whole tokens left in it
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next token, when the request was not allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets
+type Store interface {
+	// Take removes a token from the bucket named key, refilled according to limit
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// LockoutPolicy locks an account once Threshold logins in a row have failed. The first lock lasts
+// Base, and each further failure doubles it, up to Max. Failures are forgotten after Max without one.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+// Enabled reports whether the policy ever locks an account
+func (p LockoutPolicy) Enabled() bool {
+	return p.Threshold > 0 && p.Base > 0 && p.Max >= p.Base
+}
+
+// Duration is how long an account stays locked after its nth failure in a row
+func (p LockoutPolicy) Duration(failures int) time.Duration {
+	if !p.Enabled() || failures < p.Threshold {
+		return 0
+	}
+	d := p.Base
+	for i := p.Threshold; i < failures && d < p.Max; i++ {
+		d *= 2
+	}
+	return min(d, p.Max)
+}
+
+// LockoutStore counts failed logins per account
+type LockoutStore interface {
+	// Locked returns how much longer key is locked out, or zero
+	Locked(ctx context.Context, key string) (time.Duration, error)
+	// Fail records a failed login for key and returns how long it is now locked out, or zero
+	Fail(ctx context.Context, key string, policy LockoutPolicy) (time.Duration, error)
+	// Succeed clears the failures recorded for key
+	Succeed(ctx context.Context, key string) error
+}
@@ -0,0 +1,177 @@
+// Package tlsconfig builds the server's TLS configuration: a certificate that is reloaded from disk
+// when it is renewed, and optional verification of client certificates for mutual TLS.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Client certificate modes
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// Options locates the server's certificate and the CA its clients' certificates are checked against
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile holds the PEM certificates of the CAs that issue client certificates
+	ClientCAFile string
+	// ClientAuth is none, optional (verify a certificate if the client sends one) or require
+	ClientAuth string
+}
+
+// Enabled reports whether the server should serve TLS
+func (o Options) Enabled() bool {
+	return o.CertFile != "" || o.KeyFile != ""
+}
+
+// Validate checks that the options are complete and consistent
+func (o Options) Validate() error {
+	if !o.Enabled() {
+		if o.ClientCAFile != "" || (o.ClientAuth != "" && o.ClientAuth != ClientAuthNone) {
+			return errors.New("client certificates need TLS; set the certificate and key files")
+		}
+		return nil
+	}
+	if o.CertFile == "" || o.KeyFile == "" {
+		return errors.New("both the certificate and key files are needed for TLS")
+	}
+	switch o.ClientAuth {
+	case "", ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if o.ClientCAFile == "" {
+			return fmt.Errorf("client auth %q needs a client CA file", o.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("unknown client auth %q; use none, optional or require", o.ClientAuth)
+	}
+	return nil
+}
+
+// ServerConfig returns the TLS configuration for the server, taking its certificate from reloader
+func ServerConfig(opts Options, reloader *Reloader) (*tls.Config, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch opts.ClientAuth {
+	case ClientAuthOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(opts.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	config.ClientCAs = x509.NewCertPool()
+	if !config.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", opts.ClientCAFile)
+	}
+	return config, nil
+}
+
+// Reloader serves a certificate and key from disk and loads them again when either file changes,
+// so a renewed certificate is picked up without a restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+// NewReloader loads the certificate and key
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate is the tls.Config hook that hands out the current certificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads the files again if either has been modified since the last load and reports
+// whether it did. On error the previous certificate stays in use.
+func (r *Reloader) Reload() (bool, error) {
+	modTimes, err := r.stat()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := r.cert != nil && modTimes == r.modTimes
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return true, nil
+}
+
+func (r *Reloader) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// Watch checks the files for changes every interval until ctx is cancelled
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				slog.Error("reloading TLS certificate; keeping the current one", "cert", r.certFile, "error", err)
+			} else if reloaded {
+				slog.Info("reloaded TLS certificate", "cert", r.certFile)
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package tlsconfig_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cameo1221/Go-Asset/tlsconfig"
+)
+
+// issued is a certificate with its key, signed by a test CA or by itself
+type issued struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+var serial int64
+
+func issue(t *testing.T, parent *issued, cn string, usage x509.ExtKeyUsage) *issued {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.ExtKeyUsage = []x509.ExtKeyUsage{usage}
+		template.DNSNames = []string{cn}
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &issued{cert: cert, key: key}
+}
+
+// write stores the certificate and key as PEM files and returns their paths
+func (i *issued) write(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(i.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writePEM(t, certFile, "CERTIFICATE", i.cert.Raw)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touch moves the modification times forward so a rewrite within the same clock tick is noticed
+func touch(t *testing.T, names ...string) {
+	t.Helper()
+	later := time.Now().Add(time.Minute)
+	for _, name := range names {
+		if err := os.Chtimes(name, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func servedSerial(t *testing.T, r *tlsconfig.Reloader) int64 {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber.Int64()
+}
+
+func TestReloader(t *testing.T) {
+	ca := issue(t, nil, "test CA", 0)
+	first := issue(t, ca, "localhost", x509.ExtKeyUsageServerAuth)
+	dir := t.TempDir()
+	certFile, keyFile := first.write(t, dir)
+
+	reloader, err := tlsconfig.NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	if reloaded, err := reloader.Reload(); reloaded || err != nil {
+		t.Errorf("Reload of unchanged files = %v, %v; want nothing done", reloaded, err)
+	}
+
+	renewed := issue(t, ca, "localhost", x509.ExtKeyUsageServerAuth)
+	renewed.write(t, dir)
+	touch(t, certFile, keyFile)
+	if reloaded, err := reloader.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload of renewed files = %v, %v; want the certificate reloaded", reloaded, err)
+	}
+	if got, want := servedSerial(t, reloader), renewed.cert.SerialNumber.Int64(); got != want {
+		t.Errorf("serving certificate %d, want the renewed %d", got, want)
+	}
+
+	// A half-written renewal is refused and the current certificate kept
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, keyFile)
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("Reload of a broken key succeeded")
+	}
+	if got, want := servedSerial(t, reloader), renewed.cert.SerialNumber.Int64(); got != want {
+		t.Errorf("serving certificate %d after a failed reload, want %d kept", got, want)
+	}
+}
+
+func TestServerConfigClientAuth(t *testing.T) {
+	ca := issue(t, nil, "test CA", 0)
+	server := issue(t, ca, "localhost", x509.ExtKeyUsageServerAuth)
+	client := issue(t, ca, "mdm.example.com", x509.ExtKeyUsageClientAuth)
+	rogueCA := issue(t, nil, "rogue CA", 0)
+	rogue := issue(t, rogueCA, "mdm.example.com", x509.ExtKeyUsageClientAuth)
+
+	dir := t.TempDir()
+	certFile, keyFile := server.write(t, dir)
+	caFile := filepath.Join(dir, "clients.pem")
+	writePEM(t, caFile, "CERTIFICATE", ca.cert.Raw)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	clientFor := func(cert *issued) *http.Client {
+		config := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if cert != nil {
+			// Sent even when the server asks for another CA, so the server has to reject it
+			config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return &tls.Certificate{Certificate: [][]byte{cert.cert.Raw}, PrivateKey: cert.key}, nil
+			}
+		}
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	}
+
+	for _, tc := range []struct {
+		clientAuth string
+		client     *issued
+		wantCN     string
+		wantErr    bool
+	}{
+		{tlsconfig.ClientAuthOptional, nil, "", false},
+		{tlsconfig.ClientAuthOptional, client, "mdm.example.com", false},
+		{tlsconfig.ClientAuthOptional, rogue, "", true},
+		{tlsconfig.ClientAuthRequire, nil, "", true},
+		{tlsconfig.ClientAuthRequire, client, "mdm.example.com", false},
+	} {
+		opts := tlsconfig.Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: tc.clientAuth}
+		reloader, err := tlsconfig.NewReloader(certFile, keyFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := tlsconfig.ServerConfig(opts, reloader)
+		if err != nil {
+			t.Fatalf("ServerConfig(%s): %v", tc.clientAuth, err)
+		}
+
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(r.TLS.VerifiedChains) > 0 {
+				io.WriteString(w, r.TLS.VerifiedChains[0][0].Subject.CommonName)
+			}
+		}))
+		srv.TLS = config
+		srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+		srv.StartTLS()
+
+		resp, err := clientFor(tc.client).Get(srv.URL)
+		if tc.wantErr {
+			if err == nil {
+				resp.Body.Close()
+				t.Errorf("%s with client %v: request succeeded, want the handshake refused", tc.clientAuth, tc.client != nil)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v", tc.clientAuth, err)
+		} else {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != tc.wantCN {
+				t.Errorf("%s: verified client %q, want %q", tc.clientAuth, body, tc.wantCN)
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	for _, tc := range []struct {
+		opts  tlsconfig.Options
+		valid bool
+	}{
+		{tlsconfig.Options{}, true},
+		{tlsconfig.Options{CertFile: "tls.crt", KeyFile: "tls.key"}, true},
+		{tlsconfig.Options{CertFile: "tls.crt"}, false},
+		{tlsconfig.Options{ClientAuth: tlsconfig.ClientAuthRequire, ClientCAFile: "ca.pem"}, false},
+		{tlsconfig.Options{CertFile: "tls.crt", KeyFile: "tls.key", ClientAuth: tlsconfig.ClientAuthRequire}, false},
+		{tlsconfig.Options{CertFile: "tls.crt", KeyFile: "tls.key", ClientAuth: "sometimes", ClientCAFile: "ca.pem"}, false},
+	} {
+		if err := tc.opts.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%+v: Validate = %v, want valid %v", tc.opts, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package tracing
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.20.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer the driver wrapper reports as
+const instrumentationName = "github.com/cameo1221/Go-Asset/tracing"
+
+// rowsAttribute is the number of rows a statement returned or changed
+var rowsAttribute = attribute.Key("db.rows")
+
+// WrapDriver returns a driver that opens a span for every statement run through QueryContext or
+// ExecContext. Spans are named after the statement's operation and table, such as "SELECT asset",
+// and carry the SQL text and the number of rows returned or affected. A query's span stays open
+// until its rows are closed, so cursor reads are included. The global tracer provider is looked up
+// per statement, so Setup may run after the driver is registered.
+func WrapDriver(d driver.Driver) driver.Driver {
+	return &tracedDriver{Driver: d}
+}
+
+type tracedDriver struct {
+	driver.Driver
+}
+
+func (d *tracedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn}, nil
+}
+
+var (
+	statementTable = regexp.MustCompile(`(?is)^\s*(?:select\b.*?\bfrom|insert\s+into|update|delete\s+from)\s+([a-z_][a-z0-9_.]*)`)
+	statementVerb  = regexp.MustCompile(`^\s*([A-Za-z]+)`)
+)
+
+// StatementName summarises a statement as "OPERATION table" for use as a span name
+func StatementName(query string) string {
+	verb := statementVerb.FindStringSubmatch(query)
+	if verb == nil {
+		return "SQL"
+	}
+	name := strings.ToUpper(verb[1])
+	if table := statementTable.FindStringSubmatch(query); table != nil {
+		name += " " + strings.ToLower(table[1])
+	}
+	return name
+}
+
+func startStatement(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := StatementName(query)
+	operation, table, _ := strings.Cut(name, " ")
+
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemPostgreSQL,
+		semconv.DBStatement(query),
+		semconv.DBOperation(operation),
+	}
+	if table != "" {
+		attrs = append(attrs, semconv.DBSQLTable(table))
+	}
+
+	return otel.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+func endWithError(span trace.Span, err error) {
+	if err != nil && err != driver.ErrSkip {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedConn passes every optional driver interface through to the wrapped connection
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startStatement(ctx, query)
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		endWithError(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := startStatement(ctx, query)
+	result, err := execer.ExecContext(ctx, query, args)
+	if err == nil {
+		if n, err := result.RowsAffected(); err == nil {
+			span.SetAttributes(rowsAttribute.Int64(n))
+		}
+	}
+	endWithError(span, err)
+	return result, err
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// tracedRows counts the rows read and ends the statement's span when they are closed
+type tracedRows struct {
+	driver.Rows
+	span  trace.Span
+	count int64
+	err   error
+}
+
+func (r *tracedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	switch {
+	case err == nil:
+		r.count++
+	case err != io.EOF:
+		r.err = err
+	}
+	return err
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	if err == nil {
+		err = r.err
+	}
+	r.span.SetAttributes(rowsAttribute.Int64(r.count))
+	endWithError(r.span, err)
+	return err
+}
@@ -0,0 +1,187 @@
+package tracing_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cameo1221/Go-Asset/tracing"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// record installs a tracer provider that keeps finished spans in memory
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestRouterSpans(t *testing.T) {
+	recorder := record(t)
+
+	router := mux.NewRouter()
+	router.Use(otelmux.Middleware(tracing.ServiceName))
+	router.HandleFunc("/assets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/assets/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "/assets/{id}" {
+		t.Errorf("span name = %q, want the route template", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("trace ID = %s, want the caller's %s", got, traceID)
+	}
+	if !span.Parent().IsRemote() {
+		t.Error("span parent is not the remote caller")
+	}
+
+	attrs := attributes(span)
+	if got := attrs["http.status_code"].AsInt64(); got != http.StatusNotFound {
+		t.Errorf("status attribute = %d, want %d", got, http.StatusNotFound)
+	}
+	if got := attrs["http.method"].AsString(); got != "GET" {
+		t.Errorf("method attribute = %q, want GET", got)
+	}
+}
+
+func TestStatementName(t *testing.T) {
+	tests := map[string]string{
+		`SELECT id, name FROM asset WHERE id = $1`:                  "SELECT asset",
+		"\n\t\tINSERT INTO employee_asset_mapping (id) VALUES ($1)": "INSERT employee_asset_mapping",
+		`UPDATE asset SET status = $1`:                              "UPDATE asset",
+		`DELETE FROM session WHERE expires_at < now()`:              "DELETE session",
+		`SELECT EXISTS (SELECT 1 FROM admin WHERE id = $1)`:         "SELECT admin",
+		`SELECT 1`: "SELECT",
+		`CREATE TABLE schema_migrations (version text PRIMARY KEY)`: "CREATE",
+		``: "SQL",
+	}
+	for query, want := range tests {
+		if got := tracing.StatementName(query); got != want {
+			t.Errorf("StatementName(%q) = %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestDriverSpans(t *testing.T) {
+	recorder := record(t)
+
+	sql.Register("fake+otel", tracing.WrapDriver(fakeDriver{}))
+	db, err := sql.Open("fake+otel", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+
+	rows, err := db.QueryContext(ctx, `SELECT id FROM asset WHERE status = $1`, "available")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 3 {
+		t.Fatalf("read %d rows, want 3", count)
+	}
+
+	if _, err := db.ExecContext(ctx, `UPDATE asset SET status = $1`, "retired"); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	for name, want := range map[string]int64{"SELECT asset": 3, "UPDATE asset": 7} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %q span", name)
+			continue
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request span", name)
+		}
+		attrs := attributes(span)
+		if got := attrs["db.rows"].AsInt64(); got != want {
+			t.Errorf("%s rows = %d, want %d", name, got, want)
+		}
+		if got := attrs["db.sql.table"].AsString(); got != "asset" {
+			t.Errorf("%s table = %q, want asset", name, got)
+		}
+		if attrs["db.statement"].AsString() == "" {
+			t.Errorf("%s has no statement", name)
+		}
+	}
+}
+
+// fakeDriver answers every query with three rows and every statement with seven affected rows
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &fakeRows{left: 3}, nil
+}
+
+func (fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(7), nil
+}
+
+type fakeRows struct{ left int }
+
+func (*fakeRows) Columns() []string { return []string{"id"} }
+func (*fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.left == 0 {
+		return io.EOF
+	}
+	r.left--
+	dest[0] = int64(r.left)
+	return nil
+}